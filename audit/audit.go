@@ -0,0 +1,222 @@
+//This package is charged with reporting on the health of the records held within an account
+package audit
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	cry "github.com/rigelrozanski/passwerk/crypto"
+	tre "github.com/rigelrozanski/passwerk/tree"
+)
+
+//passwords with an estimated entropy below this are reported as weak
+const WeakEntropyBits float64 = 50
+
+//list of SHA-1 hashes (or hash prefixes) of breached passwords
+type BreachedList struct {
+	prefixes map[string]bool
+	lengths  []int //distinct prefix lengths held within prefixes
+}
+
+type RecordReport struct {
+	CIdName     string
+	EntropyBits float64
+	Weak        bool
+	DuplicateOf []string //other identifiers which hold the same password
+	AgeDays     int      //-1 if the record doesn't hold a modified time
+	Stale       bool
+	Breached    bool
+}
+
+//report holding no plaintext passwords
+type Report struct {
+	Records []RecordReport
+	Issues  int
+}
+
+//load a breached password list from a file, each line holds an uppercase or lowercase
+//  hex SHA-1 hash or hash prefix optionally followed by ":count" (as per haveibeenpwned)
+func LoadBreachedList(filename string) (BreachedList, error) {
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return BreachedList{}, err
+	}
+	defer f.Close()
+
+	return ReadBreachedList(f)
+}
+
+func ReadBreachedList(r io.Reader) (BreachedList, error) {
+
+	bl := BreachedList{prefixes: make(map[string]bool)}
+	lengthSeen := make(map[int]bool)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		prefix := strings.ToUpper(strings.TrimSpace(strings.Split(scanner.Text(), ":")[0]))
+		if len(prefix) < 1 {
+			continue
+		}
+		bl.prefixes[prefix] = true
+		if !lengthSeen[len(prefix)] {
+			lengthSeen[len(prefix)] = true
+			bl.lengths = append(bl.lengths, len(prefix))
+		}
+	}
+
+	return bl, scanner.Err()
+}
+
+//determine if the SHA-1 hash of a password matches an entry of the breached list
+func (bl BreachedList) Contains(password string) bool {
+
+	if len(bl.prefixes) < 1 {
+		return false
+	}
+
+	sum := sha1.Sum([]byte(password))
+	hashHex := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	for _, length := range bl.lengths {
+		if length <= len(hashHex) && bl.prefixes[hashHex[:length]] {
+			return true
+		}
+	}
+	return false
+}
+
+//estimate the entropy of a password in bits from its length and the character classes used
+func EstimateEntropy(password string) float64 {
+
+	var lower, upper, digits, symbols bool
+	for _, c := range password {
+		switch {
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= '0' && c <= '9':
+			digits = true
+		default:
+			symbols = true
+		}
+	}
+
+	pool := 0
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digits {
+		pool += 10
+	}
+	if symbols {
+		pool += 33
+	}
+
+	if pool == 0 {
+		return 0
+	}
+	return float64(len([]rune(password))) * math.Log2(float64(pool))
+}
+
+//decrypt all the records held by an account and report on their health.
+//  the reader variables are updated for each record read
+func GenerateHealthReport(
+	ptr *tre.PwkTreeReader,
+	urlUsername,
+	urlPassword string,
+	maxAgeDays int,
+	breached BreachedList,
+	now time.Time) (report Report, err error) {
+
+	usernameHashed := cry.GetHashedHexString(urlUsername)
+	hashInputCIdNameEncryption := tre.HashInputCIdNameEncryption(urlUsername, urlPassword)
+
+	ptr.SetVariables(usernameHashed, "", hashInputCIdNameEncryption, "")
+
+	var cIdNames []string
+	cIdNames, err = ptr.RetrieveCIdNames(false)
+	if err != nil {
+		return
+	}
+
+	//identifiers grouped by the hash of their password, so plaintext is never held as a key
+	passwordGroups := make(map[string][]string)
+	passwordHashes := make(map[string]string)
+
+	for _, cIdName := range cIdNames {
+		if len(cIdName) < 1 {
+			continue
+		}
+
+		ptr.SetVariables(
+			usernameHashed,
+			cIdName,
+			hashInputCIdNameEncryption,
+			tre.HashInputCPasswordEncryption(urlUsername, urlPassword, cIdName),
+		)
+
+		var fields map[string]string
+		fields, err = ptr.RetrieveCRecord()
+		if err != nil {
+			return
+		}
+
+		cPassword := fields[tre.FieldPassword]
+
+		recordReport := RecordReport{
+			CIdName:     cIdName,
+			EntropyBits: EstimateEntropy(cPassword),
+			Breached:    breached.Contains(cPassword),
+			AgeDays:     -1,
+		}
+		recordReport.Weak = recordReport.EntropyBits < WeakEntropyBits
+
+		if modified, parseErr := time.Parse(time.RFC3339, fields[tre.FieldModified]); parseErr == nil {
+			recordReport.AgeDays = int(now.Sub(modified).Hours() / 24)
+			recordReport.Stale = maxAgeDays > 0 && recordReport.AgeDays >= maxAgeDays
+		}
+
+		passwordHash := cry.GetHashedHexString(cPassword)
+		passwordHashes[cIdName] = passwordHash
+		passwordGroups[passwordHash] = append(passwordGroups[passwordHash], cIdName)
+
+		report.Records = append(report.Records, recordReport)
+	}
+
+	//determine duplicates and count the issues found
+	for i, recordReport := range report.Records {
+		for _, cIdName := range passwordGroups[passwordHashes[recordReport.CIdName]] {
+			if cIdName != recordReport.CIdName {
+				report.Records[i].DuplicateOf = append(report.Records[i].DuplicateOf, cIdName)
+			}
+		}
+		sort.Strings(report.Records[i].DuplicateOf)
+
+		if recordReport.Weak {
+			report.Issues++
+		}
+		if recordReport.Stale {
+			report.Issues++
+		}
+		if recordReport.Breached {
+			report.Issues++
+		}
+		if len(report.Records[i].DuplicateOf) > 0 {
+			report.Issues++
+		}
+	}
+
+	return
+}
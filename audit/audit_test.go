@@ -0,0 +1,114 @@
+//This package tests the audit package
+package audit
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	cry "github.com/rigelrozanski/passwerk/crypto"
+	tre "github.com/rigelrozanski/passwerk/tree"
+)
+
+func TestAudit(t *testing.T) {
+
+	//inititilize the in-memory trees for testing
+	ptw, ptr := tre.NewMemTreePair()
+
+	mUsr := "masterUsr"
+	mPwd := "masterPwd"
+	now := time.Now()
+
+	//register the account and write the records to be audited
+	usernameHashed := cry.GetHashedHexString(mUsr)
+	hashInputCIdNameEncryption := tre.HashInputCIdNameEncryption(mUsr, mPwd)
+	ptw.SetVariables(usernameHashed, "", "")
+	verifierEncrypted, err := cry.GetEncryptedBoundHexString(cry.LegacyCipherSuite, hashInputCIdNameEncryption, tre.VerifierCanary,
+		tre.AssociatedDataVerifier(usernameHashed))
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	err = ptw.NewAccount(verifierEncrypted)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	writeRecord := func(cIdName, cPassword string, modified time.Time) {
+		cIdNameEncrypted, err := cry.GetEncryptedBoundHexString(cry.LegacyCipherSuite, hashInputCIdNameEncryption, cIdName,
+			tre.AssociatedDataCIdName(usernameHashed))
+		if err != nil {
+			t.Errorf(err.Error())
+		}
+		ptw.SetVariables(
+			usernameHashed,
+			cry.GetHashedHexString(cIdName),
+			cIdNameEncrypted,
+		)
+		fields := map[string]string{
+			tre.FieldPassword: cPassword,
+			tre.FieldModified: modified.UTC().Format(time.RFC3339),
+		}
+		cRecordEncrypted, err := tre.GetEncryptedRecord(cry.LegacyCipherSuite, tre.HashInputCPasswordEncryption(mUsr, mPwd, cIdName),
+			usernameHashed, cry.GetHashedHexString(cIdName), fields)
+		if err != nil {
+			t.Errorf(err.Error())
+		}
+		err = ptw.NewRecord(cRecordEncrypted)
+		if err != nil {
+			t.Errorf(err.Error())
+		}
+	}
+
+	strongPassword := "x7#Kq9!mZ2@vL5$wR8^tN4"
+	writeRecord("breached", "hunter2", now)
+	writeRecord("reused1", strongPassword, now)
+	writeRecord("reused2", strongPassword, now.Add(-100*24*time.Hour))
+
+	//the breached list holds the full hash of hunter2 as well as an unrelated prefix
+	breached, err := ReadBreachedList(strings.NewReader(
+		"F3BBBD66A63D4BF1747940578EC3D0103530E21D:2413945\n0000A\n"))
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	report, err := GenerateHealthReport(&ptr, mUsr, mPwd, 90, breached, now)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	if len(report.Records) != 3 {
+		t.Errorf("unexpected number of records in the health report")
+		return
+	}
+
+	for _, recordReport := range report.Records {
+		switch recordReport.CIdName {
+		case "breached":
+			if !recordReport.Breached || !recordReport.Weak || recordReport.Stale {
+				t.Errorf("bad health report for a weak breached password")
+			}
+		case "reused1":
+			if recordReport.Breached || recordReport.Weak || recordReport.Stale ||
+				len(recordReport.DuplicateOf) != 1 || recordReport.DuplicateOf[0] != "reused2" {
+				t.Errorf("bad health report for a reused password")
+			}
+		case "reused2":
+			if !recordReport.Stale || recordReport.AgeDays != 100 || len(recordReport.DuplicateOf) != 1 {
+				t.Errorf("bad health report for a stale reused password")
+			}
+		default:
+			t.Errorf("unexpected record in health report " + recordReport.CIdName)
+		}
+	}
+
+	//breached + weak, reused1 duplicate, reused2 duplicate + stale
+	if report.Issues != 5 {
+		t.Errorf("unexpected number of issues in the health report")
+	}
+
+	//bad authentication should produce an error
+	_, err = GenerateHealthReport(&ptr, mUsr, "masterzzzzPi", 90, breached, now)
+	if err == nil {
+		t.Errorf("health report with a bad password does not produce an error")
+	}
+}
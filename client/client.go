@@ -0,0 +1,272 @@
+package client
+
+import (
+	"strings"
+	"time"
+
+	cry "github.com/rigelrozanski/passwerk/crypto"
+	tre "github.com/rigelrozanski/passwerk/tree"
+)
+
+//retries of requests which fail to reach the passwerk application
+const DefaultRetries int = 3
+const DefaultRetryDelay time.Duration = 500 * time.Millisecond
+
+//a decrypted record, the password of the identifier along with any additional fields
+type Record map[string]string
+
+func (r Record) Password() string {
+	return r[tre.FieldPassword]
+}
+
+//the time the record was written, zero when not recorded
+func (r Record) Modified() time.Time {
+	modified, _ := time.Parse(time.RFC3339, r[tre.FieldModified])
+	return modified
+}
+
+//typed client of an account, records are encrypted and decrypted locally and
+//  exchanged with the passwerk application over the transport
+type Client struct {
+	transport  Transport
+	account    *Account
+	Retries    int           //retries of each request which fails to reach the application
+	RetryDelay time.Duration //delay before the first retry, doubled after each retry
+}
+
+func newClient(transport Transport, username, password string) *Client {
+	return &Client{
+		transport:  transport,
+		account:    NewAccount(username, password),
+		Retries:    DefaultRetries,
+		RetryDelay: DefaultRetryDelay,
+	}
+}
+
+//login to an existing account, the cipher suite of the account is read to derive the
+//  signing key before the master password is authenticated
+func Login(transport Transport, username, password string) (c *Client, err error) {
+	return LoginTwoFactor(transport, username, password, "")
+}
+
+//login to an account enrolled in two-factor authentication with a current totp code, which
+//  opens a session of the UI used by further reads until it expires
+func LoginTwoFactor(transport Transport, username, password, totpCode string) (c *Client, err error) {
+
+	c = newClient(transport, username, password)
+	var suiteState tre.EndToEndState
+	err = c.retry(func() (err error) {
+		suiteState, err = c.transport.Read(c.account.SuiteRequest())
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = c.account.SetSuite(suiteState)
+	if err != nil {
+		return nil, err
+	}
+	if len(totpCode) > 0 {
+		c.account.SetTwoFactorCode(totpCode)
+	}
+
+	state, err := c.read("")
+	if err != nil {
+		return nil, err
+	}
+	err = c.account.Unlock(state)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+//register a new account under the cipher suite
+func Register(transport Transport, username, password string, suite cry.CipherSuite) (c *Client, err error) {
+
+	c = newClient(transport, username, password)
+	tx, err := c.account.RegisterTx(suite)
+	if err != nil {
+		return nil, err
+	}
+	err = c.broadcast(tx)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+//the identifiers of the records held by the account in sorted order
+func (c *Client) List() ([]string, error) {
+
+	state, err := c.read("")
+	if err != nil {
+		return nil, err
+	}
+	return c.account.CIdNames(state)
+}
+
+func (c *Client) Get(cIdName string) (Record, error) {
+
+	state, err := c.read(cIdName)
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := c.account.Record(cIdName, state)
+	if err != nil {
+		return nil, err
+	}
+	return toRecord(secrets), nil
+}
+
+//the previous values of the record, most recent first. Values are held from when
+//  the record is overwritten or deleted, the values of deleted records are cleared
+//  when the account cipher suite is changed or the account is recovered
+func (c *Client) History(cIdName string) (history []Record, err error) {
+
+	state, err := c.read(cIdName)
+	if err != nil {
+		return
+	}
+	previous, err := c.account.RecordHistory(cIdName, state)
+	if err != nil {
+		return
+	}
+	for _, secrets := range previous {
+		history = append(history, toRecord(secrets))
+	}
+	return
+}
+
+//write the record of the identifier, replacing any existing record
+func (c *Client) Put(cIdName string, record Record) error {
+
+	state, err := c.read("")
+	if err != nil {
+		return err
+	}
+	txs, err := c.account.WriteTxs(cIdName, record, state)
+	if err != nil {
+		return err
+	}
+	for _, tx := range txs {
+		err = c.broadcast(tx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) Delete(cIdName string) error {
+
+	state, err := c.read("")
+	if err != nil {
+		return err
+	}
+	tx, err := c.account.DeleteTx(cIdName, state)
+	if err != nil {
+		return err
+	}
+	return c.broadcast(tx)
+}
+
+//delete the account along with all of its records
+func (c *Client) DeleteAccount() error {
+	return c.broadcast(c.account.DeleteAccountTx())
+}
+
+//copy the record out of its secrets, wiping them
+func toRecord(secrets map[string]*cry.Secret) Record {
+
+	defer tre.WipeRecord(secrets)
+
+	record := make(Record)
+	for name, value := range secrets {
+		record[name] = string(value.Bytes())
+	}
+	return record
+}
+
+/////////////////////////////////////////////
+//   Retries
+////////////////////////////////////////////
+
+//perform the request until it succeeds, is rejected, or the retries are exhausted
+func (c *Client) retry(request func() error) (err error) {
+
+	delay := c.RetryDelay
+	for attempt := 0; ; attempt++ {
+		err = request()
+		if _, rejected := err.(*RejectedError); err == nil || rejected || attempt >= c.Retries {
+			return
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+//each attempt signs a new read request to remain within the request window
+func (c *Client) read(cIdName string) (state tre.EndToEndState, err error) {
+	err = c.retry(func() (err error) {
+		state, err = c.transport.Read(c.account.ReadRequest(cIdName))
+		return
+	})
+	if err == nil {
+		c.account.SetSession(state)
+	}
+	return
+}
+
+//a broadcast which fails to reach the application may still have been committed, so before
+//  each retry the state is read to check whether the tx was applied, rather than risk
+//  applying it twice
+func (c *Client) broadcast(tx string) error {
+
+	attempted := false
+	return c.retry(func() error {
+		if attempted && c.applied(tx) {
+			return nil
+		}
+		attempted = true
+		return c.transport.BroadcastTx(tx)
+	})
+}
+
+//whether the effect of the tx is held within the state of the application
+func (c *Client) applied(tx string) bool {
+
+	parts := strings.Split(tx, "/")
+	if len(parts) < 3 {
+		return false
+	}
+
+	switch parts[1] {
+	case "registering":
+		_, err := c.transport.Read(c.account.ReadRequest(""))
+		return err == nil
+
+	case "deletingAccount":
+		_, err := c.transport.Read(c.account.ReadRequest(""))
+		return isRejected(err, "badAuthentication")
+
+	//the encrypted identifier is removed from the cIdList
+	case "deleting":
+		state, err := c.transport.Read(c.account.ReadRequest(""))
+		return err == nil && len(parts) > 4 && !strings.Contains(state.CIdList, "/"+parts[4]+"/")
+
+	//the record holds the encrypted record of the tx
+	case "writing":
+		if len(parts) < 6 {
+			return false
+		}
+		state, err := c.transport.Read(c.account.readRequestHashed(parts[3]))
+		return err == nil && state.CRecordEncrypted == parts[5]
+	}
+	return false
+}
+
+func isRejected(err error, reason string) bool {
+	rejected, ok := err.(*RejectedError)
+	return ok && rejected.Reason == reason
+}
@@ -0,0 +1,352 @@
+//Tests the end-to-end client
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cry "github.com/rigelrozanski/passwerk/crypto"
+	"github.com/rigelrozanski/passwerk/passwerktest"
+	tre "github.com/rigelrozanski/passwerk/tree"
+	"github.com/rigelrozanski/passwerk/ui"
+)
+
+func TestEndToEnd(t *testing.T) {
+
+	//inititilize the in-process application for testing
+	h := passwerktest.New(passwerktest.Config{})
+	defer h.Close()
+	ptr := h.PTR
+
+	broadcast := func(txs ...string) {
+		for _, tx := range txs {
+			err := h.Broadcast(tx)
+			if err != nil {
+				t.Errorf("broadcasting %s: %v", strings.Split(tx, "/")[1], err)
+			}
+		}
+	}
+
+	//the state as output by the server to a read request
+	readState := func(a *Account, cIdName string) tre.EndToEndState {
+		state, err := ptr.ReadEndToEnd(a.ReadRequest(cIdName), time.Now(), cry.LegacyCipherSuite)
+		if err != nil && err.Error() != "invalidCIdName" {
+			t.Errorf("reading the state: %v", err)
+		}
+		return state
+	}
+
+	readRecord := func(a *Account, cIdName string) map[string]string {
+		secrets, err := a.Record(cIdName, readState(a, cIdName))
+		if err != nil {
+			t.Errorf("reading the record %s: %v", cIdName, err)
+			return nil
+		}
+		defer tre.WipeRecord(secrets)
+
+		fields := make(map[string]string)
+		for name, value := range secrets {
+			fields[name] = string(value.Bytes())
+		}
+		return fields
+	}
+
+	//register an account encrypted by the client
+	a := NewAccount("e2eUsr", "e2ePwd")
+	tx, err := a.RegisterTx(cry.Argon2idCipherSuite)
+	if err != nil {
+		t.Errorf("building the registration: %v", err)
+	}
+	broadcast(tx)
+
+	//the read request is signed by the account
+	request := a.ReadRequest("")
+	parts := strings.Split(request, "/")
+	signingKey, err := ptr.RetrieveSigningKey(a.UsernameHashed())
+	if err != nil || len(parts) != 7 || parts[4] != "-" || parts[5] != a.UsernameHashed() ||
+		!cry.VerifySignatureHexString(signingKey, request[:strings.LastIndex(request, "/")], parts[6]) {
+		t.Errorf("bad read request: %s", request)
+	}
+
+	//the suite is read without a signature, accounts which don't exist read as the default suite
+	b := NewAccount("e2eUsr", "e2ePwd")
+	suiteState, err := ptr.ReadEndToEnd(b.SuiteRequest(), time.Now(), cry.LegacyCipherSuite)
+	if err != nil || suiteState.Suite != cry.Argon2idCipherSuite.ID() || len(suiteState.VerifierEncrypted) > 0 {
+		t.Errorf("bad suite state: %v %v", suiteState, err)
+	}
+	if err = b.SetSuite(suiteState); err != nil {
+		t.Errorf("setting the suite: %v", err)
+	}
+	unknownState, err := ptr.ReadEndToEnd(NewAccount("unknownUsr", "-").SuiteRequest(), time.Now(),
+		cry.Argon2idCipherSuite)
+	if err != nil || unknownState.Suite != cry.Argon2idCipherSuite.ID() {
+		t.Errorf("bad suite state of an account which doesn't exist: %v %v", unknownState, err)
+	}
+
+	//unlock the account with the master password, and not with a mistyped one
+	if _, err = b.Record("e2eID", readState(b, "e2eID")); err == nil || err.Error() != "accountLocked" {
+		t.Errorf("read a record of a locked account: %v", err)
+	}
+	if err = b.Unlock(readState(b, "")); err != nil {
+		t.Errorf("unlocking the account: %v", err)
+	}
+	mistyped := NewAccount("e2eUsr", "e2ePwdd")
+	mistyped.SetSuite(suiteState)
+	_, err = ptr.ReadEndToEnd(mistyped.ReadRequest(""), time.Now(), cry.LegacyCipherSuite)
+	if err == nil || err.Error() != "badAuthentication" {
+		t.Errorf("read the account with a mistyped password: %v", err)
+	}
+	if err = mistyped.Unlock(readState(b, "")); err == nil || err.Error() != "badAuthentication" {
+		t.Errorf("unlocked the account with a mistyped password: %v", err)
+	}
+
+	//write, overwrite, list and read records
+	txs, err := b.WriteTxs("e2eID", map[string]string{tre.FieldPassword: "e2ePass", tre.FieldUsername: "bob"},
+		readState(b, ""))
+	if err != nil || len(txs) != 1 {
+		t.Errorf("building the write: %v", err)
+	}
+	broadcast(txs...)
+
+	fields := readRecord(b, "e2eID")
+	if fields[tre.FieldPassword] != "e2ePass" || fields[tre.FieldUsername] != "bob" || len(fields[tre.FieldModified]) < 1 {
+		t.Errorf("bad record fields: %v", fields)
+	}
+
+	txs, err = a.WriteTxs("e2eID", map[string]string{tre.FieldPassword: "e2ePass2"}, readState(a, ""))
+	if err != nil || len(txs) != 2 {
+		t.Errorf("building the overwrite: %v", err)
+	}
+	broadcast(txs...)
+	txs, _ = a.WriteTxs("e2eID2", map[string]string{tre.FieldPassword: "e2ePass3"}, readState(a, ""))
+	broadcast(txs...)
+
+	cIdNames, err := b.CIdNames(readState(b, ""))
+	if err != nil || strings.Join(cIdNames, ",") != "e2eID,e2eID2" {
+		t.Errorf("bad identifiers: %v %v", cIdNames, err)
+	}
+	if fields = readRecord(b, "e2eID"); fields[tre.FieldPassword] != "e2ePass2" {
+		t.Errorf("bad overwritten record fields: %v", fields)
+	}
+
+	//delete a record, then the account
+	tx, err = b.DeleteTx("e2eID", readState(b, ""))
+	if err != nil {
+		t.Errorf("building the deletion: %v", err)
+	}
+	broadcast(tx)
+	if _, err = b.DeleteTx("e2eID", readState(b, "")); err == nil || err.Error() != "invalidCIdName" {
+		t.Errorf("deleted a record which doesn't exist: %v", err)
+	}
+	if cIdNames, _ = b.CIdNames(readState(b, "")); strings.Join(cIdNames, ",") != "e2eID2" {
+		t.Errorf("bad identifiers after deletion: %v", cIdNames)
+	}
+
+	broadcast(b.DeleteAccountTx())
+	if _, err = ptr.RetrieveCipherSuite(b.UsernameHashed()); err == nil {
+		t.Errorf("account exists after deletion")
+	}
+}
+
+//in-process transport of the harness, broadcasts are lost after being committed while
+//  the transport is set to fail
+type memTransport struct {
+	h     *passwerktest.Harness
+	fails int //number of the following requests to fail
+}
+
+func (m *memTransport) BroadcastTx(tx string) error {
+	err := m.h.Broadcast(tx)
+	if err != nil {
+		return &RejectedError{err.Error()}
+	}
+	if m.fails > 0 {
+		m.fails--
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func (m *memTransport) Read(readRequest string) (tre.EndToEndState, error) {
+	if m.fails > 0 {
+		m.fails--
+		return tre.EndToEndState{}, errors.New("connection reset")
+	}
+	state, err := m.h.PTR.ReadEndToEnd(readRequest, time.Now(), cry.LegacyCipherSuite)
+	if err != nil {
+		return state, &RejectedError{err.Error()}
+	}
+	return state, nil
+}
+
+func TestClient(t *testing.T) {
+
+	//inititilize the in-process application for testing
+	h := passwerktest.New(passwerktest.Config{})
+	defer h.Close()
+	transport := &memTransport{h: h}
+
+	c, err := Register(transport, "clientUsr", "clientPwd", cry.Argon2idCipherSuite)
+	if err != nil {
+		t.Fatalf("registering: %v", err)
+	}
+	c.RetryDelay = time.Millisecond
+	if _, err = Login(transport, "clientUsr", "clientPwdd"); !isRejected(err, "badAuthentication") {
+		t.Errorf("logged in with a mistyped password: %v", err)
+	}
+	if c, err = Login(transport, "clientUsr", "clientPwd"); err != nil {
+		t.Fatalf("logging in: %v", err)
+	}
+	c.RetryDelay = time.Millisecond
+
+	//write and overwrite a record, the previous value is held within its history
+	if err = c.Put("clientID", Record{tre.FieldPassword: "clientPass", tre.FieldUsername: "bob"}); err != nil {
+		t.Errorf("writing: %v", err)
+	}
+	if err = c.Put("clientID", Record{tre.FieldPassword: "clientPass2"}); err != nil {
+		t.Errorf("overwriting: %v", err)
+	}
+	record, err := c.Get("clientID")
+	if err != nil || record.Password() != "clientPass2" || len(record[tre.FieldUsername]) > 0 ||
+		record.Modified().IsZero() {
+		t.Errorf("bad record: %v %v", record, err)
+	}
+	history, err := c.History("clientID")
+	if err != nil || len(history) != 1 || history[0].Password() != "clientPass" || history[0][tre.FieldUsername] != "bob" {
+		t.Errorf("bad history: %v %v", history, err)
+	}
+
+	//a lost broadcast response is retried without applying the tx twice
+	transport.fails = 1
+	if err = c.Put("clientID2", Record{tre.FieldPassword: "clientPass3"}); err != nil {
+		t.Errorf("writing with a lost response: %v", err)
+	}
+	cIdNames, err := c.List()
+	if err != nil || strings.Join(cIdNames, ",") != "clientID,clientID2" {
+		t.Errorf("bad identifiers: %v %v", cIdNames, err)
+	}
+
+	//retries are exhausted, while rejections are not retried
+	transport.fails = c.Retries + 1
+	if _, err = c.List(); err == nil || err.Error() != "connection reset" {
+		t.Errorf("read with exhausted retries: %v", err)
+	}
+	transport.fails = 0
+	if _, err = c.Get("clientID3"); !isRejected(err, "invalidCIdName") {
+		t.Errorf("read a record which doesn't exist: %v", err)
+	}
+
+	//a deleted record is held only within its history
+	if err = c.Delete("clientID"); err != nil {
+		t.Errorf("deleting: %v", err)
+	}
+	if _, err = c.Get("clientID"); err == nil || err.Error() != "invalidCIdName" {
+		t.Errorf("read a deleted record: %v", err)
+	}
+	if history, err = c.History("clientID"); err != nil || len(history) != 2 || history[0].Password() != "clientPass2" {
+		t.Errorf("bad history of a deleted record: %v %v", history, err)
+	}
+
+	transport.fails = 1
+	if err = c.DeleteAccount(); err != nil {
+		t.Errorf("deleting the account with a lost response: %v", err)
+	}
+	if h.AccountExists("clientUsr") {
+		t.Errorf("account exists after deletion")
+	}
+
+	//the HTTP transport served by the UI of an application in end-to-end mode
+	e2e := passwerktest.New(passwerktest.Config{EndToEnd: true})
+	defer e2e.Close()
+	server := httptest.NewServer(e2e.Handler)
+	defer server.Close()
+
+	c, err = Register(NewHTTPTransport(server.URL, nil), "httpUsr", "httpPwd", cry.LegacyCipherSuite)
+	if err != nil {
+		t.Fatalf("registering over HTTP: %v", err)
+	}
+	if err = c.Put("httpID", Record{tre.FieldPassword: "httpPass"}); err != nil {
+		t.Errorf("writing over HTTP: %v", err)
+	}
+	if record, err = c.Get("httpID"); err != nil || record.Password() != "httpPass" {
+		t.Errorf("bad record over HTTP: %v %v", record, err)
+	}
+	if err = c.Delete("httpID2"); err == nil || err.Error() != "invalidCIdName" {
+		t.Errorf("deleted a record which doesn't exist over HTTP: %v", err)
+	}
+	if _, err = Login(NewHTTPTransport(server.URL, nil), "httpUsr", "httpPwdd"); !isRejected(err, "badAuthentication") {
+		t.Errorf("logged in over HTTP with a mistyped password: %v", err)
+	}
+}
+
+func TestTransports(t *testing.T) {
+
+	state := tre.EndToEndState{Suite: cry.LegacyCipherSuite.ID(), CIdList: "/abc/"}
+
+	//UI responses as per the end-to-end handler
+	uiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, ui.EndToEndTxPath("bad")):
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, tre.EncodeEndToEndError(errors.New("badAuthentication")))
+		case strings.HasPrefix(r.URL.Path, ui.EndToEndTxPath("rejected")):
+			fmt.Fprint(w, ui.EndToEndResultPrefix+`{"jsonrpc":"2.0","id":"","result":[6,{"code":3,"data":"","log":"invalidCIdName"}],"error":""}`+"\n")
+		case strings.HasPrefix(r.URL.Path, ui.EndToEndTxPath("good")):
+			fmt.Fprint(w, ui.EndToEndResultPrefix+`{"jsonrpc":"2.0","id":"","result":[6,{"code":0,"data":"","log":""}],"error":""}`+"\n")
+		case strings.HasPrefix(r.URL.Path, ui.EndToEndReadPath("read")):
+			fmt.Fprint(w, state.Encode())
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer uiServer.Close()
+
+	h := NewHTTPTransport(uiServer.URL, nil)
+	if err := h.BroadcastTx("good"); err != nil {
+		t.Errorf("http broadcast: %v", err)
+	}
+	if err := h.BroadcastTx("rejected"); !isRejected(err, "invalidCIdName") {
+		t.Errorf("http broadcast rejected by tendermint: %v", err)
+	}
+	if err := h.BroadcastTx("bad"); !isRejected(err, "badAuthentication") {
+		t.Errorf("http broadcast rejected by the UI: %v", err)
+	}
+	if err := h.BroadcastTx("down"); err == nil || isRejected(err, "") {
+		t.Errorf("http broadcast to a failing server: %v", err)
+	}
+	if read, err := h.Read("read"); err != nil || read.CIdList != state.CIdList {
+		t.Errorf("http read: %v %v", read, err)
+	}
+
+	//tendermint RPC responses, data is hex encoded
+	rpcServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/broadcast_tx_commit":
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":"","result":[6,{"code":0,"data":"","log":""}],"error":""}`)
+		case "/tmsp_query":
+			if r.URL.Query().Get("query") != `"`+fmt.Sprintf("%x", "read")+`"` {
+				fmt.Fprint(w, `{"jsonrpc":"2.0","id":"","result":[6,{"code":3,"data":"","log":"staleRequest"}],"error":""}`)
+				return
+			}
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":"","result":[6,{"code":0,"data":"%X","log":""}],"error":""}`,
+				state.Encode())
+		}
+	}))
+	defer rpcServer.Close()
+
+	rpc := NewRPCTransport(rpcServer.URL, nil)
+	if err := rpc.BroadcastTx("good"); err != nil {
+		t.Errorf("rpc broadcast: %v", err)
+	}
+	if read, err := rpc.Read("read"); err != nil || read.CIdList != state.CIdList || read.Suite != state.Suite {
+		t.Errorf("rpc read: %v %v", read, err)
+	}
+	if _, err := rpc.Read("stale"); !isRejected(err, "staleRequest") {
+		t.Errorf("rpc read rejected: %v", err)
+	}
+}
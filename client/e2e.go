@@ -0,0 +1,350 @@
+//This package is charged with end-to-end clients of passwerk, the master username/password
+//  and record contents are only ever held by the client. Keys are derived and records are
+//  encrypted locally, while the server is only provided txs and signed read requests
+package client
+
+import (
+	"errors"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	cry "github.com/rigelrozanski/passwerk/crypto"
+	tre "github.com/rigelrozanski/passwerk/tree"
+)
+
+//placeholder of values which are not provided
+const emptyPart string = "-"
+
+//an account of an end-to-end client, requests are only signed once the cipher suite of the
+//  account is known, and records are only read once the account is unlocked with the state
+//  read from the server, or by registering
+type Account struct {
+	username       string
+	password       string
+	usernameHashed string
+	suite          cry.CipherSuite //nil until the suite is read
+	keyPassword    string          //master password stretched by the suite
+	twoFactor      string          //second factor of read requests, a totp code or a session token
+	unlocked       bool
+}
+
+func NewAccount(username, password string) *Account {
+	return &Account{
+		username:       username,
+		password:       password,
+		usernameHashed: cry.GetHashedHexString(username),
+	}
+}
+
+func (a *Account) UsernameHashed() string {
+	return a.usernameHashed
+}
+
+//set the cipher suite of the account and stretch the master password
+func (a *Account) setSuite(suite cry.CipherSuite) {
+	a.suite = suite
+	a.keyPassword = suite.KDF(a.password, a.usernameHashed)
+}
+
+func (a *Account) hashInputCIdNameEncryption() string {
+	return tre.HashInputCIdNameEncryption(a.username, a.keyPassword)
+}
+
+func (a *Account) hashInputCPasswordEncryption(cIdName string) string {
+	return tre.HashInputCPasswordEncryption(a.username, a.keyPassword, cIdName)
+}
+
+//append the hashed username of the account to the tx or request, followed by the
+//  signature of both. The signing key is derived from the stretched master password
+func (a *Account) sign(tx string) string {
+
+	message := path.Join(tx, a.usernameHashed)
+	return path.Join(message, cry.GetSignatureHexString(tre.HashInputSigningKey(a.username, a.keyPassword), message))
+}
+
+/////////////////////////////////////////////
+//   Reading
+////////////////////////////////////////////
+
+//the unsigned request to read the cipher suite of the account
+func (a *Account) SuiteRequest() string {
+	return path.Join("readingSuite", a.usernameHashed)
+}
+
+//set the cipher suite of the account as read by the suite request, after which
+//  requests are signed. The account remains locked
+func (a *Account) SetSuite(state tre.EndToEndState) error {
+
+	suite, err := cry.GetCipherSuite(state.Suite)
+	if err != nil {
+		return err
+	}
+	a.setSuite(suite)
+	return nil
+}
+
+//the signed request to read the ciphertexts of the account, along with the
+//  record of the identifier if provided. The suite of the account must be set
+func (a *Account) ReadRequest(cIdName string) string {
+
+	cIdNameHashed := emptyPart
+	if len(cIdName) > 0 {
+		cIdNameHashed = cry.GetHashedHexString(cIdName)
+	}
+	return a.readRequestHashed(cIdNameHashed)
+}
+
+func (a *Account) readRequestHashed(cIdNameHashed string) string {
+
+	twoFactor := emptyPart
+	if len(a.twoFactor) > 0 {
+		twoFactor = a.twoFactor
+	}
+	return a.sign(path.Join(
+		time.Now().UTC().Format(tre.EndToEndTimeFormat),
+		"reading",
+		a.usernameHashed,
+		cIdNameHashed,
+		twoFactor))
+}
+
+//provide the totp code of an account enrolled in two-factor authentication with the next
+//  read request, along with the two-factor key which decrypts the totp secret. The suite
+//  of the account must be set
+func (a *Account) SetTwoFactorCode(totpCode string) {
+	a.twoFactor = tre.EncodeEndToEndTwoFactor(tre.TwoFactorKey(a.username, a.keyPassword), totpCode)
+}
+
+//provide the token of the session opened by a totp code in place of further codes
+func (a *Account) SetSession(state tre.EndToEndState) {
+	if len(state.Session) > 0 {
+		a.twoFactor = state.Session
+	}
+}
+
+//unlock the account with the state read from the server, authenticating the master
+//  password against the account verifier
+func (a *Account) Unlock(state tre.EndToEndState) error {
+
+	suite, err := cry.GetCipherSuite(state.Suite)
+	if err != nil {
+		return err
+	}
+	a.setSuite(suite)
+
+	verifier, err := cry.ReadDecryptedBound(a.hashInputCIdNameEncryption(), state.VerifierEncrypted,
+		tre.AssociatedDataVerifier(a.usernameHashed))
+	if err != nil || verifier != tre.VerifierCanary {
+		a.suite, a.keyPassword, a.unlocked = nil, "", false
+		return errors.New("badAuthentication")
+	}
+	a.unlocked = true
+	return nil
+}
+
+//decrypt the identifiers of the records held by the account in sorted order
+func (a *Account) CIdNames(state tre.EndToEndState) (cIdNames []string, err error) {
+
+	entries, err := a.cIdListEntries(state)
+	if err != nil {
+		return
+	}
+	for cIdName := range entries {
+		cIdNames = append(cIdNames, cIdName)
+	}
+	sort.Strings(cIdNames)
+	return
+}
+
+//decrypt the record fields read along with the state, each field value is held
+//  as a secret and the record is to be wiped by the caller using tre.WipeRecord
+func (a *Account) Record(cIdName string, state tre.EndToEndState) (fields map[string]*cry.Secret, err error) {
+
+	if !a.unlocked {
+		err = errors.New("accountLocked")
+		return
+	}
+	if len(state.CRecordEncrypted) < 1 {
+		err = errors.New("invalidCIdName")
+		return
+	}
+
+	return tre.ReadDecryptedRecordSecrets(a.hashInputCPasswordEncryption(cIdName), a.usernameHashed,
+		cry.GetHashedHexString(cIdName), state.CRecordEncrypted)
+}
+
+//decrypt the previous values of the record read along with the state, most recent first.
+//  Each record is to be wiped by the caller using tre.WipeRecord
+func (a *Account) RecordHistory(cIdName string, state tre.EndToEndState) (history []map[string]*cry.Secret, err error) {
+
+	if !a.unlocked {
+		err = errors.New("accountLocked")
+		return
+	}
+
+	for _, cRecordEncrypted := range state.History {
+		var fields map[string]*cry.Secret
+		fields, err = tre.ReadDecryptedRecordSecrets(a.hashInputCPasswordEncryption(cIdName), a.usernameHashed,
+			cry.GetHashedHexString(cIdName), cRecordEncrypted)
+		if err != nil {
+			for _, previous := range history {
+				tre.WipeRecord(previous)
+			}
+			return nil, err
+		}
+		history = append(history, fields)
+	}
+	return
+}
+
+//decrypt the cIdList of the state into each identifier and its encrypted entry
+func (a *Account) cIdListEntries(state tre.EndToEndState) (entries map[string]string, err error) {
+
+	if !a.unlocked {
+		err = errors.New("accountLocked")
+		return
+	}
+
+	entries = make(map[string]string)
+	for _, cIdNameEncrypted := range splitCIdList(state.CIdList) {
+		var cIdName string
+		cIdName, err = cry.ReadDecryptedBound(a.hashInputCIdNameEncryption(), cIdNameEncrypted,
+			tre.AssociatedDataCIdName(a.usernameHashed))
+		if err != nil {
+			entries = nil
+			return
+		}
+		entries[cIdName] = cIdNameEncrypted
+	}
+	return
+}
+
+//the cIdList holds each encrypted identifier between a pair of seperators
+func splitCIdList(cIdList string) (cIdNamesEncrypted []string) {
+	for _, cIdNameEncrypted := range strings.Split(cIdList, "/") {
+		if len(cIdNameEncrypted) > 0 {
+			cIdNamesEncrypted = append(cIdNamesEncrypted, cIdNameEncrypted)
+		}
+	}
+	return
+}
+
+/////////////////////////////////////////////
+//   Writing
+////////////////////////////////////////////
+
+//the tx to register the account under the cipher suite, the account is unlocked
+func (a *Account) RegisterTx(suite cry.CipherSuite) (tx string, err error) {
+
+	a.setSuite(suite)
+	a.unlocked = true
+
+	verifierEncrypted, err := cry.GetEncryptedBoundHexString(suite, a.hashInputCIdNameEncryption(),
+		tre.VerifierCanary, tre.AssociatedDataVerifier(a.usernameHashed))
+	if err != nil {
+		return
+	}
+
+	//publish the public key used to share vaults with the account,
+	//  and the signing key used to authenticate its txs and requests
+	publicKey, _ := cry.GetBoxKeyPair(tre.HashInputBoxKey(a.username, a.keyPassword))
+
+	tx = path.Join(
+		now(),
+		"registering",
+		a.usernameHashed,
+		verifierEncrypted,
+		cry.GetPublicKeyHexString(publicKey),
+		cry.GetSigningPublicKeyHexString(tre.HashInputSigningKey(a.username, a.keyPassword)))
+
+	//accounts without a cipher suite use the legacy suite
+	if suite.ID() != cry.LegacyCipherSuite.ID() {
+		tx = path.Join(tx, tre.EncodeRecoveryCodes(nil), suite.ID())
+	}
+	return
+}
+
+//the signed txs to write a record, any record of the identifier held within the state
+//  is first deleted. The time the record is modified is set within its fields
+func (a *Account) WriteTxs(cIdName string, fields map[string]string, state tre.EndToEndState) (
+	txs []string, err error) {
+
+	entries, err := a.cIdListEntries(state)
+	if err != nil {
+		return
+	}
+
+	cIdNameHashed := cry.GetHashedHexString(cIdName)
+	if cIdNameEncrypted2Delete, exists := entries[cIdName]; exists {
+		txs = append(txs, a.sign(path.Join(
+			now(),
+			"deleting",
+			a.usernameHashed,
+			cIdNameHashed,
+			cIdNameEncrypted2Delete)))
+	}
+
+	recordFields := make(map[string]string)
+	for name, value := range fields {
+		recordFields[name] = value
+	}
+	recordFields[tre.FieldModified] = time.Now().UTC().Format(time.RFC3339)
+
+	cIdNameEncrypted, err := cry.GetEncryptedBoundHexString(a.suite, a.hashInputCIdNameEncryption(), cIdName,
+		tre.AssociatedDataCIdName(a.usernameHashed))
+	if err != nil {
+		return nil, err
+	}
+	cRecordEncrypted, err := tre.GetEncryptedRecord(a.suite, a.hashInputCPasswordEncryption(cIdName),
+		a.usernameHashed, cIdNameHashed, recordFields)
+	if err != nil {
+		return nil, err
+	}
+
+	txs = append(txs, a.sign(path.Join(
+		now(),
+		"writing",
+		a.usernameHashed,
+		cIdNameHashed,
+		cIdNameEncrypted,
+		cRecordEncrypted)))
+	return
+}
+
+//the signed tx to delete the record of the identifier
+func (a *Account) DeleteTx(cIdName string, state tre.EndToEndState) (tx string, err error) {
+
+	entries, err := a.cIdListEntries(state)
+	if err != nil {
+		return
+	}
+
+	cIdNameEncrypted2Delete, exists := entries[cIdName]
+	if !exists {
+		err = errors.New("invalidCIdName")
+		return
+	}
+
+	tx = a.sign(path.Join(
+		now(),
+		"deleting",
+		a.usernameHashed,
+		cry.GetHashedHexString(cIdName),
+		cIdNameEncrypted2Delete))
+	return
+}
+
+//the signed tx to delete the account along with all of its records
+func (a *Account) DeleteAccountTx() string {
+	return a.sign(path.Join(
+		now(),
+		"deletingAccount",
+		a.usernameHashed))
+}
+
+//txs are timestamped as per the UI, the timestamps of signed txs must increase
+func now() string {
+	return tre.NewTxTimestamp()
+}
@@ -0,0 +1,223 @@
+package client
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	tre "github.com/rigelrozanski/passwerk/tree"
+	"github.com/rigelrozanski/passwerk/ui"
+)
+
+//address of the RPC of a local tendermint-core node
+const DefaultRPCAddr string = "http://localhost:46657"
+
+//exchange of signed txs and read requests with a passwerk application
+type Transport interface {
+	BroadcastTx(tx string) error
+	Read(readRequest string) (tre.EndToEndState, error)
+}
+
+//a request rejected by the passwerk application, as opposed to a request which
+//  failed to reach it. Rejected requests are not retried
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return e.Reason
+}
+
+//the decoded error of a read is a rejection by the passwerk application
+func rejectedRead(state tre.EndToEndState, err error) (tre.EndToEndState, error) {
+	if err != nil {
+		return state, &RejectedError{err.Error()}
+	}
+	return state, nil
+}
+
+/////////////////////////////////////////////
+//   HTTP Transport
+////////////////////////////////////////////
+
+//transport over the end-to-end HTTP API of the passwerk UI
+type httpTransport struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+//the baseURL is the scheme and host of the UI such as http://localhost:8080,
+//  http.DefaultClient is used when no client is provided
+func NewHTTPTransport(baseURL string, httpClient *http.Client) Transport {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &httpTransport{strings.TrimSuffix(baseURL, "/"), httpClient}
+}
+
+func (t *httpTransport) BroadcastTx(tx string) error {
+
+	body, err := t.get(ui.EndToEndTxPath(tx))
+	if err != nil {
+		return err
+	}
+
+	//the response of tendermint follows the result prefix, txs of a testing UI are only relayed
+	i := strings.Index(body, ui.EndToEndResultPrefix)
+	if i < 0 {
+		return errors.New("unexpected response: " + body)
+	}
+	result := strings.TrimSpace(body[i+len(ui.EndToEndResultPrefix):])
+	if result == "relayed" {
+		return nil
+	}
+	_, err = decodeRPCResult([]byte(result))
+	return err
+}
+
+func (t *httpTransport) Read(readRequest string) (tre.EndToEndState, error) {
+
+	body, err := t.get(ui.EndToEndReadPath(readRequest))
+	if err != nil {
+		return tre.EndToEndState{}, err
+	}
+	return rejectedRead(tre.DecodeEndToEndState(body))
+}
+
+//requests rejected by the UI are answered with a bad request status and an encoded error
+func (t *httpTransport) get(urlPath string) (body string, err error) {
+
+	resp, err := t.httpClient.Get(t.baseURL + urlPath)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	body = string(bodyBytes)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadRequest:
+		_, err = rejectedRead(tre.DecodeEndToEndState(body))
+		if err == nil {
+			err = &RejectedError{"generalError"}
+		}
+	default:
+		err = fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return
+}
+
+/////////////////////////////////////////////
+//   Tendermint RPC Transport
+////////////////////////////////////////////
+
+//transport over the RPC of a tendermint-core node, txs are broadcast directly to
+//  the node and reads are answered by the query of the tmsp application
+type rpcTransport struct {
+	rpcURL     string
+	httpClient *http.Client
+}
+
+//http.DefaultClient is used when no client is provided
+func NewRPCTransport(rpcURL string, httpClient *http.Client) Transport {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &rpcTransport{strings.TrimSuffix(rpcURL, "/"), httpClient}
+}
+
+func (t *rpcTransport) BroadcastTx(tx string) error {
+	_, err := t.call("broadcast_tx_commit", "tx", tx)
+	return err
+}
+
+func (t *rpcTransport) Read(readRequest string) (tre.EndToEndState, error) {
+
+	data, err := t.call("tmsp_query", "query", readRequest)
+	if err != nil {
+		return tre.EndToEndState{}, err
+	}
+	return rejectedRead(tre.DecodeEndToEndState(data))
+}
+
+//arguments are hex encoded as per the broadcasts of the UI
+func (t *rpcTransport) call(method, argName, arg string) (data string, err error) {
+
+	resp, err := t.httpClient.Get(t.rpcURL + "/" + method + "?" + argName + `="` +
+		hex.EncodeToString([]byte(arg)) + `"`)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("unexpected status: %s", resp.Status)
+		return
+	}
+	return decodeRPCResult(bodyBytes)
+}
+
+//the result of a tmsp call, nested within the JSON-RPC response of tendermint
+type rpcResult struct {
+	Code int    `json:"code"`
+	Data string `json:"data"`
+	Log  string `json:"log"`
+}
+
+//decode the JSON-RPC response of tendermint into the data of the result. Results are
+//  either an object or a [type, object] pair, and any non-zero code is a rejection
+func decodeRPCResult(body []byte) (data string, err error) {
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  string          `json:"error"`
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return
+	}
+	if len(response.Error) > 0 {
+		err = errors.New(response.Error)
+		return
+	}
+
+	raw := response.Result
+	var typed []json.RawMessage
+	if json.Unmarshal(raw, &typed) == nil && len(typed) == 2 {
+		raw = typed[1]
+	}
+
+	var result rpcResult
+	err = json.Unmarshal(raw, &result)
+	if err != nil {
+		return
+	}
+	if result.Code != 0 {
+		reason := result.Log
+		if len(reason) < 1 {
+			reason = fmt.Sprintf("tmsp code %d", result.Code)
+		}
+		err = &RejectedError{reason}
+		return
+	}
+
+	//data is hex encoded by go-wire
+	decoded, hexErr := hex.DecodeString(result.Data)
+	if hexErr != nil {
+		return result.Data, nil
+	}
+	return string(decoded), nil
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/rigelrozanski/passwerk/ui"
+
+	"github.com/spf13/cobra"
+)
+
+//initialize the flags of commands which connect to the running passwerk application
+func addUIClientFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&portUI, "portUI", "p", "8080", "local port of the running passwerk application")
+	cmd.Flags().StringVar(&tlsCA, "tlsCA", "", "certificate authority file of the passwerk UI certificate, connects over HTTPS")
+	cmd.Flags().StringVar(&tlsCert, "tlsCert", "", "client certificate file for mutual-TLS")
+	cmd.Flags().StringVar(&tlsKey, "tlsKey", "", "client private key file for mutual-TLS")
+}
+
+//perform a request of the running passwerk application and output the response
+func getUI(urlPath string) {
+
+	body, err := requestUI(urlPath)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	fmt.Println(body)
+}
+
+//perform a request of the running passwerk application and return the response
+func requestUI(urlPath string) (body string, err error) {
+
+	client, baseURL, err := uiHTTPClient()
+	if err != nil {
+		return
+	}
+
+	resp, err := client.Get(baseURL + urlPath)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	body = string(bodyBytes)
+	return
+}
+
+//the HTTP client and base URL of the running passwerk application,
+//  over HTTPS when a certificate authority is provided
+func uiHTTPClient() (client *http.Client, baseURL string, err error) {
+
+	uiURL := url.URL{
+		Scheme: "http",
+		Host:   "localhost:" + portUI,
+	}
+
+	client = http.DefaultClient
+	if len(tlsCA) > 0 {
+		var pool *x509.CertPool
+		pool, err = ui.LoadCertPool(tlsCA)
+		if err != nil {
+			return
+		}
+		tlsConfig := &tls.Config{
+			RootCAs:    pool,
+			MinVersion: tls.VersionTLS12,
+		}
+
+		if len(tlsCert) > 0 {
+			var cert tls.Certificate
+			cert, err = tls.LoadX509KeyPair(tlsCert, tlsKey)
+			if err != nil {
+				return
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+
+		client = &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		uiURL.Scheme = "https"
+	}
+
+	baseURL = uiURL.String()
+	return
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rigelrozanski/passwerk/client"
+	cry "github.com/rigelrozanski/passwerk/crypto"
+	tre "github.com/rigelrozanski/passwerk/tree"
+
+	"github.com/spf13/cobra"
+)
+
+var e2eCmd = &cobra.Command{
+	Use:   "e2e register|list|read|history|write|delete|deleteAccount masterUsername masterPassword [identifier [password]]",
+	Short: "access an account with end-to-end encryption",
+	Long: `access an account of the running passwerk application with end-to-end
+encryption, keys are derived and records are encrypted locally so that the
+master-username/master-password and record contents never reach the server
+	register:       register a new account
+	list:           list the identifiers of the saved records
+	read:           read the record of an identifier
+	history:        read the previous values of the record of an identifier
+	write:          save the password of an identifier
+	delete:         delete the record of an identifier
+	deleteAccount:  delete the account along with all of its records`,
+	Run: e2eRun,
+}
+
+func init() {
+	//initialize local flags
+	e2eCmd.Flags().StringVar(&cipherSuite, "cipherSuite", cry.LegacyCipherSuite.ID(), "cipher suite of a registered account ("+strings.Join(cry.CipherSuiteIDs(), ", ")+")")
+	e2eCmd.Flags().StringVar(&rpcAddr, "rpcAddr", "", "RPC address of a tendermint-core node such as "+client.DefaultRPCAddr+", connects to the node rather than the passwerk application")
+	e2eCmd.Flags().StringVar(&totpCode, "2fa", "", "current totp code of an account enrolled in two-factor authentication")
+	addUIClientFlags(e2eCmd)
+
+	RootCmd.AddCommand(e2eCmd)
+}
+
+func e2eRun(cmd *cobra.Command, args []string) {
+
+	//the number of arguments expected by each operation
+	expectedArgs := map[string]int{
+		"register":      3,
+		"list":          3,
+		"read":          4,
+		"history":       4,
+		"write":         5,
+		"delete":        4,
+		"deleteAccount": 3,
+	}
+	if len(args) < 1 || expectedArgs[args[0]] != len(args) {
+		fmt.Println("unexpected arguments, see passwerk e2e --help")
+		return
+	}
+
+	var transport client.Transport
+	if len(rpcAddr) > 0 {
+		transport = client.NewRPCTransport(rpcAddr, nil)
+	} else {
+		httpClient, baseURL, err := uiHTTPClient()
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		transport = client.NewHTTPTransport(baseURL, httpClient)
+	}
+
+	if args[0] == "register" {
+		suite, err := cry.GetCipherSuite(cipherSuite)
+		if err != nil {
+			fmt.Println("unknown cipher suite " + cipherSuite + ", see passwerk e2e --help")
+			return
+		}
+		_, err = client.Register(transport, args[1], args[2], suite)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		fmt.Println("registered")
+		return
+	}
+
+	c, err := client.LoginTwoFactor(transport, args[1], args[2], totpCode)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	switch args[0] {
+	case "list":
+		var cIdNames []string
+		cIdNames, err = c.List()
+		for _, name := range cIdNames {
+			fmt.Println(name)
+		}
+
+	case "read":
+		var record client.Record
+		record, err = c.Get(args[3])
+		for _, name := range tre.SortedFieldNames(record) {
+			fmt.Println(name + ": " + record[name])
+		}
+
+	case "history":
+		var history []client.Record
+		history, err = c.History(args[3])
+		for i, record := range history {
+			if i > 0 {
+				fmt.Println()
+			}
+			for _, name := range tre.SortedFieldNames(record) {
+				fmt.Println(name + ": " + record[name])
+			}
+		}
+
+	case "write":
+		err = c.Put(args[3], client.Record{tre.FieldPassword: args[4]})
+
+	case "delete":
+		err = c.Delete(args[3])
+
+	case "deleteAccount":
+		err = c.DeleteAccount()
+	}
+
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+}
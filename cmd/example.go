@@ -28,7 +28,52 @@ are described as follows:
   identifier - a retrievable unique identifier for a saved password
   savedpassword - a retrievable saved password associated with an identifier
 
-The following examples demonstrate the four functions available within passwerk:
+When passwerk is started with a TLS certificate the examples are served 
+over https:// rather than http://
+
+The following examples demonstrate the functions available within passwerk:
+
+  registering a new master-username/master-password account, an account
+  must be registered before any records may be written to it
+    http://localhost:8080/n/masterUsername/masterPassword
+
+  registering an account along with a number of one-time recovery codes 
+  (up to 16), which are output once and never again
+    http://localhost:8080/n/masterUsername/masterPassword/8
+
+  recovering an account with a recovery code by setting a new 
+  master-password, all previous recovery codes are replaced with new codes 
+  which are output. shared vault memberships and two-factor authentication 
+  are kept, enrolled accounts must also provide their second factor
+    http://localhost:8080/k/masterUsername/recoveryCode/newMasterPassword
+
+  enabling two-factor authentication, a generated totp secret is first 
+  output to be added to an authenticator app, the account is then enrolled 
+  once a current code of the secret is confirmed. one-time backup codes are 
+  output once enrolled
+    http://localhost:8080/t/masterUsername/masterPassword
+    http://localhost:8080/t/masterUsername/masterPassword/totpSecret/123456
+
+  all operations of an enrolled account require a current totp code, or an 
+  unused backup code, as the 2fa URL query parameter. disabling two-factor 
+  authentication also consumes an unused backup code
+    http://localhost:8080/r/masterUsername/masterPassword/idenfier?2fa=123456
+    http://localhost:8080/f/masterUsername/masterPassword/backupCode?2fa=123456
+
+  logging in to create a short-lived session, the output session token may 
+  be used as the session URL query parameter in place of the 
+  master-username/master-password URL sections. sessions expire once idle 
+  or once their maximum lifetime has passed
+    http://localhost:8080/i/masterUsername/masterPassword
+    http://localhost:8080/r/idenfier?session=sessionToken
+
+  logging out of a session, or logging out of all the sessions of an 
+  account with the master-password
+    http://localhost:8080/q?session=sessionToken
+    http://localhost:8080/q/masterUsername/masterPassword
+
+  deleting an account along with all of its saved passwords
+    http://localhost:8080/x/masterUsername/masterPassword
 
   writing a new record to the system:
     http://localhost:8080/w/masterUsername/masterPassword/idenfier/savedpassword
@@ -42,5 +87,89 @@ The following examples demonstrate the four functions available within passwerk:
     http://localhost:8080/w/masterUsername/masterPassword/idenfier/savedpassword
 
   retrieve a saved password for a given master-username/master-password/identifier
-    http://localhost:8080/w/masterUsername/masterPassword/idenfier/savedpassword`)
+    http://localhost:8080/w/masterUsername/masterPassword/idenfier/savedpassword
+
+  additional fields (such as username, url, notes, totp, or any custom name)
+  may be saved with a record as URL query parameters when writing, and a 
+  single field may be retrieved by name
+    http://localhost:8080/w/masterUsername/masterPassword/idenfier/savedpassword?username=bob&url=example.com
+    http://localhost:8080/r/masterUsername/masterPassword/idenfier/url
+
+  a totp field holding an otpauth:// URI (or base32 secret) is read as its
+  current one-time code, the seed may be read as the totpseed field unless
+  the record was written with totpconcealed=true
+    http://localhost:8080/r/masterUsername/masterPassword/idenfier/totp
+
+  writing a new record with a generated password, the optional policy is a 
+  comma seperated list of a mode (random, pronounceable, or diceware), a 
+  length, and character classes to exclude (nolower, noupper, nodigits, 
+  nosymbols). additional fields may be provided as URL query parameters
+    http://localhost:8080/g/masterUsername/masterPassword/idenfier/random,24,nosymbols
+
+  retrieve a health report of the saved passwords for a given master-username/
+  master-password, reporting weak, reused, and breached passwords (see the 
+  breachedList flag of passwerk start) as well as records older than the 
+  optional maximum age in days
+    http://localhost:8080/h/masterUsername/masterPassword/90
+
+  shared vaults hold records which are shared between users, the vault 
+  operations are prefixed with "v" and hold the vault name after the 
+  master-password. the reading, writing, deleting, and generating 
+  operations are performed as per personal records
+    http://localhost:8080/vw/masterUsername/masterPassword/vaultName/idenfier/savedpassword
+
+  creating a shared vault, the creator is the owner of the vault
+    http://localhost:8080/vc/masterUsername/masterPassword/vaultName
+
+  inviting a user to a shared vault (owner, or organization admins for 
+  collections), and accepting an invite
+    http://localhost:8080/vi/masterUsername/masterPassword/vaultName/inviteeUsername
+    http://localhost:8080/va/masterUsername/masterPassword/vaultName
+
+  revoking a member from a shared vault (owner, organization admins for 
+  collections, or members revoking themselves), the vault key is rotated
+    http://localhost:8080/vx/masterUsername/masterPassword/vaultName/memberUsername
+
+  designating an emergency contact of a shared vault (owner only) who may 
+  claim membership of the vault once they have requested access and a 
+  waiting period, measured in blocks, has passed
+    http://localhost:8080/ve/masterUsername/masterPassword/vaultName/contactUsername/waitBlocks
+
+  requesting and claiming emergency access as the emergency contact, and 
+  cancelling emergency access during the waiting period (owner only)
+    http://localhost:8080/vq/masterUsername/masterPassword/vaultName
+    http://localhost:8080/vk/masterUsername/masterPassword/vaultName
+    http://localhost:8080/vn/masterUsername/masterPassword/vaultName/contactUsername
+
+  splitting the key of a shared vault (owner only) across custodians with 
+  Shamir secret sharing, any threshold number of the custodians may release 
+  their shares to reconstruct the vault key for a requester
+    http://localhost:8080/vs/masterUsername/masterPassword/vaultName/threshold/custodian1,custodian2,custodian3
+
+  requesting reconstruction of a split vault key, approving the request (owner, 
+  or organization admins for collections), cancelling the request, releasing a 
+  share to the named requester as a custodian, and reconstructing the vault key 
+  as the requester once enough shares have been released
+    http://localhost:8080/vo/masterUsername/masterPassword/vaultName
+    http://localhost:8080/vp/masterUsername/masterPassword/vaultName/requesterUsername
+    http://localhost:8080/vu/masterUsername/masterPassword/vaultName
+    http://localhost:8080/vl/masterUsername/masterPassword/vaultName/requesterUsername
+    http://localhost:8080/vb/masterUsername/masterPassword/vaultName
+
+  organizations group users by role (owner, admin, member, or readonly), the 
+  organization operations are prefixed with "o" and hold the organization 
+  name after the master-password. creating an organization, the creator is 
+  the owner of the organization
+    http://localhost:8080/oc/masterUsername/masterPassword/orgName
+
+  setting the role of a user within an organization, and removing a user from 
+  an organization along with the organization's collections (owners and 
+  admins only, admins may only manage members and readonly members)
+    http://localhost:8080/os/masterUsername/masterPassword/orgName/memberUsername/member
+    http://localhost:8080/ox/masterUsername/masterPassword/orgName/memberUsername
+
+  adding a shared vault to an organization as a collection along with the 
+  minimum roles required to read and write its records (vault owner, who is 
+  an organization owner or admin, only)
+    http://localhost:8080/oa/masterUsername/masterPassword/orgName/vaultName/readonly,member`)
 }
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	cry "github.com/rigelrozanski/passwerk/crypto"
+)
+
+var generateCmd = &cobra.Command{
+	Use:   "generate [masterUsername masterPassword identifier]",
+	Short: "generate a new password",
+	Long: `generate a new password, if a master-username/master-password/identifier 
+is provided then the generated password is also stored by the running 
+passwerk application under the identifier`,
+	Run: generateRun,
+}
+
+//flag variables for password generation
+var genMode string
+var genLength int
+var genLower, genUpper, genDigits, genSymbols bool
+
+func init() {
+	//initialize local flags
+	generateCmd.Flags().StringVarP(&genMode, "mode", "m", cry.GenModeRandom, "generator mode: random | pronounceable | diceware")
+	generateCmd.Flags().IntVarP(&genLength, "length", "l", 0, "number of characters, or words for diceware (default depends on mode)")
+	generateCmd.Flags().BoolVar(&genLower, "lower", true, "include lowercase letters")
+	generateCmd.Flags().BoolVar(&genUpper, "upper", true, "include uppercase letters")
+	generateCmd.Flags().BoolVar(&genDigits, "digits", true, "include digits")
+	generateCmd.Flags().BoolVar(&genSymbols, "symbols", true, "include symbols")
+	addUIClientFlags(generateCmd)
+
+	RootCmd.AddCommand(generateCmd)
+}
+
+func generateRun(cmd *cobra.Command, args []string) {
+
+	//build the policy specification shared with the UI
+	policySpec := genMode
+	if genLength > 0 {
+		policySpec += "," + strconv.Itoa(genLength)
+	}
+	for _, class := range []struct {
+		name    string
+		include bool
+	}{{"lower", genLower}, {"upper", genUpper}, {"digits", genDigits}, {"symbols", genSymbols}} {
+		if !class.include {
+			policySpec += ",no" + class.name
+		}
+	}
+
+	switch len(args) {
+	case 0:
+		policy, err := cry.ParsePasswordPolicy(policySpec)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+
+		password, err := cry.GeneratePassword(policy)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		fmt.Println(password)
+
+	case 3:
+		//generate and store in one step through the running application
+		//  so the password is never typed
+		getUI("/" + path.Join("g", args[0], args[1], args[2], policySpec))
+
+	default:
+		fmt.Println("either zero or three arguments are expected, see passwerk generate --help")
+	}
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/spf13/cobra"
+)
+
+var recoverCmd = &cobra.Command{
+	Use:   "recover masterUsername recoveryCode newMasterPassword",
+	Short: "recover an account with a recovery code",
+	Long: `recover an account of the running passwerk application using one of its 
+recovery codes, the account records are re-encrypted under the new 
+master-password and all previous recovery codes are replaced by the 
+new codes which are output`,
+	Run: recoverRun,
+}
+
+func init() {
+	//initialize local flags
+	addUIClientFlags(recoverCmd)
+
+	RootCmd.AddCommand(recoverCmd)
+}
+
+func recoverRun(cmd *cobra.Command, args []string) {
+
+	if len(args) != 3 {
+		fmt.Println("three arguments are expected, see passwerk recover --help")
+		return
+	}
+
+	getUI("/" + path.Join("k", args[0], args[1], args[2]))
+}
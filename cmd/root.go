@@ -3,12 +3,28 @@ package cmd
 import (
 	//"flag"
 	//"fmt"
+	"time"
+
+	"github.com/rigelrozanski/passwerk/ui"
+
 	"github.com/spf13/cobra"
 )
 
 //flag variables pointed to throughout cmd
 var cacheSize int
-var portUI, dBPath, dBName string
+var portUI, dBPath, dBName, breachedList string
+var sessionIdleTimeout, sessionMaxLifetime time.Duration
+var bindAddr, tlsCert, tlsKey, tlsClientCA, tlsCA, redirectPort string
+var tlsSelfSigned bool
+var authLimits ui.AuthLimits
+var auditLogPath string
+var uniformErrors bool
+var cipherSuite string
+var migrateSuites bool
+var endToEnd bool
+var rpcAddr string
+var totpCode string
+var dBBackend string
 
 var RootCmd = &cobra.Command{
 	Use:   "passwerk",
@@ -21,11 +37,20 @@ with distributed consensus using tendermint
 		clearDB: deletes the database used by passwerk
 		example: displays example usage for a running 
 			passwerk application
+		generate: generates a new password, optionally 
+			storing it in a running passwerk application
+		recover: recovers an account of a running passwerk 
+			application using a recovery code
+		e2e: accesses an account of a running passwerk 
+			application with end-to-end encryption
 	Additionally flags can be used to specify command 
 	parameters, for details on flags please see use help:
 		passwerk --help
 		passwerk start --help
-		passwerk clearDB --help`,
+		passwerk clearDB --help
+		passwerk generate --help
+		passwerk recover --help
+		passwerk e2e --help`,
 	//The following code can be uncommented if there is any default action for the root cmd, right now there isn't
 	//Run: func(cmd *cobra.Command, args []string) {
 	//},
@@ -1,20 +1,21 @@
 package cmd
 
 import (
+	"crypto/tls"
 	"flag"
 	"fmt"
+	"os"
 	"path"
-	"sync"
+	"strings"
 
-	cmn "github.com/rigelrozanski/passwerk/common"
+	"github.com/rigelrozanski/passwerk/audit"
+	cry "github.com/rigelrozanski/passwerk/crypto"
 	pwkTMSP "github.com/rigelrozanski/passwerk/tmsp"
 	tre "github.com/rigelrozanski/passwerk/tree"
 	"github.com/rigelrozanski/passwerk/ui"
 
 	"github.com/spf13/cobra"
 	. "github.com/tendermint/go-common"
-	dbm "github.com/tendermint/go-db"
-	"github.com/tendermint/go-merkle"
 	"github.com/tendermint/tmsp/server"
 )
 
@@ -30,6 +31,28 @@ func init() {
 	startCmd.Flags().IntVarP(&cacheSize, "cacheSize", "c", 0, "Cache size for momma merkle trees and child trees (default 0)")
 	startCmd.Flags().StringVarP(&portUI, "portUI", "p", "8080", "local port for the passwerk application")
 	startCmd.Flags().StringVarP(&dBName, "dBName", "n", "pwkDB", "name of the passwerk database being stored")
+	startCmd.Flags().StringVarP(&breachedList, "breachedList", "b", "", "file of breached password SHA-1 hashes (or prefixes) used for health reports")
+	startCmd.Flags().DurationVar(&sessionIdleTimeout, "sessionIdleTimeout", ui.DefaultSessionIdleTimeout, "duration after which an unused session expires")
+	startCmd.Flags().StringVar(&bindAddr, "bindAddr", "", "local address the passwerk UI binds to (default all interfaces)")
+	startCmd.Flags().StringVar(&tlsCert, "tlsCert", "", "certificate file of the passwerk UI, enables HTTPS along with tlsKey")
+	startCmd.Flags().StringVar(&tlsKey, "tlsKey", "", "private key file of the passwerk UI certificate")
+	startCmd.Flags().StringVar(&tlsClientCA, "tlsClientCA", "", "certificate authority file which client certificates must be signed by (mutual-TLS)")
+	startCmd.Flags().BoolVar(&tlsSelfSigned, "tlsSelfSigned", false, "generate a self-signed certificate for local use if the certificate files do not exist (default within the db directory)")
+	startCmd.Flags().StringVar(&redirectPort, "redirectPort", "", "local port on which plain HTTP requests are redirected to HTTPS")
+	startCmd.Flags().DurationVar(&sessionMaxLifetime, "sessionMaxLifetime", ui.DefaultSessionMaxLifetime, "duration after which a session expires regardless of use")
+
+	defaultAuthLimits := ui.DefaultAuthLimits()
+	startCmd.Flags().IntVar(&authLimits.MaxFailures, "authMaxFailures", defaultAuthLimits.MaxFailures, "failed authentications of an account before it is locked out")
+	startCmd.Flags().IntVar(&authLimits.MaxClientFailures, "authMaxClientFailures", defaultAuthLimits.MaxClientFailures, "failed authentications of a client IP before it is locked out")
+	startCmd.Flags().DurationVar(&authLimits.Backoff, "authBackoff", defaultAuthLimits.Backoff, "delay of an account after a failed authentication, doubled with each failure")
+	startCmd.Flags().DurationVar(&authLimits.Lockout, "authLockout", defaultAuthLimits.Lockout, "duration of account and client lockouts")
+	startCmd.Flags().DurationVar(&authLimits.FailureFloor, "authFailureFloor", defaultAuthLimits.FailureFloor, "minimum duration of failed authentication responses")
+	startCmd.Flags().StringVar(&auditLogPath, "auditLog", "", "file to which the detailed reason of each authentication failure is appended")
+	startCmd.Flags().BoolVar(&uniformErrors, "uniformErrors", false, "report all authentication failures with an identical response")
+	startCmd.Flags().StringVar(&cipherSuite, "cipherSuite", cry.LegacyCipherSuite.ID(), "cipher suite of new accounts and vaults ("+strings.Join(cry.CipherSuiteIDs(), ", ")+")")
+	startCmd.Flags().BoolVar(&migrateSuites, "migrateSuites", false, "migrate accounts to the cipher suite as they log in")
+	startCmd.Flags().StringVar(&dBBackend, "db-backend", tre.DBBackendLevelDB, "backend of the passwerk database ("+strings.Join(tre.DBBackends(), ", ")+"), the "+tre.DBBackendMemDB+" backend is lost when passwerk exits")
+	startCmd.Flags().BoolVar(&endToEnd, "endToEnd", false, "only accept requests of end-to-end clients, which never provide the master username/password")
 
 	RootCmd.AddCommand(startCmd)
 }
@@ -44,55 +67,97 @@ func startRun(cmd *cobra.Command, args []string) {
 	//  Load Database
 	/////////////////////////////////////
 
-	//Keyz for db values which hold information which isn't the contents of a Merkle tree
-	dBKeyMerkleHash := []byte(cmn.DBKeyMerkleHash)
-
-	//setup the persistent merkle tree to be used by both the UI and TMSP
-	oldDBNotPresent, _ := cmn.IsDirEmpty(path.Join(dBName, dBName) + ".db")
+	//open the db, if the db doesn't exist it will be created
+	storage, err := tre.OpenStorage(dBBackend, dBName, dBName)
+	if err != nil {
+		Exit(err.Error() + ", see passwerk start --help")
+	}
 
-	if oldDBNotPresent {
-		fmt.Println("no existing db, creating new db")
-	} else {
+	//either load, or set and load the merkle state
+	//pwkTree will be limited to read only when fed into the UI
+	pwkTree, existing := storage.LoadTree(cacheSize)
+	if existing {
 		fmt.Println("loading existing db")
+	} else {
+		fmt.Println("no existing db, creating new db")
 	}
 
-	//open the db, if the db doesn't exist it will be created
-	pwkDB := dbm.NewDB(dBName, dbm.DBBackendLevelDB, dBName)
-
-	var state merkle.Tree
-
-	state = merkle.NewIAVLTree(cacheSize, pwkDB)
-
-	//for WAL version of go-merkle
-	//state = merkle.NewIAVLTree(cacheSize, path.Join(dBName, cmn.WalSubDir), pwkDB)
+	//define the readers and writers for UI and TMSP respectively
+	ptw, ptr := tre.NewPwkTreePair(pwkTree)
 
-	//either load, or set and load the merkle state
-	if oldDBNotPresent {
-		pwkDB.Set(dBKeyMerkleHash, state.Save())
+	////////////////////////////////////
+	//  Load the breached password list for health reports
+	var breached audit.BreachedList
+	if len(breachedList) > 0 {
+		var err error
+		breached, err = audit.LoadBreachedList(breachedList)
+		if err != nil {
+			Exit(err.Error())
+		}
 	}
-	state.Load(pwkDB.Get([]byte(dBKeyMerkleHash)))
 
-	//define the pwkTree which will be fed into UI and TMSP
-	//pwkTree will be limited to read only when fed into the UI
-	pwkTree := tre.NewPwkMerkleTree(state, cacheSize, pwkDB, dBName)
+	////////////////////////////////////
+	//  Load the TLS configuration of the UI, generating a self-signed certificate if requested
+	var tlsConfig *tls.Config
+	if tlsSelfSigned {
+		if len(tlsCert) < 1 {
+			tlsCert = path.Join(dBName, "uiCert.pem")
+		}
+		if len(tlsKey) < 1 {
+			tlsKey = path.Join(dBName, "uiKey.pem")
+		}
+		if _, err := os.Stat(tlsCert); os.IsNotExist(err) {
+			fmt.Println("generating self-signed certificate " + tlsCert)
+			hosts := []string{"localhost", "127.0.0.1", "::1", bindAddr}
+			err = ui.GenerateSelfSignedCert(tlsCert, tlsKey, hosts)
+			if err != nil {
+				Exit(err.Error())
+			}
+		}
+	}
+	if len(tlsCert) > 0 || len(tlsKey) > 0 {
+		var err error
+		tlsConfig, err = ui.LoadTLSConfig(tlsCert, tlsKey, tlsClientCA)
+		if err != nil {
+			Exit(err.Error())
+		}
+	} else if len(tlsClientCA) > 0 || len(redirectPort) > 0 {
+		Exit("tlsClientCA and redirectPort require a certificate, see passwerk start --help")
+	}
 
-	var pR tre.TreeReading = pwkTree
-	var pW tre.TreeWriting = pwkTree
+	////////////////////////////////////
+	//  Open the audit log of authentication failures
+	var auditLog *ui.AuditLog
+	var auditLogFile *os.File
+	if len(auditLogPath) > 0 {
+		var err error
+		auditLogFile, err = os.OpenFile(auditLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			Exit(err.Error())
+		}
+		auditLog = ui.NewAuditLog(auditLogFile)
+	}
 
-	//define the readers and writers for UI and TMSP respectively
-	mtx := new(sync.Mutex)                               //lock for data access
-	ptr := tre.NewPwkTreeReader(mtx, pR, "", "", "", "") //initilize blank reader variables, updated in UI
-	ptw := tre.NewPwkTreeWriter(mtx, pW, "", "", "")     //initilize blank reader variables, updated in TMSP
+	////////////////////////////////////
+	//  Select the cipher suite of new accounts and vaults
+	suite, err := cry.GetCipherSuite(cipherSuite)
+	if err != nil {
+		Exit("unknown cipher suite " + cipherSuite + ", see passwerk start --help")
+	}
 
 	////////////////////////////////////
 	//  Start UI
-	go ui.HTTPListener(ptr, portUI, false) //start on a seperate Thread
+	go ui.HTTPListener(ptr, bindAddr, portUI, tlsConfig, redirectPort, breached,
+		sessionIdleTimeout, sessionMaxLifetime, authLimits, auditLog, uniformErrors,
+		suite, migrateSuites, endToEnd, false) //start on a seperate Thread
 
 	////////////////////////////////////
 	//  Start TMSP
 
-	// Start the listener
-	_, err := server.NewServer(*addrPtr, *tmspPtr, pwkTMSP.NewPasswerkApplication(ptw))
+	// Start the listener, accounts which don't exist are read with the suite of new accounts
+	pwkApp := pwkTMSP.NewPasswerkApplication(ptw)
+	pwkApp.SetOption("cipherSuite", suite.ID())
+	_, err = server.NewServer(*addrPtr, *tmspPtr, pwkApp)
 
 	if err != nil {
 		Exit(err.Error())
@@ -100,6 +165,9 @@ func startRun(cmd *cobra.Command, args []string) {
 
 	// Wait forever
 	TrapSignal(func() {
-		pwkDB.Close()
+		storage.Close()
+		if auditLogFile != nil {
+			auditLogFile.Close()
+		}
 	})
 }
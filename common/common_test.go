@@ -15,7 +15,7 @@ func TestCommon(t *testing.T) {
 	//make the test directory, will be empty
 	err := os.Mkdir(testDir, 0777)
 	if err != nil {
-		t.Errorf("err creating dir: ", err.Error())
+		t.Errorf("err creating dir: %v", err)
 		err = nil
 	}
 
@@ -23,7 +23,7 @@ func TestCommon(t *testing.T) {
 	var dirIsEmpty bool = false
 	dirIsEmpty, err = IsDirEmpty(testDir)
 	if err != nil {
-		t.Errorf("err testing IsDirEmpty: ", err.Error())
+		t.Errorf("err testing IsDirEmpty: %v", err)
 		err = nil
 	} else if !dirIsEmpty {
 		t.Errorf("failed IsDirEmpty logic, empty dir considered non-empty")
@@ -32,7 +32,7 @@ func TestCommon(t *testing.T) {
 	//make the test sub directory
 	err = os.Mkdir(testSubDir, 0777)
 	if err != nil {
-		t.Errorf("err creating dir: ", err.Error())
+		t.Errorf("err creating dir: %v", err)
 		err = nil
 	}
 
@@ -40,7 +40,7 @@ func TestCommon(t *testing.T) {
 	dirIsEmpty = true
 	dirIsEmpty, err = IsDirEmpty(testDir)
 	if err != nil {
-		t.Errorf("err testing IsDirEmpty: ", err.Error())
+		t.Errorf("err testing IsDirEmpty: %v", err)
 		err = nil
 	} else if dirIsEmpty {
 		t.Errorf("failed IsDirEmpty logic, non-empty dir considered empty")
@@ -49,7 +49,7 @@ func TestCommon(t *testing.T) {
 	//test the copy directory, should copy all sub files (including the sub directory generated)
 	err = CopyDir(testDir, testDir4Copy)
 	if err != nil {
-		t.Errorf("err copying dir: ", err.Error())
+		t.Errorf("err copying dir: %v", err)
 		err = nil
 	}
 
@@ -57,7 +57,7 @@ func TestCommon(t *testing.T) {
 	dirIsEmpty = true
 	dirIsEmpty, err = IsDirEmpty(testDir4Copy)
 	if err != nil {
-		t.Errorf("err testing IsDirEmpty: ", err.Error())
+		t.Errorf("err testing IsDirEmpty: %v", err)
 		err = nil
 	} else if dirIsEmpty {
 		t.Errorf("failed IsDirEmpty logic, non-empty dir considered empty")
@@ -67,7 +67,7 @@ func TestCommon(t *testing.T) {
 	err = DeleteDir(testDir)
 	err = DeleteDir(testDir4Copy)
 	if err != nil {
-		t.Errorf("err deleting dir: ", err.Error())
+		t.Errorf("err deleting dir: %v", err)
 		err = nil
 	}
 }
@@ -0,0 +1,107 @@
+//public-key functionality used to share vault keys between users
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+)
+
+//derive a box keypair deterministically from the hashed value of the input variable hashInput,
+//  the private key is to be wiped by the caller once used
+func GetBoxKeyPair(hashInput string) (publicKey, privateKey *[32]byte) {
+
+	publicKey = new([32]byte)
+	privateKey = deriveKey(hashInput)
+
+	curve25519.ScalarBaseMult(publicKey, privateKey)
+	return
+}
+
+//return a new random key as a hex string
+func GetRandomKeyHexString() (string, error) {
+
+	var key [32]byte
+	if _, err := io.ReadFull(rand.Reader, key[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key[:]), nil
+}
+
+func GetPublicKeyHexString(publicKey *[32]byte) string {
+	return hex.EncodeToString(publicKey[:])
+}
+
+func ReadPublicKeyHexString(publicKeyHex string) (publicKey *[32]byte, err error) {
+
+	var publicKeyBytes []byte
+	publicKeyBytes, err = hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return
+	}
+	if len(publicKeyBytes) != 32 {
+		err = errors.New("invalid public key length")
+		return
+	}
+
+	publicKey = new([32]byte)
+	copy(publicKey[:], publicKeyBytes)
+	return
+}
+
+//wrap a secret to a public key using an ephemeral sender keypair
+//  the output hex holds the ephemeral public key, nonce and sealed secret
+func WrapKey(publicKey *[32]byte, secret string) (wrappedHex string, err error) {
+	return wrapBytes(publicKey, []byte(secret))
+}
+
+//re-wrap a secret which was wrapped to the public key of privateKey to another public key,
+//  the unwrapped secret is wiped once re-wrapped
+func RewrapKey(privateKey, publicKey *[32]byte, wrappedHex string) (rewrappedHex string, err error) {
+
+	unwrapped, err := UnwrapSecret(privateKey, wrappedHex)
+	if err != nil {
+		return
+	}
+	defer unwrapped.Wipe()
+
+	return wrapBytes(publicKey, unwrapped.Bytes())
+}
+
+func wrapBytes(publicKey *[32]byte, secret []byte) (wrappedHex string, err error) {
+
+	var ephemeralPublic, ephemeralPrivate *[32]byte
+	ephemeralPublic, ephemeralPrivate, err = box.GenerateKey(rand.Reader)
+	if err != nil {
+		return
+	}
+
+	var nonce [24]byte
+	if _, err = io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return
+	}
+
+	wrapped := append(ephemeralPublic[:], nonce[:]...)
+	wrapped = box.Seal(wrapped, secret, &nonce, publicKey, ephemeralPrivate)
+
+	wrappedHex = hex.EncodeToString(wrapped)
+	return
+}
+
+//unwrap a secret which was wrapped to the public key of privateKey, see UnwrapSecret
+//  to avoid holding the unwrapped value within an immutable string
+func UnwrapKey(privateKey *[32]byte, wrappedHex string) (secret string, err error) {
+
+	unwrapped, err := UnwrapSecret(privateKey, wrappedHex)
+	if err != nil {
+		return
+	}
+	secret = string(unwrapped.Bytes())
+	unwrapped.Wipe()
+
+	return
+}
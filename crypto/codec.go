@@ -0,0 +1,152 @@
+//ciphertext encoding, every ciphertext is parsed and length checked here before it's opened
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+//errors produced when reading a ciphertext, the error strings are reported by the UI
+var (
+	//not a hex encoded ciphertext
+	ErrMalformedCiphertext = errors.New("malformedCiphertext")
+
+	//too short to hold a nonce and authenticator
+	ErrShortCiphertext = errors.New("shortCiphertext")
+
+	//failed authentication, the ciphertext was modified, moved to another
+	//  location, or opened with the wrong key
+	ErrTamperedCiphertext = errors.New("tamperedCiphertext")
+
+	//sealed before associated data was supported where associated data is expected,
+	//  such ciphertexts are only read to be resealed by a cipher suite migration
+	ErrLegacyCiphertext = errors.New("legacyCiphertext")
+)
+
+//ciphertexts sealed by a cipher suite are prefixed with the suite's non-hex prefix character,
+//  "x" being the prefix of the legacy suite. Ciphertexts without a prefix were sealed with
+//  NaCl secretbox before associated data was supported
+const aeadPrefix string = "x"
+
+const nonceSize int = 24 //the nonce size of both secretbox and XChaCha20-Poly1305
+
+//a parsed ciphertext, held as its nonce followed by the sealed text
+type ciphertext struct {
+	suite     CipherSuite //the suite which sealed the ciphertext
+	secretbox bool        //true for unprefixed ciphertexts sealed with NaCl secretbox
+	raw       []byte
+}
+
+//parse an encoded ciphertext without opening it
+func decodeCiphertext(encoded string) (ct ciphertext, err error) {
+
+	ct.suite, ct.secretbox = LegacyCipherSuite, true
+	if len(encoded) > 0 {
+		if suite := getCipherSuiteByPrefix(encoded[:1]); suite != nil {
+			ct.suite, ct.secretbox = suite, false
+			encoded = encoded[1:]
+		}
+	}
+
+	ct.raw, err = hex.DecodeString(encoded)
+	if err != nil {
+		err = ErrMalformedCiphertext
+	}
+	return
+}
+
+//derive the symmetric key of the suite's hash of hashInput, the key is
+//  to be wiped by the caller once used
+func deriveSuiteKey(suite CipherSuite, hashInput string) *[32]byte {
+
+	input := []byte(hashInput)
+	key := new([32]byte)
+	*key = suite.Hash(input)
+	Wipe(input)
+	return key
+}
+
+//seal with the suite's AEAD under a random nonce
+func sealCiphertext(suite CipherSuite, hashInput string, text, associatedData []byte) (encoded string, err error) {
+
+	key := deriveSuiteKey(suite, hashInput)
+	defer Wipe(key[:])
+
+	aead, err := suite.AEAD(key[:])
+	if err != nil {
+		return
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return
+	}
+
+	return suite.Prefix() + hex.EncodeToString(aead.Seal(nonce, nonce, text, associatedData)), nil
+}
+
+//open the ciphertext into a secret. Legacy secretbox ciphertexts cannot be bound to
+//  associated data, and are refused where associated data is expected unless allowLegacy
+func (ct ciphertext) open(hashInput string, associatedData []byte, allowLegacy bool) (*Secret, error) {
+
+	if ct.secretbox && len(associatedData) > 0 && !allowLegacy {
+		return nil, ErrLegacyCiphertext
+	}
+
+	key := deriveSuiteKey(ct.suite, hashInput)
+	defer Wipe(key[:])
+
+	var aead cipher.AEAD = secretboxAEAD{key}
+	if !ct.secretbox {
+		var err error
+		aead, err = ct.suite.AEAD(key[:])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(ct.raw) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrShortCiphertext
+	}
+	nonce, sealed := ct.raw[:aead.NonceSize()], ct.raw[aead.NonceSize():]
+
+	secret := NewSecret(len(sealed) - aead.Overhead())
+	plaintext, err := aead.Open(secret.b, nonce, sealed, associatedData)
+	if err != nil {
+		secret.Wipe()
+		return nil, ErrTamperedCiphertext
+	}
+	secret.b = plaintext
+
+	return secret, nil
+}
+
+//NaCl secretbox as an AEAD without associated data, only used to read legacy ciphertexts
+type secretboxAEAD struct {
+	key *[32]byte
+}
+
+func (secretboxAEAD) NonceSize() int { return nonceSize }
+func (secretboxAEAD) Overhead() int  { return box.Overhead }
+
+func (s secretboxAEAD) Seal(dst, nonce, plaintext, _ []byte) []byte {
+	var n [nonceSize]byte
+	copy(n[:], nonce)
+	return box.SealAfterPrecomputation(dst, plaintext, &n, s.key)
+}
+
+func (s secretboxAEAD) Open(dst, nonce, sealed, _ []byte) ([]byte, error) {
+	var n [nonceSize]byte
+	copy(n[:], nonce)
+
+	plaintext, success := box.OpenAfterPrecomputation(dst, sealed, &n, s.key)
+	if !success {
+		return nil, ErrTamperedCiphertext
+	}
+	return plaintext, nil
+}
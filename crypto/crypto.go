@@ -2,44 +2,40 @@
 package crypto
 
 import (
-	"crypto/rand"
 	"encoding/hex"
-	"errors"
-	"io"
 
-	"golang.org/x/crypto/nacl/box"
 	"golang.org/x/crypto/sha3"
 )
 
-//read and decrypt from the hashPasswordList
+//read and decrypt from the hashPasswordList, see DecryptSecret to avoid holding
+//  the decrypted value within an immutable string
 func ReadDecrypted(hashInput, encryptedString string) (decryptedString string, err error) {
+	return ReadDecryptedBound(hashInput, encryptedString, "")
+}
 
-	var key [32]byte
-	copy(key[:], getHash(hashInput))
-
-	var ciphertext, decryptedByte []byte
+//read and decrypt a ciphertext bound to the associated data (see GetEncryptedBoundHexString)
+func ReadDecryptedBound(hashInput, encryptedString, associatedData string) (decryptedString string, err error) {
 
-	ciphertext, err = hex.DecodeString(encryptedString)
-	decryptedByte, err = decryptNaCl(&key, ciphertext)
-	decryptedString = string(decryptedByte[:])
+	secret, err := DecryptSecret(hashInput, encryptedString, associatedData)
+	if err != nil {
+		return
+	}
+	decryptedString = string(secret.Bytes())
+	secret.Wipe()
 
 	return
 }
 
-//return an encrypted string. the encyption key is taken as hashed value of the input variable hashInput
-func GetEncryptedHexString(hashInput, unencryptedString string) string {
-
-	var key [32]byte
-	copy(key[:], getHash(hashInput))
-
-	encryptedByte, err := encryptNaCl(&key, []byte(unencryptedString))
-
-	if err == nil {
-		encryptedHexString := hex.EncodeToString(encryptedByte[:])
-		return encryptedHexString
-	}
+//return an encrypted string. the encyption key is taken as hashed value of the input variable hashInput,
+//  sealed with the legacy suite for values which aren't held under the suite of an account
+func GetEncryptedHexString(hashInput, unencryptedString string) (string, error) {
+	return GetEncryptedBoundHexString(LegacyCipherSuite, hashInput, unencryptedString, "")
+}
 
-	return ""
+//return an encrypted string which is bound to the associated data, the associated data
+//  isn't encrypted but the ciphertext will only decrypt when provided the same associated data
+func GetEncryptedBoundHexString(suite CipherSuite, hashInput, unencryptedString, associatedData string) (string, error) {
+	return EncryptSecret(suite, hashInput, []byte(unencryptedString), associatedData)
 }
 
 func bytes2HexString(dataInput []byte) string {
@@ -62,36 +58,3 @@ func getHash(dataInput string) []byte {
 	hashBytes := sha3.Sum256([]byte(dataInput))
 	return hashBytes[:]
 }
-
-func encryptNaCl(key *[32]byte, text []byte) (ciphertext []byte, err error) {
-
-	var nonce [24]byte
-
-	if _, err = io.ReadFull(rand.Reader, nonce[:]); err != nil {
-		return
-	}
-
-	//crypted := make([]byte, 0, box.Overhead+len(message))
-
-	ciphertext = box.SealAfterPrecomputation([]byte(""), text, &nonce, key)
-	ciphertext = append(nonce[:], ciphertext...)
-
-	return
-}
-
-func decryptNaCl(key *[32]byte, ciphertext []byte) (plaintext []byte, err error) {
-
-	var nonce [24]byte
-	copy(nonce[:], ciphertext[:24])
-
-	cipherMessage := ciphertext[24:]
-
-	plaintext, success := box.OpenAfterPrecomputation([]byte(""), cipherMessage, &nonce, key)
-
-	if !success {
-		err = errors.New("bad decryption")
-		return
-	}
-
-	return
-}
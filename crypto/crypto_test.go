@@ -2,22 +2,29 @@
 package crypto
 
 import (
+	"strings"
 	"testing"
+	"time"
+
+	"golang.org/x/crypto/nacl/box"
 )
 
 //func ReadDecrypted(hashInput, encryptedString string) (decryptedString string, err error) {
-//func GetEncryptedHexString(hashInput, unencryptedString string) string {
+//func GetEncryptedHexString(hashInput, unencryptedString string) (string, error) {
 //func GetHashedHexString(dataInput string) string {
 
 func TestCrypto(t *testing.T) {
 	testSharedEncryptionKey := "topSecretKey"
 	secretMessage := "property is theft"
 
-	encryptedHexString := GetEncryptedHexString(testSharedEncryptionKey, secretMessage)
+	encryptedHexString, err := GetEncryptedHexString(testSharedEncryptionKey, secretMessage)
+	if err != nil {
+		t.Errorf("err encrypting: %v", err)
+	}
 	decryptedHexString, err := ReadDecrypted(testSharedEncryptionKey, encryptedHexString)
 
 	if err != nil {
-		t.Errorf("err decrypting: ", err.Error())
+		t.Errorf("err decrypting: %v", err)
 	}
 
 	if decryptedHexString != secretMessage {
@@ -25,3 +32,546 @@ func TestCrypto(t *testing.T) {
 	}
 
 }
+
+//test vectors from RFC 4226 and RFC 6238
+func TestOTP(t *testing.T) {
+
+	key, err := ParseOTPKey("otpauth://hotp/passwerk?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=1")
+	if err != nil {
+		t.Errorf("err parsing otp key: %v", err)
+	}
+
+	hotpCodes := []string{"755224", "287082", "359152"}
+	for i, expected := range hotpCodes {
+		code, err := GenerateHOTP(key, uint64(i))
+		if err != nil {
+			t.Errorf("err generating hotp: %v", err)
+		}
+		if code != expected {
+			t.Errorf("bad hotp got %v but expected %v", code, expected)
+		}
+	}
+
+	totpSecrets := map[string]string{
+		"SHA1":   "12345678901234567890",
+		"SHA256": "12345678901234567890123456789012",
+		"SHA512": "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	totpCodes := map[string]string{
+		"SHA1":   "94287082",
+		"SHA256": "46119246",
+		"SHA512": "90693936",
+	}
+	for algorithm, secret := range totpSecrets {
+		key := OTPKey{
+			Type:      "totp",
+			Secret:    []byte(secret),
+			Algorithm: algorithm,
+			Digits:    8,
+			Period:    30,
+		}
+		code, remaining, err := GenerateTOTP(key, time.Unix(59, 0))
+		if err != nil {
+			t.Errorf("err generating totp: %v", err)
+		}
+		if code != totpCodes[algorithm] {
+			t.Errorf("bad %v totp got %v but expected %v", algorithm, code, totpCodes[algorithm])
+		}
+		if remaining != time.Second {
+			t.Errorf("bad totp remaining validity")
+		}
+	}
+
+	//bad keys should not parse
+	if _, err := ParseOTPKey("otpauth://yotp/passwerk?secret=GEZDGNBV"); err == nil {
+		t.Errorf("bad otp type does not produce error")
+	}
+	if _, err := ParseOTPKey("otpauth://totp/passwerk?secret=GEZDGNBV&algorithm=MD5"); err == nil {
+		t.Errorf("bad otp algorithm does not produce error")
+	}
+
+	//generated secrets should round trip through their enrollment URI and verify
+	//  codes from adjacent time steps only
+	secret, err := GenerateOTPSecret()
+	if err != nil {
+		t.Errorf("err generating otp secret: %v", err)
+	}
+	key, err = ParseOTPKey(GetTOTPURI("passwerk", "user", secret))
+	if err != nil {
+		t.Errorf("err parsing totp uri: %v", err)
+	}
+	now := time.Unix(1000000, 0)
+	for offset, valid := range map[int64]bool{-30: true, 0: true, 30: true, 90: false} {
+		code, _, err := GenerateTOTP(key, now.Add(time.Duration(offset)*time.Second))
+		if err != nil {
+			t.Errorf("err generating totp: %v", err)
+		}
+		if VerifyTOTP(key, code, now, 1) != valid {
+			t.Errorf("bad totp verification for offset %v", offset)
+		}
+	}
+
+	//codes at or before the last accepted time step are rejected
+	code, _, err := GenerateTOTP(key, now)
+	if err != nil {
+		t.Errorf("err generating totp: %v", err)
+	}
+	step, valid := VerifyTOTPAfter(key, code, now, 1, -1)
+	if !valid || step != now.Unix()/key.Period {
+		t.Errorf("bad totp step %v", step)
+	}
+	if _, valid = VerifyTOTPAfter(key, code, now, 1, step); valid {
+		t.Errorf("reused totp code accepted")
+	}
+	if _, valid = VerifyTOTPAfter(key, code, now, 1, step-1); !valid {
+		t.Errorf("unused totp code rejected")
+	}
+}
+
+func TestGeneratePassword(t *testing.T) {
+
+	//random passwords must contain each of the selected character classes
+	policy, err := ParsePasswordPolicy("random,32,nosymbols")
+	if err != nil {
+		t.Errorf("err parsing policy: %v", err)
+	}
+	password, err := GeneratePassword(policy)
+	if err != nil {
+		t.Errorf("err generating password: %v", err)
+	}
+	if len(password) != 32 ||
+		!strings.ContainsAny(password, genLower) ||
+		!strings.ContainsAny(password, genUpper) ||
+		!strings.ContainsAny(password, genDigits) ||
+		strings.ContainsAny(password, genSymbols) {
+		t.Errorf("random password does not satisfy the policy: %v", password)
+	}
+
+	//consecutive passwords should differ
+	password2, _ := GeneratePassword(policy)
+	if password == password2 {
+		t.Errorf("consecutive generated passwords are identical")
+	}
+
+	//pronounceable passwords alternate consonants and vowels
+	policy, _ = ParsePasswordPolicy("pronounceable,10,noupper,nodigits")
+	password, err = GeneratePassword(policy)
+	if err != nil {
+		t.Errorf("err generating password: %v", err)
+	}
+	for i := 0; i < len(password); i++ {
+		if (i%2 == 1) != strings.ContainsRune(genVowels, rune(password[i])) {
+			t.Errorf("pronounceable password does not alternate consonants and vowels: %v", password)
+			break
+		}
+	}
+
+	//diceware passphrases contain the requested number of words
+	policy, _ = ParsePasswordPolicy("diceware,5,noupper,nodigits")
+	password, err = GeneratePassword(policy)
+	if err != nil {
+		t.Errorf("err generating password: %v", err)
+	}
+	if len(strings.Split(password, policy.Separator)) != 5 {
+		t.Errorf("diceware passphrase has an unexpected number of words: %v", password)
+	}
+
+	//bad policies
+	if _, err := ParsePasswordPolicy("random,notanumber"); err == nil {
+		t.Errorf("bad policy does not produce error")
+	}
+	policy, _ = ParsePasswordPolicy("random,3")
+	if _, err := GeneratePassword(policy); err == nil {
+		t.Errorf("password too short for its character classes does not produce error")
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+
+	codes, err := GenerateRecoveryCodes(4)
+	if err != nil {
+		t.Errorf("err generating recovery codes: %v", err)
+	}
+	if len(codes) != 4 {
+		t.Errorf("incorrect number of recovery codes generated")
+	}
+
+	for _, code := range codes {
+		if len(code) != 23 || strings.Count(code, "-") != 3 ||
+			strings.Trim(code, recoveryCodeChars+"-") != "" {
+			t.Errorf("bad recovery code format: %v", code)
+		}
+	}
+	if codes[0] == codes[1] {
+		t.Errorf("consecutive recovery codes are identical")
+	}
+}
+
+func TestSecretSharing(t *testing.T) {
+
+	secret, _ := GetRandomKeyHexString()
+
+	shares, err := GetSecretShareHexStrings(secret, 3, 5)
+	if err != nil {
+		t.Errorf("err splitting secret: %v", err)
+	}
+	if len(shares) != 5 {
+		t.Errorf("incorrect number of shares")
+	}
+
+	//any three shares reconstruct the secret
+	for _, subset := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}} {
+		var subsetShares []string
+		for _, i := range subset {
+			subsetShares = append(subsetShares, shares[i])
+		}
+		combined, err := CombineSecretShareHexStrings(subsetShares)
+		if err != nil {
+			t.Errorf("err combining shares: %v", err)
+		}
+		if combined != secret {
+			t.Errorf("combined shares do not reconstruct the secret")
+		}
+	}
+
+	//two shares do not reconstruct the secret
+	combined, _ := CombineSecretShareHexStrings(shares[:2])
+	if combined == secret {
+		t.Errorf("shares below the threshold reconstruct the secret")
+	}
+
+	//duplicate shares are rejected, as is a threshold above the number of shares
+	_, err = CombineSecretShareHexStrings([]string{shares[0], shares[0]})
+	if err == nil {
+		t.Errorf("duplicate shares do not produce an error")
+	}
+	_, err = GetSecretShareHexStrings(secret, 6, 5)
+	if err == nil {
+		t.Errorf("threshold above the number of shares does not produce an error")
+	}
+}
+
+func TestWrapKey(t *testing.T) {
+
+	publicKey, privateKey := GetBoxKeyPair("alice/alicePassword/boxKey")
+	_, wrongPrivateKey := GetBoxKeyPair("mallory/malloryPassword/boxKey")
+
+	//keypairs are deterministic
+	publicKey2, _ := GetBoxKeyPair("alice/alicePassword/boxKey")
+	if *publicKey != *publicKey2 {
+		t.Errorf("derived keypairs are not deterministic")
+	}
+
+	//public keys survive hex encoding
+	publicKey3, err := ReadPublicKeyHexString(GetPublicKeyHexString(publicKey))
+	if err != nil || *publicKey != *publicKey3 {
+		t.Errorf("bad public key hex encoding")
+	}
+
+	vaultKey, err := GetRandomKeyHexString()
+	if err != nil {
+		t.Errorf("err generating key: %v", err)
+	}
+
+	wrapped, err := WrapKey(publicKey, vaultKey)
+	if err != nil {
+		t.Errorf("err wrapping key: %v", err)
+	}
+
+	unwrapped, err := UnwrapKey(privateKey, wrapped)
+	if err != nil {
+		t.Errorf("err unwrapping key: %v", err)
+	}
+	if unwrapped != vaultKey {
+		t.Errorf("unwrapped key does not match original key")
+	}
+
+	if _, err := UnwrapKey(wrongPrivateKey, wrapped); err == nil {
+		t.Errorf("unwrapping with the wrong private key does not produce an error")
+	}
+	if _, err := UnwrapKey(privateKey, "abcd"); err == nil {
+		t.Errorf("unwrapping a short input does not produce an error")
+	}
+
+	//re-wrapped keys are only unwrapped by the new private key
+	newPublicKey, newPrivateKey := GetBoxKeyPair("alice/aliceNewPassword/boxKey")
+	rewrapped, err := RewrapKey(privateKey, newPublicKey, wrapped)
+	if err != nil {
+		t.Errorf("err re-wrapping key: %v", err)
+	}
+	unwrapped, err = UnwrapKey(newPrivateKey, rewrapped)
+	if err != nil || unwrapped != vaultKey {
+		t.Errorf("re-wrapped key does not match original key")
+	}
+	if _, err := UnwrapKey(privateKey, rewrapped); err == nil {
+		t.Errorf("unwrapping a re-wrapped key with the old private key does not produce an error")
+	}
+	if _, err := RewrapKey(wrongPrivateKey, newPublicKey, wrapped); err == nil {
+		t.Errorf("re-wrapping with the wrong private key does not produce an error")
+	}
+}
+
+func TestSignature(t *testing.T) {
+
+	hashInput := "alice/alicePassword/signingKey"
+	message := "timeStamp/settingRole/org/alice/bob/member"
+
+	publicKeyHex := GetSigningPublicKeyHexString(hashInput)
+	signatureHex := GetSignatureHexString(hashInput, message)
+
+	if !VerifySignatureHexString(publicKeyHex, message, signatureHex) {
+		t.Errorf("valid signature does not verify")
+	}
+	if VerifySignatureHexString(publicKeyHex, message+"/admin", signatureHex) {
+		t.Errorf("signature verifies against a modified message")
+	}
+	if VerifySignatureHexString(GetSigningPublicKeyHexString("mallory"), message, signatureHex) {
+		t.Errorf("signature verifies against the wrong public key")
+	}
+	if VerifySignatureHexString(publicKeyHex, message, "zz") {
+		t.Errorf("malformed signature verifies")
+	}
+}
+
+func TestSecret(t *testing.T) {
+
+	//secrets take ownership of their input which is wiped
+	input := []byte("property is theft")
+	secret := NewSecretFromBytes(input)
+	if string(input) != strings.Repeat("\x00", len(input)) {
+		t.Errorf("secret input was not wiped")
+	}
+	if string(secret.Bytes()) != "property is theft" {
+		t.Errorf("secret does not hold the input")
+	}
+
+	//secrets are never printed
+	if secret.String() == "property is theft" {
+		t.Errorf("secret is printed")
+	}
+
+	//appending beyond the capacity moves the secret and wipes the previous buffer
+	held := secret.Bytes()
+	secret.AppendString(", property is liberty")
+	if string(secret.Bytes()) != "property is theft, property is liberty" {
+		t.Errorf("bad secret append")
+	}
+	if string(held) != strings.Repeat("\x00", len(held)) {
+		t.Errorf("previous secret buffer was not wiped")
+	}
+
+	held = secret.Bytes()
+	secret.Wipe()
+	if secret.Len() != 0 || string(held) != strings.Repeat("\x00", len(held)) {
+		t.Errorf("secret was not wiped")
+	}
+
+	//decrypted secrets match their string counterparts
+	encryptedHexString, err := GetEncryptedHexString("topSecretKey", "property is theft")
+	if err != nil {
+		t.Errorf("err encrypting: %v", err)
+	}
+	decrypted, err := DecryptSecret("topSecretKey", encryptedHexString, "")
+	if err != nil || string(decrypted.Bytes()) != "property is theft" {
+		t.Errorf("bad secret decryption")
+	}
+	decrypted.Wipe()
+
+	if _, err := DecryptSecret("wrongKey", encryptedHexString, ""); err == nil {
+		t.Errorf("secret decrypted with the wrong key")
+	}
+	if _, err := DecryptSecret("topSecretKey", "00", ""); err == nil {
+		t.Errorf("short ciphertext does not produce an error")
+	}
+}
+
+func TestBoundEncryption(t *testing.T) {
+	testSharedEncryptionKey := "topSecretKey"
+	secretMessage := "property is theft"
+
+	encryptedHexString, err := GetEncryptedBoundHexString(LegacyCipherSuite, testSharedEncryptionKey, secretMessage, "alice/record1")
+	if err != nil {
+		t.Errorf("err encrypting: %v", err)
+	}
+	decrypted, err := ReadDecryptedBound(testSharedEncryptionKey, encryptedHexString, "alice/record1")
+	if err != nil || decrypted != secretMessage {
+		t.Errorf("bad bound decryption")
+	}
+
+	//ciphertexts moved to another location fail to decrypt
+	if _, err := ReadDecryptedBound(testSharedEncryptionKey, encryptedHexString, "alice/record2"); err == nil {
+		t.Errorf("bound ciphertext decrypted with the wrong associated data")
+	}
+	if _, err := ReadDecrypted(testSharedEncryptionKey, encryptedHexString); err == nil {
+		t.Errorf("bound ciphertext decrypted without associated data")
+	}
+
+	//ciphertexts sealed before associated data was supported are refused where associated
+	//  data is expected, and only read to be resealed by a migration
+	legacy := getLegacyEncryptedHexString(testSharedEncryptionKey, secretMessage)
+	if _, err := ReadDecryptedBound(testSharedEncryptionKey, legacy, "alice/record1"); err != ErrLegacyCiphertext {
+		t.Errorf("legacy ciphertext read where associated data is expected produced %v", err)
+	}
+	legacySecret, err := DecryptLegacySecret(testSharedEncryptionKey, legacy, "alice/record1")
+	if err != nil || string(legacySecret.Bytes()) != secretMessage {
+		t.Errorf("bad legacy decryption")
+	}
+	legacySecret.Wipe()
+	decrypted, err = ReadDecrypted(testSharedEncryptionKey, legacy)
+	if err != nil || decrypted != secretMessage {
+		t.Errorf("bad legacy decryption without associated data")
+	}
+}
+
+//seal with NaCl secretbox as ciphertexts were sealed before associated data was supported
+func getLegacyEncryptedHexString(hashInput, unencryptedString string) string {
+
+	var key [32]byte
+	copy(key[:], getHash(hashInput))
+
+	var nonce [nonceSize]byte
+	copy(nonce[:], getHash("legacyNonce"))
+
+	sealed := box.SealAfterPrecomputation(nonce[:], []byte(unencryptedString), &nonce, &key)
+	return bytes2HexString(sealed)
+}
+
+func TestCiphertextErrors(t *testing.T) {
+	testSharedEncryptionKey := "topSecretKey"
+
+	encryptedHexString, err := GetEncryptedHexString(testSharedEncryptionKey, "property is theft")
+	if err != nil {
+		t.Errorf("err encrypting: %v", err)
+	}
+	legacy := getLegacyEncryptedHexString(testSharedEncryptionKey, "property is theft")
+
+	//flip the last hex character of a ciphertext
+	tamper := func(encrypted string) string {
+		last := encrypted[len(encrypted)-1:]
+		if last == "0" {
+			return encrypted[:len(encrypted)-1] + "1"
+		}
+		return encrypted[:len(encrypted)-1] + "0"
+	}
+
+	cases := []struct {
+		encrypted string
+		expected  error
+	}{
+		{"", ErrShortCiphertext},
+		{aeadPrefix, ErrShortCiphertext},
+		{"00", ErrShortCiphertext},
+		{aeadPrefix + strings.Repeat("00", nonceSize+15), ErrShortCiphertext},
+		{"zz", ErrMalformedCiphertext},
+		{"abc", ErrMalformedCiphertext},
+		{aeadPrefix + aeadPrefix + legacy, ErrMalformedCiphertext},
+		{tamper(encryptedHexString), ErrTamperedCiphertext},
+		{tamper(legacy), ErrTamperedCiphertext},
+		{encryptedHexString[:len(encryptedHexString)-2], ErrTamperedCiphertext},
+	}
+
+	for i, c := range cases {
+		_, err := ReadDecrypted(testSharedEncryptionKey, c.encrypted)
+		if err != c.expected {
+			t.Errorf("case %d expected %v but got %v", i, c.expected, err)
+		}
+	}
+
+	//wrapped keys are parsed with the same errors
+	_, privateKey := GetBoxKeyPair("alice/alicePassword/boxKey")
+	if _, err := UnwrapKey(privateKey, "zz"); err != ErrMalformedCiphertext {
+		t.Errorf("malformed wrapped key produced %v", err)
+	}
+	if _, err := UnwrapKey(privateKey, "00"); err != ErrShortCiphertext {
+		t.Errorf("short wrapped key produced %v", err)
+	}
+}
+
+func TestCipherSuites(t *testing.T) {
+	testSharedEncryptionKey := "topSecretKey"
+	secretMessage := "property is theft"
+
+	//ciphertexts are prefixed by their suite and read regardless of the current suite
+	for _, id := range CipherSuiteIDs() {
+		suite, err := GetCipherSuite(id)
+		if err != nil {
+			t.Errorf("err retrieving suite %v: %v", id, err)
+			continue
+		}
+
+		encryptedHexString, err := GetEncryptedBoundHexString(suite, testSharedEncryptionKey, secretMessage, "alice/record1")
+		if err != nil {
+			t.Errorf("err encrypting with %v: %v", id, err)
+		}
+		if !strings.HasPrefix(encryptedHexString, suite.Prefix()) {
+			t.Errorf("%v ciphertext is not prefixed by its suite", id)
+		}
+		decrypted, err := ReadDecryptedBound(testSharedEncryptionKey, encryptedHexString, "alice/record1")
+		if err != nil || decrypted != secretMessage {
+			t.Errorf("bad %v decryption", id)
+		}
+		if _, err := ReadDecryptedBound(testSharedEncryptionKey, encryptedHexString, "alice/record2"); err != ErrTamperedCiphertext {
+			t.Errorf("%v ciphertext decrypted with the wrong associated data", id)
+		}
+	}
+
+	//the suites derive different keys from the same hash input
+	if LegacyCipherSuite.Hash([]byte(testSharedEncryptionKey)) == Argon2idCipherSuite.Hash([]byte(testSharedEncryptionKey)) {
+		t.Errorf("suites derive the same key")
+	}
+
+	//master passwords are only stretched by the argon2id suite, salted by the username
+	if LegacyCipherSuite.KDF("masterPwd", "alice") != "masterPwd" {
+		t.Errorf("legacy suite stretches the master password")
+	}
+	stretched := Argon2idCipherSuite.KDF("masterPwd", "alice")
+	if stretched == "masterPwd" || stretched != Argon2idCipherSuite.KDF("masterPwd", "alice") {
+		t.Errorf("bad argon2id stretch")
+	}
+	if stretched == Argon2idCipherSuite.KDF("masterPwd", "bob") {
+		t.Errorf("argon2id stretch is not salted")
+	}
+
+	if _, err := GetCipherSuite("rot13"); err != ErrUnknownCipherSuite {
+		t.Errorf("unknown suite produced %v", err)
+	}
+
+	//suites may not share an identifier or prefix, or be prefixed by a hex character
+	if RegisterCipherSuite(LegacyCipherSuite) == nil {
+		t.Errorf("re-registering a suite does not produce an error")
+	}
+	if RegisterCipherSuite(hexPrefixedSuite{}) == nil {
+		t.Errorf("registering a hex prefixed suite does not produce an error")
+	}
+}
+
+//a suite which may not be registered as its prefix is a hex character
+type hexPrefixedSuite struct{ legacySuite }
+
+func (hexPrefixedSuite) ID() string     { return "hexPrefixed" }
+func (hexPrefixedSuite) Prefix() string { return "a" }
+
+//decrypting arbitrary input must never panic, any failure is one of the ciphertext errors
+func FuzzReadDecrypted(f *testing.F) {
+
+	encryptedHexString, err := GetEncryptedHexString("topSecretKey", "property is theft")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add("topSecretKey", encryptedHexString)
+	f.Add("topSecretKey", getLegacyEncryptedHexString("topSecretKey", "property is theft"))
+	f.Add("topSecretKey", encryptedHexString[:len(encryptedHexString)/2])
+	f.Add("", "")
+	f.Add("", aeadPrefix)
+	f.Add("topSecretKey", "zz")
+
+	f.Fuzz(func(t *testing.T, hashInput, encrypted string) {
+		_, err := ReadDecrypted(hashInput, encrypted)
+		if err != nil &&
+			err != ErrMalformedCiphertext &&
+			err != ErrShortCiphertext &&
+			err != ErrTamperedCiphertext {
+			t.Errorf("unexpected error type: %v", err)
+		}
+	})
+}
@@ -0,0 +1,283 @@
+//password generation backed by crypto/rand
+package crypto
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	GenModeRandom        string = "random"
+	GenModePronounceable string = "pronounceable"
+	GenModeDiceware      string = "diceware"
+)
+
+//character classes, note that "/" and "?" are excluded from
+//  the symbols as they hold special meaning within URLs
+const (
+	genLower      string = "abcdefghijklmnopqrstuvwxyz"
+	genUpper      string = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	genDigits     string = "0123456789"
+	genSymbols    string = "!@#$^&*()-_=+[]{};:,.<>~"
+	genConsonants string = "bcdfghjklmnprstvwz"
+	genVowels     string = "aeiou"
+)
+
+type PasswordPolicy struct {
+	Mode      string //one of GenModeRandom, GenModePronounceable, or GenModeDiceware
+	Length    int    //number of characters, or number of words for diceware
+	Lower     bool   //character classes which must each appear at least once,
+	Upper     bool   //  pronounceable passwords only honour Upper and Digits,
+	Digits    bool   //  diceware passphrases only honour Upper and Digits
+	Symbols   bool
+	Separator string //seperator placed between diceware words
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		Mode:      GenModeRandom,
+		Length:    20,
+		Lower:     true,
+		Upper:     true,
+		Digits:    true,
+		Symbols:   true,
+		Separator: ".",
+	}
+}
+
+//parse a comma seperated policy specification such as "diceware,6" or "random,32,nosymbols"
+//  tokens are either a mode, a length, or a character class prefixed with "no" to disable it.
+//  any options which are not specified are left at their default values
+func ParsePasswordPolicy(spec string) (policy PasswordPolicy, err error) {
+
+	policy = DefaultPasswordPolicy()
+	lengthSet := false
+
+	for _, token := range strings.Split(spec, ",") {
+		switch token {
+		case "":
+			continue
+		case GenModeRandom, GenModePronounceable, GenModeDiceware:
+			policy.Mode = token
+		case "nolower":
+			policy.Lower = false
+		case "noupper":
+			policy.Upper = false
+		case "nodigits":
+			policy.Digits = false
+		case "nosymbols":
+			policy.Symbols = false
+		default:
+			policy.Length, err = strconv.Atoi(token)
+			if err != nil || policy.Length < 1 || policy.Length > 1024 {
+				err = errors.New("invalid password policy option " + token)
+				return
+			}
+			lengthSet = true
+		}
+	}
+
+	if !lengthSet {
+		switch policy.Mode {
+		case GenModePronounceable:
+			policy.Length = 14
+		case GenModeDiceware:
+			policy.Length = 6
+		}
+	}
+
+	return
+}
+
+//generate a new password which satisfies the policy
+func GeneratePassword(policy PasswordPolicy) (string, error) {
+
+	switch policy.Mode {
+	case GenModeRandom:
+		return generateRandom(policy)
+	case GenModePronounceable:
+		return generatePronounceable(policy)
+	case GenModeDiceware:
+		return generateDiceware(policy)
+	default:
+		return "", errors.New("invalid generator mode")
+	}
+}
+
+func generateRandom(policy PasswordPolicy) (string, error) {
+
+	var classes []string
+	if policy.Lower {
+		classes = append(classes, genLower)
+	}
+	if policy.Upper {
+		classes = append(classes, genUpper)
+	}
+	if policy.Digits {
+		classes = append(classes, genDigits)
+	}
+	if policy.Symbols {
+		classes = append(classes, genSymbols)
+	}
+
+	if len(classes) < 1 {
+		return "", errors.New("no character classes selected")
+	}
+	if policy.Length < len(classes) {
+		return "", errors.New("password length too short for selected character classes")
+	}
+
+	password := make([]byte, policy.Length)
+
+	//place one character of each class, then fill the rest from all classes
+	for i, class := range classes {
+		c, err := randomChar(class)
+		if err != nil {
+			return "", err
+		}
+		password[i] = c
+	}
+	all := strings.Join(classes, "")
+	for i := len(classes); i < policy.Length; i++ {
+		c, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		password[i] = c
+	}
+
+	err := shuffle(password)
+	if err != nil {
+		return "", err
+	}
+
+	return string(password), nil
+}
+
+func generatePronounceable(policy PasswordPolicy) (string, error) {
+
+	if policy.Length < 4 {
+		return "", errors.New("password length too short for pronounceable mode")
+	}
+
+	//alternate consonants and vowels
+	password := make([]byte, policy.Length)
+	for i := range password {
+		class := genConsonants
+		if i%2 == 1 {
+			class = genVowels
+		}
+		c, err := randomChar(class)
+		if err != nil {
+			return "", err
+		}
+		password[i] = c
+	}
+
+	if policy.Upper {
+		password[0] = strings.ToUpper(string(password[0]))[0]
+	}
+
+	//digits are placed at the end so the password remains pronounceable
+	if policy.Digits {
+		for i := policy.Length - 2; i < policy.Length; i++ {
+			c, err := randomChar(genDigits)
+			if err != nil {
+				return "", err
+			}
+			password[i] = c
+		}
+	}
+
+	return string(password), nil
+}
+
+func generateDiceware(policy PasswordPolicy) (string, error) {
+
+	if policy.Length < 1 {
+		return "", errors.New("passphrase must contain at least one word")
+	}
+
+	words := make([]string, policy.Length)
+	for i := range words {
+		n, err := randomInt(len(dicewareWordList))
+		if err != nil {
+			return "", err
+		}
+		words[i] = dicewareWordList[n]
+
+		if policy.Upper {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+
+	if policy.Digits {
+		c, err := randomChar(genDigits)
+		if err != nil {
+			return "", err
+		}
+		words[len(words)-1] = words[len(words)-1] + string(c)
+	}
+
+	return strings.Join(words, policy.Separator), nil
+}
+
+//characters of recovery codes, excludes characters easily confused with one another
+const recoveryCodeChars string = "abcdefghjkmnpqrstuvwxyz23456789"
+
+//generate one-time account recovery codes, each of four dash-seperated groups of five characters
+func GenerateRecoveryCodes(n int) (codes []string, err error) {
+
+	for i := 0; i < n; i++ {
+		code := make([]byte, 0, 23)
+		for j := 0; j < 20; j++ {
+			if j > 0 && j%5 == 0 {
+				code = append(code, '-')
+			}
+
+			var c byte
+			c, err = randomChar(recoveryCodeChars)
+			if err != nil {
+				return
+			}
+			code = append(code, c)
+		}
+		codes = append(codes, string(code))
+	}
+	return
+}
+
+//return a uniformly distributed integer within [0, max)
+func randomInt(max int) (int, error) {
+
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, err
+	}
+	return int(n.Int64()), nil
+}
+
+func randomChar(class string) (byte, error) {
+
+	n, err := randomInt(len(class))
+	if err != nil {
+		return 0, err
+	}
+	return class[n], nil
+}
+
+//Fisher-Yates shuffle
+func shuffle(b []byte) error {
+
+	for i := len(b) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return err
+		}
+		b[i], b[j] = b[j], b[i]
+	}
+	return nil
+}
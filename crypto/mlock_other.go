@@ -0,0 +1,17 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package crypto
+
+import (
+	"errors"
+)
+
+//memory locking is unsupported on this OS, secrets are still wiped after use
+func lockMemory(b []byte) error {
+	return errors.New("memory locking unsupported")
+}
+
+func unlockMemory(b []byte) error {
+	return nil
+}
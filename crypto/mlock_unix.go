@@ -0,0 +1,17 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package crypto
+
+import (
+	"syscall"
+)
+
+//lock the pages of a buffer into memory so that they are never swapped to disk
+func lockMemory(b []byte) error {
+	return syscall.Mlock(b)
+}
+
+func unlockMemory(b []byte) error {
+	return syscall.Munlock(b)
+}
@@ -0,0 +1,227 @@
+//one-time password generation (RFC 4226 HOTP and RFC 6238 TOTP)
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type OTPKey struct {
+	Type      string //either "totp" or "hotp"
+	Secret    []byte
+	Algorithm string //one of SHA1, SHA256, or SHA512
+	Digits    int
+	Period    int64  //seconds each totp code is valid for
+	Counter   uint64 //moving factor for hotp codes
+}
+
+//parse either an otpauth:// URI or a bare base32 secret (treated as default totp)
+func ParseOTPKey(input string) (key OTPKey, err error) {
+
+	key = OTPKey{
+		Type:      "totp",
+		Algorithm: "SHA1",
+		Digits:    6,
+		Period:    30,
+	}
+
+	if !strings.HasPrefix(input, "otpauth://") {
+		key.Secret, err = decodeOTPSecret(input)
+		return
+	}
+
+	var u *url.URL
+	u, err = url.Parse(input)
+	if err != nil {
+		return
+	}
+
+	key.Type = strings.ToLower(u.Host)
+	if key.Type != "totp" && key.Type != "hotp" {
+		err = errors.New("invalid otp type")
+		return
+	}
+
+	q := u.Query()
+
+	key.Secret, err = decodeOTPSecret(q.Get("secret"))
+	if err != nil {
+		return
+	}
+
+	if algorithm := q.Get("algorithm"); len(algorithm) > 0 {
+		key.Algorithm = strings.ToUpper(algorithm)
+	}
+	if digits := q.Get("digits"); len(digits) > 0 {
+		key.Digits, err = strconv.Atoi(digits)
+		if err != nil || key.Digits < 6 || key.Digits > 10 {
+			err = errors.New("invalid otp digits")
+			return
+		}
+	}
+	if period := q.Get("period"); len(period) > 0 {
+		key.Period, err = strconv.ParseInt(period, 10, 64)
+		if err != nil || key.Period < 1 {
+			err = errors.New("invalid otp period")
+			return
+		}
+	}
+	if counter := q.Get("counter"); len(counter) > 0 {
+		key.Counter, err = strconv.ParseUint(counter, 10, 64)
+		if err != nil {
+			err = errors.New("invalid otp counter")
+			return
+		}
+	}
+
+	_, err = getOTPHash(key.Algorithm)
+	return
+}
+
+//return the otpauth:// URI with its counter parameter replaced, used to advance hotp keys
+func SetOTPCounter(input string, counter uint64) (string, error) {
+
+	u, err := url.Parse(input)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Set("counter", strconv.FormatUint(counter, 10))
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+//generate the hotp code for a given counter value
+func GenerateHOTP(key OTPKey, counter uint64) (string, error) {
+
+	hashFnc, err := getOTPHash(key.Algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	var counterBytes [8]byte
+	binary.BigEndian.PutUint64(counterBytes[:], counter)
+
+	mac := hmac.New(hashFnc, key.Secret)
+	mac.Write(counterBytes[:])
+	sum := mac.Sum(nil)
+
+	//dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	binCode := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	var modulo uint64 = 1
+	for i := 0; i < key.Digits; i++ {
+		modulo *= 10
+	}
+
+	code := strconv.FormatUint(uint64(binCode)%modulo, 10)
+	for len(code) < key.Digits {
+		code = "0" + code
+	}
+
+	return code, nil
+}
+
+//generate the totp code for a given time along with the remaining validity of the code
+func GenerateTOTP(key OTPKey, t time.Time) (code string, remaining time.Duration, err error) {
+
+	unix := t.Unix()
+	code, err = GenerateHOTP(key, uint64(unix/key.Period))
+	remaining = time.Duration(key.Period-unix%key.Period) * time.Second
+
+	return
+}
+
+//verify a totp code against the codes of the time step of t, and of the time steps
+//  within skew steps either side of t to allow for clock drift
+func VerifyTOTP(key OTPKey, code string, t time.Time, skew int64) bool {
+
+	_, valid := VerifyTOTPAfter(key, code, t, skew, -1)
+	return valid
+}
+
+//verify a totp code as VerifyTOTP, accepting only time steps later than lastStep so
+//  that a code may not be reused. The time step of the accepted code is returned
+func VerifyTOTPAfter(key OTPKey, code string, t time.Time, skew, lastStep int64) (step int64, valid bool) {
+
+	current := t.Unix() / key.Period
+	for i := -skew; i <= skew; i++ {
+		if current+i < 0 || current+i <= lastStep {
+			continue
+		}
+		expected, err := GenerateHOTP(key, uint64(current+i))
+		if err != nil {
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return current + i, true
+		}
+	}
+	return
+}
+
+//generate a random base32 otp secret of 160 bits as recommended by RFC 4226
+func GenerateOTPSecret() (string, error) {
+
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret), nil
+}
+
+//return the otpauth:// URI of a default totp secret for enrollment within an authenticator app
+func GetTOTPURI(issuer, accountName, secret string) string {
+
+	u := url.URL{
+		Scheme: "otpauth",
+		Host:   "totp",
+		Path:   "/" + issuer + ":" + accountName,
+	}
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+func decodeOTPSecret(secret string) ([]byte, error) {
+
+	secret = strings.ToUpper(strings.Replace(secret, " ", "", -1))
+	secret = strings.TrimRight(secret, "=")
+	if len(secret) < 1 {
+		return nil, errors.New("missing otp secret")
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+}
+
+func getOTPHash(algorithm string) (func() hash.Hash, error) {
+
+	switch algorithm {
+	case "SHA1":
+		return sha1.New, nil
+	case "SHA256":
+		return sha256.New, nil
+	case "SHA512":
+		return sha512.New, nil
+	default:
+		return nil, errors.New("invalid otp algorithm")
+	}
+}
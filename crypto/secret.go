@@ -0,0 +1,161 @@
+//secret handling, decrypted secrets and derived keys are held within byte slices
+//  which are zeroed after use rather than within immutable strings
+package crypto
+
+import (
+	"encoding/hex"
+	"runtime"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+//zero a byte slice holding a secret or key
+func Wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	runtime.KeepAlive(b)
+}
+
+//a secret held within a buffer which is locked into memory (where the OS allows) so
+//  that it is never swapped to disk, and which is zeroed once wiped
+type Secret struct {
+	b      []byte
+	locked bool
+}
+
+//allocate an empty secret with the capacity for n bytes
+func NewSecret(n int) *Secret {
+
+	s := &Secret{b: make([]byte, 0, n)}
+	if n > 0 {
+		s.locked = lockMemory(s.b[:n]) == nil
+	}
+	return s
+}
+
+//move the bytes into a new secret, the input bytes are wiped
+func NewSecretFromBytes(b []byte) *Secret {
+
+	s := NewSecret(len(b))
+	s.b = append(s.b, b...)
+	Wipe(b)
+	return s
+}
+
+//the bytes of the secret, valid until the secret is wiped
+func (s *Secret) Bytes() []byte {
+	if s == nil {
+		return nil
+	}
+	return s.b
+}
+
+func (s *Secret) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.b)
+}
+
+//append to the secret, when the capacity is exceeded the secret is moved to a
+//  larger locked buffer and the previous buffer is wiped
+func (s *Secret) Append(b []byte) {
+
+	if len(s.b)+len(b) > cap(s.b) {
+		grown := NewSecret(2*cap(s.b) + len(b))
+		grown.b = append(grown.b, s.b...)
+		s.Wipe()
+		*s = *grown
+	}
+	s.b = append(s.b, b...)
+}
+
+func (s *Secret) AppendString(str string) {
+	s.Append([]byte(str))
+}
+
+//secrets are never printed, a deliberate conversion of Bytes is required
+func (s *Secret) String() string {
+	return "<secret>"
+}
+
+//zero and unlock the secret, a nil secret is ignored
+func (s *Secret) Wipe() {
+
+	if s == nil {
+		return
+	}
+
+	full := s.b[:cap(s.b)]
+	Wipe(full)
+	if s.locked && len(full) > 0 {
+		unlockMemory(full)
+		s.locked = false
+	}
+	s.b = s.b[:0]
+}
+
+//derive the symmetric key of the hashed value of hashInput, the key is
+//  to be wiped by the caller once used. The box and signing keys are derived
+//  with the legacy hash as their hash input holds the master password already
+//  stretched by the cipher suite of the account
+func deriveKey(hashInput string) *[32]byte {
+	return deriveSuiteKey(LegacyCipherSuite, hashInput)
+}
+
+//decrypt into a secret, the encyption key is taken as the hashed value of the input variable hashInput
+//  using the hash of the suite which sealed the ciphertext. Ciphertexts sealed before associated
+//  data was supported are refused where associated data is provided (see DecryptLegacySecret)
+func DecryptSecret(hashInput, encryptedString, associatedData string) (*Secret, error) {
+
+	ct, err := decodeCiphertext(encryptedString)
+	if err != nil {
+		return nil, err
+	}
+	return ct.open(hashInput, []byte(associatedData), false)
+}
+
+//decrypt as DecryptSecret, but reading ciphertexts sealed before associated data was supported
+//  without associated data. Only to be used to reseal ciphertexts within a cipher suite migration
+func DecryptLegacySecret(hashInput, encryptedString, associatedData string) (*Secret, error) {
+
+	ct, err := decodeCiphertext(encryptedString)
+	if err != nil {
+		return nil, err
+	}
+	return ct.open(hashInput, []byte(associatedData), true)
+}
+
+//return the encrypted hex string of a secret bound to the associated data, the encyption
+//  key is taken as the suite's hashed value of the input variable hashInput
+func EncryptSecret(suite CipherSuite, hashInput string, secret []byte, associatedData string) (string, error) {
+	return sealCiphertext(suite, hashInput, secret, []byte(associatedData))
+}
+
+//unwrap a secret which was wrapped to the public key of privateKey
+func UnwrapSecret(privateKey *[32]byte, wrappedHex string) (*Secret, error) {
+
+	wrapped, err := hex.DecodeString(wrappedHex)
+	if err != nil {
+		return nil, ErrMalformedCiphertext
+	}
+	if len(wrapped) < 32+nonceSize+box.Overhead {
+		return nil, ErrShortCiphertext
+	}
+
+	var ephemeralPublic [32]byte
+	var nonce [nonceSize]byte
+	copy(ephemeralPublic[:], wrapped[:32])
+	copy(nonce[:], wrapped[32:32+nonceSize])
+
+	secret := NewSecret(len(wrapped) - 32 - nonceSize - box.Overhead)
+	plaintext, success := box.Open(secret.b, wrapped[32+nonceSize:], &nonce, &ephemeralPublic, privateKey)
+	if !success {
+		secret.Wipe()
+		return nil, ErrTamperedCiphertext
+	}
+	secret.b = plaintext
+
+	return secret, nil
+}
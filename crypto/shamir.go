@@ -0,0 +1,159 @@
+//shamir's secret sharing over GF(256), used to split vault keys across custodians
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+)
+
+//logarithm and exponent tables of GF(256) with the generator 3
+//  and the reducing polynomial x^8 + x^4 + x^3 + x + 1
+var gfLog, gfExp [256]byte
+
+func init() {
+	var x byte = 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfLog[x] = byte(i)
+
+		//multiply by the generator 3 (x*2 xor x)
+		x2 := x << 1
+		if x&0x80 != 0 {
+			x2 ^= 0x1b
+		}
+		x = x2 ^ x
+	}
+	gfExp[255] = gfExp[0]
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])+int(gfLog[b]))%255]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])-int(gfLog[b])+255)%255]
+}
+
+//split a secret into n shares, any threshold of which reconstruct the secret
+//  each share is the x-coordinate followed by the y-coordinate of each secret byte
+func SplitSecret(secret []byte, threshold, n int) (shares [][]byte, err error) {
+
+	if threshold < 1 || threshold > n || n > 255 {
+		err = errors.New("invalid threshold or number of shares")
+		return
+	}
+	if len(secret) < 1 {
+		err = errors.New("empty secret")
+		return
+	}
+
+	shares = make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][0] = byte(i + 1)
+	}
+
+	//a random polynomial of degree threshold-1 for each byte, with the byte as the constant term
+	coefficients := make([]byte, threshold)
+	for b, secretByte := range secret {
+		coefficients[0] = secretByte
+		_, err = rand.Read(coefficients[1:])
+		if err != nil {
+			return
+		}
+
+		for _, share := range shares {
+			//evaluate the polynomial at x using horner's method
+			var y byte
+			for c := threshold - 1; c >= 0; c-- {
+				y = gfMul(y, share[0]) ^ coefficients[c]
+			}
+			share[b+1] = y
+		}
+	}
+	return
+}
+
+//reconstruct a secret from shares by interpolating each byte's polynomial at zero,
+//  note that too few shares reconstruct an incorrect secret rather than an error
+func CombineShares(shares [][]byte) (secret []byte, err error) {
+
+	if len(shares) < 1 {
+		err = errors.New("no shares")
+		return
+	}
+
+	length := len(shares[0])
+	seen := make(map[byte]bool)
+	for _, share := range shares {
+		if len(share) != length || length < 2 || share[0] == 0 {
+			err = errors.New("malformed share")
+			return
+		}
+		if seen[share[0]] {
+			err = errors.New("duplicate share")
+			return
+		}
+		seen[share[0]] = true
+	}
+
+	secret = make([]byte, length-1)
+	for j, share := range shares {
+
+		//lagrange basis polynomial of share j evaluated at zero
+		var basis byte = 1
+		for m, other := range shares {
+			if m != j {
+				basis = gfMul(basis, gfDiv(other[0], other[0]^share[0]))
+			}
+		}
+
+		for b := range secret {
+			secret[b] ^= gfMul(share[b+1], basis)
+		}
+	}
+	return
+}
+
+//split a secret string into hex encoded shares
+func GetSecretShareHexStrings(secret string, threshold, n int) (sharesHex []string, err error) {
+
+	shares, err := SplitSecret([]byte(secret), threshold, n)
+	if err != nil {
+		return
+	}
+
+	for _, share := range shares {
+		sharesHex = append(sharesHex, hex.EncodeToString(share))
+	}
+	return
+}
+
+//reconstruct a secret string from hex encoded shares
+func CombineSecretShareHexStrings(sharesHex []string) (secret string, err error) {
+
+	var shares [][]byte
+	for _, shareHex := range sharesHex {
+		var share []byte
+		share, err = hex.DecodeString(shareHex)
+		if err != nil {
+			err = errors.New("malformed share")
+			return
+		}
+		shares = append(shares, share)
+	}
+
+	secretBytes, err := CombineShares(shares)
+	if err != nil {
+		return
+	}
+	secret = string(secretBytes)
+	return
+}
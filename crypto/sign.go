@@ -0,0 +1,53 @@
+//signing functionality used to authenticate txs within the tmsp application
+package crypto
+
+import (
+	"encoding/hex"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+//derive a signing keypair deterministically from the hashed value of the input variable hashInput
+func GetSigningKeyPair(hashInput string) (publicKey ed25519.PublicKey, privateKey ed25519.PrivateKey) {
+
+	seed := deriveKey(hashInput)
+	privateKey = ed25519.NewKeyFromSeed(seed[:])
+	Wipe(seed[:])
+	publicKey = privateKey.Public().(ed25519.PublicKey)
+	return
+}
+
+func GetSigningPublicKeyHexString(hashInput string) string {
+
+	publicKey, _ := GetSigningKeyPair(hashInput)
+	return hex.EncodeToString(publicKey)
+}
+
+//return the signature of the message as a hex string
+func GetSignatureHexString(hashInput, message string) string {
+
+	_, privateKey := GetSigningKeyPair(hashInput)
+	defer Wipe(privateKey)
+	return SignHexString(privateKey, message)
+}
+
+//return the signature of the message by an already derived signing key as a hex string
+func SignHexString(privateKey ed25519.PrivateKey, message string) string {
+	return hex.EncodeToString(ed25519.Sign(privateKey, []byte(message)))
+}
+
+//verify a hex signature of the message against a hex public key
+func VerifySignatureHexString(publicKeyHex, message, signatureHex string) bool {
+
+	publicKey, err := hex.DecodeString(publicKeyHex)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return false
+	}
+
+	signature, err := hex.DecodeString(signatureHex)
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return false
+	}
+
+	return ed25519.Verify(publicKey, []byte(message), signature)
+}
@@ -0,0 +1,140 @@
+//cipher suites, each suite determines how a master password is stretched, how symmetric keys
+//  are hashed from their hash inputs, and the AEAD which seals ciphertexts. Ciphertexts are
+//  prefixed by the suite which sealed them, so they may be read regardless of the current suite
+package crypto
+
+import (
+	"crypto/cipher"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/sha3"
+)
+
+type CipherSuite interface {
+	ID() string                           //identifier stored with each account and vault
+	Prefix() string                       //single non-hex character prefixing the suite's ciphertexts
+	KDF(password, salt string) string     //stretch a master password
+	Hash(input []byte) [32]byte           //hash a hash input into a symmetric key
+	AEAD(key []byte) (cipher.AEAD, error) //the AEAD which seals ciphertexts under a key
+}
+
+//the requested suite has not been registered
+var ErrUnknownCipherSuite = errors.New("unknownCipherSuite")
+
+//the original suite, master passwords are used as provided, keys are SHA3-256 hashes, and
+//  ciphertexts are sealed with XChaCha20-Poly1305 (or NaCl secretbox before associated data was supported)
+var LegacyCipherSuite CipherSuite = legacySuite{}
+
+//master passwords are stretched with Argon2id salted by the hashed username, keys are
+//  BLAKE2b-256 hashes, and ciphertexts are sealed with XChaCha20-Poly1305
+var Argon2idCipherSuite CipherSuite = argon2idSuite{}
+
+//Argon2id parameters, the second recommended option of RFC 9106
+const argon2Time uint32 = 3
+const argon2Memory uint32 = 64 * 1024 //KiB
+const argon2Threads uint8 = 4
+
+type legacySuite struct{}
+
+func (legacySuite) ID() string                           { return "legacy" }
+func (legacySuite) Prefix() string                       { return aeadPrefix }
+func (legacySuite) KDF(password, salt string) string     { return password }
+func (legacySuite) Hash(input []byte) [32]byte           { return sha3.Sum256(input) }
+func (legacySuite) AEAD(key []byte) (cipher.AEAD, error) { return chacha20poly1305.NewX(key) }
+
+type argon2idSuite struct{}
+
+func (argon2idSuite) ID() string                           { return "argon2id" }
+func (argon2idSuite) Prefix() string                       { return "z" }
+func (argon2idSuite) Hash(input []byte) [32]byte           { return blake2b.Sum256(input) }
+func (argon2idSuite) AEAD(key []byte) (cipher.AEAD, error) { return chacha20poly1305.NewX(key) }
+
+func (argon2idSuite) KDF(password, salt string) string {
+
+	key := argon2.IDKey([]byte(password), []byte(salt), argon2Time, argon2Memory, argon2Threads, 32)
+	stretched := hex.EncodeToString(key)
+	Wipe(key)
+	return stretched
+}
+
+//registered suites by identifier and by ciphertext prefix
+var suitesMtx sync.RWMutex
+var suitesByID = make(map[string]CipherSuite)
+var suitesByPrefix = make(map[string]CipherSuite)
+
+func init() {
+	for _, suite := range []CipherSuite{LegacyCipherSuite, Argon2idCipherSuite} {
+		if err := RegisterCipherSuite(suite); err != nil {
+			panic(err)
+		}
+	}
+}
+
+//register a suite so that it may be selected and its ciphertexts read, the prefix must not be
+//  a hex character so that it cannot be confused with the ciphertext it prefixes
+func RegisterCipherSuite(suite CipherSuite) error {
+
+	prefix := suite.Prefix()
+	if len(prefix) != 1 || strings.Contains("0123456789abcdefABCDEF", prefix) {
+		return errors.New("cipher suite prefix must be a single non-hex character")
+	}
+	if len(suite.ID()) < 1 || strings.Contains(suite.ID(), "/") {
+		return errors.New("bad cipher suite identifier")
+	}
+
+	suitesMtx.Lock()
+	defer suitesMtx.Unlock()
+
+	if _, exists := suitesByID[suite.ID()]; exists {
+		return errors.New("cipher suite already registered")
+	}
+	if _, exists := suitesByPrefix[prefix]; exists {
+		return errors.New("cipher suite prefix already registered")
+	}
+
+	suitesByID[suite.ID()] = suite
+	suitesByPrefix[prefix] = suite
+	return nil
+}
+
+//retrieve a registered suite by its identifier
+func GetCipherSuite(id string) (CipherSuite, error) {
+
+	suitesMtx.RLock()
+	defer suitesMtx.RUnlock()
+
+	suite, exists := suitesByID[id]
+	if !exists {
+		return nil, ErrUnknownCipherSuite
+	}
+	return suite, nil
+}
+
+//identifiers of all the registered suites in sorted order
+func CipherSuiteIDs() (ids []string) {
+
+	suitesMtx.RLock()
+	defer suitesMtx.RUnlock()
+
+	for id := range suitesByID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return
+}
+
+//retrieve the suite of a ciphertext prefix, nil if the prefix isn't registered
+func getCipherSuiteByPrefix(prefix string) CipherSuite {
+
+	suitesMtx.RLock()
+	defer suitesMtx.RUnlock()
+
+	return suitesByPrefix[prefix]
+}
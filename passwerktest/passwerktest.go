@@ -0,0 +1,192 @@
+//This package is charged with testing passwerk in-process, an in-memory tree is wired to its
+//  reader, writer, tmsp application and UI handler. Txs broadcast by the UI are committed
+//  directly to the tmsp application, each within its own block, in place of tendermint-core
+package passwerktest
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/rigelrozanski/passwerk/audit"
+	cry "github.com/rigelrozanski/passwerk/crypto"
+	"github.com/rigelrozanski/passwerk/tmsp"
+	tre "github.com/rigelrozanski/passwerk/tree"
+	"github.com/rigelrozanski/passwerk/ui"
+)
+
+//configuration of the harness, the zero value serves the legacy cipher suite
+//  without authentication rate limiting
+type Config struct {
+	CipherSuite cry.CipherSuite //cipher suite of new accounts, legacy if nil
+	EndToEnd    bool            //only accept requests of end-to-end clients
+	AuthLimits  ui.AuthLimits   //zero limits do not limit authentication
+}
+
+//an in-process passwerk application
+type Harness struct {
+	PTW     tre.PwkTreeWriter
+	PTR     tre.PwkTreeReader
+	App     *tmsp.PasswerkTMSP
+	Handler *ui.Handler //handler of the UI, txs are committed to App
+
+	mtx sync.Mutex //txs are committed in sequence as per consensus
+	txs []string   //committed txs in order
+}
+
+func New(config Config) *Harness {
+
+	if config.CipherSuite == nil {
+		config.CipherSuite = cry.LegacyCipherSuite
+	}
+
+	h := new(Harness)
+	h.PTW, h.PTR = tre.NewMemTreePair()
+	h.App = tmsp.NewPasswerkApplication(h.PTW)
+	h.App.SetOption("cipherSuite", config.CipherSuite.ID())
+	h.Handler = ui.NewHandler(h.PTR, audit.BreachedList{}, ui.DefaultSessionIdleTimeout, ui.DefaultSessionMaxLifetime,
+		config.AuthLimits, nil, false, config.CipherSuite, false, config.EndToEnd, h.broadcast)
+	return h
+}
+
+//stop the periodic sweeps of the UI handler, the harness is not to be used once closed
+func (h *Harness) Close() {
+	h.Handler.Close()
+}
+
+/////////////////////////////////////////////
+//   Txs
+////////////////////////////////////////////
+
+//commit the tx within its own block, the error holds the log of a rejected tx
+func (h *Harness) Broadcast(tx string) error {
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	checkTxResult := h.App.CheckTx([]byte(tx))
+	if checkTxResult.IsErr() {
+		return errors.New(checkTxResult.Log)
+	}
+
+	h.App.BeginBlock(h.PTW.GetBlockHeight() + 1)
+	appendTxResult := h.App.AppendTx([]byte(tx))
+	h.App.EndBlock(h.PTW.GetBlockHeight())
+	commitResult := h.App.Commit()
+	if appendTxResult.IsErr() {
+		return errors.New(appendTxResult.Log)
+	}
+	if commitResult.IsErr() {
+		return errors.New(commitResult.Log)
+	}
+
+	h.txs = append(h.txs, tx)
+	return nil
+}
+
+//the committed txs in order
+func (h *Harness) Txs() []string {
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	return append([]string(nil), h.txs...)
+}
+
+//the app hash of the latest commit
+func (h *Harness) AppHash() []byte {
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	return h.PTW.Hash()
+}
+
+//broadcasts of the UI are answered as per the broadcast_tx_commit RPC of tendermint-core
+func (h *Harness) broadcast(tx string) string {
+
+	result := struct {
+		Code int    `json:"code"`
+		Data string `json:"data"`
+		Log  string `json:"log"`
+	}{}
+	if err := h.Broadcast(tx); err != nil {
+		result.Code, result.Log = 1, err.Error()
+	}
+
+	response, _ := json.Marshal(struct {
+		JSONRPC string        `json:"jsonrpc"`
+		ID      string        `json:"id"`
+		Result  []interface{} `json:"result"`
+		Error   string        `json:"error"`
+	}{"2.0", "", []interface{}{0, result}, ""})
+	return string(response)
+}
+
+/////////////////////////////////////////////
+//   UI Flows
+////////////////////////////////////////////
+
+//perform a request of the UI, returning the status and the page
+func (h *Harness) Do(urlPath string) (status int, page string) {
+
+	recorder := httptest.NewRecorder()
+	h.Handler.ServeHTTP(recorder, httptest.NewRequest("GET", urlPath, nil))
+
+	body, _ := ioutil.ReadAll(recorder.Body)
+	return recorder.Code, string(body)
+}
+
+//perform a UI request which is to commit a tx, the error holds the page when no tx is committed
+func (h *Harness) doTx(urlPath string) error {
+
+	committed := len(h.Txs())
+	_, page := h.Do(urlPath)
+	if len(h.Txs()) == committed {
+		return errors.New("no tx committed: " + page)
+	}
+	return nil
+}
+
+func (h *Harness) Register(username, password string) error {
+	return h.doTx("/" + path.Join("n", username, password))
+}
+
+func (h *Harness) Write(username, password, cIdName, cPassword string) error {
+	return h.doTx("/" + path.Join("w", username, password, cIdName, cPassword))
+}
+
+func (h *Harness) Delete(username, password, cIdName string) error {
+	return h.doTx("/" + path.Join("d", username, password, cIdName))
+}
+
+//the page listing the identifiers of the account
+func (h *Harness) List(username, password string) string {
+	_, page := h.Do("/" + path.Join("r", username, password))
+	return page
+}
+
+//the page holding the record of the identifier
+func (h *Harness) Read(username, password, cIdName string) string {
+	_, page := h.Do("/" + path.Join("r", username, password, cIdName))
+	return page
+}
+
+/////////////////////////////////////////////
+//   State Inspection
+////////////////////////////////////////////
+
+//accounts are registered along with their signing key
+func (h *Harness) AccountExists(username string) bool {
+	_, err := h.PTR.RetrieveSigningKey(cry.GetHashedHexString(username))
+	return err == nil
+}
+
+//whether the identifier is listed by the account
+func (h *Harness) Listed(username, password, cIdName string) bool {
+	return strings.Contains(h.List(username, password), cIdName)
+}
@@ -0,0 +1,206 @@
+//Tests the in-process harness
+package passwerktest
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rigelrozanski/passwerk/client"
+	cry "github.com/rigelrozanski/passwerk/crypto"
+	tre "github.com/rigelrozanski/passwerk/tree"
+)
+
+func TestHarness(t *testing.T) {
+
+	h := New(Config{})
+	defer h.Close()
+
+	//each harness is held in memory, independent of any other
+	other := New(Config{})
+	defer other.Close()
+	if other.AccountExists("harnessUsr") {
+		t.Errorf("harnesses share state")
+	}
+
+	if err := h.Register("harnessUsr", "harnessPwd"); err != nil {
+		t.Fatalf("registering: %v", err)
+	}
+	if !h.AccountExists("harnessUsr") || h.AccountExists("harnessUsr2") {
+		t.Errorf("bad account existence")
+	}
+	if err := h.Register("harnessUsr", "harnessPwd"); err == nil {
+		t.Errorf("registered an existing account")
+	}
+
+	//write, overwrite, read and delete a record
+	if err := h.Write("harnessUsr", "harnessPwd", "harnessID", "harnessPass"); err != nil {
+		t.Errorf("writing: %v", err)
+	}
+	if err := h.Write("harnessUsr", "harnessPwd", "harnessID", "harnessPass2"); err != nil {
+		t.Errorf("overwriting: %v", err)
+	}
+	if page := h.Read("harnessUsr", "harnessPwd", "harnessID"); !strings.Contains(page, "harnessPass2") {
+		t.Errorf("bad read page: %s", page)
+	}
+	if page := h.Read("harnessUsr", "harnessPwdd", "harnessID"); strings.Contains(page, "harnessPass2") {
+		t.Errorf("read with a mistyped password: %s", page)
+	}
+	if err := h.Write("harnessUsr", "harnessPwdd", "harnessID2", "harnessPass3"); err == nil {
+		t.Errorf("wrote with a mistyped password")
+	}
+	if !h.Listed("harnessUsr", "harnessPwd", "harnessID") {
+		t.Errorf("record not listed")
+	}
+
+	hash := h.AppHash()
+	if err := h.Delete("harnessUsr", "harnessPwd", "harnessID"); err != nil {
+		t.Errorf("deleting: %v", err)
+	}
+	if h.Listed("harnessUsr", "harnessPwd", "harnessID") || string(hash) == string(h.AppHash()) {
+		t.Errorf("record listed after deletion")
+	}
+	if err := h.Delete("harnessUsr", "harnessPwd", "harnessID"); err == nil {
+		t.Errorf("deleted a record which doesn't exist")
+	}
+
+	//txs committed through the UI are recorded in order, rejected txs are not
+	txs := h.Txs()
+	if len(txs) < 4 || strings.Split(txs[0], "/")[1] != "registering" ||
+		strings.Split(txs[len(txs)-1], "/")[1] != "deleting" {
+		t.Errorf("bad committed txs: %v", txs)
+	}
+	if err := h.Broadcast("bogus/tx"); err == nil || len(h.Txs()) != len(txs) {
+		t.Errorf("committed a bogus tx: %v", err)
+	}
+
+	//end-to-end requests are only served in end-to-end mode
+	if _, page := h.Do("/e2e/read/zz"); strings.Contains(page, "error: ") {
+		t.Errorf("served an end-to-end request outside of end-to-end mode: %s", page)
+	}
+
+	//end-to-end mode rejects requests providing the master username/password
+	e2e := New(Config{EndToEnd: true})
+	defer e2e.Close()
+	if err := e2e.Register("harnessUsr", "harnessPwd"); err == nil {
+		t.Errorf("registered through the UI in end-to-end mode")
+	}
+	if status, _ := e2e.Do("/e2e/read/zz"); status != http.StatusBadRequest {
+		t.Errorf("bad end-to-end status: %d", status)
+	}
+}
+
+//each seed determines a sequence of blocks, restarts and crashes
+func TestSimulation(t *testing.T) {
+	for _, seed := range []int64{1, 2, 3, 4} {
+		t.Run(fmt.Sprintf("seed%d", seed), func(t *testing.T) {
+			simulate(t, seed)
+		})
+	}
+}
+
+func simulate(t *testing.T, seed int64) {
+
+	rnd := rand.New(rand.NewSource(seed))
+
+	net := NewNetwork(4)
+
+	var accounts []*client.Account
+	for i := 0; i < 4; i++ {
+		accounts = append(accounts, client.NewAccount(fmt.Sprintf("simUsr%d", i), fmt.Sprintf("simPwd%d", i)))
+	}
+	cIdNames := []string{"simID0", "simID1", "simID2"}
+
+	//the state of the account as held by the first node, txs are built before the block is delivered
+	readState := func(a *client.Account) (tre.EndToEndState, bool) {
+		suiteState, _ := net.Nodes[0].PTR.ReadEndToEnd(a.SuiteRequest(), time.Now(), cry.LegacyCipherSuite)
+		if a.SetSuite(suiteState) != nil {
+			return tre.EndToEndState{}, false
+		}
+		state, err := net.Nodes[0].PTR.ReadEndToEnd(a.ReadRequest(""), time.Now(), cry.LegacyCipherSuite)
+		return state, err == nil && a.Unlock(state) == nil
+	}
+
+	//a random tx, either valid or invalid as of the state held prior to the block
+	randomTx := func() string {
+
+		a := accounts[rnd.Intn(len(accounts))]
+		cIdName := cIdNames[rnd.Intn(len(cIdNames))]
+		state, registered := readState(a)
+
+		switch op := rnd.Intn(10); {
+		case op < 2 || !registered:
+			tx, _ := a.RegisterTx(cry.LegacyCipherSuite)
+			return tx
+
+		case op < 6:
+			txs, err := a.WriteTxs(cIdName, map[string]string{tre.FieldPassword: fmt.Sprint(rnd.Int())}, state)
+			if err != nil {
+				t.Errorf("building a write: %v", err)
+			}
+			return txs[len(txs)-1] //a write of an existing record without its deletion is invalid
+
+		case op < 7:
+			tx, err := a.DeleteTx(cIdName, state)
+			if err != nil {
+				return a.DeleteAccountTx()
+			}
+			return tx
+
+		case op < 8:
+			txs, _ := a.WriteTxs(cIdName, map[string]string{tre.FieldPassword: "tampered"}, state)
+			tx := txs[len(txs)-1]
+			return tx[:len(tx)-1] + "0" //tampered signature
+
+		default:
+			return fmt.Sprintf("%x/%x/%x", rnd.Int63(), rnd.Int63(), rnd.Int63()) //garbage
+		}
+	}
+
+	genesisHash := net.Nodes[0].PTW.Hash()
+	for block := 0; block < 40; block++ {
+
+		var txs []string
+		for i := rnd.Intn(6); i > 0; i-- {
+			txs = append(txs, randomTx())
+		}
+
+		//nodes restart between blocks, or crash part way through a block
+		node := net.Nodes[rnd.Intn(len(net.Nodes))]
+		switch rnd.Intn(4) {
+		case 0:
+			node.Restart()
+		case 1:
+			node.CrashDuring(net.Height()+1, txs[:rnd.Intn(len(txs)+1)])
+		}
+
+		if _, err := net.DeliverBlock(txs); err != nil {
+			t.Fatalf("block %d: %v", net.Height(), err)
+		}
+	}
+
+	//valid txs of the sequence are applied, leaving records held by the accounts
+	records := 0
+	for _, a := range accounts {
+		if state, registered := readState(a); registered {
+			names, _ := a.CIdNames(state)
+			records += len(names)
+		}
+	}
+	if string(net.Nodes[0].PTW.Hash()) == string(genesisHash) || records < 1 {
+		t.Errorf("no simulated records were committed")
+	}
+	if _, err := net.Join(); err != nil {
+		t.Errorf("joining: %v", err)
+	}
+
+	//a node which is delivered a block the others are not is detected
+	stray, _ := accounts[0].RegisterTx(cry.LegacyCipherSuite)
+	net.Nodes[1].deliverBlock(net.Height()+1, []string{accounts[1].DeleteAccountTx()})
+	if _, err := net.DeliverBlock([]string{stray}); err == nil || !strings.Contains(err.Error(), "node 1") {
+		t.Errorf("disagreement not detected: %v", err)
+	}
+}
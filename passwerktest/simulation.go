@@ -0,0 +1,134 @@
+package passwerktest
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/rigelrozanski/passwerk/tmsp"
+	tre "github.com/rigelrozanski/passwerk/tree"
+)
+
+//a node of a simulated network, its tmsp application is backed by an in-memory db
+//  of its own which is retained across restarts
+type Node struct {
+	storage *tre.Storage
+	PTW     tre.PwkTreeWriter
+	PTR     tre.PwkTreeReader
+	App     *tmsp.PasswerkTMSP
+}
+
+func newNode() *Node {
+
+	storage, _ := tre.OpenStorage(tre.DBBackendMemDB, "", "")
+	n := &Node{storage: storage}
+	n.Restart()
+	return n
+}
+
+//restart the application from the state of its last commit, any block in progress is lost
+func (n *Node) Restart() {
+
+	tree, _ := n.storage.LoadTree(0)
+	n.PTW, n.PTR = tre.NewPwkTreePair(tree)
+	n.App = tmsp.NewPasswerkApplication(n.PTW)
+}
+
+//append the txs of the block at the height and then restart before it is committed,
+//  as per a node which crashes part way through a block
+func (n *Node) CrashDuring(height uint64, txs []string) {
+
+	n.App.BeginBlock(height)
+	for _, tx := range txs {
+		n.App.AppendTx([]byte(tx))
+	}
+	n.Restart()
+}
+
+//deliver the block at the height as per tendermint-core, returning the app hash of the
+//  commit along with the result code of each tx
+func (n *Node) deliverBlock(height uint64, txs []string) (appHash []byte, codes []string) {
+
+	//the mempool check is performed by each node, its result doesn't affect the block
+	for _, tx := range txs {
+		n.App.CheckTx([]byte(tx))
+	}
+
+	n.App.BeginBlock(height)
+	for _, tx := range txs {
+		result := n.App.AppendTx([]byte(tx))
+		codes = append(codes, fmt.Sprintf("%d: %s", result.Code, result.Log))
+	}
+	n.App.EndBlock(height)
+	return n.App.Commit().Data, codes
+}
+
+//a simulated network of nodes which are delivered the same sequence of blocks
+type Network struct {
+	Nodes     []*Node
+	blocks    [][]string //each block delivered, as replayed to nodes which join
+	appHashes [][]byte   //app hash of the commit of each block
+}
+
+func NewNetwork(nodes int) *Network {
+
+	net := new(Network)
+	for i := 0; i < nodes; i++ {
+		net.Nodes = append(net.Nodes, newNode())
+	}
+	return net
+}
+
+//the height of the last block delivered
+func (net *Network) Height() uint64 {
+	return uint64(len(net.blocks))
+}
+
+//deliver the next block to every node, the error describes the first node which
+//  disagrees with the first node on the result of a tx or the app hash of the commit
+func (net *Network) DeliverBlock(txs []string) (appHash []byte, err error) {
+
+	net.blocks = append(net.blocks, txs)
+	height := net.Height()
+
+	appHash, codes := net.Nodes[0].deliverBlock(height, txs)
+	net.appHashes = append(net.appHashes, appHash)
+	for i, node := range net.Nodes[1:] {
+		nodeHash, nodeCodes := node.deliverBlock(height, txs)
+		if err == nil {
+			err = disagreement(i+1, height, txs, codes, nodeCodes, appHash, nodeHash)
+		}
+	}
+	return
+}
+
+//add a node which replays every block delivered from genesis, the error describes
+//  the first block at which its app hash differs from the network
+func (net *Network) Join() (*Node, error) {
+
+	joined := newNode()
+	net.Nodes = append(net.Nodes, joined)
+
+	for i, txs := range net.blocks {
+		replayed, _ := joined.deliverBlock(uint64(i+1), txs)
+		if !bytes.Equal(replayed, net.appHashes[i]) {
+			return joined, fmt.Errorf("joined node disagrees on the app hash of block %d, %X expected %X",
+				i+1, replayed, net.appHashes[i])
+		}
+	}
+	return joined, nil
+}
+
+func disagreement(node int, height uint64, txs, codes, nodeCodes []string, appHash, nodeHash []byte) error {
+
+	for i := range codes {
+		if codes[i] != nodeCodes[i] {
+			return fmt.Errorf("node %d disagrees on tx %d of block %d (%s), result %q expected %q",
+				node, i, height, txs[i], nodeCodes[i], codes[i])
+		}
+	}
+	if !bytes.Equal(appHash, nodeHash) {
+		return fmt.Errorf("node %d disagrees on the app hash of block %d, %X expected %X",
+			node, height, nodeHash, appHash)
+	}
+	return nil
+}
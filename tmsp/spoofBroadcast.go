@@ -18,6 +18,9 @@ func TestspoofBroadcast(tx2SpoofBroadcast []byte, ptw tre.PwkTreeWriter) error {
 		return errors.New(checkTxResult.Log)
 	}
 
+	//each spoofed tx is broadcast within its own block
+	app.BeginBlock(ptw.GetBlockHeight() + 1)
+
 	appendTxResult := app.AppendTx(tx2SpoofBroadcast)
 
 	if appendTxResult.IsErr() {
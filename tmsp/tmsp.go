@@ -2,8 +2,12 @@
 package tmsp
 
 import (
+	"errors"
+	"strconv"
 	"strings"
+	"time"
 
+	cry "github.com/rigelrozanski/passwerk/crypto"
 	tre "github.com/rigelrozanski/passwerk/tree"
 
 	. "github.com/tendermint/go-common"
@@ -11,12 +15,14 @@ import (
 )
 
 type PasswerkTMSP struct {
-	ptw tre.PwkTreeWriter
+	ptw   tre.PwkTreeWriter
+	suite cry.CipherSuite //suite of new accounts, as output for accounts which don't exist
 }
 
 func NewPasswerkApplication(ptw tre.PwkTreeWriter) *PasswerkTMSP {
 	app := &PasswerkTMSP{
-		ptw: ptw,
+		ptw:   ptw,
+		suite: cry.LegacyCipherSuite,
 	}
 	return app
 }
@@ -26,47 +32,430 @@ func (app *PasswerkTMSP) Info() string {
 	return Fmt("Info not supported")
 }
 
-//SetOption is currently unsupported
+//SetOption sets the cipher suite of new accounts by the option "cipherSuite", which is
+//  to match the suite of the UI. Other options are currently unsupported
 func (app *PasswerkTMSP) SetOption(key, value string) (log string) {
-	return ""
+
+	if key != "cipherSuite" {
+		return ""
+	}
+	suite, err := cry.GetCipherSuite(value)
+	if err != nil {
+		return err.Error()
+	}
+	app.suite = suite
+	return "success"
+}
+
+//InitChain is currently unsupported
+func (app *PasswerkTMSP) InitChain(validators []*types.Validator) {
+}
+
+//the height of each block is recorded in the merkle state, all txs within the block
+//  measure emergency access waiting periods against this height
+func (app *PasswerkTMSP) BeginBlock(height uint64) {
+	app.ptw.SetBlockHeight(height)
+}
+
+//EndBlock does not change the validator set
+func (app *PasswerkTMSP) EndBlock(height uint64) (diffs []*types.Validator) {
+	return nil
 }
 
 //Because the tx is saved in the mempool, all tx items passed to AppendTx have already been Hashed/Encrypted
 func (app *PasswerkTMSP) AppendTx(tx []byte) types.Result {
 
-	//perform a CheckTx to prevent tx errors
-	checkTxResult := app.CheckTx(tx)
+	//perform the checks of CheckTx to prevent tx errors, the tx is applied once to a
+	//  staged writer and its modifications are only committed to the tree when accepted
+	staged, checkTxResult := app.stageTx(tx)
 	if checkTxResult.IsErr() {
 		return checkTxResult
 	}
 
-	//seperate the tx into all the parts to be written
-	parts := strings.Split(string(tx), "/")
+	err := staged.CommitStaged()
+	if err != nil {
+		return badReturn(err.Error())
+	}
 
-	//The number of parts in the TX are verified upstream within CheckTx
-	operationalOption := parts[1] //part[0] contains the timeStamp which is currently ignored (used to avoid duplicate tx submissions)
+	return types.OK
+}
+
+//apply the tx to the tree of the writer, the number of parts in the tx along with
+//  its signature are verified upstream within CheckTx
+func applyTx(ptw *tre.PwkTreeWriter, parts []string) error {
 
-	app.ptw.SetVariables(
-		parts[2], //usernameHashed,
-		parts[3], //cIdNameHashed,
-		parts[4], //mapCIdNameEncrypted
-	)
+	operationalOption := parts[1] //part[0] contains the timeStamp which is currently ignored (used to avoid duplicate tx submissions)
 
 	switch operationalOption {
+	case "registering":
+		ptw.SetVariables(parts[2], "", "") //parts[2] is usernameHashed
+		err := ptw.NewAccount(parts[3])    //parts[3] is verifierEncrypted
+		if err != nil {
+			return err
+		}
+
+		//parts[4] is the optional public key
+		if len(parts) > 4 {
+			err = ptw.PublishPublicKey(parts[2], parts[4])
+			if err != nil {
+				return err
+			}
+		}
+
+		//parts[5] is the optional signing key
+		if len(parts) > 5 {
+			err = ptw.PublishSigningKey(parts[2], parts[5])
+			if err != nil {
+				return err
+			}
+		}
+
+		//parts[6] is the optional list of recovery codes, verified upstream within CheckTx
+		if len(parts) > 6 {
+			codes, _ := tre.DecodeRecoveryCodes(parts[6])
+			err = ptw.SetRecoveryCodes(codes)
+			if err != nil {
+				return err
+			}
+		}
+
+		//parts[7] is the optional cipher suite, accounts without a suite use the legacy suite
+		if len(parts) > 7 {
+			err = ptw.SetCipherSuite(parts[7])
+			if err != nil {
+				return err
+			}
+		}
+
+	case "recovering":
+		//the lists are verified upstream within CheckTx
+		codes, _ := tre.DecodeRecoveryCodes(parts[7])
+		records, _ := tre.DecodeRekeyedRecords(parts[8])
+		wrappedKeys, _ := tre.DecodeWrappedKeys(parts[9])
+
+		ptw.SetVariables(parts[2], "", "") //parts[2] is usernameHashed
+		err := ptw.RekeyAccount(
+			parts[3],  //usedCodeHashed
+			parts[4],  //verifierEncrypted
+			parts[5],  //publicKey
+			parts[6],  //signingKey
+			parts[10], //twoFactorSecretEncrypted
+			codes,
+			records,
+			wrappedKeys,
+		)
+		if err != nil {
+			return err
+		}
+
+	case "enablingTwoFactor":
+		//the backup code list is verified upstream within CheckTx
+		backupCodesHashed, _ := tre.DecodeTwoFactorBackupCodes(parts[4])
+
+		ptw.SetVariables(parts[2], "", "") //parts[2] is usernameHashed
+		err := ptw.EnableTwoFactor(
+			parts[3], //secretEncrypted
+			backupCodesHashed,
+		)
+		if err != nil {
+			return err
+		}
+
+	case "disablingTwoFactor":
+		ptw.SetVariables(parts[2], "", "")                            //parts[2] is usernameHashed
+		err := ptw.DisableTwoFactor(cry.GetHashedHexString(parts[3])) //parts[3] is the backup code proof
+		if err != nil {
+			return err
+		}
+
+	case "usingBackupCode":
+		ptw.SetVariables(parts[2], "", "")                                  //parts[2] is usernameHashed
+		err := ptw.UseTwoFactorBackupCode(cry.GetHashedHexString(parts[3])) //parts[3] is the backup code proof
+		if err != nil {
+			return err
+		}
+
+	case "usingTwoFactorCode":
+		//the time step is verified upstream within CheckTx
+		step, _ := strconv.ParseInt(parts[3], 10, 64)
+
+		ptw.SetVariables(parts[2], "", "") //parts[2] is usernameHashed
+		err := ptw.UseTwoFactorStep(step)
+		if err != nil {
+			return err
+		}
+
+	case "migratingSuite":
+		//the record and wrapped key lists are verified upstream within CheckTx
+		records, _ := tre.DecodeRekeyedRecords(parts[6])
+		wrappedKeys, _ := tre.DecodeWrappedKeys(parts[9])
+
+		ptw.SetVariables(parts[2], "", "") //parts[2] is usernameHashed
+		err := ptw.MigrateCipherSuite(
+			parts[3], //suiteID
+			parts[4], //verifierEncrypted
+			parts[5], //twoFactorSecretEncrypted
+			records,
+		)
+		if err != nil {
+			return err
+		}
+
+		//the published keys are derived from the master password as stretched by the suite
+		err = ptw.ReplaceAccountKeys(
+			parts[7], //publicKey
+			parts[8], //signingKey
+			wrappedKeys,
+		)
+		if err != nil {
+			return err
+		}
+
+	case "migratingVaultSuite":
+		//the record list is verified upstream within CheckTx
+		records, _ := tre.DecodeRekeyedRecords(parts[5])
+
+		ptw.SetVariables(parts[2], "", "") //parts[2] is vaultHashed
+		err := ptw.MigrateCipherSuite(
+			parts[4], //suiteID
+			"-",      //vaults hold no verifier
+			"-",      //or two-factor secret
+			records,
+		)
+		if err != nil {
+			return err
+		}
+
+	case "creatingOrg":
+		err := ptw.NewOrg(
+			parts[2], //orgHashed
+			parts[3], //ownerUsernameHashed
+		)
+		if err != nil {
+			return err
+		}
+
+	case "settingRole":
+		err := ptw.SetOrgRole(
+			parts[2], //orgHashed
+			parts[3], //actorUsernameHashed
+			parts[4], //memberUsernameHashed
+			parts[5], //role
+		)
+		if err != nil {
+			return err
+		}
+
+	case "removingMember":
+		err := ptw.RemoveOrgMember(
+			parts[2], //orgHashed
+			parts[3], //actorUsernameHashed
+			parts[4], //memberUsernameHashed
+		)
+		if err != nil {
+			return err
+		}
+
+	case "addingCollection":
+		err := ptw.AddOrgCollection(
+			parts[2], //orgHashed
+			parts[3], //actorUsernameHashed
+			parts[4], //vaultHashed
+			parts[5], //readRole
+			parts[6], //writeRole
+		)
+		if err != nil {
+			return err
+		}
+
+	case "creatingVault":
+		err := ptw.NewVault(
+			parts[2], //vaultHashed
+			parts[3], //ownerUsernameHashed
+			parts[4], //wrappedKey
+		)
+		if err != nil {
+			return err
+		}
+
+	case "invitingMember":
+		err := ptw.InviteVaultMember(
+			parts[2], //vaultHashed
+			parts[3], //inviterUsernameHashed
+			parts[4], //inviteeUsernameHashed
+			parts[5], //wrappedKey
+		)
+		if err != nil {
+			return err
+		}
+
+	case "acceptingInvite":
+		err := ptw.AcceptVaultInvite(
+			parts[2], //vaultHashed
+			parts[3], //usernameHashed
+		)
+		if err != nil {
+			return err
+		}
+
+	case "revokingMember":
+		//the record and key lists are verified upstream within CheckTx
+		records, _ := tre.DecodeRekeyedRecords(parts[5])
+		keys, _ := tre.DecodeRotatedKeys(parts[6])
+
+		err := ptw.RevokeVaultMember(
+			parts[2], //vaultHashed
+			parts[3], //revokerUsernameHashed
+			parts[4], //memberUsernameHashed
+			records,
+			keys,
+		)
+		if err != nil {
+			return err
+		}
+
+	case "designatingContact":
+		//the wait is verified upstream within CheckTx
+		waitBlocks, _ := strconv.ParseUint(parts[5], 10, 64)
+		err := ptw.DesignateEmergencyContact(
+			parts[2], //vaultHashed
+			parts[3], //ownerUsernameHashed
+			parts[4], //contactUsernameHashed
+			waitBlocks,
+			parts[6], //wrappedKey
+		)
+		if err != nil {
+			return err
+		}
+
+	case "requestingAccess":
+		err := ptw.RequestEmergencyAccess(
+			parts[2], //vaultHashed
+			parts[3], //contactUsernameHashed
+		)
+		if err != nil {
+			return err
+		}
+
+	case "cancelingAccess":
+		err := ptw.CancelEmergencyAccess(
+			parts[2], //vaultHashed
+			parts[3], //ownerUsernameHashed
+			parts[4], //contactUsernameHashed
+		)
+		if err != nil {
+			return err
+		}
+
+	case "claimingAccess":
+		err := ptw.ClaimEmergencyAccess(
+			parts[2], //vaultHashed
+			parts[3], //contactUsernameHashed
+		)
+		if err != nil {
+			return err
+		}
+
+	case "splittingKey":
+		//the threshold and shares are verified upstream within CheckTx
+		threshold, _ := strconv.Atoi(parts[4])
+		shares, _ := tre.DecodeKeyShares(parts[5])
+		err := ptw.SplitVaultKey(
+			parts[2], //vaultHashed
+			parts[3], //ownerUsernameHashed
+			threshold,
+			shares,
+		)
+		if err != nil {
+			return err
+		}
+
+	case "requestingReconstruction":
+		err := ptw.RequestReconstruction(
+			parts[2], //vaultHashed
+			parts[3], //requesterUsernameHashed
+		)
+		if err != nil {
+			return err
+		}
+
+	case "approvingReconstruction":
+		err := ptw.ApproveReconstruction(
+			parts[2], //vaultHashed
+			parts[3], //approverUsernameHashed
+			parts[4], //requesterUsernameHashed
+		)
+		if err != nil {
+			return err
+		}
+
+	case "cancelingReconstruction":
+		err := ptw.CancelReconstruction(
+			parts[2], //vaultHashed
+			parts[3], //actorUsernameHashed
+		)
+		if err != nil {
+			return err
+		}
+
+	case "releasingShare":
+		err := ptw.ReleaseKeyShare(
+			parts[2], //vaultHashed
+			parts[3], //custodianUsernameHashed
+			parts[4], //requesterUsernameHashed
+			parts[5], //wrappedShare
+		)
+		if err != nil {
+			return err
+		}
+
+	case "reconstructing":
+		err := ptw.ReconstructVaultKey(
+			parts[2], //vaultHashed
+			parts[3], //requesterUsernameHashed
+			parts[4], //wrappedKey
+		)
+		if err != nil {
+			return err
+		}
+
+	case "deletingAccount":
+		ptw.SetVariables(parts[2], "", "") //parts[2] is usernameHashed
+		err := ptw.DeleteAccount()
+		if err != nil {
+			return err
+		}
+
 	case "writing":
-		err := app.ptw.NewRecord(parts[5]) //parts[6] is cPasswordEncrypted
+		ptw.SetVariables(
+			parts[2], //usernameHashed,
+			parts[3], //cIdNameHashed,
+			parts[4], //mapCIdNameEncrypted
+		)
+		err := ptw.NewRecord(parts[5]) //parts[5] is cRecordEncrypted
 		if err != nil {
-			return badReturn(err.Error())
+			return err
 		}
 
 	case "deleting":
-		err := app.ptw.DeleteRecord()
+		ptw.SetVariables(
+			parts[2], //usernameHashed,
+			parts[3], //cIdNameHashed,
+			parts[4], //mapCIdNameEncrypted
+		)
+		err := ptw.DeleteRecord()
 		if err != nil {
-			return badReturn(err.Error())
+			return err
 		}
 	}
 
-	return types.OK
+	//every tx other than a registration is signed, the signer and signature being the final
+	//  two parts, the timestamp of the signed tx is recorded so that it may not be replayed
+	if operationalOption != "registering" {
+		ptw.RecordSignedTxTime(parts[len(parts)-2], parts[0])
+	}
+
+	return nil
 }
 
 //transaction logic is verfied upstream of CheckTx within InputHandler
@@ -81,9 +470,36 @@ func (app *PasswerkTMSP) AppendTx(tx []byte) types.Result {
 //     from multiple uses on the same system.
 func (app *PasswerkTMSP) CheckTx(tx []byte) types.Result {
 
+	//the staged writer is discarded, nothing of a checked tx is written to the tree
+	_, result := app.stageTx(tx)
+	return result
+}
+
+//verify the tx and apply it to a staged writer of the tree so that every precondition
+//  of the tree is verified, a tx which would fail part way through being applied is
+//  rejected here rather than partially modifying the tree
+func (app *PasswerkTMSP) stageTx(tx []byte) (staged tre.PwkTreeWriter, result types.Result) {
+
 	//seperate the tx into all the parts to be written
 	parts := strings.Split(string(tx), "/")
 
+	result = app.verifyTx(tx, parts)
+	if result.IsErr() {
+		return
+	}
+
+	staged = app.ptw.Staged()
+	err := applyTx(&staged, parts)
+	if err != nil {
+		return staged, badReturn(err.Error())
+	}
+
+	return staged, types.OK
+}
+
+//verify the number of parts and the signature of the tx along with its preconditions
+func (app *PasswerkTMSP) verifyTx(tx []byte, parts []string) types.Result {
+
 	if len(parts) < 2 {
 		return badReturn("Invalid number of TX parts")
 	}
@@ -91,17 +507,556 @@ func (app *PasswerkTMSP) CheckTx(tx []byte) types.Result {
 	operationalOption := parts[1] //part[0] contains the timeStamp which is currently ignored (used to avoid duplicate tx submissions)
 
 	switch operationalOption {
+	case "registering":
+		if len(parts) < 4 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		app.ptw.SetVariables(parts[2], "", "")
+		if app.ptw.VerifyAccountExists() {
+			return badReturn("Account to register already exists")
+		}
+		if app.ptw.VerifyAccountRetired() {
+			return badReturn("Username of a deleted account may not be registered")
+		}
+		if len(parts) > 4 && app.ptw.VerifyPublicKeyExists(parts[2]) {
+			return badReturn("Public key already published")
+		}
+		if len(parts) > 5 {
+			if _, err := app.ptw.GetSigningKey(parts[2]); err == nil {
+				return badReturn("Signing key already published")
+			}
+		}
+		if len(parts) > 6 {
+			if _, err := tre.DecodeRecoveryCodes(parts[6]); err != nil {
+				return badReturn(err.Error())
+			}
+		}
+		if len(parts) > 7 {
+			if _, err := cry.GetCipherSuite(parts[7]); err != nil {
+				return badReturn(err.Error())
+			}
+		}
+
+	//recovery txs must be signed with the signing key derived from the previous
+	//  recovery key, which the holder of a recovery code is able to unwrap
+	case "recovering":
+		if len(parts) < 11 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 11, parts[2])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		app.ptw.SetVariables(parts[2], "", "")
+		if !app.ptw.VerifyRecoveryCodeExists(parts[3]) {
+			return badReturn("Recovery code does not exist")
+		}
+
+		if _, err := tre.DecodeRecoveryCodes(parts[7]); err != nil {
+			return badReturn(err.Error())
+		}
+		if _, err := tre.DecodeRekeyedRecords(parts[8]); err != nil {
+			return badReturn(err.Error())
+		}
+		if _, err := tre.DecodeWrappedKeys(parts[9]); err != nil {
+			return badReturn(err.Error())
+		}
+
+	//two-factor txs must be signed so that two-factor authentication may not be disabled
+	//  by anyone other than the account holder, disabling also requires an unused backup code
+	//  as the master password alone may decrypt the totp secret
+	case "enablingTwoFactor":
+		if len(parts) < 5 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 5, parts[2])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		app.ptw.SetVariables(parts[2], "", "")
+		if !app.ptw.VerifyAccountExists() {
+			return badReturn("Account does not exist")
+		}
+		if app.ptw.VerifyTwoFactorEnabled() {
+			return badReturn("Two-factor already enabled")
+		}
+		if _, err := tre.DecodeTwoFactorBackupCodes(parts[4]); err != nil {
+			return badReturn(err.Error())
+		}
+
+	case "disablingTwoFactor":
+		if len(parts) < 4 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 4, parts[2])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		app.ptw.SetVariables(parts[2], "", "")
+		if !app.ptw.VerifyTwoFactorEnabled() {
+			return badReturn("Two-factor not enabled")
+		}
+		if !app.ptw.VerifyTwoFactorBackupCodeExists(cry.GetHashedHexString(parts[3])) {
+			return badReturn("Backup code does not exist")
+		}
+
+	case "usingBackupCode":
+		if len(parts) < 4 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 4, parts[2])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		app.ptw.SetVariables(parts[2], "", "")
+		if !app.ptw.VerifyTwoFactorBackupCodeExists(cry.GetHashedHexString(parts[3])) {
+			return badReturn("Backup code does not exist")
+		}
+
+	//the time step of each accepted totp code is recorded so that codes may not be reused
+	case "usingTwoFactorCode":
+		if len(parts) < 4 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 4, parts[2])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		step, err := strconv.ParseInt(parts[3], 10, 64)
+		if err != nil || step < 0 {
+			return badReturn("Invalid totp time step")
+		}
+		app.ptw.SetVariables(parts[2], "", "")
+		if !app.ptw.VerifyTwoFactorEnabled() {
+			return badReturn("Two-factor not enabled")
+		}
+		if !app.ptw.VerifyTwoFactorStepUnused(step) {
+			return badReturn("Totp code already used")
+		}
+
+	//cipher suite migrations must be signed by the account holder, or for vaults by
+	//  the vault owner or an organization admin of a collection
+	case "migratingSuite":
+		if len(parts) < 10 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 10, parts[2])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		if _, err := cry.GetCipherSuite(parts[3]); err != nil {
+			return badReturn(err.Error())
+		}
+		records, err := tre.DecodeRekeyedRecords(parts[6])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+		if _, err := tre.DecodeWrappedKeys(parts[9]); err != nil {
+			return badReturn(err.Error())
+		}
+		app.ptw.SetVariables(parts[2], "", "")
+		err = app.ptw.VerifyMigrationCoversRecords(records)
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+	case "migratingVaultSuite":
+		if len(parts) < 6 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 6, parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		err = app.ptw.VerifyVaultAuthority(parts[2], parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		if _, err := cry.GetCipherSuite(parts[4]); err != nil {
+			return badReturn(err.Error())
+		}
+		records, err := tre.DecodeRekeyedRecords(parts[5])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+		app.ptw.SetVariables(parts[2], "", "")
+		err = app.ptw.VerifyMigrationCoversRecords(records)
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+	case "creatingOrg":
+		if len(parts) < 4 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 4, parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		app.ptw.SetVariables(parts[2], "", "")
+		if app.ptw.VerifyAccountExists() {
+			return badReturn("Organization to create already exists")
+		}
+
+	case "settingRole":
+		if len(parts) < 6 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 6, parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		if tre.OrgRoleRank(parts[5]) < 1 {
+			return badReturn("Invalid organization role")
+		}
+
+		actorRole, err := app.ptw.GetOrgRole(parts[2], parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+		if tre.OrgRoleRank(actorRole) < tre.OrgRoleRank(tre.OrgAdmin) {
+			return badReturn("Only organization owners and admins may manage members")
+		}
+
+	case "removingMember":
+		if len(parts) < 5 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 5, parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		memberRole, err := app.ptw.GetOrgRole(parts[2], parts[4])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+		if len(memberRole) < 1 {
+			return badReturn("Member to remove does not exist")
+		}
+
+	case "addingCollection":
+		if len(parts) < 7 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 7, parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		vaultStatus, err := app.ptw.GetVaultMemberStatus(parts[4], parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+		if vaultStatus != tre.VaultOwner {
+			return badReturn("Only the vault owner may add a vault as a collection")
+		}
+
+	//vault txs must be signed by the acting user, the owner of a new vault or the
+	//  member who is inviting, accepting or revoking
+	case "creatingVault":
+		if len(parts) < 5 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 5, parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		app.ptw.SetVariables(parts[2], "", "")
+		if app.ptw.VerifyAccountExists() {
+			return badReturn("Vault to create already exists")
+		}
+
+	case "invitingMember":
+		if len(parts) < 6 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 6, parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		err = app.ptw.VerifyVaultAuthority(parts[2], parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+		if !app.ptw.VerifyPublicKeyExists(parts[4]) {
+			return badReturn("Invitee has no published public key")
+		}
+
+	case "acceptingInvite":
+		if len(parts) < 4 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 4, parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		status, err := app.ptw.GetVaultMemberStatus(parts[2], parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+		if status != tre.VaultInvited {
+			return badReturn("No invite to accept")
+		}
+
+	case "revokingMember":
+		if len(parts) < 7 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 7, parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		records, err := tre.DecodeRekeyedRecords(parts[5])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+		keys, err := tre.DecodeRotatedKeys(parts[6])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+		err = app.ptw.VerifyRotationCovers(parts[2], parts[4], records, keys)
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		memberStatus, err := app.ptw.GetVaultMemberStatus(parts[2], parts[4])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+		if len(memberStatus) < 1 {
+			return badReturn("Member to revoke does not exist")
+		}
+
+	case "designatingContact":
+		if len(parts) < 7 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 7, parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		waitBlocks, err := strconv.ParseUint(parts[5], 10, 64)
+		if err != nil || waitBlocks > tre.MaxEmergencyWaitBlocks {
+			return badReturn("Invalid emergency access waiting period")
+		}
+
+		ownerStatus, err := app.ptw.GetVaultMemberStatus(parts[2], parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+		if ownerStatus != tre.VaultOwner {
+			return badReturn("Only the vault owner may designate emergency contacts")
+		}
+
+	case "requestingAccess":
+		if len(parts) < 4 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 4, parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		_, requestHeight, err := app.ptw.GetEmergencyContact(parts[2], parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+		if requestHeight > 0 {
+			return badReturn("Emergency access already requested")
+		}
+
+	case "cancelingAccess":
+		if len(parts) < 5 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 5, parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		_, _, err = app.ptw.GetEmergencyContact(parts[2], parts[4])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+	case "claimingAccess":
+		if len(parts) < 4 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 4, parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		//the waiting period is measured against the height of the current block
+		waitBlocks, requestHeight, err := app.ptw.GetEmergencyContact(parts[2], parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+		if requestHeight < 1 {
+			return badReturn("Emergency access not requested")
+		}
+		if !tre.EmergencyWaitPassed(app.ptw.GetBlockHeight(), requestHeight, waitBlocks) {
+			return badReturn("Emergency access waiting period has not passed")
+		}
+
+	case "splittingKey":
+		if len(parts) < 6 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 6, parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		if _, err := strconv.Atoi(parts[4]); err != nil {
+			return badReturn("Invalid key split threshold")
+		}
+		if _, err := tre.DecodeKeyShares(parts[5]); err != nil {
+			return badReturn(err.Error())
+		}
+
+		ownerStatus, err := app.ptw.GetVaultMemberStatus(parts[2], parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+		if ownerStatus != tre.VaultOwner {
+			return badReturn("Only the vault owner may split the vault key")
+		}
+
+	case "requestingReconstruction":
+		if len(parts) < 4 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 4, parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+	case "approvingReconstruction":
+		if len(parts) < 5 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 5, parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		err = app.ptw.VerifyVaultAuthority(parts[2], parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+	case "cancelingReconstruction":
+		if len(parts) < 4 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 4, parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+	//shares are released to the requester named by the custodian
+	case "releasingShare":
+		if len(parts) < 6 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 6, parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+	case "reconstructing":
+		if len(parts) < 5 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 5, parts[3])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+	//accounts may only be deleted by the account holder
+	case "deletingAccount":
+		if len(parts) < 3 {
+			return badReturn("Invalid number of TX parts")
+		}
+
+		err := app.verifySignedTx(string(tx), parts, 3, parts[2])
+		if err != nil {
+			return badReturn(err.Error())
+		}
+
+		app.ptw.SetVariables(parts[2], "", "")
+		if !app.ptw.VerifyAccountExists() {
+			return badReturn("Account to delete does not exist")
+		}
+
 	case "writing":
 		if len(parts) < 6 {
 			return badReturn("Invalid number of TX parts")
 		}
-		//TODO add proof-of-valid-transaction verification
+
+		app.ptw.SetVariables(parts[2], parts[3], parts[4])
+		if !app.ptw.VerifyAccountExists() {
+			return badReturn("Account to write to does not exist")
+		}
+
+		err := app.verifyVaultWriteTx(string(tx), parts, 6)
+		if err != nil {
+			return badReturn(err.Error())
+		}
 
 	case "deleting":
 		if len(parts) < 5 {
 			return badReturn("Invalid number of TX parts")
 		}
-		//TODO add proof-of-valid-transaction verification
+
+		err := app.verifyVaultWriteTx(string(tx), parts, 5)
+		if err != nil {
+			return badReturn(err.Error())
+		}
 
 		app.ptw.SetVariables(parts[2], parts[3], parts[4])
 
@@ -122,6 +1077,75 @@ func (app *PasswerkTMSP) CheckTx(tx []byte) types.Result {
 	return types.OK
 }
 
+//Signed txs append the hashed username of the signer and a signature to the
+//  unsigned tx:  <unsigned tx>/signerUsernameHashed/signature
+//  where the signature is over everything preceding the final "/". Signatures are
+//  verified against the signing key published at registration, and the signer must
+//  be the user the tx acts on behalf of. The timestamp leading a signed tx must follow
+//  that of the last signed tx of the signer so that signed txs may not be replayed.
+func (app *PasswerkTMSP) verifySignedTx(tx string, parts []string, unsignedLen int,
+	actorUsernameHashed string) error {
+
+	if len(parts) != unsignedLen+2 {
+		return errors.New("TX must be signed")
+	}
+
+	signerUsernameHashed := parts[unsignedLen]
+	if signerUsernameHashed != actorUsernameHashed {
+		return errors.New("TX must be signed by the acting user")
+	}
+
+	signingKey, err := app.ptw.GetSigningKey(signerUsernameHashed)
+	if err != nil {
+		return err
+	}
+
+	message := tx[:strings.LastIndex(tx, "/")]
+	if !cry.VerifySignatureHexString(signingKey, message, parts[unsignedLen+1]) {
+		return errors.New("Invalid TX signature")
+	}
+
+	return app.ptw.VerifySignedTxTime(signerUsernameHashed, parts[0])
+}
+
+//records of accounts may only be written or deleted by txs signed by the account holder,
+//  while records of shared vaults may only be written or deleted by txs signed by the vault
+//  owner or a member. Members of an organization collection must also hold the write role
+//  of the collection
+func (app *PasswerkTMSP) verifyVaultWriteTx(tx string, parts []string, unsignedLen int) error {
+
+	if app.ptw.VerifyIsAccount(parts[2]) {
+		return app.verifySignedTx(tx, parts, unsignedLen, parts[2])
+	}
+	if len(parts) < unsignedLen+2 {
+		return errors.New("TX must be signed")
+	}
+
+	signerUsernameHashed := parts[unsignedLen]
+	err := app.verifySignedTx(tx, parts, unsignedLen, signerUsernameHashed)
+	if err != nil {
+		return err
+	}
+
+	status, err := app.ptw.GetVaultMemberStatus(parts[2], signerUsernameHashed)
+	if err != nil {
+		return err
+	}
+	if status != tre.VaultOwner && status != tre.VaultMember {
+		return errors.New("Only vault members may write to the vault")
+	}
+
+	isCollection, role, _, writeRole, err := app.ptw.GetCollectionAccess(parts[2], signerUsernameHashed)
+	if err != nil {
+		return err
+	}
+	if isCollection && status != tre.VaultOwner && tre.OrgRoleRank(role) < tre.OrgRoleRank(writeRole) {
+		return errors.New("Organization role may not write to the collection")
+	}
+
+	return nil
+}
+
 //return the hash of the merkle tree, use locks
 func (app *PasswerkTMSP) Commit() types.Result {
 
@@ -131,9 +1155,15 @@ func (app *PasswerkTMSP) Commit() types.Result {
 	return types.NewResultOK(app.ptw.Hash(), "")
 }
 
-//currently Query is unsupported but arguably should be supported for reading_IdNames and reading_Password values for operationalOptions
+//queries are the signed read requests of end-to-end clients, answered with the encoded
+//  ciphertexts of the account or shared vault, see tree.EndToEndState
 func (app *PasswerkTMSP) Query(query []byte) types.Result {
-	return types.NewResultOK(nil, Fmt("Query is not supported"))
+
+	state, err := app.ptw.ReadEndToEnd(string(query), time.Now(), app.suite)
+	if err != nil {
+		return badReturn(err.Error())
+	}
+	return types.NewResultOK([]byte(state.Encode()), "")
 }
 
 func badReturn(log string) types.Result {
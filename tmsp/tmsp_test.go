@@ -2,37 +2,627 @@
 package tmsp
 
 import (
+	"bytes"
+	"path"
+	"strings"
 	"testing"
+	"time"
 
+	cry "github.com/rigelrozanski/passwerk/crypto"
 	tre "github.com/rigelrozanski/passwerk/tree"
 )
 
 func TestTMSP(t *testing.T) {
 
-	//inititilize DB for testing
-	pwkDb, ptw, _, err := tre.InitTestingDB()
+	//inititilize the in-memory trees for testing
+	ptw, ptr := tre.NewMemTreePair()
+	var err error
 
+	/////////////////////////////
+	// Perform a test broadcast
+	urlStringBytes := []byte("w/masterU/masterP/testID/testPass")
+
+	//note more specific scenarios of broadcast are tested as a part of ui_test.go
+	TestspoofBroadcast(urlStringBytes, ptw)
+
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	/////////////////////////////
+	// Register an account, a second registration should be rejected
+	registerTx := []byte("timeStamp/registering/testUsernameHashed/testVerifier")
+
+	err = TestspoofBroadcast(registerTx, ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	err = TestspoofBroadcast(registerTx, ptw)
+	if err == nil {
+		t.Errorf("re-registering an account does not produce an error")
+	}
+
+	//accounts may only be deleted by signed txs
+	err = TestspoofBroadcast([]byte("timeStamp/deletingAccount/testUsernameHashed"), ptw)
+	if err == nil {
+		t.Errorf("unsigned account deletion does not produce an error")
+	}
+
+	/////////////////////////////
+	// Organization txs must be signed by the acting user
+	signingKey := cry.GetSigningPublicKeyHexString("testSigningKey")
+	err = TestspoofBroadcast([]byte("timeStamp/registering/testSigner/testVerifier/testPubKey/"+signingKey), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	orgTx := stamp("timeStamp/creatingOrg/testOrgHashed/testSigner/testSigner")
+
+	err = TestspoofBroadcast([]byte(orgTx), ptw)
+	if err == nil {
+		t.Errorf("unsigned organization tx does not produce an error")
+	}
+
+	err = TestspoofBroadcast([]byte(path.Join(orgTx,
+		cry.GetSignatureHexString("wrongSigningKey", orgTx))), ptw)
+	if err == nil {
+		t.Errorf("organization tx with a bad signature does not produce an error")
+	}
+
+	err = TestspoofBroadcast([]byte(path.Join(orgTx,
+		cry.GetSignatureHexString("testSigningKey", orgTx))), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	//txs which the tree would reject are rejected by CheckTx rather than when appended
+	selfRoleTx := stamp("timeStamp/settingRole/testOrgHashed/testSigner/testSigner/member/testSigner")
+	if NewPasswerkApplication(ptw).CheckTx([]byte(path.Join(selfRoleTx,
+		cry.GetSignatureHexString("testSigningKey", selfRoleTx)))).IsOK() {
+		t.Errorf("managing your own organization role does not produce an error")
+	}
+
+	/////////////////////////////
+	// Emergency access may only be claimed once the waiting period has passed
+	contactSigningKey := cry.GetSigningPublicKeyHexString("testContactSigningKey")
+	signedTx := func(tx, signer, hashInputSigningKey string) []byte {
+		return []byte(fuzzSignedTx(tx, signer, hashInputSigningKey))
+	}
+
+	err = TestspoofBroadcast([]byte("timeStamp/registering/testContact/testVerifier/testPubKey/"+contactSigningKey), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	//vault txs must be signed by the acting user, vaults may not be created on behalf of another user
+	vaultTx := "timeStamp/creatingVault/testVaultHashed/testSigner/testWrappedKey"
+	if NewPasswerkApplication(ptw).CheckTx([]byte(vaultTx)).IsOK() {
+		t.Errorf("unsigned vault creation does not produce an error")
+	}
+	if NewPasswerkApplication(ptw).CheckTx(signedTx(vaultTx, "testContact", "testContactSigningKey")).IsOK() {
+		t.Errorf("creating a vault owned by another user does not produce an error")
+	}
+	err = TestspoofBroadcast(signedTx(vaultTx, "testSigner", "testSigningKey"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	//records of a vault may only be written by signed txs of its members
+	vaultWriteTx := "timeStamp/writing/testVaultHashed/testCIdHashed/testCIdEncrypted/testRecord"
+	if NewPasswerkApplication(ptw).CheckTx([]byte(vaultWriteTx)).IsOK() {
+		t.Errorf("unsigned vault write does not produce an error")
+	}
+	if NewPasswerkApplication(ptw).CheckTx(signedTx(vaultWriteTx, "testContact", "testContactSigningKey")).IsOK() {
+		t.Errorf("vault write by a non-member does not produce an error")
+	}
+	err = TestspoofBroadcast(signedTx(vaultWriteTx, "testSigner", "testSigningKey"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	vaultDeleteTx := "timeStamp/deleting/testVaultHashed/testCIdHashed/testCIdEncrypted"
+	if NewPasswerkApplication(ptw).CheckTx([]byte(vaultDeleteTx)).IsOK() {
+		t.Errorf("unsigned vault deletion does not produce an error")
+	}
+	err = TestspoofBroadcast(signedTx(vaultDeleteTx, "testSigner", "testSigningKey"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	//records of an account may only be written or deleted by signed txs of the account holder
+	accountWriteTx := "timeStamp/writing/testSigner/testCIdHashed/testCIdEncrypted/testRecord"
+	if NewPasswerkApplication(ptw).CheckTx([]byte(accountWriteTx)).IsOK() {
+		t.Errorf("unsigned account write does not produce an error")
+	}
+	if NewPasswerkApplication(ptw).CheckTx(signedTx(accountWriteTx, "testContact", "testContactSigningKey")).IsOK() {
+		t.Errorf("account write by another user does not produce an error")
+	}
+	err = TestspoofBroadcast(signedTx(accountWriteTx, "testSigner", "testSigningKey"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	accountDeleteTx := "timeStamp/deleting/testSigner/testCIdHashed/testCIdEncrypted"
+	if NewPasswerkApplication(ptw).CheckTx([]byte(accountDeleteTx)).IsOK() {
+		t.Errorf("unsigned account deletion does not produce an error")
+	}
+	err = TestspoofBroadcast(signedTx(accountDeleteTx, "testSigner", "testSigningKey"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	//invites may only be sent by the vault owner, and accepted by the invitee
+	inviteTx := "timeStamp/invitingMember/testVaultHashed/testSigner/testContact/testWrappedKey"
+	if NewPasswerkApplication(ptw).CheckTx([]byte(inviteTx)).IsOK() {
+		t.Errorf("unsigned invite does not produce an error")
+	}
+	err = TestspoofBroadcast(signedTx(inviteTx, "testSigner", "testSigningKey"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	acceptTx := "timeStamp/acceptingInvite/testVaultHashed/testContact"
+	if NewPasswerkApplication(ptw).CheckTx(signedTx(acceptTx, "testSigner", "testSigningKey")).IsOK() {
+		t.Errorf("accepting the invite of another user does not produce an error")
+	}
+	revokeTx := "timeStamp/revokingMember/testVaultHashed/testSigner/testContact/-/testSigner.member.testRotatedKey"
+	if NewPasswerkApplication(ptw).CheckTx([]byte(revokeTx)).IsOK() {
+		t.Errorf("unsigned revocation does not produce an error")
+	}
+	if NewPasswerkApplication(ptw).CheckTx(signedTx("timeStamp/revokingMember/testVaultHashed/testSigner/testContact/-/-",
+		"testSigner", "testSigningKey")).IsOK() {
+		t.Errorf("revocation without rotating the vault key of the remaining members does not produce an error")
+	}
+	if NewPasswerkApplication(ptw).CheckTx(signedTx("timeStamp/revokingMember/testVaultHashed/testSigner/testContact/-/"+
+		"testSigner.member.testRotatedKey,testContact.member.testRotatedKey", "testSigner", "testSigningKey")).IsOK() {
+		t.Errorf("rotating the vault key to the revoked member does not produce an error")
+	}
+	err = TestspoofBroadcast(signedTx(revokeTx, "testSigner", "testSigningKey"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	if NewPasswerkApplication(ptw).CheckTx(signedTx("timeStamp/designatingContact/testVaultHashed/testSigner/testContact/18446744073709551615/testWrappedKey",
+		"testSigner", "testSigningKey")).IsOK() {
+		t.Errorf("designating an overlong waiting period does not produce an error")
+	}
+	err = TestspoofBroadcast(signedTx("timeStamp/designatingContact/testVaultHashed/testSigner/testContact/1/testWrappedKey",
+		"testSigner", "testSigningKey"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	err = TestspoofBroadcast(signedTx("timeStamp/requestingAccess/testVaultHashed/testContact",
+		"testContact", "testContactSigningKey"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	claimTx := signedTx("timeStamp/claimingAccess/testVaultHashed/testContact", "testContact", "testContactSigningKey")
+	if NewPasswerkApplication(ptw).CheckTx(claimTx).IsOK() {
+		t.Errorf("claiming emergency access before the waiting period does not produce an error")
+	}
+
+	//pass a block, after which the waiting period is over
+	err = TestspoofBroadcast([]byte("timeStamp/registering/testBlockPasser/testVerifier"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	err = TestspoofBroadcast(claimTx, ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	/////////////////////////////
+	// Two-factor txs must be signed by the account holder, and backup codes may only be used once
+	//backup codes are proven by the preimage of the stored code
+	backupCodes := cry.GetHashedHexString("testCode1") + ";" + cry.GetHashedHexString("testCode2")
+	if NewPasswerkApplication(ptw).CheckTx([]byte("timeStamp/enablingTwoFactor/testSigner/testSecret/" + backupCodes)).IsOK() {
+		t.Errorf("unsigned two-factor tx does not produce an error")
+	}
+	err = TestspoofBroadcast(signedTx("timeStamp/enablingTwoFactor/testSigner/testSecret/"+backupCodes,
+		"testSigner", "testSigningKey"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	useCodeTx := signedTx("timeStamp/usingBackupCode/testSigner/testCode1", "testSigner", "testSigningKey")
+	err = TestspoofBroadcast(useCodeTx, ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	if NewPasswerkApplication(ptw).CheckTx(signedTx("timeStamp/usingBackupCode/testSigner/testCode1",
+		"testSigner", "testSigningKey")).IsOK() {
+		t.Errorf("re-using a backup code does not produce an error")
+	}
+	if NewPasswerkApplication(ptw).CheckTx(signedTx("timeStamp/usingBackupCode/testSigner/"+
+		cry.GetHashedHexString("testCode2"), "testSigner", "testSigningKey")).IsOK() {
+		t.Errorf("using a stored backup code as its own proof does not produce an error")
+	}
+
+	//totp codes may only be used once, and not after a later code
+	err = TestspoofBroadcast(signedTx("timeStamp/usingTwoFactorCode/testSigner/100", "testSigner", "testSigningKey"), ptw)
 	if err != nil {
 		t.Errorf(err.Error())
 	}
+	for _, step := range []string{"100", "99"} {
+		if NewPasswerkApplication(ptw).CheckTx(signedTx("timeStamp/usingTwoFactorCode/testSigner/"+step,
+			"testSigner", "testSigningKey")).IsOK() {
+			t.Errorf("re-using the totp time step %v does not produce an error", step)
+		}
+	}
+	if NewPasswerkApplication(ptw).CheckTx(signedTx("timeStamp/usingTwoFactorCode/testContact/100",
+		"testContact", "testContactSigningKey")).IsOK() {
+		t.Errorf("using a totp code without two-factor enabled does not produce an error")
+	}
 
-	//remove the testing db before exit
-	defer func() {
-		err = tre.DeleteTestingDB(pwkDb)
+	//signed txs may not be replayed, nor signed with a timestamp preceding the last signed tx
+	if NewPasswerkApplication(ptw).CheckTx(useCodeTx).IsOK() {
+		t.Errorf("replaying a signed tx does not produce an error")
+	}
+	staleTx := "timeStamp/writing/testSigner/testCIdHashed/testCIdEncrypted/testRecord"
+	staleTx = time.Now().Add(-time.Hour).UTC().Format(tre.TxTimeFormat) + strings.TrimPrefix(staleTx, "timeStamp")
+	if NewPasswerkApplication(ptw).CheckTx(signedTx(staleTx, "testSigner", "testSigningKey")).IsOK() {
+		t.Errorf("signed tx preceding the last signed tx does not produce an error")
+	}
+
+	//recovering an enrolled account must reseal its two-factor secret, the unused backup codes are kept
+	ptw.SetVariables("testSigner", "", "")
+	ptw.SetRecoveryCodes([]tre.RecoveryCode{{CodeHashed: "testCodeHashed", RecoveryKeyEncrypted: "testRecoveryKey"}})
+	recoveryTx := "timeStamp/recovering/testSigner/testCodeHashed/testVerifier/testPubKey/" + signingKey + "/-/-/-/"
+	if NewPasswerkApplication(ptw).CheckTx(signedTx(recoveryTx+"-", "testSigner", "testSigningKey")).IsOK() {
+		t.Errorf("recovery dropping the two-factor secret does not produce an error")
+	}
+	err = TestspoofBroadcast(signedTx(recoveryTx+"testSecret2", "testSigner", "testSigningKey"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	ptr.SetVariables("testSigner", "", "", "")
+	if secretEncrypted, err := ptr.RetrieveTwoFactorSecret(); err != nil || secretEncrypted != "testSecret2" ||
+		!ptr.VerifyTwoFactorBackupCode(cry.GetHashedHexString("testCode2")) ||
+		ptr.VerifyTwoFactorBackupCode(cry.GetHashedHexString("testCode1")) {
+		t.Errorf("the two-factor enrollment was not carried over by the recovery")
+	}
+	if ptr.RetrieveTwoFactorStep() != 100 {
+		t.Errorf("the last totp time step was not carried over by the recovery")
+	}
 
-		if err != nil {
-			t.Errorf("err deleting testing DB: ", err.Error())
+	//disabling two-factor authentication consumes an unused backup code
+	if NewPasswerkApplication(ptw).CheckTx(signedTx("timeStamp/disablingTwoFactor/testSigner/testCode2",
+		"testContact", "testContactSigningKey")).IsOK() {
+		t.Errorf("disabling two-factor for another account does not produce an error")
+	}
+	for _, code := range []string{"testCode1", cry.GetHashedHexString("testCode2")} {
+		if NewPasswerkApplication(ptw).CheckTx(signedTx("timeStamp/disablingTwoFactor/testSigner/"+code,
+			"testSigner", "testSigningKey")).IsOK() {
+			t.Errorf("disabling two-factor without an unused backup code does not produce an error")
 		}
-	}()
+	}
+	err = TestspoofBroadcast(signedTx("timeStamp/disablingTwoFactor/testSigner/testCode2", "testSigner", "testSigningKey"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
 
 	/////////////////////////////
-	// Perform a test broadcast
-	urlStringBytes := []byte("w/masterU/masterP/testID/testPass")
+	// Cipher suite migrations must be signed by the account holder or vault owner, to a known suite
+	if NewPasswerkApplication(ptw).CheckTx([]byte("timeStamp/registering/testSuiteUser/testVerifier/testPubKey/testSigningKey/-/rot13")).IsOK() {
+		t.Errorf("registering with an unknown cipher suite does not produce an error")
+	}
+	//the published keys are replaced along with the keys wrapped to the account
+	migrationKeys := "testPubKey2/" + signingKey + "/"
+	if NewPasswerkApplication(ptw).CheckTx([]byte("timeStamp/migratingSuite/testSigner/argon2id/testVerifier/-/-/" +
+		migrationKeys + "-")).IsOK() {
+		t.Errorf("unsigned cipher suite migration does not produce an error")
+	}
+	if NewPasswerkApplication(ptw).CheckTx(signedTx("timeStamp/migratingSuite/testSigner/rot13/testVerifier/-/-/"+
+		migrationKeys+"-", "testSigner", "testSigningKey")).IsOK() {
+		t.Errorf("migrating to an unknown cipher suite does not produce an error")
+	}
+	if NewPasswerkApplication(ptw).CheckTx(signedTx("timeStamp/migratingSuite/testSigner/argon2id/testVerifier/-/-/"+
+		migrationKeys+"testVaultHashed.emergency.-.testRewrappedKey", "testSigner", "testSigningKey")).IsOK() {
+		t.Errorf("re-wrapping a key which isn't wrapped to the account does not produce an error")
+	}
+	err = TestspoofBroadcast(signedTx("timeStamp/migratingSuite/testSigner/argon2id/testVerifier/-/-/"+
+		migrationKeys+"testVaultHashed.member.-.testRewrappedKey", "testSigner", "testSigningKey"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	if status, wrappedKey, err := ptr.RetrieveVaultMembership(
+		"testVaultHashed", "testSigner"); err != nil || status != tre.VaultOwner || wrappedKey != "testRewrappedKey" {
+		t.Errorf("the vault key of the owner was not re-wrapped by the migration")
+	}
 
-	//note more specific scenarios of broadcast are tested as a part of ui_test.go
-	TestspoofBroadcast(urlStringBytes, ptw)
+	if NewPasswerkApplication(ptw).CheckTx(signedTx("timeStamp/migratingVaultSuite/testVaultHashed/testContact/argon2id/-",
+		"testContact", "testContactSigningKey")).IsOK() {
+		t.Errorf("cipher suite migration of a vault by a non-owner does not produce an error")
+	}
+	err = TestspoofBroadcast(signedTx("timeStamp/migratingVaultSuite/testVaultHashed/testSigner/argon2id/-",
+		"testSigner", "testSigningKey"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	/////////////////////////////
+	// Queries are answered for read requests signed by the account or a vault member
+	readRequest := func(target string) string {
+		return path.Join(time.Now().UTC().Format(tre.EndToEndTimeFormat), "reading", target, "-", "-")
+	}
+	result := NewPasswerkApplication(ptw).Query(signedTx(readRequest("testSigner"), "testSigner", "testSigningKey"))
+	state, err := tre.DecodeEndToEndState(string(result.Data))
+	if result.IsErr() || err != nil || state.Suite != "argon2id" || state.VerifierEncrypted != "testVerifier" {
+		t.Errorf("bad query result: %v %s", err, result.Log)
+	}
+	if NewPasswerkApplication(ptw).Query(signedTx(readRequest("testSigner"), "testSigner", "testContactSigningKey")).IsOK() {
+		t.Errorf("query with an invalid signature does not produce an error")
+	}
+	if NewPasswerkApplication(ptw).Query(signedTx(readRequest("testSigner"), "testContact", "testContactSigningKey")).IsOK() {
+		t.Errorf("query of another account does not produce an error")
+	}
+	result = NewPasswerkApplication(ptw).Query(signedTx(readRequest("testVaultHashed"), "testSigner", "testSigningKey"))
+	if state, _ = tre.DecodeEndToEndState(string(result.Data)); result.IsErr() || state.Status != tre.VaultOwner {
+		t.Errorf("bad vault query result: %s", result.Log)
+	}
+
+	//accounts which don't exist are queried as using the suite of new accounts
+	suiteApp := NewPasswerkApplication(ptw)
+	if log := suiteApp.SetOption("cipherSuite", "argon2id"); log != "success" {
+		t.Errorf("bad cipher suite option: %s", log)
+	}
+	result = suiteApp.Query([]byte("readingSuite/testUnknown"))
+	if state, _ = tre.DecodeEndToEndState(string(result.Data)); result.IsErr() || state.Suite != "argon2id" {
+		t.Errorf("bad suite query result of an account which doesn't exist: %s", result.Log)
+	}
+
+	/////////////////////////////
+	// Reconstruction requests must be approved by the vault owner, may not be replaced while
+	//  pending, and shares are bound to the requester they are released to
+	err = TestspoofBroadcast([]byte("timeStamp/registering/testRequester/testVerifier/testRequesterPubKey/"+
+		cry.GetSigningPublicKeyHexString("testRequesterSigningKey")), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	err = TestspoofBroadcast(signedTx("timeStamp/splittingKey/testVaultHashed/testSigner/1/testContact:testShare",
+		"testSigner", "testSigningKey"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	requestTx := "timeStamp/requestingReconstruction/testVaultHashed/testRequester"
+	err = TestspoofBroadcast(signedTx(requestTx, "testRequester", "testRequesterSigningKey"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	if NewPasswerkApplication(ptw).CheckTx(signedTx(requestTx, "testRequester", "testRequesterSigningKey")).IsOK() {
+		t.Errorf("replacing a pending reconstruction request does not produce an error")
+	}
+	releaseTx := "timeStamp/releasingShare/testVaultHashed/testContact/testRequester/testWrappedShare"
+	if NewPasswerkApplication(ptw).CheckTx(signedTx(releaseTx, "testContact", "testContactSigningKey")).IsOK() {
+		t.Errorf("releasing a share before approval does not produce an error")
+	}
+	if NewPasswerkApplication(ptw).CheckTx(signedTx("timeStamp/approvingReconstruction/testVaultHashed/testContact/testRequester",
+		"testContact", "testContactSigningKey")).IsOK() {
+		t.Errorf("approving reconstruction as a non-owner does not produce an error")
+	}
+	err = TestspoofBroadcast(signedTx("timeStamp/approvingReconstruction/testVaultHashed/testSigner/testRequester",
+		"testSigner", "testSigningKey"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	if NewPasswerkApplication(ptw).CheckTx(signedTx("timeStamp/releasingShare/testVaultHashed/testContact/testSigner/testWrappedShare",
+		"testContact", "testContactSigningKey")).IsOK() {
+		t.Errorf("releasing a share to another requester does not produce an error")
+	}
+	err = TestspoofBroadcast(signedTx(releaseTx, "testContact", "testContactSigningKey"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	err = TestspoofBroadcast(signedTx("timeStamp/reconstructing/testVaultHashed/testRequester/testWrappedKey",
+		"testRequester", "testRequesterSigningKey"), ptw)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
 
+	/////////////////////////////
+	// Accounts may only be deleted by the account holder, vaults and organizations may not be deleted as accounts
+	if NewPasswerkApplication(ptw).CheckTx(signedTx("timeStamp/deletingAccount/testContact",
+		"testSigner", "testSigningKey")).IsOK() {
+		t.Errorf("deleting another account does not produce an error")
+	}
+	for _, target := range []string{"testVaultHashed", "testOrgHashed"} {
+		if NewPasswerkApplication(ptw).CheckTx(signedTx("timeStamp/deletingAccount/"+target,
+			"testSigner", "testSigningKey")).IsOK() {
+			t.Errorf("deleting %s as an account does not produce an error", target)
+		}
+	}
+	err = TestspoofBroadcast(signedTx("timeStamp/deletingAccount/testContact", "testContact", "testContactSigningKey"), ptw)
 	if err != nil {
 		t.Errorf(err.Error())
 	}
+	if _, err := ptw.GetSigningKey("testContact"); err == nil {
+		t.Errorf("signing key remains after the account is deleted")
+	}
+
+	//the username of the deleted account may not be registered again, as it remains the
+	//  emergency contact of the vault
+	if NewPasswerkApplication(ptw).CheckTx([]byte("timeStamp/registering/testContact/testVerifier")).IsOK() {
+		t.Errorf("re-registering a deleted account does not produce an error")
+	}
+}
+
+//the txs of the tests lead with a placeholder timestamp, replaced as the tx is signed as
+//  the timestamps of signed txs must increase
+func stamp(tx string) string {
+	if !strings.HasPrefix(tx, "timeStamp/") {
+		return tx
+	}
+	return tre.NewTxTimestamp() + strings.TrimPrefix(tx, "timeStamp")
+}
+
+//sign the tx as per the end-to-end clients
+func fuzzSignedTx(tx, signer, hashInputSigningKey string) string {
+	message := path.Join(stamp(tx), signer)
+	return path.Join(message, cry.GetSignatureHexString(hashInputSigningKey, message))
+}
+
+//fuzzed txs are signed by one of the accounts of the fuzz state so that they reach beyond
+//  signature verification, or are left unsigned
+var fuzzSigners = []struct {
+	usernameHashed      string
+	hashInputSigningKey string
+}{
+	{"", ""},
+	{"testSigner", "testSigningKey"},
+	{"testContact", "testContactSigningKey"},
+	{"testRequester", "testRequesterSigningKey"},
+}
+
+func fuzzTx(tx string, signer uint8) []byte {
+	s := fuzzSigners[int(signer)%len(fuzzSigners)]
+	if len(s.usernameHashed) < 1 {
+		return []byte(tx)
+	}
+	return []byte(fuzzSignedTx(tx, s.usernameHashed, s.hashInputSigningKey))
+}
+
+//every op of the tmsp application acting on the fuzz state, along with the index of its signer
+var fuzzSeedTxs = []struct {
+	tx     string
+	signer uint8
+}{
+	{"w/masterU/masterP/testID/testPass", 0},
+	{"timeStamp/registering/testUsernameHashed/testVerifier", 0},
+	{"timeStamp/registering/testSigner/testVerifier/testPubKey/testSigningKey/-/argon2id", 0},
+	{"timeStamp/recovering/testSigner/testCodeHashed/testVerifier/testPubKey2/testSigningKey2/-/-/-/-", 1},
+	{"timeStamp/recovering/testSigner/testCodeHashed/testVerifier/testPubKey2/testSigningKey2/-/" +
+		"testCIdHashed.testCIdEncrypted.testRecord.testHistory1+testHistory2/testVaultHashed.member.-.testWrappedKey2/-", 1},
+	{"timeStamp/enablingTwoFactor/testSigner/testSecret/testCode1;testCode2", 1},
+	{"timeStamp/disablingTwoFactor/testContact/testCode2", 2},
+	{"timeStamp/usingBackupCode/testContact/testCode1", 2},
+	{"timeStamp/usingTwoFactorCode/testSigner/100", 1},
+	{"timeStamp/usingTwoFactorCode/testSigner/-1", 1},
+	{"timeStamp/migratingSuite/testSigner/argon2id/testVerifier/-/-/testPubKey2/testSigningKey2/-", 1},
+	{"timeStamp/migratingSuite/testSigner/argon2id/testVerifier/-/testCIdHashed.testCIdEncrypted.testRecord/" +
+		"testPubKey2/testSigningKey2/testVaultHashed.member.-.testWrappedKey2", 1},
+	{"timeStamp/migratingVaultSuite/testVaultHashed/testSigner/argon2id/-", 1},
+	{"timeStamp/creatingOrg/testOrgHashed2/testSigner", 1},
+	{"timeStamp/settingRole/testOrgHashed/testSigner/testContact/admin", 1},
+	{"timeStamp/settingRole/testOrgHashed/testSigner/testSigner/member", 1},
+	{"timeStamp/settingRole/testOrgHashed/testContact/testRequester/member", 2},
+	{"timeStamp/removingMember/testOrgHashed/testSigner/testContact", 1},
+	{"timeStamp/removingMember/testOrgHashed/testContact/testSigner", 2},
+	{"timeStamp/addingCollection/testOrgHashed/testSigner/testVaultHashed/member/admin", 1},
+	{"timeStamp/creatingVault/testVaultHashed2/testSigner/testWrappedKey", 1},
+	{"timeStamp/creatingVault/testVaultHashed2/testSigner/testWrappedKey", 2},
+	{"timeStamp/invitingMember/testVaultHashed/testSigner/testRequester/testWrappedKey", 1},
+	{"timeStamp/acceptingInvite/testVaultHashed/testRequester", 3},
+	{"timeStamp/revokingMember/testVaultHashed/testSigner/testSigner/-/-", 1},
+	{"timeStamp/revokingMember/testVaultHashed/testSigner/testContact/-/" +
+		"testSigner.member.testWrappedKey2,testContact.emergency.testWrappedKey2,testContact.share.testShare2", 1},
+	{"timeStamp/designatingContact/testVaultHashed/testSigner/testRequester/1/testWrappedKey", 1},
+	{"timeStamp/requestingAccess/testVaultHashed/testContact", 2},
+	{"timeStamp/cancelingAccess/testVaultHashed/testSigner/testContact", 1},
+	{"timeStamp/claimingAccess/testVaultHashed/testContact", 2},
+	{"timeStamp/splittingKey/testVaultHashed/testSigner/1/testContact:testShare", 1},
+	{"timeStamp/splittingKey/testVaultHashed/testSigner/2/testContact:testShare", 1},
+	{"timeStamp/requestingReconstruction/testVaultHashed/testRequester", 3},
+	{"timeStamp/requestingReconstruction/testVaultHashed/testSigner", 1},
+	{"timeStamp/approvingReconstruction/testVaultHashed/testSigner/testRequester", 1},
+	{"timeStamp/approvingReconstruction/testVaultHashed/testContact/testRequester", 2},
+	{"timeStamp/cancelingReconstruction/testVaultHashed/testRequester", 3},
+	{"timeStamp/cancelingReconstruction/testVaultHashed/testContact", 2},
+	{"timeStamp/releasingShare/testVaultHashed/testContact/testRequester/testWrappedShare", 2},
+	{"timeStamp/releasingShare/testVaultHashed/testContact/testSigner/testWrappedShare", 2},
+	{"timeStamp/reconstructing/testVaultHashed/testRequester/testWrappedKey", 3},
+	{"timeStamp/deletingAccount/testUsernameHashed", 0},
+	{"timeStamp/deletingAccount/testSigner", 1},
+	{"timeStamp/deletingAccount/testVaultHashed", 1},
+	{"timeStamp/writing/testSigner/testCIdHashed2/testCIdEncrypted2/testRecord2", 1},
+	{"timeStamp/writing/testVaultHashed/testCIdHashed2/testCIdEncrypted2/testRecord2", 1},
+	{"timeStamp/writing/testOrgHashed/testCIdHashed2/testCIdEncrypted2/testRecord2", 0},
+	{"timeStamp/deleting/testSigner/testCIdHashed/testCIdEncrypted", 1},
+	{"timeStamp/deleting/testVaultHashed/testCIdHashed/testCIdEncrypted", 3},
+	{"/", 0},
+	{"", 0},
+}
+
+//a state holding accounts, an organization, a vault with an emergency contact and a split
+//  key with an approved reconstruction request, and a record for the fuzzed txs to act on
+func fuzzState(t *testing.T) tre.PwkTreeWriter {
+
+	signed := func(tx string, signer uint8) string {
+		return string(fuzzTx(tx, signer))
+	}
+
+	ptw, _ := tre.NewMemTreePair()
+	for _, tx := range []string{
+		"timeStamp/registering/testSigner/testVerifier/testPubKey/" + cry.GetSigningPublicKeyHexString("testSigningKey") +
+			"/testCodeHashed:testPasswordEncrypted",
+		"timeStamp/registering/testContact/testVerifier/testContactPubKey/" + cry.GetSigningPublicKeyHexString("testContactSigningKey"),
+		"timeStamp/registering/testRequester/testVerifier/testRequesterPubKey/" + cry.GetSigningPublicKeyHexString("testRequesterSigningKey"),
+		signed("timeStamp/enablingTwoFactor/testContact/testSecret/"+cry.GetHashedHexString("testCode1")+";"+
+			cry.GetHashedHexString("testCode2"), 2),
+		signed("timeStamp/creatingVault/testVaultHashed/testSigner/testWrappedKey", 1),
+		signed("timeStamp/creatingOrg/testOrgHashed/testSigner", 1),
+		signed("timeStamp/settingRole/testOrgHashed/testSigner/testContact/member", 1),
+		signed("timeStamp/designatingContact/testVaultHashed/testSigner/testContact/0/testWrappedKey", 1),
+		signed("timeStamp/splittingKey/testVaultHashed/testSigner/1/testContact:testShare", 1),
+		signed("timeStamp/requestingReconstruction/testVaultHashed/testRequester", 3),
+		signed("timeStamp/approvingReconstruction/testVaultHashed/testSigner/testRequester", 1),
+		signed("timeStamp/writing/testSigner/testCIdHashed/testCIdEncrypted/testRecord", 1),
+	} {
+		if err := TestspoofBroadcast([]byte(tx), ptw); err != nil {
+			t.Fatalf("building the fuzz state with %s: %v", tx, err)
+		}
+	}
+	return ptw
+}
+
+//checking a tx must never panic or modify the tree
+func FuzzCheckTx(f *testing.F) {
+
+	for _, seed := range fuzzSeedTxs {
+		f.Add(seed.tx, seed.signer)
+	}
+
+	f.Fuzz(func(t *testing.T, tx string, signer uint8) {
+
+		ptw := fuzzState(t)
+		hash := ptw.Hash()
+
+		NewPasswerkApplication(ptw).CheckTx(fuzzTx(tx, signer))
+		if !bytes.Equal(hash, ptw.Hash()) {
+			t.Errorf("CheckTx modified the tree: %q", tx)
+		}
+	})
+}
+
+//appending a tx must never panic, a tx accepted by CheckTx must be appended, and a tx
+//  which fails to be appended must leave the tree unmodified
+func FuzzAppendTx(f *testing.F) {
+
+	for _, seed := range fuzzSeedTxs {
+		f.Add(seed.tx, seed.signer)
+	}
+
+	f.Fuzz(func(t *testing.T, tx string, signer uint8) {
+
+		ptw := fuzzState(t)
+		app := NewPasswerkApplication(ptw)
+		app.BeginBlock(ptw.GetBlockHeight() + 1)
+		hash := ptw.Hash()
+
+		checkTxResult := app.CheckTx(fuzzTx(tx, signer))
+		appendTxResult := app.AppendTx(fuzzTx(tx, signer))
+		switch {
+		case checkTxResult.IsOK() && appendTxResult.IsErr():
+			t.Errorf("CheckTx accepted but AppendTx rejected %q: %s", tx, appendTxResult.Log)
+		case appendTxResult.IsErr() && !bytes.Equal(hash, ptw.Hash()):
+			t.Errorf("rejected AppendTx modified the tree: %q", tx)
+		}
+		if app.Commit().IsErr() {
+			t.Errorf("commit failed after %q", tx)
+		}
+
+		//the state remains usable by every tx
+		for _, seed := range fuzzSeedTxs {
+			app.CheckTx(fuzzTx(seed.tx, seed.signer))
+		}
+	})
 }
@@ -0,0 +1,265 @@
+//emergency access to shared vaults, an owner may designate a trusted contact who
+//  receives a copy of the vault key which may only be claimed once the contact has
+//  requested access and a waiting period (measured in blocks) has passed without
+//  the owner cancelling the request. The wrapped key is held in the public merkle state
+//  from the moment of designation, the contact is able to unwrap it immediately and it
+//  remains in the history of the state after the contact is cancelled. The waiting period
+//  only gates the vault membership of the contact, contacts are trusted with the vault key.
+package tree
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+//the longest waiting period which may be designated, about a year of one second blocks
+const MaxEmergencyWaitBlocks uint64 = 31536000
+
+//whether the waiting period of an emergency access requested at requestHeight has passed
+//  at the block height, free of overflow for any requestHeight and waitBlocks
+func EmergencyWaitPassed(height, requestHeight, waitBlocks uint64) bool {
+	return height >= requestHeight && height-requestHeight >= waitBlocks
+}
+
+//value held for each emergency contact, the waiting period, the height at which
+//  access was requested (zero if not requested), and the wrapped vault key
+func getEmergencyContactValue(waitBlocks, requestHeight uint64, wrappedKey string) []byte {
+	return []byte(strconv.FormatUint(waitBlocks, 10) + "/" +
+		strconv.FormatUint(requestHeight, 10) + "/" + wrappedKey)
+}
+
+func readEmergencyContactValue(value []byte) (waitBlocks, requestHeight uint64, wrappedKey string, err error) {
+	parts := strings.Split(string(value), "/")
+	if len(parts) != 3 {
+		err = errors.New("bad emergency contact record")
+		return
+	}
+
+	waitBlocks, err = strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		err = errors.New("bad emergency contact record")
+		return
+	}
+	requestHeight, err = strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		err = errors.New("bad emergency contact record")
+		return
+	}
+	wrappedKey = parts[2]
+	return
+}
+
+func getBlockHeight(tree TreeReading) uint64 {
+	_, value, exists := tree.Get(getBlockHeightKey())
+	if !exists {
+		return 0
+	}
+	height, _ := strconv.ParseUint(string(value), 10, 64)
+	return height
+}
+
+func getEmergencyContact(tree TreeReading, vaultHashed, contactUsernameHashed string) (
+	waitBlocks, requestHeight uint64, wrappedKey string, err error) {
+
+	subTree, err := tree.LoadSubTree(vaultHashed)
+	if err != nil {
+		err = errors.New("vault doesn't exist")
+		return
+	}
+
+	_, value, exists := subTree.Get(getEmergencyContactKey(vaultHashed, contactUsernameHashed))
+	if !exists {
+		err = errors.New("not an emergency contact")
+		return
+	}
+
+	return readEmergencyContactValue(value)
+}
+
+/////////////////////////////////////////////
+//   WRITE Emergency Access Operations
+////////////////////////////////////////////
+
+//record the height of the current block, used to measure emergency access waiting periods
+func (ptw *PwkTreeWriter) SetBlockHeight(height uint64) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	ptw.tree.Set(getBlockHeightKey(), []byte(strconv.FormatUint(height, 10)))
+}
+
+func (ptw *PwkTreeWriter) GetBlockHeight() uint64 {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	return getBlockHeight(ptw.tree)
+}
+
+//designate an emergency contact for a vault, only the owner may designate contacts and the
+//  contact must have a published key. Re-designating a contact replaces any pending request.
+//  Note that as with vault members, the wrapped key is held in the merkle state, the waiting
+//  period is enforced by passwerk and not cryptographically.
+func (ptw *PwkTreeWriter) DesignateEmergencyContact(vaultHashed, ownerUsernameHashed,
+	contactUsernameHashed string, waitBlocks uint64, wrappedKey string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	if waitBlocks > MaxEmergencyWaitBlocks {
+		err = errors.New("emergency access waiting period is too long")
+		return
+	}
+
+	var ownerStatus, contactStatus string
+	ownerStatus, err = ptw.getVaultMemberStatus(vaultHashed, ownerUsernameHashed)
+	if err != nil {
+		return
+	}
+	if ownerStatus != VaultOwner {
+		err = errors.New("only the vault owner may designate emergency contacts")
+		return
+	}
+
+	contactStatus, _ = ptw.getVaultMemberStatus(vaultHashed, contactUsernameHashed)
+	if len(contactStatus) > 0 {
+		err = errors.New("emergency contact is already a vault member")
+		return
+	}
+
+	if !ptw.tree.Has(getPublicKeyKey(contactUsernameHashed)) {
+		err = errors.New("emergency contact has no published public key")
+		return
+	}
+
+	subTree, _ := ptw.tree.LoadSubTree(vaultHashed)
+	subTree.Set(getEmergencyContactKey(vaultHashed, contactUsernameHashed),
+		getEmergencyContactValue(waitBlocks, 0, wrappedKey))
+	ptw.tree.SaveSubTree(vaultHashed, subTree)
+
+	return
+}
+
+//request emergency access to a vault, starting the waiting period at the current block
+func (ptw *PwkTreeWriter) RequestEmergencyAccess(vaultHashed, contactUsernameHashed string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	var waitBlocks, requestHeight uint64
+	var wrappedKey string
+	waitBlocks, requestHeight, wrappedKey, err = getEmergencyContact(ptw.tree, vaultHashed, contactUsernameHashed)
+	if err != nil {
+		return
+	}
+	if requestHeight > 0 {
+		err = errors.New("emergency access already requested")
+		return
+	}
+
+	requestHeight = getBlockHeight(ptw.tree)
+	if requestHeight < 1 {
+		err = errors.New("block height unknown")
+		return
+	}
+
+	subTree, _ := ptw.tree.LoadSubTree(vaultHashed)
+	subTree.Set(getEmergencyContactKey(vaultHashed, contactUsernameHashed),
+		getEmergencyContactValue(waitBlocks, requestHeight, wrappedKey))
+	ptw.tree.SaveSubTree(vaultHashed, subTree)
+
+	return
+}
+
+//cancel an emergency contact along with any pending request, only the owner may cancel
+func (ptw *PwkTreeWriter) CancelEmergencyAccess(vaultHashed, ownerUsernameHashed,
+	contactUsernameHashed string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	var ownerStatus string
+	ownerStatus, err = ptw.getVaultMemberStatus(vaultHashed, ownerUsernameHashed)
+	if err != nil {
+		return
+	}
+	if ownerStatus != VaultOwner {
+		err = errors.New("only the vault owner may cancel emergency access")
+		return
+	}
+
+	_, _, _, err = getEmergencyContact(ptw.tree, vaultHashed, contactUsernameHashed)
+	if err != nil {
+		return
+	}
+
+	subTree, _ := ptw.tree.LoadSubTree(vaultHashed)
+	subTree.Remove(getEmergencyContactKey(vaultHashed, contactUsernameHashed))
+	ptw.tree.SaveSubTree(vaultHashed, subTree)
+
+	return
+}
+
+//claim emergency access once the waiting period has passed, the contact becomes a vault member
+func (ptw *PwkTreeWriter) ClaimEmergencyAccess(vaultHashed, contactUsernameHashed string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	var waitBlocks, requestHeight uint64
+	var wrappedKey string
+	waitBlocks, requestHeight, wrappedKey, err = getEmergencyContact(ptw.tree, vaultHashed, contactUsernameHashed)
+	if err != nil {
+		return
+	}
+	if requestHeight < 1 {
+		err = errors.New("emergency access not requested")
+		return
+	}
+	if !EmergencyWaitPassed(getBlockHeight(ptw.tree), requestHeight, waitBlocks) {
+		err = errors.New("emergency access waiting period has not passed")
+		return
+	}
+
+	subTree, _ := ptw.tree.LoadSubTree(vaultHashed)
+	subTree.Remove(getEmergencyContactKey(vaultHashed, contactUsernameHashed))
+	subTree.Set(getVaultMemberKey(vaultHashed, contactUsernameHashed), getVaultMemberValue(VaultMember, wrappedKey))
+	ptw.tree.SaveSubTree(vaultHashed, subTree)
+
+	return
+}
+
+//retrieve the emergency access of a contact, the waiting period and height the access was requested
+func (ptw *PwkTreeWriter) GetEmergencyContact(vaultHashed, contactUsernameHashed string) (
+	waitBlocks, requestHeight uint64, err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	waitBlocks, requestHeight, _, err = getEmergencyContact(ptw.tree, vaultHashed, contactUsernameHashed)
+	return
+}
+
+/////////////////////////////////////////////
+//   READ Emergency Access Operations
+////////////////////////////////////////////
+
+func (ptr *PwkTreeReader) RetrieveBlockHeight() uint64 {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	return getBlockHeight(ptr.tree)
+}
+
+//retrieve the waiting period, height access was requested, and wrapped vault key of a contact
+func (ptr *PwkTreeReader) RetrieveEmergencyContact(vaultHashed, contactUsernameHashed string) (
+	waitBlocks, requestHeight uint64, wrappedKey string, err error) {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	return getEmergencyContact(ptr.tree, vaultHashed, contactUsernameHashed)
+}
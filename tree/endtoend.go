@@ -0,0 +1,308 @@
+//end-to-end reads, the ciphertexts of an account or shared vault are output to end-to-end clients
+//  in response to a read request signed by the account, or by an owner or member of the vault:
+//    timestamp/reading/targetHashed/cIdNameHashed/twoFactor/signerUsernameHashed/signature
+//  with cIdNameHashed as "-" when no record is requested. twoFactor holds the second factor of
+//  signers enrolled in two-factor authentication, which is verified by the UI (see
+//  EncodeEndToEndTwoFactor), or "-". The keys which sign read requests are derived from the
+//  master password as stretched by the cipher suite of the account, so the suite is first
+//  read by an unsigned request:
+//    readingSuite/usernameHashed
+package tree
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	cry "github.com/rigelrozanski/passwerk/crypto"
+)
+
+//time format of read request timestamps, requests are only accepted within
+//  the request window to limit the replay of a signed request
+const EndToEndTimeFormat string = time.RFC3339
+const endToEndRequestWindow time.Duration = 5 * time.Minute
+
+//the ciphertexts of an account or shared vault output to an end-to-end client,
+//  values which are not requested or not held are left empty
+type EndToEndState struct {
+	Suite             string //identifier of the cipher suite
+	VerifierEncrypted string //accounts only
+	CIdList           string
+	CRecordEncrypted  string   //the requested record
+	History           []string //previous values of the requested record, most recent first
+	Status            string   //vault membership status of the signer, vaults only
+	WrappedKey        string   //vault key wrapped for the signer, vaults only
+	Session           string   //end-to-end session opened by the totp code of the read request
+}
+
+//field names of the encoded state, one "name: value" line per field
+const (
+	endToEndFieldSuite      string = "suite"
+	endToEndFieldVerifier   string = "verifier"
+	endToEndFieldCIdList    string = "cIdList"
+	endToEndFieldRecord     string = "record"
+	endToEndFieldHistory    string = "history"
+	endToEndFieldStatus     string = "status"
+	endToEndFieldWrappedKey string = "wrappedKey"
+	endToEndFieldSession    string = "session"
+	endToEndFieldError      string = "error"
+)
+
+func (state EndToEndState) Encode() (encoded string) {
+
+	for _, field := range []struct{ name, value string }{
+		{endToEndFieldSuite, state.Suite},
+		{endToEndFieldVerifier, state.VerifierEncrypted},
+		{endToEndFieldCIdList, state.CIdList},
+		{endToEndFieldRecord, state.CRecordEncrypted},
+		{endToEndFieldHistory, strings.Join(state.History, recordHistorySep)},
+		{endToEndFieldStatus, state.Status},
+		{endToEndFieldWrappedKey, state.WrappedKey},
+		{endToEndFieldSession, state.Session},
+	} {
+		if len(field.value) > 0 {
+			encoded += field.name + ": " + field.value + "\n"
+		}
+	}
+	return
+}
+
+//the encoded output of a failed read request
+func EncodeEndToEndError(err error) string {
+	return endToEndFieldError + ": " + err.Error() + "\n"
+}
+
+//decode the output of a read request, an error output is returned as the error
+func DecodeEndToEndState(output string) (state EndToEndState, err error) {
+
+	for _, line := range strings.Split(output, "\n") {
+		i := strings.Index(line, ": ")
+		if i < 0 {
+			continue
+		}
+		value := line[i+2:]
+
+		switch line[:i] {
+		case endToEndFieldSuite:
+			state.Suite = value
+		case endToEndFieldVerifier:
+			state.VerifierEncrypted = value
+		case endToEndFieldCIdList:
+			state.CIdList = value
+		case endToEndFieldRecord:
+			state.CRecordEncrypted = value
+		case endToEndFieldHistory:
+			if len(value) > 0 {
+				state.History = strings.Split(value, recordHistorySep)
+			}
+		case endToEndFieldStatus:
+			state.Status = value
+		case endToEndFieldWrappedKey:
+			state.WrappedKey = value
+		case endToEndFieldSession:
+			state.Session = value
+		case endToEndFieldError:
+			err = errors.New(value)
+			return
+		}
+	}
+
+	if len(state.Suite) < 1 {
+		err = errors.New("generalError")
+	}
+	return
+}
+
+//the second factor of a read request, a totp code is provided along with the two-factor
+//  key of the account to decrypt the totp secret, and opens a session. The token of the
+//  session is then provided in place of further codes
+func EncodeEndToEndTwoFactor(twoFactorKey, totpCode string) string {
+	return twoFactorKey + "." + totpCode
+}
+
+func DecodeEndToEndTwoFactor(twoFactor string) (twoFactorKey, totpCode, sessionToken string) {
+
+	if twoFactor == emptyTxList {
+		return
+	}
+	if i := strings.Index(twoFactor, "."); i >= 0 {
+		return twoFactor[:i], twoFactor[i+1:], ""
+	}
+	return "", "", twoFactor
+}
+
+/////////////////////////////////////////////
+//   READ End-to-End Operations
+////////////////////////////////////////////
+
+//verify the signature of a signed tx or read request, the signer and signature are the final
+//  two parts. Returns the hashed username of the signer
+func (ptr *PwkTreeReader) VerifyEndToEndSignature(request string) (signerUsernameHashed string, err error) {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	return verifyEndToEndSignature(ptr.tree, request)
+}
+
+//output the ciphertexts requested by a signed read request as of the time provided, accounts
+//  which don't exist are output as using the default suite of new accounts
+func (ptr *PwkTreeReader) ReadEndToEnd(request string, now time.Time, defaultSuite cry.CipherSuite) (
+	state EndToEndState, err error) {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	return readEndToEnd(ptr.tree, request, now, defaultSuite)
+}
+
+//as per the reader, used to answer the queries of the tmsp application
+func (ptw *PwkTreeWriter) ReadEndToEnd(request string, now time.Time, defaultSuite cry.CipherSuite) (
+	state EndToEndState, err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	return readEndToEnd(ptw.tree, request, now, defaultSuite)
+}
+
+//verify the totp code of a signer enrolled in two-factor authentication, the totp secret is
+//  decrypted with the two-factor key provided. Codes at or before the last accepted time step
+//  are rejected, and the time step of the code is returned, zero for signers not enrolled
+func (ptr *PwkTreeReader) VerifyEndToEndTwoFactor(signerUsernameHashed, twoFactorKey, totpCode string,
+	now time.Time) (step int64, err error) {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	subTree, loadErr := ptr.tree.LoadSubTree(signerUsernameHashed)
+	if loadErr != nil {
+		return
+	}
+	_, secretEncrypted, enrolled := subTree.Get(getTwoFactorKey(signerUsernameHashed))
+	if !enrolled {
+		return
+	}
+	if len(totpCode) < 1 {
+		err = errors.New("twoFactorRequired")
+		return
+	}
+
+	secret, err := cry.ReadDecrypted(HashInputTwoFactorEncryption(twoFactorKey), string(secretEncrypted))
+	if err != nil {
+		err = errors.New("badTwoFactor")
+		return
+	}
+	key, err := cry.ParseOTPKey(secret)
+	if err != nil {
+		err = errors.New("badTwoFactor")
+		return
+	}
+	step, valid := cry.VerifyTOTPAfter(key, totpCode, now, TwoFactorSkew,
+		getTwoFactorStep(subTree, signerUsernameHashed))
+	if !valid {
+		err = errors.New("badTwoFactor")
+	}
+	return
+}
+
+func verifyEndToEndSignature(tree TreeReading, request string) (signerUsernameHashed string, err error) {
+
+	parts := strings.Split(request, "/")
+	if len(parts) < 5 {
+		err = errors.New("badAuthentication")
+		return
+	}
+
+	signerUsernameHashed = parts[len(parts)-2]
+	_, signingKey, exists := tree.Get(getSigningKeyKey(signerUsernameHashed))
+	message := request[:strings.LastIndex(request, "/")]
+	if !exists || !cry.VerifySignatureHexString(string(signingKey), message, parts[len(parts)-1]) {
+		err = errors.New("badAuthentication")
+	}
+	return
+}
+
+func readEndToEnd(tree TreeReading, request string, now time.Time, defaultSuite cry.CipherSuite) (
+	state EndToEndState, err error) {
+
+	parts := strings.Split(request, "/")
+	if len(parts) == 2 && parts[0] == "readingSuite" {
+		state.Suite = readSuite(tree, parts[1], defaultSuite)
+		return
+	}
+	if len(parts) != 7 || parts[1] != "reading" {
+		err = errors.New("generalError")
+		return
+	}
+
+	signerUsernameHashed, err := verifyEndToEndSignature(tree, request)
+	if err != nil {
+		return
+	}
+
+	timestamp, timeErr := time.Parse(EndToEndTimeFormat, parts[0])
+	if timeErr != nil {
+		err = errors.New("generalError")
+		return
+	}
+	if age := now.Sub(timestamp); age > endToEndRequestWindow || age < -endToEndRequestWindow {
+		err = errors.New("staleRequest")
+		return
+	}
+
+	targetHashed, cIdNameHashed := parts[2], parts[3]
+	subTree, loadErr := tree.LoadSubTree(targetHashed)
+	if loadErr != nil {
+		err = errors.New("generalError")
+		return
+	}
+
+	//shared vaults are read by their owner and members, along with the vault key wrapped for them
+	if targetHashed == signerUsernameHashed {
+		_, verifierEncrypted, _ := subTree.Get(GetVerifierKey(targetHashed))
+		state.VerifierEncrypted = string(verifierEncrypted)
+	} else {
+		_, value, exists := subTree.Get(getVaultMemberKey(targetHashed, signerUsernameHashed))
+		if exists {
+			state.Status, state.WrappedKey, err = readVaultMemberValue(value)
+		}
+		if !exists || err != nil || (state.Status != VaultOwner && state.Status != VaultMember) {
+			state = EndToEndState{}
+			err = errors.New("notVaultMember")
+			return
+		}
+	}
+
+	state.Suite = readSuite(tree, targetHashed, defaultSuite)
+
+	_, cIdList, _ := subTree.Get(GetCIdListKey(targetHashed))
+	state.CIdList = string(cIdList)
+
+	if cIdNameHashed != emptyTxList {
+		_, cRecordEncrypted, _ := subTree.Get(GetRecordKey(targetHashed, cIdNameHashed))
+		state.CRecordEncrypted = string(cRecordEncrypted)
+		state.History = readRecordHistory(subTree, targetHashed, cIdNameHashed)
+		if len(state.CRecordEncrypted) < 1 && len(state.History) < 1 {
+			state = EndToEndState{}
+			err = errors.New("invalidCIdName")
+		}
+	}
+	return
+}
+
+//the identifier of the cipher suite of an account or vault, accounts which don't exist are
+//  output as using the default suite of new accounts so that their existence isn't revealed.
+//  Accounts registered before cipher suites hold no suite and use the legacy suite
+func readSuite(tree TreeReading, targetHashed string, defaultSuite cry.CipherSuite) string {
+
+	subTree, err := tree.LoadSubTree(targetHashed)
+	if err != nil {
+		return defaultSuite.ID()
+	}
+	_, suiteID, exists := subTree.Get(getCipherSuiteKey(targetHashed))
+	if !exists {
+		return cry.LegacyCipherSuite.ID()
+	}
+	return string(suiteID)
+}
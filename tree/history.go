@@ -0,0 +1,62 @@
+//record history, the previous values of a record are retained each time the record is deleted,
+//  which includes each time the record is overwritten. Previous values remain readable with the
+//  keys of the record as the ciphertexts are bound to the account and identifier rather than
+//  to a version. Note that deleted values already remain within the txs of the blockchain,
+//  the history only makes them readable from the current state
+package tree
+
+import (
+	"strings"
+)
+
+//the number of previous values retained for each record
+const maxRecordHistory int = 10
+
+//previous values are held most recent first seperated by "/", which cannot appear within a record value
+const recordHistorySep string = "/"
+
+//add a deleted record value to the front of its history, dropping the oldest values
+func archiveRecord(subTree TreeWriting, usernameHashed, cIdNameHashed, cRecordEncrypted string) {
+
+	history := append([]string{cRecordEncrypted}, readRecordHistory(subTree, usernameHashed, cIdNameHashed)...)
+	if len(history) > maxRecordHistory {
+		history = history[:maxRecordHistory]
+	}
+
+	subTree.Set(getRecordHistoryKey(usernameHashed, cIdNameHashed), []byte(strings.Join(history, recordHistorySep)))
+}
+
+//the previous values of a record, most recent first
+func readRecordHistory(subTree TreeReading, usernameHashed, cIdNameHashed string) []string {
+
+	_, value, exists := subTree.Get(getRecordHistoryKey(usernameHashed, cIdNameHashed))
+	if !exists || len(value) < 1 {
+		return nil
+	}
+	return strings.Split(string(value), recordHistorySep)
+}
+
+//replace the history of a record with values re-encrypted by a migration or recovery
+func setRecordHistory(subTree TreeWriting, usernameHashed, cIdNameHashed string, history []string) {
+
+	if len(history) < 1 {
+		return
+	}
+	subTree.Set(getRecordHistoryKey(usernameHashed, cIdNameHashed), []byte(strings.Join(history, recordHistorySep)))
+}
+
+//remove the history of every record held by the subtree
+func clearRecordHistory(subTree TreeWriting) {
+
+	var historyKeys [][]byte
+	for i := 0; i < subTree.Size(); i++ {
+		key, _ := subTree.GetByIndex(i)
+		if strings.HasPrefix(string(key), keyPrefix4RecordHistory+"/") {
+			historyKeys = append(historyKeys, key)
+		}
+	}
+
+	for _, key := range historyKeys {
+		subTree.Remove(key)
+	}
+}
@@ -16,26 +16,165 @@ import (
 //  of the hex-string of the hash of username/password
 const keyPrefix4SubTree string = "S"
 const keyPrefix4SubTreeValue string = "V"
+const keyPrefix4SubTreeVerifier string = "A"
+const keyPrefix4PublicKey string = "P"
+const keyPrefix4VaultMember string = "M"
+const keyPrefix4SigningKey string = "K"
+const keyPrefix4OrgRole string = "R"
+const keyPrefix4OrgCollection string = "C"
+const keyPrefix4OrgCollectionList string = "L"
+const keyPrefix4VaultOrg string = "O"
+const keyPrefix4EmergencyContact string = "E"
+const keyPrefix4BlockHeight string = "B"
+const keyPrefix4RecoveryCode string = "Q"
+const keyPrefix4KeySplit string = "Y"
+const keyPrefix4KeyShare string = "X"
+const keyPrefix4Reconstruction string = "Z"
+const keyPrefix4ReleasedShare string = "W"
+const keyPrefix4TwoFactor string = "T"
+const keyPrefix4TwoFactorBackupCodes string = "U"
+const keyPrefix4TwoFactorStep string = "J"
+const keyPrefix4CipherSuite string = "G"
+const keyPrefix4RecordHistory string = "H"
+const keyPrefix4SignedTxTime string = "N"
+const keyPrefix4RetiredAccount string = "D"
 
 //momma-tree key for record containing the hash for the subtree
 func getMapKey(usernameHashed string) []byte {
 	return []byte(path.Join(keyPrefix4SubTree, usernameHashed))
 }
 
+//momma-tree key for the record containing the published public key of a user
+func getPublicKeyKey(usernameHashed string) []byte {
+	return []byte(path.Join(keyPrefix4PublicKey, usernameHashed))
+}
+
+//momma-tree key for the record containing the published signing key of a user
+func getSigningKeyKey(usernameHashed string) []byte {
+	return []byte(path.Join(keyPrefix4SigningKey, usernameHashed))
+}
+
+//momma-tree key for the record marking the username of a deleted account as retired
+func getRetiredAccountKey(usernameHashed string) []byte {
+	return []byte(path.Join(keyPrefix4RetiredAccount, usernameHashed))
+}
+
+//momma-tree key for the record containing the timestamp of the last signed tx of a user
+func getSignedTxTimeKey(usernameHashed string) []byte {
+	return []byte(path.Join(keyPrefix4SignedTxTime, usernameHashed))
+}
+
+//organization subtree key for the record holding a member's role
+func getOrgRoleKey(orgHashed, usernameHashed string) []byte {
+	return []byte(path.Join(keyPrefix4OrgRole, orgHashed, usernameHashed))
+}
+
+//organization subtree key for the record holding the permissions of a collection
+func getOrgCollectionKey(orgHashed, vaultHashed string) []byte {
+	return []byte(path.Join(keyPrefix4OrgCollection, orgHashed, vaultHashed))
+}
+
+//organization subtree key for the record which holds the list of collections
+func getOrgCollectionListKey(orgHashed string) []byte {
+	return []byte(path.Join(keyPrefix4OrgCollectionList, orgHashed))
+}
+
+//shared vault subtree key for the record holding the organization the vault is a collection of
+func getVaultOrgKey(vaultHashed string) []byte {
+	return []byte(path.Join(keyPrefix4VaultOrg, vaultHashed))
+}
+
+//shared vault subtree key for the record holding an emergency contact's waiting period,
+//  access request, and wrapped vault key
+func getEmergencyContactKey(vaultHashed, contactUsernameHashed string) []byte {
+	return []byte(path.Join(keyPrefix4EmergencyContact, vaultHashed, contactUsernameHashed))
+}
+
+//shared vault subtree key for the record holding the threshold and custodians of a split vault key
+func getKeySplitKey(vaultHashed string) []byte {
+	return []byte(path.Join(keyPrefix4KeySplit, vaultHashed))
+}
+
+//shared vault subtree key for the record holding a custodian's share wrapped to their public key
+func getKeyShareKey(vaultHashed, custodianUsernameHashed string) []byte {
+	return []byte(path.Join(keyPrefix4KeyShare, vaultHashed, custodianUsernameHashed))
+}
+
+//shared vault subtree key for the record holding the user requesting reconstruction of the vault key
+//  along with the status of the request
+func getReconstructionKey(vaultHashed string) []byte {
+	return []byte(path.Join(keyPrefix4Reconstruction, vaultHashed))
+}
+
+//shared vault subtree key for the record holding a custodian's share released to a requester,
+//  the key binds the released share to the requester it was released to
+func getReleasedShareKey(vaultHashed, requesterUsernameHashed, custodianUsernameHashed string) []byte {
+	return []byte(path.Join(keyPrefix4ReleasedShare, vaultHashed, requesterUsernameHashed, custodianUsernameHashed))
+}
+
+//momma-tree key for the record containing the height of the current block
+func getBlockHeightKey() []byte {
+	return []byte(keyPrefix4BlockHeight)
+}
+
+//shared vault subtree key for the record holding a member's status and wrapped vault key
+func getVaultMemberKey(vaultHashed, usernameHashed string) []byte {
+	return []byte(path.Join(keyPrefix4VaultMember, vaultHashed, usernameHashed))
+}
+
 //subtree key for the record which holds the list of saved password identifiers (cId's)
 func GetCIdListKey(usernameHashed string) []byte {
 	return []byte(path.Join(keyPrefix4SubTreeValue, usernameHashed))
 }
 
+//subtree key for the record which holds the encrypted account verifier
+func GetVerifierKey(usernameHashed string) []byte {
+	return []byte(path.Join(keyPrefix4SubTreeVerifier, usernameHashed))
+}
+
+//subtree key for the record which holds the master password wrapped by a recovery code
+func getRecoveryCodeKey(usernameHashed, codeHashed string) []byte {
+	return []byte(path.Join(keyPrefix4RecoveryCode, usernameHashed, codeHashed))
+}
+
+//subtree key for the record which holds the encrypted two-factor secret
+func getTwoFactorKey(usernameHashed string) []byte {
+	return []byte(path.Join(keyPrefix4TwoFactor, usernameHashed))
+}
+
+//subtree key for the record which holds the list of unused two-factor backup codes
+func getTwoFactorBackupCodesKey(usernameHashed string) []byte {
+	return []byte(path.Join(keyPrefix4TwoFactorBackupCodes, usernameHashed))
+}
+
+//subtree key for the record which holds the time step of the last accepted totp code
+func getTwoFactorStepKey(usernameHashed string) []byte {
+	return []byte(path.Join(keyPrefix4TwoFactorStep, usernameHashed))
+}
+
+//subtree key for the record which holds the identifier of the cipher suite of an account or vault
+func getCipherSuiteKey(usernameHashed string) []byte {
+	return []byte(path.Join(keyPrefix4CipherSuite, usernameHashed))
+}
+
 //subtree key for a record and password combination
 func GetRecordKey(usernameHashed, cIdNameHashed string) []byte {
 	return []byte(path.Join(keyPrefix4SubTreeValue, usernameHashed, cIdNameHashed))
 }
 
+//sub-tree key for the previous values of a record
+func getRecordHistoryKey(usernameHashed, cIdNameHashed string) []byte {
+	return []byte(path.Join(keyPrefix4RecordHistory, usernameHashed, cIdNameHashed))
+}
+
 ////////////////////////////
 //Encryption Keys
 ////////////////////////////
 
+//plaintext of the verifier stored for each account, a successful decryption
+//  of the stored verifier to this value authenticates the master password
+const VerifierCanary string = "passwerkVerifier"
+
 //TODO create more secure shared key equivalent
 func HashInputCIdNameEncryption(urlUsername, urlPassword string) string {
 	return path.Join(urlUsername, urlPassword)
@@ -44,3 +183,91 @@ func HashInputCIdNameEncryption(urlUsername, urlPassword string) string {
 func HashInputCPasswordEncryption(urlUsername, urlPassword, urlCIdName string) string {
 	return path.Join(urlCIdName, urlPassword, urlUsername)
 }
+
+//input hashed to identify a recovery code within the account subtree
+func HashInputRecoveryCodeID(urlUsername, recoveryCode string) string {
+	return path.Join(urlUsername, recoveryCode, "recoveryCodeID")
+}
+
+//input hashed to derive the key which wraps the recovery key for a recovery code
+func HashInputRecoveryCode(urlUsername, recoveryCode string) string {
+	return path.Join(recoveryCode, urlUsername, "recoveryCode")
+}
+
+//input hashed to derive the key which encrypts the two-factor secret from the two-factor key
+//  of the account (see TwoFactorKey)
+func HashInputTwoFactorEncryption(twoFactorKey string) string {
+	return path.Join(twoFactorKey, "twoFactor")
+}
+
+//input hashed to identify a two-factor backup code within the account subtree
+func HashInputTwoFactorBackupCode(urlUsername, backupCode string) string {
+	return path.Join(urlUsername, backupCode, "twoFactorBackupCode")
+}
+
+//input hashed to derive a user's box keypair from the master password
+//  as stretched by the cipher suite of the account
+func HashInputBoxKey(urlUsername, keyPassword string) string {
+	return path.Join(urlUsername, keyPassword, "boxKey")
+}
+
+//input hashed to derive a user's signing keypair from the master password
+//  as stretched by the cipher suite of the account
+func HashInputSigningKey(urlUsername, keyPassword string) string {
+	return path.Join(urlUsername, keyPassword, "signingKey")
+}
+
+//input hashed to determine the subtree of an organization
+func HashInputOrgName(urlOrgName string) string {
+	return path.Join("org", urlOrgName)
+}
+
+//input hashed to determine the subtree of a shared vault, seperates
+//  the vault subtrees from the user subtrees held in the momma-tree
+func HashInputVaultName(urlVaultName string) string {
+	return path.Join("vault", urlVaultName)
+}
+
+//shared vault records are encrypted with the vault key rather than
+//  the master username/password
+func HashInputVaultCIdNameEncryption(vaultKey string) string {
+	return vaultKey
+}
+
+func HashInputVaultCPasswordEncryption(vaultKey, urlCIdName string) string {
+	return path.Join(urlCIdName, vaultKey)
+}
+
+////////////////////////////
+//Associated Data
+////////////////////////////
+
+//associated data binds a ciphertext to its location within the tree, a ciphertext
+//  copied to another location fails to decrypt. usernameHashed is the vault hash
+//  for the records of a shared vault.
+
+//the verifier of an account
+func AssociatedDataVerifier(usernameHashed string) string {
+	return path.Join(usernameHashed, "verifier")
+}
+
+//the recovery key wrapped by a recovery code
+func AssociatedDataRecoveryCode(usernameHashed, codeHashed string) string {
+	return path.Join(usernameHashed, codeHashed, "recoveryCode")
+}
+
+//an entry of the cIdList, the identifier isn't known until the entry is decrypted
+//  so entries are bound to the account and the cIdList only
+func AssociatedDataCIdName(usernameHashed string) string {
+	return path.Join(usernameHashed, "cIdName")
+}
+
+//the encrypted name of a record field
+func AssociatedDataFieldName(usernameHashed, cIdNameHashed string) string {
+	return path.Join(usernameHashed, cIdNameHashed, "fieldName")
+}
+
+//the encrypted value of a record field
+func AssociatedDataFieldValue(usernameHashed, cIdNameHashed, fieldName string) string {
+	return path.Join(usernameHashed, cIdNameHashed, "field", fieldName)
+}
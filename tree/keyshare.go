@@ -0,0 +1,436 @@
+//split vault keys, the owner of a vault may split the vault key k-of-n across custodians with
+//  shamir's secret sharing. To reconstruct the key a user requests reconstruction, which the
+//  vault owner or an organization admin of the collection approves, custodians release their
+//  share re-wrapped to the requester, and once the threshold of shares has been released the
+//  requester becomes a member of the vault
+package tree
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+//a share of a vault key wrapped to the public key of its custodian
+type KeyShare struct {
+	CustodianHashed string
+	WrappedShare    string
+}
+
+//encode the key shares for a tx as custodianHashed:wrappedShare;...
+func EncodeKeyShares(shares []KeyShare) string {
+
+	if len(shares) < 1 {
+		return emptyTxList
+	}
+
+	encoded := make([]string, len(shares))
+	for i, share := range shares {
+		encoded[i] = share.CustodianHashed + ":" + share.WrappedShare
+	}
+	return strings.Join(encoded, ";")
+}
+
+func DecodeKeyShares(encoded string) (shares []KeyShare, err error) {
+
+	if encoded == emptyTxList {
+		return
+	}
+
+	for _, pair := range strings.Split(encoded, ";") {
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 || len(parts[0]) < 1 || len(parts[1]) < 1 {
+			err = errors.New("bad key share list")
+			return
+		}
+		shares = append(shares, KeyShare{parts[0], parts[1]})
+	}
+	return
+}
+
+//status of a reconstruction request
+const (
+	reconstructionPending  string = "pending"
+	reconstructionApproved string = "approved"
+)
+
+//value held for a reconstruction request, the requester and the status of the request
+func getReconstructionValue(requesterUsernameHashed string, approved bool) []byte {
+	status := reconstructionPending
+	if approved {
+		status = reconstructionApproved
+	}
+	return []byte(requesterUsernameHashed + "/" + status)
+}
+
+func readReconstructionValue(value []byte) (requesterUsernameHashed string, approved bool, err error) {
+	parts := strings.Split(string(value), "/")
+	if len(parts) != 2 || len(parts[0]) < 1 ||
+		(parts[1] != reconstructionPending && parts[1] != reconstructionApproved) {
+		err = errors.New("bad reconstruction record")
+		return
+	}
+	return parts[0], parts[1] == reconstructionApproved, nil
+}
+
+//the requester of the reconstruction of a vault key and whether the request is approved
+func getReconstruction(subTree TreeReading, vaultHashed string) (
+	requesterUsernameHashed string, approved, requested bool) {
+
+	_, value, requested := subTree.Get(getReconstructionKey(vaultHashed))
+	if !requested {
+		return
+	}
+	requesterUsernameHashed, approved, err := readReconstructionValue(value)
+	requested = err == nil
+	return
+}
+
+//value held for the split of a vault key, the threshold and the custodians
+func getKeySplitValue(threshold int, custodians []string) []byte {
+	return []byte(strconv.Itoa(threshold) + "/" + strings.Join(custodians, ","))
+}
+
+func getKeySplit(tree TreeReading, vaultHashed string) (threshold int, custodians []string, err error) {
+
+	subTree, err := tree.LoadSubTree(vaultHashed)
+	if err != nil {
+		err = errors.New("vault doesn't exist")
+		return
+	}
+
+	_, value, exists := subTree.Get(getKeySplitKey(vaultHashed))
+	if !exists {
+		err = errors.New("vault key has not been split")
+		return
+	}
+
+	parts := strings.Split(string(value), "/")
+	if len(parts) != 2 {
+		err = errors.New("bad key split record")
+		return
+	}
+	threshold, err = strconv.Atoi(parts[0])
+	if err != nil {
+		err = errors.New("bad key split record")
+		return
+	}
+	custodians = strings.Split(parts[1], ",")
+	return
+}
+
+//remove any reconstruction request along with the shares released to it
+func clearReconstruction(subTree TreeWriting, vaultHashed string, custodians []string) {
+
+	requester, _, requested := getReconstruction(subTree, vaultHashed)
+	subTree.Remove(getReconstructionKey(vaultHashed))
+	if !requested {
+		return
+	}
+	for _, custodian := range custodians {
+		subTree.Remove(getReleasedShareKey(vaultHashed, requester, custodian))
+	}
+}
+
+/////////////////////////////////////////////
+//   WRITE Key Share Operations
+////////////////////////////////////////////
+
+//split the vault key across custodians, only the owner may split the vault key and each
+//  custodian must have a published key. Any previous split and reconstruction is replaced
+func (ptw *PwkTreeWriter) SplitVaultKey(vaultHashed, ownerUsernameHashed string,
+	threshold int, shares []KeyShare) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	var ownerStatus string
+	ownerStatus, err = ptw.getVaultMemberStatus(vaultHashed, ownerUsernameHashed)
+	if err != nil {
+		return
+	}
+	if ownerStatus != VaultOwner {
+		err = errors.New("only the vault owner may split the vault key")
+		return
+	}
+
+	if threshold < 1 || threshold > len(shares) || len(shares) > 255 {
+		err = errors.New("invalid threshold or number of shares")
+		return
+	}
+
+	custodians := make([]string, len(shares))
+	seen := make(map[string]bool)
+	for i, share := range shares {
+		if seen[share.CustodianHashed] {
+			err = errors.New("duplicate custodian")
+			return
+		}
+		seen[share.CustodianHashed] = true
+
+		if !ptw.tree.Has(getPublicKeyKey(share.CustodianHashed)) {
+			err = errors.New("custodian has no published public key")
+			return
+		}
+		custodians[i] = share.CustodianHashed
+	}
+
+	subTree, _ := ptw.tree.LoadSubTree(vaultHashed)
+
+	//remove the previous split
+	_, previousCustodians, previousErr := getKeySplit(ptw.tree, vaultHashed)
+	if previousErr == nil {
+		clearReconstruction(subTree, vaultHashed, previousCustodians)
+		for _, custodian := range previousCustodians {
+			subTree.Remove(getKeyShareKey(vaultHashed, custodian))
+		}
+	}
+
+	subTree.Set(getKeySplitKey(vaultHashed), getKeySplitValue(threshold, custodians))
+	for _, share := range shares {
+		subTree.Set(getKeyShareKey(vaultHashed, share.CustodianHashed), []byte(share.WrappedShare))
+	}
+	ptw.tree.SaveSubTree(vaultHashed, subTree)
+
+	return
+}
+
+//request reconstruction of a split vault key, the request must be approved by the vault owner
+//  or an organization admin of the collection before any share is released. A pending request
+//  may only be cancelled rather than replaced. The requester must have a published key and may
+//  not already be a member of the vault
+func (ptw *PwkTreeWriter) RequestReconstruction(vaultHashed, requesterUsernameHashed string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	_, _, err = getKeySplit(ptw.tree, vaultHashed)
+	if err != nil {
+		return
+	}
+
+	status, _ := ptw.getVaultMemberStatus(vaultHashed, requesterUsernameHashed)
+	if status == VaultOwner || status == VaultMember {
+		err = errors.New("requester is already a vault member")
+		return
+	}
+	if !ptw.tree.Has(getPublicKeyKey(requesterUsernameHashed)) {
+		err = errors.New("requester has no published public key")
+		return
+	}
+
+	subTree, _ := ptw.tree.LoadSubTree(vaultHashed)
+	if subTree.Has(getReconstructionKey(vaultHashed)) {
+		err = errors.New("reconstruction already requested")
+		return
+	}
+	subTree.Set(getReconstructionKey(vaultHashed), getReconstructionValue(requesterUsernameHashed, false))
+	ptw.tree.SaveSubTree(vaultHashed, subTree)
+
+	return
+}
+
+//approve the pending reconstruction request of the requester, only the vault owner or an
+//  organization admin of the collection may approve reconstruction
+func (ptw *PwkTreeWriter) ApproveReconstruction(vaultHashed, approverUsernameHashed,
+	requesterUsernameHashed string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	err = ptw.verifyVaultAuthority(vaultHashed, approverUsernameHashed)
+	if err != nil {
+		return
+	}
+
+	subTree, _ := ptw.tree.LoadSubTree(vaultHashed)
+	requester, approved, requested := getReconstruction(subTree, vaultHashed)
+	if !requested || requester != requesterUsernameHashed {
+		err = errors.New("reconstruction not requested")
+		return
+	}
+	if approved {
+		err = errors.New("reconstruction already approved")
+		return
+	}
+
+	subTree.Set(getReconstructionKey(vaultHashed), getReconstructionValue(requesterUsernameHashed, true))
+	ptw.tree.SaveSubTree(vaultHashed, subTree)
+
+	return
+}
+
+//cancel the reconstruction request along with the shares released to it, either as the
+//  requester or as the vault owner or an organization admin of the collection
+func (ptw *PwkTreeWriter) CancelReconstruction(vaultHashed, actorUsernameHashed string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	var custodians []string
+	_, custodians, err = getKeySplit(ptw.tree, vaultHashed)
+	if err != nil {
+		return
+	}
+
+	subTree, _ := ptw.tree.LoadSubTree(vaultHashed)
+	requester, _, requested := getReconstruction(subTree, vaultHashed)
+	if !requested {
+		err = errors.New("reconstruction not requested")
+		return
+	}
+	if requester != actorUsernameHashed {
+		err = ptw.verifyVaultAuthority(vaultHashed, actorUsernameHashed)
+		if err != nil {
+			return
+		}
+	}
+
+	clearReconstruction(subTree, vaultHashed, custodians)
+	ptw.tree.SaveSubTree(vaultHashed, subTree)
+
+	return
+}
+
+//release a custodian's share to the requester of the approved reconstruction, the share
+//  is re-wrapped to the requester's public key by the custodian and bound to the requester
+func (ptw *PwkTreeWriter) ReleaseKeyShare(vaultHashed, custodianUsernameHashed,
+	requesterUsernameHashed, wrappedShare string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	subTree, err := ptw.tree.LoadSubTree(vaultHashed)
+	if err != nil {
+		err = errors.New("vault doesn't exist")
+		return
+	}
+	requester, approved, requested := getReconstruction(subTree, vaultHashed)
+	if !requested || requester != requesterUsernameHashed {
+		err = errors.New("reconstruction not requested")
+		return
+	}
+	if !approved {
+		err = errors.New("reconstruction not approved")
+		return
+	}
+	if !subTree.Has(getKeyShareKey(vaultHashed, custodianUsernameHashed)) {
+		err = errors.New("not a custodian of the vault key")
+		return
+	}
+
+	subTree.Set(getReleasedShareKey(vaultHashed, requester, custodianUsernameHashed), []byte(wrappedShare))
+	ptw.tree.SaveSubTree(vaultHashed, subTree)
+
+	return
+}
+
+//complete the reconstruction once the threshold of shares has been released, the requester
+//  becomes a vault member holding the reconstructed vault key wrapped to their public key.
+//  Note the reconstructed key itself cannot be verified here, only the number of shares released
+func (ptw *PwkTreeWriter) ReconstructVaultKey(vaultHashed, requesterUsernameHashed, wrappedKey string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	var threshold int
+	var custodians []string
+	threshold, custodians, err = getKeySplit(ptw.tree, vaultHashed)
+	if err != nil {
+		return
+	}
+
+	subTree, _ := ptw.tree.LoadSubTree(vaultHashed)
+	requester, approved, requested := getReconstruction(subTree, vaultHashed)
+	if !requested || requester != requesterUsernameHashed {
+		err = errors.New("reconstruction not requested")
+		return
+	}
+	if !approved {
+		err = errors.New("reconstruction not approved")
+		return
+	}
+
+	released := 0
+	for _, custodian := range custodians {
+		if subTree.Has(getReleasedShareKey(vaultHashed, requester, custodian)) {
+			released++
+		}
+	}
+	if released < threshold {
+		err = errors.New("threshold of shares not released")
+		return
+	}
+
+	clearReconstruction(subTree, vaultHashed, custodians)
+	subTree.Set(getVaultMemberKey(vaultHashed, requesterUsernameHashed), getVaultMemberValue(VaultMember, wrappedKey))
+	ptw.tree.SaveSubTree(vaultHashed, subTree)
+
+	return
+}
+
+/////////////////////////////////////////////
+//   READ Key Share Operations
+////////////////////////////////////////////
+
+//retrieve the threshold and custodians of a split vault key
+func (ptr *PwkTreeReader) RetrieveKeySplit(vaultHashed string) (threshold int, custodians []string, err error) {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	return getKeySplit(ptr.tree, vaultHashed)
+}
+
+//retrieve a custodian's share wrapped to their public key
+func (ptr *PwkTreeReader) RetrieveKeyShare(vaultHashed, custodianUsernameHashed string) (wrappedShare string, err error) {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	subTree, err := ptr.tree.LoadSubTree(vaultHashed)
+	if err != nil {
+		err = errors.New("vault doesn't exist")
+		return
+	}
+
+	_, value, exists := subTree.Get(getKeyShareKey(vaultHashed, custodianUsernameHashed))
+	if !exists {
+		err = errors.New("not a custodian of the vault key")
+		return
+	}
+
+	wrappedShare = string(value)
+	return
+}
+
+//retrieve the requester of a reconstruction, whether the request is approved, and the
+//  shares released to the requester
+func (ptr *PwkTreeReader) RetrieveReconstruction(vaultHashed string) (
+	requesterUsernameHashed string, approved bool, releasedShares []string, err error) {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	var custodians []string
+	_, custodians, err = getKeySplit(ptr.tree, vaultHashed)
+	if err != nil {
+		return
+	}
+
+	subTree, _ := ptr.tree.LoadSubTree(vaultHashed)
+	var requested bool
+	requesterUsernameHashed, approved, requested = getReconstruction(subTree, vaultHashed)
+	if !requested {
+		err = errors.New("reconstruction not requested")
+		return
+	}
+
+	for _, custodian := range custodians {
+		_, releasedShare, released := subTree.Get(getReleasedShareKey(vaultHashed, requesterUsernameHashed, custodian))
+		if released {
+			releasedShares = append(releasedShares, string(releasedShare))
+		}
+	}
+	return
+}
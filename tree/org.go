@@ -0,0 +1,471 @@
+//organizations, subtrees holding the roles of members and the shared vaults
+//  (collections) which members may access according to their role
+package tree
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+//the format of the timestamp leading each tx, the timestamps of the signed txs of a
+//  user must increase so that a signed tx may not be replayed
+const TxTimeFormat string = time.RFC3339Nano
+
+var lastTxTime struct {
+	sync.Mutex
+	t time.Time
+}
+
+//the timestamp of a new tx, consecutive timestamps strictly increase even where the
+//  clock has a coarse resolution so that txs created in order are applied in order
+func NewTxTimestamp() string {
+
+	lastTxTime.Lock()
+	defer lastTxTime.Unlock()
+
+	t := time.Now().UTC()
+	if !t.After(lastTxTime.t) {
+		t = lastTxTime.t.Add(time.Nanosecond)
+	}
+	lastTxTime.t = t
+	return t.Format(TxTimeFormat)
+}
+
+//roles of a member within an organization
+const (
+	OrgOwner    string = "owner"
+	OrgAdmin    string = "admin"
+	OrgMember   string = "member"
+	OrgReadOnly string = "readonly"
+)
+
+//rank of a role, users without a role have a rank of zero
+func OrgRoleRank(role string) int {
+	switch role {
+	case OrgOwner:
+		return 4
+	case OrgAdmin:
+		return 3
+	case OrgMember:
+		return 2
+	case OrgReadOnly:
+		return 1
+	default:
+		return 0
+	}
+}
+
+/////////////////////////////////////////////
+//   WRITE Organization Operations
+////////////////////////////////////////////
+
+//publish the signing key of a user, a published key cannot be replaced
+func (ptw *PwkTreeWriter) PublishSigningKey(usernameHashed, signingKey string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	if ptw.tree.Has(getSigningKeyKey(usernameHashed)) {
+		err = errors.New("signing key already published")
+		return
+	}
+
+	ptw.tree.Set(getSigningKeyKey(usernameHashed), []byte(signingKey))
+	return
+}
+
+func (ptw *PwkTreeWriter) GetSigningKey(usernameHashed string) (signingKey string, err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	_, value, exists := ptw.tree.Get(getSigningKeyKey(usernameHashed))
+	if !exists {
+		err = errors.New("signing key not published")
+		return
+	}
+
+	signingKey = string(value)
+	return
+}
+
+//verify the timestamp of a signed tx follows the timestamp of the last signed tx of the user
+func (ptw *PwkTreeWriter) VerifySignedTxTime(usernameHashed, timestamp string) error {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	txTime, err := time.Parse(TxTimeFormat, timestamp)
+	if err != nil {
+		return errors.New("bad tx timestamp")
+	}
+
+	_, value, exists := ptw.tree.Get(getSignedTxTimeKey(usernameHashed))
+	if !exists {
+		return nil
+	}
+	lastTime, err := time.Parse(TxTimeFormat, string(value))
+	if err != nil {
+		return err
+	}
+	if !txTime.After(lastTime) {
+		return errors.New("tx timestamp does not follow the last signed tx")
+	}
+	return nil
+}
+
+//record the timestamp of a signed tx of the user, verified upstream within CheckTx
+func (ptw *PwkTreeWriter) RecordSignedTxTime(usernameHashed, timestamp string) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	ptw.tree.Set(getSignedTxTimeKey(usernameHashed), []byte(timestamp))
+}
+
+//retrieve the published signing key of a user, used to verify the requests of end-to-end clients
+func (ptr *PwkTreeReader) RetrieveSigningKey(usernameHashed string) (signingKey string, err error) {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	_, value, exists := ptr.tree.Get(getSigningKeyKey(usernameHashed))
+	if !exists {
+		err = errors.New("signing key not published")
+		return
+	}
+
+	signingKey = string(value)
+	return
+}
+
+func (ptw *PwkTreeWriter) NewOrg(orgHashed, ownerUsernameHashed string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	if ptw.tree.Has(getMapKey(orgHashed)) {
+		err = errors.New("organization already exists")
+		return
+	}
+
+	subTree := ptw.tree.NewSubTree(orgHashed)
+	subTree.Set(getOrgRoleKey(orgHashed, ownerUsernameHashed), []byte(OrgOwner))
+	subTree.Set(getOrgCollectionListKey(orgHashed), []byte("/"))
+
+	ptw.tree.SaveSubTree(orgHashed, subTree)
+	return
+}
+
+func (ptw *PwkTreeWriter) GetOrgRole(orgHashed, usernameHashed string) (role string, err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	return ptw.getOrgRole(orgHashed, usernameHashed)
+}
+
+func (ptw *PwkTreeWriter) getOrgRole(orgHashed, usernameHashed string) (role string, err error) {
+
+	subTree, err := ptw.tree.LoadSubTree(orgHashed)
+	if err != nil {
+		err = errors.New("organization doesn't exist")
+		return
+	}
+
+	_, value, _ := subTree.Get(getOrgRoleKey(orgHashed, usernameHashed))
+	role = string(value)
+	return
+}
+
+//determine if the actor may assign or remove the role of a member
+//  owners may manage anyone else, admins may only manage members and read-only members
+func (ptw *PwkTreeWriter) verifyOrgAuthority(orgHashed, actorUsernameHashed,
+	memberUsernameHashed, role string) (err error) {
+
+	if actorUsernameHashed == memberUsernameHashed {
+		return errors.New("members may not manage their own role")
+	}
+
+	var actorRole, memberRole string
+	actorRole, err = ptw.getOrgRole(orgHashed, actorUsernameHashed)
+	if err != nil {
+		return
+	}
+	memberRole, err = ptw.getOrgRole(orgHashed, memberUsernameHashed)
+	if err != nil {
+		return
+	}
+
+	switch {
+	case OrgRoleRank(actorRole) < OrgRoleRank(OrgAdmin):
+		err = errors.New("only organization owners and admins may manage members")
+	case actorRole != OrgOwner &&
+		(OrgRoleRank(memberRole) >= OrgRoleRank(OrgAdmin) || OrgRoleRank(role) >= OrgRoleRank(OrgAdmin)):
+		err = errors.New("only organization owners may manage owners and admins")
+	}
+	return
+}
+
+//assign a role to a user within an organization, the user must have a published signing key.
+//  Lowering the role of a member revokes their membership to the collections the role may no
+//  longer read
+func (ptw *PwkTreeWriter) SetOrgRole(orgHashed, actorUsernameHashed,
+	memberUsernameHashed, role string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	if OrgRoleRank(role) < 1 {
+		err = errors.New("invalid organization role")
+		return
+	}
+
+	if !ptw.tree.Has(getSigningKeyKey(memberUsernameHashed)) {
+		err = errors.New("member has no published signing key")
+		return
+	}
+
+	err = ptw.verifyOrgAuthority(orgHashed, actorUsernameHashed, memberUsernameHashed, role)
+	if err != nil {
+		return
+	}
+
+	subTree, _ := ptw.tree.LoadSubTree(orgHashed)
+	subTree.Set(getOrgRoleKey(orgHashed, memberUsernameHashed), []byte(role))
+	ptw.tree.SaveSubTree(orgHashed, subTree)
+
+	ptw.revokeCollectionMemberships(subTree, orgHashed, memberUsernameHashed, role)
+
+	return
+}
+
+//remove a user from an organization along with their membership to each of the
+//  organization's collections (other than collections they own)
+func (ptw *PwkTreeWriter) RemoveOrgMember(orgHashed, actorUsernameHashed,
+	memberUsernameHashed string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	var memberRole string
+	memberRole, err = ptw.getOrgRole(orgHashed, memberUsernameHashed)
+	if err != nil {
+		return
+	}
+	if len(memberRole) < 1 {
+		err = errors.New("member to remove doesn't exist")
+		return
+	}
+
+	err = ptw.verifyOrgAuthority(orgHashed, actorUsernameHashed, memberUsernameHashed, "")
+	if err != nil {
+		return
+	}
+
+	subTree, _ := ptw.tree.LoadSubTree(orgHashed)
+	subTree.Remove(getOrgRoleKey(orgHashed, memberUsernameHashed))
+	ptw.tree.SaveSubTree(orgHashed, subTree)
+
+	ptw.revokeCollectionMemberships(subTree, orgHashed, memberUsernameHashed, "")
+
+	return
+}
+
+//revoke the membership of a user to each of the organization's collections which their role
+//  may not read (other than collections they own), a user without a role is revoked from every
+//  collection
+func (ptw *PwkTreeWriter) revokeCollectionMemberships(orgSubTree TreeWriting, orgHashed,
+	memberUsernameHashed, role string) {
+
+	_, collectionList, _ := orgSubTree.Get(getOrgCollectionListKey(orgHashed))
+	for _, vaultHashed := range strings.Split(string(collectionList), "/") {
+		if len(vaultHashed) < 1 {
+			continue
+		}
+
+		_, permissions, _ := orgSubTree.Get(getOrgCollectionKey(orgHashed, vaultHashed))
+		readRole := strings.Split(string(permissions), "/")[0]
+		if len(role) > 0 && OrgRoleRank(role) >= OrgRoleRank(readRole) {
+			continue
+		}
+
+		vaultSubTree, loadErr := ptw.tree.LoadSubTree(vaultHashed)
+		if loadErr != nil {
+			continue
+		}
+
+		memberKey := getVaultMemberKey(vaultHashed, memberUsernameHashed)
+		_, value, exists := vaultSubTree.Get(memberKey)
+		if !exists {
+			continue
+		}
+		if status, _, _ := readVaultMemberValue(value); status == VaultOwner {
+			continue
+		}
+
+		vaultSubTree.Remove(memberKey)
+		ptw.tree.SaveSubTree(vaultHashed, vaultSubTree)
+	}
+}
+
+//add a shared vault to an organization as a collection along with the minimum roles
+//  required to read and write the collection, the actor must own the vault and be
+//  an owner or admin of the organization
+func (ptw *PwkTreeWriter) AddOrgCollection(orgHashed, actorUsernameHashed,
+	vaultHashed, readRole, writeRole string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	if OrgRoleRank(readRole) < 1 || OrgRoleRank(writeRole) < 1 {
+		err = errors.New("invalid organization role")
+		return
+	}
+
+	var actorRole, vaultStatus string
+	actorRole, err = ptw.getOrgRole(orgHashed, actorUsernameHashed)
+	if err != nil {
+		return
+	}
+	if OrgRoleRank(actorRole) < OrgRoleRank(OrgAdmin) {
+		err = errors.New("only organization owners and admins may add collections")
+		return
+	}
+
+	vaultStatus, err = ptw.getVaultMemberStatus(vaultHashed, actorUsernameHashed)
+	if err != nil {
+		return
+	}
+	if vaultStatus != VaultOwner {
+		err = errors.New("only the vault owner may add a vault as a collection")
+		return
+	}
+
+	vaultSubTree, _ := ptw.tree.LoadSubTree(vaultHashed)
+	if vaultSubTree.Has(getVaultOrgKey(vaultHashed)) {
+		err = errors.New("vault is already a collection")
+		return
+	}
+	vaultSubTree.Set(getVaultOrgKey(vaultHashed), []byte(orgHashed))
+	ptw.tree.SaveSubTree(vaultHashed, vaultSubTree)
+
+	subTree, _ := ptw.tree.LoadSubTree(orgHashed)
+	subTree.Set(getOrgCollectionKey(orgHashed, vaultHashed), []byte(readRole+"/"+writeRole))
+	_, collectionList, _ := subTree.Get(getOrgCollectionListKey(orgHashed))
+	subTree.Set(getOrgCollectionListKey(orgHashed), []byte(string(collectionList)+vaultHashed+"/"))
+	ptw.tree.SaveSubTree(orgHashed, subTree)
+
+	return
+}
+
+//retrieve the role of a user within the organization a vault is a collection of,
+//  along with the collection permissions. isCollection is false for other vaults
+func (ptw *PwkTreeWriter) GetCollectionAccess(vaultHashed, usernameHashed string) (
+	isCollection bool, role, readRole, writeRole string, err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	return getCollectionAccess(ptw.tree, vaultHashed, usernameHashed)
+}
+
+func (ptw *PwkTreeWriter) VerifyVaultAuthority(vaultHashed, actorUsernameHashed string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	return ptw.verifyVaultAuthority(vaultHashed, actorUsernameHashed)
+}
+
+//verify the actor may manage (invite or revoke) the members of a vault, either as the
+//  vault owner, or as an organization owner/admin holding the key of a collection
+func (ptw *PwkTreeWriter) verifyVaultAuthority(vaultHashed, actorUsernameHashed string) (err error) {
+
+	var status string
+	status, err = ptw.getVaultMemberStatus(vaultHashed, actorUsernameHashed)
+	if err != nil || status == VaultOwner {
+		return
+	}
+
+	isCollection, role, _, _, _ := getCollectionAccess(ptw.tree, vaultHashed, actorUsernameHashed)
+	if isCollection && status == VaultMember && OrgRoleRank(role) >= OrgRoleRank(OrgAdmin) {
+		return
+	}
+
+	return errors.New("only the vault owner or organization admins may manage members")
+}
+
+func getCollectionAccess(tree TreeReading, vaultHashed, usernameHashed string) (
+	isCollection bool, role, readRole, writeRole string, err error) {
+
+	vaultSubTree, err := tree.LoadSubTree(vaultHashed)
+	if err != nil {
+		err = errors.New("vault doesn't exist")
+		return
+	}
+
+	_, orgHashed, isCollection := vaultSubTree.Get(getVaultOrgKey(vaultHashed))
+	if !isCollection {
+		return
+	}
+
+	orgSubTree, err := tree.LoadSubTree(string(orgHashed))
+	if err != nil {
+		err = errors.New("organization doesn't exist")
+		return
+	}
+
+	_, roleValue, _ := orgSubTree.Get(getOrgRoleKey(string(orgHashed), usernameHashed))
+	role = string(roleValue)
+
+	_, permissions, _ := orgSubTree.Get(getOrgCollectionKey(string(orgHashed), vaultHashed))
+	permissionParts := strings.Split(string(permissions), "/")
+	if len(permissionParts) != 2 {
+		err = errors.New("bad collection record")
+		return
+	}
+	readRole, writeRole = permissionParts[0], permissionParts[1]
+
+	return
+}
+
+/////////////////////////////////////////////
+//   READ Organization Operations
+////////////////////////////////////////////
+
+func (ptr *PwkTreeReader) OrgExists(orgHashed string) bool {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	return ptr.tree.Has(getMapKey(orgHashed))
+}
+
+func (ptr *PwkTreeReader) RetrieveOrgRole(orgHashed, usernameHashed string) (role string, err error) {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	subTree, err := ptr.tree.LoadSubTree(orgHashed)
+	if err != nil {
+		err = errors.New("organization doesn't exist")
+		return
+	}
+
+	_, value, _ := subTree.Get(getOrgRoleKey(orgHashed, usernameHashed))
+	role = string(value)
+	return
+}
+
+//retrieve the role of a user within the organization a vault is a collection of
+func (ptr *PwkTreeReader) RetrieveCollectionAccess(vaultHashed, usernameHashed string) (
+	isCollection bool, role, readRole, writeRole string, err error) {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	return getCollectionAccess(ptr.tree, vaultHashed, usernameHashed)
+}
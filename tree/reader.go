@@ -57,6 +57,16 @@ func (ptr *PwkTreeReader) SetVariables(
 	}
 }
 
+//decrypt ciphertexts bound to their associated data, ciphertexts sealed before associated
+//  data was supported are only read (readingLegacy) by the reads of an account or vault
+//  resealing them in a cipher suite migration
+func boundDecrypter(readingLegacy bool) func(hashInput, encryptedString, associatedData string) (*cry.Secret, error) {
+	if readingLegacy {
+		return cry.DecryptLegacySecret
+	}
+	return cry.DecryptSecret
+}
+
 /////////////////////////////////////////////
 //   Subtree Management
 ////////////////////////////////////////////
@@ -73,34 +83,66 @@ func (ptr *PwkTreeReader) loadSubTree() (TreeReading, error) {
 // Main Functions
 /////////////////////////////
 
-//authenticate the master password if
-func (ptr *PwkTreeReader) AuthMasterPassword() bool {
+//verifier decrypted in place of the verifier of usernames which don't exist, of the
+//  same length as an account verifier but which never authenticates
+var dummyVerifierEncrypted string
+
+func init() {
+	var err error
+	dummyVerifierEncrypted, err = cry.GetEncryptedHexString("dummyVerifier", strings.Repeat("-", len(VerifierCanary)))
+	if err != nil {
+		panic(err)
+	}
+}
+
+//authenticate the master password against the account verifier, the verifier
+//  is decrypted whether or not the username exists so that authentication
+//  takes equivalent work in both cases
+func (ptr *PwkTreeReader) AuthMasterPassword(readingLegacy bool) bool {
 
 	ptr.mtx.Lock()
 	defer ptr.mtx.Unlock()
 
-	//first check if the username exists
-	if !ptr.tree.Has(getMapKey(ptr.rVar.usernameHashed)) {
-		return false
-	}
+	verifierEncrypted := []byte(dummyVerifierEncrypted)
+	accountExists := false
 
-	_, err := ptr.retrieveCIdNames()
-	if err != nil {
-		return false
+	subTree, err := ptr.loadSubTree()
+	if err == nil {
+		var storedVerifierEncrypted []byte
+		_, storedVerifierEncrypted, accountExists = subTree.Get(GetVerifierKey(ptr.rVar.usernameHashed))
+		if accountExists {
+			verifierEncrypted = storedVerifierEncrypted
+		}
 	}
-	return true
+
+	verifier, err := readDecryptedWith(boundDecrypter(readingLegacy), ptr.rVar.hashInputCIdNameEncryption,
+		string(verifierEncrypted), AssociatedDataVerifier(ptr.rVar.usernameHashed))
+	return accountExists && err == nil && verifier == VerifierCanary
 }
 
-//retrieve and decrypt the list of saved passwords under and account
-func (ptr *PwkTreeReader) RetrieveCIdNames() (cIdNames []string, err error) {
+//determine if an account has been registered for the username
+func (ptr *PwkTreeReader) AccountExists() bool {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	return ptr.tree.Has(getMapKey(ptr.rVar.usernameHashed))
+}
+
+//the usernames of deleted accounts are retired and may not be registered again
+func (ptr *PwkTreeReader) AccountRetired() bool {
 
 	ptr.mtx.Lock()
 	defer ptr.mtx.Unlock()
 
-	return ptr.retrieveCIdNames()
+	return ptr.tree.Has(getRetiredAccountKey(ptr.rVar.usernameHashed))
 }
 
-func (ptr *PwkTreeReader) retrieveCIdNames() (cIdNames []string, err error) {
+//retrieve and decrypt the list of saved passwords under and account
+func (ptr *PwkTreeReader) RetrieveCIdNames(readingLegacy bool) (cIdNames []string, err error) {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
 
 	var subTree TreeReading
 	subTree, err = ptr.loadSubTree()
@@ -122,7 +164,12 @@ func (ptr *PwkTreeReader) retrieveCIdNames() (cIdNames []string, err error) {
 			if len(cIdNames[i]) < 1 {
 				continue
 			}
-			cIdNames[i], err = cry.ReadDecrypted(ptr.rVar.hashInputCIdNameEncryption, cIdNames[i])
+			cIdNames[i], err = readDecryptedWith(boundDecrypter(readingLegacy), ptr.rVar.hashInputCIdNameEncryption,
+				cIdNames[i], AssociatedDataCIdName(ptr.rVar.usernameHashed))
+			if err != nil {
+				cIdNames = nil
+				return
+			}
 		}
 		return
 	} else {
@@ -134,9 +181,105 @@ func (ptr *PwkTreeReader) retrieveCIdNames() (cIdNames []string, err error) {
 //retrieve and decrypt a saved password given an account and id information
 func (ptr *PwkTreeReader) RetrieveCPassword() (cPassword string, err error) {
 
+	var secret *cry.Secret
+	secret, err = ptr.RetrieveCPasswordSecret()
+	if err != nil {
+		return
+	}
+
+	cPassword = string(secret.Bytes())
+	secret.Wipe()
+	return
+}
+
+//retrieve and decrypt a saved password as a secret to be wiped by the caller
+func (ptr *PwkTreeReader) RetrieveCPasswordSecret() (cPassword *cry.Secret, err error) {
+
+	var fields map[string]*cry.Secret
+	fields, err = ptr.RetrieveCRecordSecrets(false)
+	if err != nil {
+		return
+	}
+
+	cPassword = fields[FieldPassword]
+	delete(fields, FieldPassword)
+	WipeRecord(fields)
+	if cPassword == nil {
+		cPassword = cry.NewSecret(0)
+	}
+	return
+}
+
+//retrieve and decrypt all the fields of a saved record given an account and id information
+func (ptr *PwkTreeReader) RetrieveCRecord() (fields map[string]string, err error) {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	var cRecordEncrypted string
+	cRecordEncrypted, err = ptr.getCRecordEncrypted()
+	if err != nil {
+		return
+	}
+
+	secrets, err := readDecryptedRecordSecrets(cry.DecryptSecret, ptr.rVar.hashInputCPasswordEncryption,
+		ptr.rVar.usernameHashed, cry.GetHashedHexString(ptr.rVar.cIdNameUnencrypted), cRecordEncrypted)
+	if err != nil {
+		return
+	}
+	fields = recordStrings(secrets)
+	return
+}
+
+//retrieve and decrypt all the fields of a saved record, each field value is
+//  held as a secret and the record is to be wiped by the caller using WipeRecord
+func (ptr *PwkTreeReader) RetrieveCRecordSecrets(readingLegacy bool) (fields map[string]*cry.Secret, err error) {
+
 	ptr.mtx.Lock()
 	defer ptr.mtx.Unlock()
 
+	var cRecordEncrypted string
+	cRecordEncrypted, err = ptr.getCRecordEncrypted()
+	if err != nil {
+		return
+	}
+
+	fields, err = readDecryptedRecordSecrets(boundDecrypter(readingLegacy), ptr.rVar.hashInputCPasswordEncryption,
+		ptr.rVar.usernameHashed, cry.GetHashedHexString(ptr.rVar.cIdNameUnencrypted), cRecordEncrypted)
+	return
+}
+
+//decrypt the previous values of the record of the id, most recent first. Each record is
+//  to be wiped by the caller using WipeRecord
+func (ptr *PwkTreeReader) RetrieveCRecordHistorySecrets(readingLegacy bool) (history []map[string]*cry.Secret, err error) {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	subTree, err := ptr.loadSubTree()
+	if err != nil {
+		return
+	}
+
+	cIdNameHashed := cry.GetHashedHexString(ptr.rVar.cIdNameUnencrypted)
+	for _, cRecordEncrypted := range readRecordHistory(subTree, ptr.rVar.usernameHashed, cIdNameHashed) {
+		var fields map[string]*cry.Secret
+		fields, err = readDecryptedRecordSecrets(boundDecrypter(readingLegacy), ptr.rVar.hashInputCPasswordEncryption,
+			ptr.rVar.usernameHashed, cIdNameHashed, cRecordEncrypted)
+		if err != nil {
+			for _, previous := range history {
+				WipeRecord(previous)
+			}
+			return nil, err
+		}
+		history = append(history, fields)
+	}
+	return
+}
+
+//the encrypted record value of the id, the caller must hold the mutex
+func (ptr *PwkTreeReader) getCRecordEncrypted() (cRecordEncrypted string, err error) {
+
 	var subTree TreeReading
 	subTree, err = ptr.loadSubTree()
 
@@ -146,8 +289,8 @@ func (ptr *PwkTreeReader) RetrieveCPassword() (cPassword string, err error) {
 
 	cPasswordKey := GetRecordKey(ptr.rVar.usernameHashed, cry.GetHashedHexString(ptr.rVar.cIdNameUnencrypted))
 	if subTree.Has(cPasswordKey) {
-		_, cPasswordEncrypted, _ := subTree.Get(cPasswordKey)
-		cPassword, err = cry.ReadDecrypted(ptr.rVar.hashInputCPasswordEncryption, string(cPasswordEncrypted))
+		_, value, _ := subTree.Get(cPasswordKey)
+		cRecordEncrypted = string(value)
 		return
 	} else {
 		err = errors.New("invalidCIdName")
@@ -185,7 +328,11 @@ func (ptr *PwkTreeReader) GetCIdListEncryptedCIdName() (cIdNameOrigEncrypted str
 			continue
 		}
 		var tempCIdNameDecrypted string
-		tempCIdNameDecrypted, err = cry.ReadDecrypted(ptr.rVar.hashInputCIdNameEncryption, cIdNames[i])
+		tempCIdNameDecrypted, err = readDecryptedWith(cry.DecryptSecret, ptr.rVar.hashInputCIdNameEncryption,
+			cIdNames[i], AssociatedDataCIdName(ptr.rVar.usernameHashed))
+		if err != nil {
+			return
+		}
 
 		//remove record from master list and merkle.Tree
 		if ptr.rVar.cIdNameUnencrypted == tempCIdNameDecrypted {
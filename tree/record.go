@@ -0,0 +1,223 @@
+//record field encoding for the values held at GetRecordKey
+package tree
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	cry "github.com/rigelrozanski/passwerk/crypto"
+)
+
+//well-known record field names
+const (
+	FieldPassword string = "password"
+	FieldUsername string = "username"
+	FieldURL      string = "url"
+	FieldNotes    string = "notes"
+	FieldTOTP     string = "totp"
+	FieldModified string = "modified" //RFC 3339 time the record was last written
+
+	//name used to read the raw otp seed rather than the current code
+	FieldTOTPSeed string = "totpseed"
+
+	//when set to "true" the raw otp seed will never be revealed after it's stored
+	FieldTOTPConcealed string = "totpconcealed"
+)
+
+//a record value is a list of fields seperated by fieldSep, each field is
+//  held as the encrypted field name and encrypted field value seperated
+//  by fieldNameSep. neither seperator can appear within a ciphertext string.
+//  Records written before fields were introduced hold only the
+//  encrypted password and are read as a single password field.
+const fieldSep string = ";"
+const fieldNameSep string = ":"
+
+//return the fields sorted by name with the password field first
+func SortedFieldNames(fields map[string]string) (names []string) {
+	for name := range fields {
+		names = append(names, name)
+	}
+	return sortFieldNames(names)
+}
+
+//return the fields of a decrypted record sorted by name with the password field first
+func SortedSecretFieldNames(fields map[string]*cry.Secret) (names []string) {
+	for name := range fields {
+		names = append(names, name)
+	}
+	return sortFieldNames(names)
+}
+
+func sortFieldNames(names []string) []string {
+
+	var sorted []string
+	hasPassword := false
+	for _, name := range names {
+		if name == FieldPassword {
+			hasPassword = true
+		} else {
+			sorted = append(sorted, name)
+		}
+	}
+	sort.Strings(sorted)
+
+	if hasPassword {
+		sorted = append([]string{FieldPassword}, sorted...)
+	}
+	return sorted
+}
+
+//encrypt each field name and value individually and return the record value to be stored,
+//  each ciphertext is bound to the record and field names are bound to their values.
+//  The fields are sealed with the cipher suite of the account or vault
+func GetEncryptedRecord(suite cry.CipherSuite, hashInputCPasswordEncryption, usernameHashed, cIdNameHashed string,
+	fields map[string]string) (recordValue string, err error) {
+
+	var encryptedFields []string
+	for _, name := range SortedFieldNames(fields) {
+		var encryptedField string
+		encryptedField, err = getEncryptedField(suite, hashInputCPasswordEncryption, usernameHashed, cIdNameHashed,
+			name, []byte(fields[name]))
+		if err != nil {
+			return
+		}
+		encryptedFields = append(encryptedFields, encryptedField)
+	}
+
+	recordValue = strings.Join(encryptedFields, fieldSep)
+	return
+}
+
+//encrypt the fields of a decrypted record and return the record value to be stored
+func GetEncryptedRecordSecrets(suite cry.CipherSuite, hashInputCPasswordEncryption, usernameHashed, cIdNameHashed string,
+	fields map[string]*cry.Secret) (recordValue string, err error) {
+
+	var encryptedFields []string
+	for _, name := range SortedSecretFieldNames(fields) {
+		var encryptedField string
+		encryptedField, err = getEncryptedField(suite, hashInputCPasswordEncryption, usernameHashed, cIdNameHashed,
+			name, fields[name].Bytes())
+		if err != nil {
+			return
+		}
+		encryptedFields = append(encryptedFields, encryptedField)
+	}
+
+	recordValue = strings.Join(encryptedFields, fieldSep)
+	return
+}
+
+func getEncryptedField(suite cry.CipherSuite, hashInputCPasswordEncryption, usernameHashed, cIdNameHashed,
+	name string, value []byte) (encryptedField string, err error) {
+
+	nameEncrypted, err := cry.GetEncryptedBoundHexString(suite, hashInputCPasswordEncryption, name,
+		AssociatedDataFieldName(usernameHashed, cIdNameHashed))
+	if err != nil {
+		return
+	}
+	valueEncrypted, err := cry.EncryptSecret(suite, hashInputCPasswordEncryption, value,
+		AssociatedDataFieldValue(usernameHashed, cIdNameHashed, name))
+	if err != nil {
+		return
+	}
+
+	encryptedField = nameEncrypted + fieldNameSep + valueEncrypted
+	return
+}
+
+//decrypt all the fields held within a record value, see ReadDecryptedRecordSecrets
+//  to avoid holding the decrypted values within immutable strings
+func ReadDecryptedRecord(hashInputCPasswordEncryption, usernameHashed, cIdNameHashed,
+	recordValue string) (fields map[string]string, err error) {
+
+	secrets, err := ReadDecryptedRecordSecrets(hashInputCPasswordEncryption, usernameHashed, cIdNameHashed, recordValue)
+	if err != nil {
+		return
+	}
+	return recordStrings(secrets), nil
+}
+
+//the fields of a decrypted record as strings, the record is wiped
+func recordStrings(secrets map[string]*cry.Secret) map[string]string {
+
+	defer WipeRecord(secrets)
+
+	fields := make(map[string]string)
+	for name, value := range secrets {
+		fields[name] = string(value.Bytes())
+	}
+	return fields
+}
+
+//decrypt into a string with the decrypt function, the secret is wiped
+func readDecryptedWith(decrypt func(hashInput, encryptedString, associatedData string) (*cry.Secret, error),
+	hashInput, encryptedString, associatedData string) (string, error) {
+
+	secret, err := decrypt(hashInput, encryptedString, associatedData)
+	if err != nil {
+		return "", err
+	}
+	decrypted := string(secret.Bytes())
+	secret.Wipe()
+	return decrypted, nil
+}
+
+//decrypt all the fields held within a record value, the field names are held as
+//  strings while each field value is held as a secret to be wiped once used
+func ReadDecryptedRecordSecrets(hashInputCPasswordEncryption, usernameHashed, cIdNameHashed,
+	recordValue string) (fields map[string]*cry.Secret, err error) {
+	return readDecryptedRecordSecrets(cry.DecryptSecret, hashInputCPasswordEncryption, usernameHashed,
+		cIdNameHashed, recordValue)
+}
+
+func readDecryptedRecordSecrets(decrypt func(hashInput, encryptedString, associatedData string) (*cry.Secret, error),
+	hashInputCPasswordEncryption, usernameHashed, cIdNameHashed,
+	recordValue string) (fields map[string]*cry.Secret, err error) {
+
+	fields = make(map[string]*cry.Secret)
+	defer func() {
+		if err != nil {
+			WipeRecord(fields)
+			fields = nil
+		}
+	}()
+
+	//records without field names only hold a password
+	if !strings.Contains(recordValue, fieldNameSep) {
+		fields[FieldPassword], err = decrypt(hashInputCPasswordEncryption, recordValue,
+			AssociatedDataFieldValue(usernameHashed, cIdNameHashed, FieldPassword))
+		return
+	}
+
+	for _, encryptedField := range strings.Split(recordValue, fieldSep) {
+		parts := strings.Split(encryptedField, fieldNameSep)
+		if len(parts) != 2 {
+			err = errors.New("badRecordEncoding")
+			return
+		}
+
+		var name string
+		var value *cry.Secret
+		name, err = readDecryptedWith(decrypt, hashInputCPasswordEncryption, parts[0],
+			AssociatedDataFieldName(usernameHashed, cIdNameHashed))
+		if err != nil {
+			return
+		}
+		value, err = decrypt(hashInputCPasswordEncryption, parts[1],
+			AssociatedDataFieldValue(usernameHashed, cIdNameHashed, name))
+		if err != nil {
+			return
+		}
+		fields[name] = value
+	}
+
+	return
+}
+
+//wipe all the field values of a decrypted record
+func WipeRecord(fields map[string]*cry.Secret) {
+	for _, value := range fields {
+		value.Wipe()
+	}
+}
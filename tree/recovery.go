@@ -0,0 +1,246 @@
+//account recovery codes, each code wraps the recovery key of the account, the master password
+//  as stretched by the cipher suite of the account, from which all of the account keys are
+//  derived. The master password itself is never wrapped. Recovering an account re-encrypts all
+//  of the account records under a new master password, re-wraps the keys wrapped to the account,
+//  and replaces all of the recovery codes
+package tree
+
+import (
+	"errors"
+	"strings"
+)
+
+//a recovery code as held within the account subtree, identified by its hash
+type RecoveryCode struct {
+	CodeHashed           string
+	RecoveryKeyEncrypted string
+}
+
+//a record re-encrypted under a new master password, along with its previous values
+type RekeyedRecord struct {
+	CIdNameHashed    string
+	CIdNameEncrypted string
+	CRecordEncrypted string
+	History          []string //most recent first
+}
+
+//placeholder for an empty list within a tx, as empty tx parts are not preserved
+const emptyTxList string = "-"
+
+//encode the recovery codes for a tx as codeHashed:recoveryKeyEncrypted;...
+func EncodeRecoveryCodes(codes []RecoveryCode) string {
+
+	if len(codes) < 1 {
+		return emptyTxList
+	}
+
+	encoded := make([]string, len(codes))
+	for i, code := range codes {
+		encoded[i] = code.CodeHashed + ":" + code.RecoveryKeyEncrypted
+	}
+	return strings.Join(encoded, ";")
+}
+
+func DecodeRecoveryCodes(encoded string) (codes []RecoveryCode, err error) {
+
+	if encoded == emptyTxList {
+		return
+	}
+
+	for _, pair := range strings.Split(encoded, ";") {
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 || len(parts[0]) < 1 || len(parts[1]) < 1 {
+			err = errors.New("bad recovery code list")
+			return
+		}
+		codes = append(codes, RecoveryCode{parts[0], parts[1]})
+	}
+	return
+}
+
+//encode the records for a tx as cIdNameHashed.cIdNameEncrypted.cRecordEncrypted,... the encrypted
+//  records themselves contain ":" and ";" seperators. Records with a history are followed by
+//  .history1+history2... as the history seperator "/" can't appear within a tx
+const rekeyedHistorySep string = "+"
+
+func EncodeRekeyedRecords(records []RekeyedRecord) string {
+
+	if len(records) < 1 {
+		return emptyTxList
+	}
+
+	encoded := make([]string, len(records))
+	for i, record := range records {
+		parts := []string{record.CIdNameHashed, record.CIdNameEncrypted, record.CRecordEncrypted}
+		if len(record.History) > 0 {
+			parts = append(parts, strings.Join(record.History, rekeyedHistorySep))
+		}
+		encoded[i] = strings.Join(parts, ".")
+	}
+	return strings.Join(encoded, ",")
+}
+
+func DecodeRekeyedRecords(encoded string) (records []RekeyedRecord, err error) {
+
+	if encoded == emptyTxList {
+		return
+	}
+
+	for _, triple := range strings.Split(encoded, ",") {
+		parts := strings.Split(triple, ".")
+		if len(parts) < 3 || len(parts) > 4 || len(parts[0]) < 1 || len(parts[1]) < 1 || len(parts[2]) < 1 {
+			err = errors.New("bad rekeyed record list")
+			return
+		}
+		record := RekeyedRecord{CIdNameHashed: parts[0], CIdNameEncrypted: parts[1], CRecordEncrypted: parts[2]}
+		if len(parts) == 4 {
+			record.History = strings.Split(parts[3], rekeyedHistorySep)
+			if len(record.History) > maxRecordHistory {
+				err = errors.New("bad rekeyed record list")
+				return
+			}
+			for _, cRecordEncrypted := range record.History {
+				if len(cRecordEncrypted) < 1 {
+					err = errors.New("bad rekeyed record list")
+					return
+				}
+			}
+		}
+		records = append(records, record)
+	}
+	return
+}
+
+/////////////////////////////////////////////
+//   WRITE Recovery Operations
+////////////////////////////////////////////
+
+//add recovery codes to the account subtree
+func (ptw *PwkTreeWriter) SetRecoveryCodes(codes []RecoveryCode) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	var subTree TreeWriting
+	subTree, err = ptw.LoadSubTree()
+	if err != nil {
+		err = errors.New("account doesn't exist")
+		return
+	}
+
+	for _, code := range codes {
+		subTree.Set(getRecoveryCodeKey(ptw.wVar.usernameHashed, code.CodeHashed), []byte(code.RecoveryKeyEncrypted))
+	}
+
+	ptw.saveSubTree(subTree)
+	return
+}
+
+func (ptw *PwkTreeWriter) VerifyRecoveryCodeExists(codeHashed string) bool {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	subTree, err := ptw.LoadSubTree()
+	if err != nil {
+		return false
+	}
+	return subTree.Has(getRecoveryCodeKey(ptw.wVar.usernameHashed, codeHashed))
+}
+
+//replace the account subtree with the records and their history re-encrypted under a new master
+//  password, a new verifier, and new recovery codes. The two-factor secret of an enrolled account
+//  must be resealed under the new master password, its backup codes are kept. The public keys
+//  derived from the master password are replaced along with the keys wrapped to the account,
+//  see ReplaceAccountKeys
+func (ptw *PwkTreeWriter) RekeyAccount(usedCodeHashed, verifierEncrypted, publicKey, signingKey,
+	twoFactorSecretEncrypted string, codes []RecoveryCode, records []RekeyedRecord,
+	wrappedKeys []WrappedKey) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	var subTree TreeWriting
+	subTree, err = ptw.LoadSubTree()
+	if err != nil {
+		err = errors.New("account doesn't exist")
+		return
+	}
+	if !subTree.Has(getRecoveryCodeKey(ptw.wVar.usernameHashed, usedCodeHashed)) {
+		err = errors.New("recovery code doesn't exist")
+		return
+	}
+
+	enrolled := subTree.Has(getTwoFactorKey(ptw.wVar.usernameHashed))
+	_, backupCodes, _ := subTree.Get(getTwoFactorBackupCodesKey(ptw.wVar.usernameHashed))
+	_, lastStep, hasStep := subTree.Get(getTwoFactorStepKey(ptw.wVar.usernameHashed))
+	if enrolled && twoFactorSecretEncrypted == emptyTxList {
+		err = errors.New("two-factor secret must be resealed")
+		return
+	}
+
+	//all previous codes are invalidated along with the used code, the
+	//  records remain sealed with the cipher suite of the account. The history
+	//  of deleted records is dropped as it remains sealed under the previous
+	//  master password, and their identifiers aren't known to re-encrypt it
+	_, suiteID, hasSuite := subTree.Get(getCipherSuiteKey(ptw.wVar.usernameHashed))
+	subTree = ptw.newSubTree()
+	subTree.Set(GetVerifierKey(ptw.wVar.usernameHashed), []byte(verifierEncrypted))
+	if hasSuite {
+		subTree.Set(getCipherSuiteKey(ptw.wVar.usernameHashed), suiteID)
+	}
+
+	cIdList := "/"
+	for _, record := range records {
+		cIdList += record.CIdNameEncrypted + "/"
+		subTree.Set(GetRecordKey(ptw.wVar.usernameHashed, record.CIdNameHashed), []byte(record.CRecordEncrypted))
+		setRecordHistory(subTree, ptw.wVar.usernameHashed, record.CIdNameHashed, record.History)
+	}
+	subTree.Set(GetCIdListKey(ptw.wVar.usernameHashed), []byte(cIdList))
+
+	if enrolled {
+		subTree.Set(getTwoFactorKey(ptw.wVar.usernameHashed), []byte(twoFactorSecretEncrypted))
+		subTree.Set(getTwoFactorBackupCodesKey(ptw.wVar.usernameHashed), backupCodes)
+	}
+	if hasStep {
+		subTree.Set(getTwoFactorStepKey(ptw.wVar.usernameHashed), lastStep)
+	}
+
+	for _, code := range codes {
+		subTree.Set(getRecoveryCodeKey(ptw.wVar.usernameHashed, code.CodeHashed), []byte(code.RecoveryKeyEncrypted))
+	}
+
+	err = ptw.replaceAccountKeys(publicKey, signingKey, wrappedKeys)
+	if err != nil {
+		return
+	}
+	ptw.saveSubTree(subTree)
+
+	return
+}
+
+/////////////////////////////////////////////
+//   READ Recovery Operations
+////////////////////////////////////////////
+
+//retrieve the recovery key wrapped by a recovery code
+func (ptr *PwkTreeReader) RetrieveRecoveryCode(codeHashed string) (recoveryKeyEncrypted string, err error) {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	subTree, err := ptr.loadSubTree()
+	if err != nil {
+		err = errors.New("badAuthentication")
+		return
+	}
+
+	_, value, exists := subTree.Get(getRecoveryCodeKey(ptr.rVar.usernameHashed, codeHashed))
+	if !exists {
+		err = errors.New("badAuthentication")
+		return
+	}
+
+	recoveryKeyEncrypted = string(value)
+	return
+}
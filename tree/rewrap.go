@@ -0,0 +1,340 @@
+//keys wrapped to the public key of an account, held within shared vaults as the vault key of a
+//  member or emergency contact, or as a share of a split vault key. When the public key of an
+//  account is replaced, by a cipher suite migration or recovery, each of these keys is re-wrapped
+//  to the new public key so that no vault access is lost. When the vault key is rotated, on the
+//  revocation of a member, the new key is wrapped to each holder of the previous key
+package tree
+
+import (
+	"errors"
+	"strings"
+)
+
+//kinds of keys wrapped to an account
+const (
+	WrappedVaultKey      string = "member"    //vault key of an owner, member, or invitee
+	WrappedEmergencyKey  string = "emergency" //vault key of an emergency contact
+	WrappedKeyShare      string = "share"     //share of a split vault key held by a custodian
+	WrappedReleasedShare string = "released"  //share released by a custodian to the requester of a reconstruction
+)
+
+//a key wrapped to an account within a vault, CustodianHashed is only held for released shares
+type WrappedKey struct {
+	VaultHashed     string
+	Kind            string
+	CustodianHashed string
+	WrappedKey      string
+}
+
+//encode the wrapped keys for a tx as vaultHashed.kind.custodianHashed.wrappedKey,...
+//  with the custodian as "-" for all but released shares
+func EncodeWrappedKeys(keys []WrappedKey) string {
+
+	if len(keys) < 1 {
+		return emptyTxList
+	}
+
+	encoded := make([]string, len(keys))
+	for i, key := range keys {
+		custodianHashed := key.CustodianHashed
+		if len(custodianHashed) < 1 {
+			custodianHashed = emptyTxList
+		}
+		encoded[i] = strings.Join([]string{key.VaultHashed, key.Kind, custodianHashed, key.WrappedKey}, ".")
+	}
+	return strings.Join(encoded, ",")
+}
+
+func DecodeWrappedKeys(encoded string) (keys []WrappedKey, err error) {
+
+	if encoded == emptyTxList {
+		return
+	}
+
+	for _, quad := range strings.Split(encoded, ",") {
+		parts := strings.Split(quad, ".")
+		if len(parts) != 4 || len(parts[0]) < 1 || len(parts[2]) < 1 || len(parts[3]) < 1 {
+			err = errors.New("bad wrapped key list")
+			return
+		}
+		key := WrappedKey{parts[0], parts[1], parts[2], parts[3]}
+		switch {
+		case key.Kind == WrappedReleasedShare && key.CustodianHashed != emptyTxList:
+		case key.Kind == WrappedVaultKey, key.Kind == WrappedEmergencyKey, key.Kind == WrappedKeyShare:
+			if key.CustodianHashed != emptyTxList {
+				err = errors.New("bad wrapped key list")
+				return
+			}
+			key.CustodianHashed = ""
+		default:
+			err = errors.New("bad wrapped key list")
+			return
+		}
+		keys = append(keys, key)
+	}
+	return
+}
+
+//a key wrapped to a holder of the vault key when the key is rotated
+type RotatedKey struct {
+	UsernameHashed string
+	Kind           string
+	WrappedKey     string
+}
+
+//encode the rotated keys for a tx as usernameHashed.kind.wrappedKey,...
+func EncodeRotatedKeys(keys []RotatedKey) string {
+
+	if len(keys) < 1 {
+		return emptyTxList
+	}
+
+	encoded := make([]string, len(keys))
+	for i, key := range keys {
+		encoded[i] = strings.Join([]string{key.UsernameHashed, key.Kind, key.WrappedKey}, ".")
+	}
+	return strings.Join(encoded, ",")
+}
+
+func DecodeRotatedKeys(encoded string) (keys []RotatedKey, err error) {
+
+	if encoded == emptyTxList {
+		return
+	}
+
+	for _, triple := range strings.Split(encoded, ",") {
+		parts := strings.Split(triple, ".")
+		if len(parts) != 3 || len(parts[0]) < 1 || len(parts[2]) < 1 {
+			err = errors.New("bad rotated key list")
+			return
+		}
+		switch parts[1] {
+		case WrappedVaultKey, WrappedEmergencyKey, WrappedKeyShare:
+		default:
+			err = errors.New("bad rotated key list")
+			return
+		}
+		keys = append(keys, RotatedKey{parts[0], parts[1], parts[2]})
+	}
+	return
+}
+
+//the subtree key and value holding a key wrapped to the user, along with the value held
+//  with the key re-wrapped. exists is false when the vault holds no such key for the user
+func getWrappedKeyRecord(subTree TreeReading, usernameHashed string, key WrappedKey) (
+	recordKey []byte, wrappedKey string, rewrap func(rewrappedKey string) []byte, exists bool) {
+
+	var value []byte
+	switch key.Kind {
+	case WrappedVaultKey:
+		recordKey = getVaultMemberKey(key.VaultHashed, usernameHashed)
+		if _, value, exists = subTree.Get(recordKey); !exists {
+			return
+		}
+		status, wrapped, err := readVaultMemberValue(value)
+		if err != nil {
+			exists = false
+			return
+		}
+		return recordKey, wrapped, func(rewrappedKey string) []byte {
+			return getVaultMemberValue(status, rewrappedKey)
+		}, true
+
+	case WrappedEmergencyKey:
+		recordKey = getEmergencyContactKey(key.VaultHashed, usernameHashed)
+		if _, value, exists = subTree.Get(recordKey); !exists {
+			return
+		}
+		waitBlocks, requestHeight, wrapped, err := readEmergencyContactValue(value)
+		if err != nil {
+			exists = false
+			return
+		}
+		return recordKey, wrapped, func(rewrappedKey string) []byte {
+			return getEmergencyContactValue(waitBlocks, requestHeight, rewrappedKey)
+		}, true
+
+	case WrappedKeyShare:
+		recordKey = getKeyShareKey(key.VaultHashed, usernameHashed)
+
+	case WrappedReleasedShare:
+		requester, _, requested := getReconstruction(subTree, key.VaultHashed)
+		if !requested || requester != usernameHashed {
+			return
+		}
+		recordKey = getReleasedShareKey(key.VaultHashed, requester, key.CustodianHashed)
+
+	default:
+		return
+	}
+
+	if _, value, exists = subTree.Get(recordKey); !exists {
+		return
+	}
+	return recordKey, string(value), func(rewrappedKey string) []byte {
+		return []byte(rewrappedKey)
+	}, true
+}
+
+//every key wrapped to the user, the subtree of every vault is searched
+func getWrappedKeys(tree TreeReading, usernameHashed string) (keys []WrappedKey) {
+
+	subTreePrefix := keyPrefix4SubTree + "/"
+	for i := 0; i < tree.Size(); i++ {
+		mapKey, _ := tree.GetByIndex(i)
+		if !strings.HasPrefix(string(mapKey), subTreePrefix) {
+			continue
+		}
+		vaultHashed := strings.TrimPrefix(string(mapKey), subTreePrefix)
+		subTree, err := tree.LoadSubTree(vaultHashed)
+		if err != nil {
+			continue
+		}
+
+		candidates := []WrappedKey{
+			{VaultHashed: vaultHashed, Kind: WrappedVaultKey},
+			{VaultHashed: vaultHashed, Kind: WrappedEmergencyKey},
+			{VaultHashed: vaultHashed, Kind: WrappedKeyShare},
+		}
+		if _, custodians, err := getKeySplit(tree, vaultHashed); err == nil {
+			for _, custodian := range custodians {
+				candidates = append(candidates, WrappedKey{VaultHashed: vaultHashed,
+					Kind: WrappedReleasedShare, CustodianHashed: custodian})
+			}
+		}
+
+		for _, candidate := range candidates {
+			_, wrappedKey, _, exists := getWrappedKeyRecord(subTree, usernameHashed, candidate)
+			if exists {
+				candidate.WrappedKey = wrappedKey
+				keys = append(keys, candidate)
+			}
+		}
+	}
+	return
+}
+
+//every holder of the vault key other than the user, as a member or invitee, an emergency
+//  contact, or a custodian of a share. The holders are returned without their wrapped keys
+func getVaultKeyHolders(subTree TreeReading, vaultHashed, exceptUsernameHashed string) (holders []RotatedKey) {
+
+	kinds := map[string]string{
+		keyPrefix4VaultMember:      WrappedVaultKey,
+		keyPrefix4EmergencyContact: WrappedEmergencyKey,
+		keyPrefix4KeyShare:         WrappedKeyShare,
+	}
+	for i := 0; i < subTree.Size(); i++ {
+		key, _ := subTree.GetByIndex(i)
+		parts := strings.Split(string(key), "/")
+		kind, isHolder := kinds[parts[0]]
+		if !isHolder || len(parts) != 3 || parts[1] != vaultHashed {
+			continue
+		}
+		if kind == WrappedVaultKey && parts[2] == exceptUsernameHashed {
+			continue
+		}
+		holders = append(holders, RotatedKey{UsernameHashed: parts[2], Kind: kind})
+	}
+	return
+}
+
+//verify the rotated keys cover every holder of the vault key other than the user, each exactly once
+func rotationCoversHolders(subTree TreeReading, vaultHashed, exceptUsernameHashed string,
+	keys []RotatedKey) error {
+
+	holders := getVaultKeyHolders(subTree, vaultHashed, exceptUsernameHashed)
+	if len(keys) != len(holders) {
+		return errors.New("rotation must cover every holder of the vault key")
+	}
+	covered := make(map[RotatedKey]bool)
+	for _, key := range keys {
+		_, _, _, exists := getWrappedKeyRecord(subTree, key.UsernameHashed,
+			WrappedKey{VaultHashed: vaultHashed, Kind: key.Kind})
+		if !exists {
+			return errors.New("no key is wrapped to the holder")
+		}
+		covered[RotatedKey{UsernameHashed: key.UsernameHashed, Kind: key.Kind}] = true
+	}
+	for _, holder := range holders {
+		if !covered[holder] {
+			return errors.New("rotation must cover every holder of the vault key")
+		}
+	}
+	return nil
+}
+
+/////////////////////////////////////////////
+//   WRITE Wrapped Key Operations
+////////////////////////////////////////////
+
+//replace the published public and signing keys of the account along with the keys wrapped
+//  to its previous public key, each re-wrapped to the new public key. Only keys already
+//  wrapped to the account may be replaced
+func (ptw *PwkTreeWriter) ReplaceAccountKeys(publicKey, signingKey string, keys []WrappedKey) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	return ptw.replaceAccountKeys(publicKey, signingKey, keys)
+}
+
+func (ptw *PwkTreeWriter) replaceAccountKeys(publicKey, signingKey string, keys []WrappedKey) (err error) {
+
+	usernameHashed := ptw.wVar.usernameHashed
+	if !ptw.tree.Has(getPublicKeyKey(usernameHashed)) || !ptw.tree.Has(getSigningKeyKey(usernameHashed)) {
+		err = errors.New("account has no published keys")
+		return
+	}
+
+	//verify every key before any is replaced
+	for _, key := range keys {
+		subTree, loadErr := ptw.tree.LoadSubTree(key.VaultHashed)
+		if loadErr != nil {
+			err = errors.New("vault doesn't exist")
+			return
+		}
+		if _, _, _, exists := getWrappedKeyRecord(subTree, usernameHashed, key); !exists {
+			err = errors.New("no key is wrapped to the account")
+			return
+		}
+	}
+
+	for _, key := range keys {
+		subTree, _ := ptw.tree.LoadSubTree(key.VaultHashed)
+		recordKey, _, rewrap, _ := getWrappedKeyRecord(subTree, usernameHashed, key)
+		subTree.Set(recordKey, rewrap(key.WrappedKey))
+		ptw.tree.SaveSubTree(key.VaultHashed, subTree)
+	}
+
+	ptw.tree.Set(getPublicKeyKey(usernameHashed), []byte(publicKey))
+	ptw.tree.Set(getSigningKeyKey(usernameHashed), []byte(signingKey))
+	return
+}
+
+/////////////////////////////////////////////
+//   READ Wrapped Key Operations
+////////////////////////////////////////////
+
+//retrieve every holder of the vault key other than the user, without their wrapped keys
+func (ptr *PwkTreeReader) RetrieveVaultKeyHolders(vaultHashed, exceptUsernameHashed string) (
+	holders []RotatedKey, err error) {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	subTree, err := ptr.tree.LoadSubTree(vaultHashed)
+	if err != nil {
+		err = errors.New("vault doesn't exist")
+		return
+	}
+	return getVaultKeyHolders(subTree, vaultHashed, exceptUsernameHashed), nil
+}
+
+//retrieve every key wrapped to the public key of a user
+func (ptr *PwkTreeReader) RetrieveWrappedKeys(usernameHashed string) []WrappedKey {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	return getWrappedKeys(ptr.tree, usernameHashed)
+}
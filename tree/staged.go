@@ -0,0 +1,201 @@
+package tree
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/tendermint/go-merkle"
+)
+
+//a view of the momma tree holding its modifications in memory, the momma tree is only
+//  read until the staged modifications are committed. Subtrees modified while staged
+//  are held unsaved so that nothing of a staged tree is written to the db before commit
+type stagedTree struct {
+	base     PwkMerkleTree
+	values   map[string][]byte        //momma tree records set while staged
+	removed  map[string]bool          //momma tree records removed while staged
+	subTrees map[string]PwkMerkleTree //subtrees set while staged, by their momma tree key
+}
+
+func newStagedTree(base PwkMerkleTree) *stagedTree {
+	return &stagedTree{
+		base:     base,
+		values:   make(map[string][]byte),
+		removed:  make(map[string]bool),
+		subTrees: make(map[string]PwkMerkleTree),
+	}
+}
+
+//the sorted keys of the momma tree as modified while staged
+func (st *stagedTree) keys() []string {
+
+	var keys []string
+	for i := 0; i < st.base.Size(); i++ {
+		key, _ := st.base.GetByIndex(i)
+		_, set := st.values[string(key)]
+		if !set && !st.removed[string(key)] {
+			keys = append(keys, string(key))
+		}
+	}
+	for key := range st.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+///////////////////////////
+//Staged Reading Functions
+///////////////////////////
+
+func (st *stagedTree) Size() (size int) {
+
+	size = st.base.Size() - len(st.removed)
+	for key := range st.values {
+		if !st.base.Has([]byte(key)) {
+			size++
+		}
+	}
+	return
+}
+
+func (st *stagedTree) Height() (height int8) {
+	return st.base.Height()
+}
+
+func (st *stagedTree) Has(key []byte) (has bool) {
+	_, _, has = st.Get(key)
+	return
+}
+
+func (st *stagedTree) Get(key []byte) (index int, value []byte, exists bool) {
+
+	value, exists = st.values[string(key)]
+	if !exists && !st.removed[string(key)] {
+		index, value, exists = st.base.Get(key)
+	} else {
+		index, _, _ = st.base.Get(key)
+	}
+
+	//offset the index of the momma tree by the staged keys preceding the key
+	for removedKey := range st.removed {
+		if removedKey < string(key) {
+			index--
+		}
+	}
+	for setKey := range st.values {
+		if setKey < string(key) && !st.base.Has([]byte(setKey)) {
+			index++
+		}
+	}
+	return
+}
+
+func (st *stagedTree) GetByIndex(index int) (key []byte, value []byte) {
+
+	keys := st.keys()
+	if index < 0 || index >= len(keys) {
+		return nil, nil
+	}
+	key = []byte(keys[index])
+	_, value, _ = st.Get(key)
+	return
+}
+
+//staged modifications are never hashed, the hash of the momma tree is only
+//  updated once they are committed
+func (st *stagedTree) Hash() (hash []byte) {
+	return nil
+}
+
+///////////////////////////
+//Staged Writing Functions
+///////////////////////////
+
+func (st *stagedTree) Set(key []byte, value []byte) (updated bool) {
+
+	updated = st.Has(key)
+	delete(st.removed, string(key))
+	st.values[string(key)] = append([]byte(nil), value...)
+	return
+}
+
+func (st *stagedTree) Remove(key []byte) (value []byte, removed bool) {
+
+	_, value, removed = st.Get(key)
+	if !removed {
+		return nil, false
+	}
+	delete(st.values, string(key))
+	delete(st.subTrees, string(key))
+	if st.base.Has(key) {
+		st.removed[string(key)] = true
+	}
+	return
+}
+
+//staged trees are never loaded nor saved, see PwkTreeWriter.CommitStaged
+func (st *stagedTree) Load(hash []byte) {}
+
+func (st *stagedTree) Save() (hash []byte) {
+	return nil
+}
+
+func (st *stagedTree) SaveMommaTree() {}
+
+/////////////////////////////////////////////
+//   Staged Subtree Management
+////////////////////////////////////////////
+
+func (st *stagedTree) LoadSubTree(UsernameHashed string) (PwkMerkleTree, error) {
+
+	mapKey := string(getMapKey(UsernameHashed))
+
+	if subTree, staged := st.subTrees[mapKey]; staged {
+		return subTree, nil
+	}
+	if st.removed[mapKey] {
+		return st.base, errors.New("sub tree doesn't exist") //return the root tree
+	}
+
+	//subtrees loaded from the momma tree are read from saved nodes only, and are
+	//  held in memory once modified
+	return st.base.LoadSubTree(UsernameHashed)
+}
+
+func (st *stagedTree) SaveSubTree(UsernameHashed string, subTree PwkMerkleTree) {
+
+	mapKey := getMapKey(UsernameHashed)
+
+	st.Set(mapKey, subTree.Hash())
+	st.subTrees[string(mapKey)] = subTree
+}
+
+func (st *stagedTree) NewSubTree(UsernameHashed string) PwkMerkleTree {
+
+	subTree := PwkMerkleTree{
+		tree:      merkle.NewIAVLTree(st.base.cacheSize, st.base.db),
+		cacheSize: st.base.cacheSize,
+		db:        st.base.db,
+	}
+	st.SaveSubTree(UsernameHashed, subTree)
+
+	return subTree
+}
+
+//write the staged modifications to the momma tree, saving the modified subtrees
+func (st *stagedTree) commit() {
+
+	for key := range st.removed {
+		st.base.Remove([]byte(key))
+	}
+	for key, value := range st.values {
+		if _, isSubTree := st.subTrees[key]; !isSubTree {
+			st.base.Set([]byte(key), value)
+		}
+	}
+	for key, subTree := range st.subTrees {
+		st.base.Set([]byte(key), subTree.Save())
+	}
+}
@@ -0,0 +1,92 @@
+package tree
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	cmn "github.com/rigelrozanski/passwerk/common"
+	dbm "github.com/tendermint/go-db"
+	"github.com/tendermint/go-merkle"
+)
+
+//backends of the database holding the merkle trees, the memdb backend is held
+//  in memory only and is lost when the application exits
+const (
+	DBBackendLevelDB string = dbm.DBBackendLevelDB
+	DBBackendMemDB   string = dbm.DBBackendMemDB
+)
+
+func DBBackends() []string {
+	return []string{DBBackendLevelDB, DBBackendMemDB}
+}
+
+//the database of the momma merkle tree and its subtrees
+type Storage struct {
+	DB   dbm.DB
+	name string
+}
+
+//open the database of the backend, persistent backends are held within the directory
+//  and created if they don't exist
+func OpenStorage(backend, name, dir string) (*Storage, error) {
+
+	switch backend {
+	case DBBackendLevelDB, DBBackendMemDB:
+	default:
+		return nil, errors.New("unknown db backend " + backend + ", expected one of: " +
+			strings.Join(DBBackends(), ", "))
+	}
+
+	return &Storage{
+		DB:   dbm.NewDB(name, backend, dir),
+		name: name,
+	}, nil
+}
+
+//load the momma merkle tree held by the storage, an empty tree is saved when
+//  the storage doesn't yet hold one
+func (s *Storage) LoadTree(cacheSize int) (tree PwkMerkleTree, existing bool) {
+
+	//Keyz for db values which hold information which isn't the contents of a Merkle tree
+	dBKeyMerkleHash := []byte(cmn.DBKeyMerkleHash)
+
+	state := merkle.NewIAVLTree(cacheSize, s.DB)
+
+	//for WAL version of go-merkle
+	//state = merkle.NewIAVLTree(cacheSize, path.Join(dBName, cmn.WalSubDir), s.DB)
+
+	existing = s.DB.Get(dBKeyMerkleHash) != nil
+	if !existing {
+		s.DB.Set(dBKeyMerkleHash, state.Save())
+	}
+	state.Load(s.DB.Get(dBKeyMerkleHash))
+
+	return NewPwkMerkleTree(state, cacheSize, s.DB, s.name), existing
+}
+
+func (s *Storage) Close() {
+	s.DB.Close()
+}
+
+//the writer and reader of the tree sharing a lock for data access, the writer is fed
+//  to the tmsp application and the reader to the UI
+func NewPwkTreePair(tree PwkMerkleTree) (ptw PwkTreeWriter, ptr PwkTreeReader) {
+
+	var pR TreeReading = tree
+	var pW TreeWriting = tree
+
+	mtx := new(sync.Mutex)
+	ptr = NewPwkTreeReader(mtx, pR, "", "", "", "") //initilize blank reader variables, updated in UI
+	ptw = NewPwkTreeWriter(mtx, pW, "", "", "")     //initilize blank reader variables, updated in TMSP
+	return
+}
+
+//a writer and reader of a new tree held in memory, as used by tests which leave the
+//  filesystem untouched
+func NewMemTreePair() (ptw PwkTreeWriter, ptr PwkTreeReader) {
+
+	storage, _ := OpenStorage(DBBackendMemDB, "", "")
+	tree, _ := storage.LoadTree(0)
+	return NewPwkTreePair(tree)
+}
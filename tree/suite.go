@@ -0,0 +1,151 @@
+//cipher suites of accounts and vaults, the identifier of the suite which seals the records
+//  of an account or vault is held within its subtree. Accounts and vaults without an
+//  identifier predate cipher suites and use the legacy suite
+package tree
+
+import (
+	"errors"
+	"strings"
+
+	cry "github.com/rigelrozanski/passwerk/crypto"
+)
+
+/////////////////////////////////////////////
+//   WRITE Cipher Suite Operations
+////////////////////////////////////////////
+
+//set the cipher suite of a new account or vault
+func (ptw *PwkTreeWriter) SetCipherSuite(suiteID string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	if _, err = cry.GetCipherSuite(suiteID); err != nil {
+		return
+	}
+
+	var subTree TreeWriting
+	subTree, err = ptw.LoadSubTree()
+	if err != nil {
+		err = errors.New("account doesn't exist")
+		return
+	}
+
+	subTree.Set(getCipherSuiteKey(ptw.wVar.usernameHashed), []byte(suiteID))
+
+	ptw.saveSubTree(subTree)
+	return
+}
+
+//migrate an account or vault to a new cipher suite, replacing every record along with the
+//  verifier and two-factor secret of an account. The verifier and two-factor secret are left
+//  unchanged when provided as "-", as is the case for vaults. The records must cover every
+//  record held so that none are left out of the replaced cIdList. The history of each record
+//  is replaced by the re-encrypted history of the migration, while the history of deleted
+//  records is cleared as it remains sealed with the previous suite
+func (ptw *PwkTreeWriter) MigrateCipherSuite(suiteID, verifierEncrypted, twoFactorSecretEncrypted string,
+	records []RekeyedRecord) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	if _, err = cry.GetCipherSuite(suiteID); err != nil {
+		return
+	}
+
+	var subTree TreeWriting
+	subTree, err = ptw.LoadSubTree()
+	if err != nil {
+		err = errors.New("account doesn't exist")
+		return
+	}
+
+	if !coversRecords(subTree, ptw.wVar.usernameHashed, records) {
+		err = errors.New("migration must cover every record")
+		return
+	}
+
+	resealRecords(subTree, ptw.wVar.usernameHashed, records)
+
+	if verifierEncrypted != emptyTxList {
+		subTree.Set(GetVerifierKey(ptw.wVar.usernameHashed), []byte(verifierEncrypted))
+	}
+	if twoFactorSecretEncrypted != emptyTxList && subTree.Has(getTwoFactorKey(ptw.wVar.usernameHashed)) {
+		subTree.Set(getTwoFactorKey(ptw.wVar.usernameHashed), []byte(twoFactorSecretEncrypted))
+	}
+	subTree.Set(getCipherSuiteKey(ptw.wVar.usernameHashed), []byte(suiteID))
+
+	ptw.saveSubTree(subTree)
+	return
+}
+
+//verify that the records of a migration cover every record held by the account or vault
+func (ptw *PwkTreeWriter) VerifyMigrationCoversRecords(records []RekeyedRecord) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	subTree, err := ptw.LoadSubTree()
+	if err != nil {
+		return errors.New("account doesn't exist")
+	}
+	if !coversRecords(subTree, ptw.wVar.usernameHashed, records) {
+		return errors.New("migration must cover every record")
+	}
+	return nil
+}
+
+//whether the records cover every record held by the account or vault, each exactly once
+func coversRecords(subTree TreeReading, usernameHashed string, records []RekeyedRecord) bool {
+
+	//the cIdList holds one entry between each pair of seperators
+	_, cIdList, _ := subTree.Get(GetCIdListKey(usernameHashed))
+	if strings.Count(string(cIdList), "/")-1 != len(records) {
+		return false
+	}
+	covered := make(map[string]bool)
+	for _, record := range records {
+		if covered[record.CIdNameHashed] || !subTree.Has(GetRecordKey(usernameHashed, record.CIdNameHashed)) {
+			return false
+		}
+		covered[record.CIdNameHashed] = true
+	}
+	return true
+}
+
+//replace every record held by the account or vault along with its history, the records must
+//  cover every record held
+func resealRecords(subTree TreeWriting, usernameHashed string, records []RekeyedRecord) {
+
+	clearRecordHistory(subTree)
+	cIdList := "/"
+	for _, record := range records {
+		cIdList += record.CIdNameEncrypted + "/"
+		subTree.Set(GetRecordKey(usernameHashed, record.CIdNameHashed), []byte(record.CRecordEncrypted))
+		setRecordHistory(subTree, usernameHashed, record.CIdNameHashed, record.History)
+	}
+	subTree.Set(GetCIdListKey(usernameHashed), []byte(cIdList))
+}
+
+/////////////////////////////////////////////
+//   READ Cipher Suite Operations
+////////////////////////////////////////////
+
+//retrieve the cipher suite of an account or vault
+func (ptr *PwkTreeReader) RetrieveCipherSuite(usernameHashed string) (suite cry.CipherSuite, err error) {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	subTree, err := ptr.tree.LoadSubTree(usernameHashed)
+	if err != nil {
+		err = errors.New("account doesn't exist")
+		return
+	}
+
+	_, suiteID, exists := subTree.Get(getCipherSuiteKey(usernameHashed))
+	if !exists {
+		return cry.LegacyCipherSuite, nil
+	}
+	return cry.GetCipherSuite(string(suiteID))
+}
@@ -2,33 +2,28 @@
 package tree
 
 import (
-	//	"errors"
+	"bytes"
+	"encoding/hex"
+	"errors"
 	"path"
+	"reflect"
+	"strings"
 	"testing"
 
 	cry "github.com/rigelrozanski/passwerk/crypto"
+	dbm "github.com/tendermint/go-db"
+	"golang.org/x/crypto/nacl/box"
 )
 
 func TestTree(t *testing.T) {
-	//inititilize DB for testing
-	pwkDb, ptw, ptr, err := InitTestingDB()
+	//inititilize the in-memory trees for testing
+	ptw, ptr := NewMemTreePair()
 
 	testErrBasic := func(errIn error) {
 		if errIn != nil {
 			t.Errorf(errIn.Error())
 		}
 	}
-
-	testErrBasic(err)
-
-	//remove the testing db before exit
-	defer func() {
-		err = DeleteTestingDB(pwkDb)
-
-		if err != nil {
-			t.Errorf("err deleting testing DB: ", err.Error())
-		}
-	}()
 	//////////////////////////////////////////////////////
 	//functions for defining new readers and writers
 
@@ -60,7 +55,8 @@ func TestTree(t *testing.T) {
 			updatePTR(urlUsername, urlPassword, urlCIdName)
 			encryptedCIdName, err = ptr.GetCIdListEncryptedCIdName()
 		} else {
-			encryptedCIdName = cry.GetEncryptedHexString(hashInputCIdNameEncryption, urlCIdName)
+			encryptedCIdName, err = cry.GetEncryptedBoundHexString(cry.LegacyCipherSuite, hashInputCIdNameEncryption, urlCIdName,
+				AssociatedDataCIdName(usernameHashed))
 		}
 
 		ptw.SetVariables(
@@ -74,12 +70,17 @@ func TestTree(t *testing.T) {
 
 	getEncryptedCPassword := func(urlUsername, urlPassword, urlCIdName, urlCPassword string) string {
 		hashInputCPasswordEncryption := path.Join(urlCIdName, urlPassword, urlUsername)
-		return cry.GetEncryptedHexString(hashInputCPasswordEncryption, urlCPassword)
+		cPasswordEncrypted, err := cry.GetEncryptedBoundHexString(cry.LegacyCipherSuite, hashInputCPasswordEncryption, urlCPassword,
+			AssociatedDataFieldValue(cry.GetHashedHexString(urlUsername), cry.GetHashedHexString(urlCIdName), FieldPassword))
+		testErrBasic(err)
+		return cPasswordEncrypted
 	}
 
 	//////////////////////////////////////////////////////////
 	//perform the actual tests
 
+	//func (ptw *PwkTreeWriter) NewAccount(verifierEncrypted string) (err error) {
+	//func (ptw *PwkTreeWriter) DeleteAccount() (err error) {
 	//func (ptw *PwkTreeWriter) DeleteRecord() (err error) {
 	//func (ptw *PwkTreeWriter) NewRecord(cPasswordEncrypted string) (err error) {
 	//func (ptr *PwkTreeReader) Authenticate() bool {
@@ -91,6 +92,28 @@ func TestTree(t *testing.T) {
 	cId := []string{"savedName1", "savedName2"}
 	cPwd := []string{"savedPass1", "savedPass2"}
 
+	//writing a record before registering the account should fail
+	testErrBasic(updatePTW(false, mUsr, mPwd, cId[0]))
+	if ptw.NewRecord(getEncryptedCPassword(mUsr, mPwd, cId[0], cPwd[0])) == nil {
+		t.Errorf("writing to an unregistered account does not produce an error")
+	}
+
+	//register the account
+	hashInputCIdNameEncryption := HashInputCIdNameEncryption(mUsr, mPwd)
+	verifierEncrypted, err := cry.GetEncryptedBoundHexString(cry.LegacyCipherSuite, hashInputCIdNameEncryption, VerifierCanary,
+		AssociatedDataVerifier(cry.GetHashedHexString(mUsr)))
+	testErrBasic(err)
+	testErrBasic(ptw.NewAccount(verifierEncrypted))
+	if ptw.NewAccount(verifierEncrypted) == nil {
+		t.Errorf("re-registering an account does not produce an error")
+	}
+
+	//authenticate with a mistyped password
+	updatePTR(mUsr, "masterzzzzPi", cId[0])
+	if ptr.AuthMasterPassword(false) {
+		t.Errorf("good authentication when expected bad authentication")
+	}
+
 	//create two new records
 	testErrBasic(updatePTW(false, mUsr, mPwd, cId[0]))
 	enPass1 := getEncryptedCPassword(mUsr, mPwd, cId[0], cPwd[0])
@@ -102,12 +125,12 @@ func TestTree(t *testing.T) {
 
 	//authenticate
 	updatePTR(mUsr, mPwd, cId[0])
-	if !ptr.AuthMasterPassword() {
+	if !ptr.AuthMasterPassword(false) {
 		t.Errorf("bad authentication when expected good authentication")
 	}
 
 	//retrieve list
-	cIdNames, err1 := ptr.RetrieveCIdNames()
+	cIdNames, err1 := ptr.RetrieveCIdNames(false)
 	testErrBasic(err1)
 
 	//note that the list begins and ends with a blank record, so the size must be at least 4 if there are two records held
@@ -136,6 +159,123 @@ func TestTree(t *testing.T) {
 		t.Errorf("bad password retrieval does not produce an expected error")
 	}
 
+	//overwrite a record with additional fields then retrieve them
+	testErrBasic(updatePTW(true, mUsr, mPwd, cId[1]))
+	testErrBasic(ptw.DeleteRecord())
+	testErrBasic(updatePTW(false, mUsr, mPwd, cId[1]))
+	fields := map[string]string{
+		FieldPassword: cPwd[1],
+		FieldUsername: "bob",
+		FieldURL:      "example.com",
+		"recovery":    "abc123",
+	}
+	recordValue, err := GetEncryptedRecord(cry.LegacyCipherSuite, HashInputCPasswordEncryption(mUsr, mPwd, cId[1]),
+		cry.GetHashedHexString(mUsr), cry.GetHashedHexString(cId[1]), fields)
+	testErrBasic(err)
+	testErrBasic(ptw.NewRecord(recordValue))
+
+	updatePTR(mUsr, mPwd, cId[1])
+	retrievedFields, err5 := ptr.RetrieveCRecord()
+	testErrBasic(err5)
+	for name, value := range fields {
+		if retrievedFields[name] != value {
+			t.Errorf("bad field retrieve for " + name + " got " + retrievedFields[name] + " but expected " + value)
+		}
+	}
+
+	//ciphertexts are bound to their location, swapped field values or a record
+	//  read under another identifier should fail to decrypt
+	encryptedFields := strings.Split(recordValue, fieldSep)
+	field0 := strings.Split(encryptedFields[0], fieldNameSep)
+	field1 := strings.Split(encryptedFields[1], fieldNameSep)
+	encryptedFields[0] = field0[0] + fieldNameSep + field1[1]
+	encryptedFields[1] = field1[0] + fieldNameSep + field0[1]
+	_, err6 := ReadDecryptedRecord(HashInputCPasswordEncryption(mUsr, mPwd, cId[1]),
+		cry.GetHashedHexString(mUsr), cry.GetHashedHexString(cId[1]), strings.Join(encryptedFields, fieldSep))
+	if err6 == nil {
+		t.Errorf("swapped field values do not produce an error")
+	}
+	_, err7 := ReadDecryptedRecord(HashInputCPasswordEncryption(mUsr, mPwd, cId[1]),
+		cry.GetHashedHexString(mUsr), cry.GetHashedHexString(cId[0]), recordValue)
+	if err7 == nil {
+		t.Errorf("record read under another identifier does not produce an error")
+	}
+
+	//records sealed with secretbox before associated data was supported are refused,
+	//  other than when read to be resealed
+	legacyKey := cry.LegacyCipherSuite.Hash([]byte(HashInputCPasswordEncryption(mUsr, mPwd, cId[1])))
+	var legacyNonce [24]byte
+	legacyRecord := hex.EncodeToString(box.SealAfterPrecomputation(legacyNonce[:], []byte(cPwd[1]),
+		&legacyNonce, &legacyKey))
+	if _, err := ReadDecryptedRecord(HashInputCPasswordEncryption(mUsr, mPwd, cId[1]), cry.GetHashedHexString(mUsr),
+		cry.GetHashedHexString(cId[1]), legacyRecord); err != cry.ErrLegacyCiphertext {
+		t.Errorf("legacy record produced %v", err)
+	}
+	legacyFields, err := readDecryptedRecordSecrets(cry.DecryptLegacySecret, HashInputCPasswordEncryption(mUsr, mPwd, cId[1]),
+		cry.GetHashedHexString(mUsr), cry.GetHashedHexString(cId[1]), legacyRecord)
+	if err != nil || string(legacyFields[FieldPassword].Bytes()) != cPwd[1] {
+		t.Errorf("bad legacy record resealing read")
+	}
+	WipeRecord(legacyFields)
+
+	//accounts without a cipher suite use the legacy suite
+	usernameHashed := cry.GetHashedHexString(mUsr)
+	suite, err8 := ptr.RetrieveCipherSuite(usernameHashed)
+	if err8 != nil || suite.ID() != cry.LegacyCipherSuite.ID() {
+		t.Errorf("account without a cipher suite does not use the legacy suite")
+	}
+
+	//migrate the records to the argon2id suite, the migration must cover every record
+	getMigratedRecord := func(cIdName string, fields map[string]string) RekeyedRecord {
+		record := RekeyedRecord{CIdNameHashed: cry.GetHashedHexString(cIdName)}
+		record.CIdNameEncrypted, err = cry.GetEncryptedBoundHexString(cry.Argon2idCipherSuite,
+			HashInputCIdNameEncryption(mUsr, mPwd), cIdName, AssociatedDataCIdName(usernameHashed))
+		testErrBasic(err)
+		record.CRecordEncrypted, err = GetEncryptedRecord(cry.Argon2idCipherSuite, HashInputCPasswordEncryption(mUsr, mPwd, cIdName),
+			usernameHashed, record.CIdNameHashed, fields)
+		testErrBasic(err)
+		return record
+	}
+	migrated := []RekeyedRecord{
+		getMigratedRecord(cId[0], map[string]string{FieldPassword: cPwd[0]}),
+		getMigratedRecord(cId[1], fields),
+	}
+
+	ptw.SetVariables(usernameHashed, "", "")
+	if ptw.MigrateCipherSuite(cry.Argon2idCipherSuite.ID(), "-", "-", migrated[:1]) == nil {
+		t.Errorf("migration missing a record does not produce an error")
+	}
+	if ptw.MigrateCipherSuite(cry.Argon2idCipherSuite.ID(), "-", "-", []RekeyedRecord{migrated[1], migrated[1]}) == nil {
+		t.Errorf("migration repeating a record does not produce an error")
+	}
+	if ptw.MigrateCipherSuite("rot13", "-", "-", migrated) == nil {
+		t.Errorf("migration to an unknown suite does not produce an error")
+	}
+	testErrBasic(ptw.MigrateCipherSuite(cry.Argon2idCipherSuite.ID(), "-", "-", migrated))
+
+	suite, err8 = ptr.RetrieveCipherSuite(usernameHashed)
+	if err8 != nil || suite.ID() != cry.Argon2idCipherSuite.ID() {
+		t.Errorf("migrated account does not use the argon2id suite")
+	}
+
+	//the migrated records are read as before, and the verifier is unchanged
+	updatePTR(mUsr, mPwd, cId[1])
+	if !ptr.AuthMasterPassword(false) {
+		t.Errorf("bad authentication after migration")
+	}
+	retrievedFields, err9 := ptr.RetrieveCRecord()
+	testErrBasic(err9)
+	for name, value := range fields {
+		if retrievedFields[name] != value {
+			t.Errorf("bad migrated field retrieve for " + name + " got " + retrievedFields[name] + " but expected " + value)
+		}
+	}
+	cIdNames, err9 = ptr.RetrieveCIdNames(false)
+	testErrBasic(err9)
+	if len(cIdNames) != 4 || cIdNames[1] != cId[0] || cIdNames[2] != cId[1] {
+		t.Errorf("bad cIdName list after migration")
+	}
+
 	//open a bad ptw (aka if attempting to perform a bad delete)
 	testErrBasic(updatePTW(true, mUsr, mPwd, "garbullyGoop"))
 	err4 := ptw.DeleteRecord()
@@ -149,10 +289,258 @@ func TestTree(t *testing.T) {
 	testErrBasic(updatePTW(true, mUsr, mPwd, cId[1]))
 	testErrBasic(ptw.DeleteRecord())
 
-	//authenticate, but should be denied because user has all records deleted
+	//authenticate, the account should remain after all records are deleted
 	updatePTR(mUsr, mPwd, cId[0])
-	if ptr.AuthMasterPassword() {
+	if !ptr.AuthMasterPassword(false) {
+		t.Errorf("bad authentication when expected good authentication")
+	}
+
+	//delete the account, authentication should now be denied
+	testErrBasic(ptw.DeleteAccount())
+	if ptr.AuthMasterPassword(false) {
 		t.Errorf("good authentication when expected bad authentication")
 	}
+	if ptw.DeleteAccount() == nil {
+		t.Errorf("deleting a non-existent account does not produce an error")
+	}
+
+	//the username of the deleted account is retired
+	if ptw.NewAccount(verifierEncrypted) == nil {
+		t.Errorf("re-registering a deleted account does not produce an error")
+	}
+	updatePTR(mUsr, mPwd, cId[0])
+	if !ptr.AccountRetired() {
+		t.Errorf("deleted account isn't retired")
+	}
+
+	//vaults share the namespace of accounts but may not be deleted as accounts
+	testErrBasic(ptw.NewVault("deletedVaultHashed", cry.GetHashedHexString(mUsr), "wrappedKey"))
+	ptw.SetVariables("deletedVaultHashed", "", "")
+	if ptw.DeleteAccount() == nil {
+		t.Errorf("deleting a vault as an account does not produce an error")
+	}
 
+	//emergency waiting periods are bounded and compared free of overflow
+	if ptw.DesignateEmergencyContact("deletedVaultHashed", cry.GetHashedHexString(mUsr), "contact",
+		MaxEmergencyWaitBlocks+1, "wrappedKey") == nil {
+		t.Errorf("designating an overlong waiting period does not produce an error")
+	}
+	if EmergencyWaitPassed(5, 2, ^uint64(0)) || EmergencyWaitPassed(1, 2, 0) || !EmergencyWaitPassed(5, 2, 3) {
+		t.Errorf("bad emergency waiting period comparison")
+	}
+
+	//revoking a member rotates the vault key of every remaining holder
+	ownerHashed := cry.GetHashedHexString(mUsr)
+	testErrBasic(ptw.PublishPublicKey("member", "memberPublicKey"))
+	testErrBasic(ptw.PublishPublicKey("contact", "contactPublicKey"))
+	testErrBasic(ptw.InviteVaultMember("deletedVaultHashed", ownerHashed, "member", "wrappedKey"))
+	testErrBasic(ptw.DesignateEmergencyContact("deletedVaultHashed", ownerHashed, "contact", 1, "wrappedKey"))
+	rotated := []RotatedKey{{ownerHashed, WrappedVaultKey, "rotatedKey"}, {"contact", WrappedEmergencyKey, "rotatedKey"}}
+	if ptw.RevokeVaultMember("deletedVaultHashed", ownerHashed, "member", nil, rotated[:1]) == nil {
+		t.Errorf("revocation missing a holder of the vault key does not produce an error")
+	}
+	if ptw.RevokeVaultMember("deletedVaultHashed", ownerHashed, "member", nil,
+		append(rotated, RotatedKey{"member", WrappedVaultKey, "rotatedKey"})) == nil {
+		t.Errorf("revocation rotating the vault key to the revoked member does not produce an error")
+	}
+	testErrBasic(ptw.RevokeVaultMember("deletedVaultHashed", ownerHashed, "member", nil, rotated))
+	if _, _, err := ptr.RetrieveVaultMembership("deletedVaultHashed", "member"); err == nil {
+		t.Errorf("revoked member remains a member")
+	}
+	if _, wrappedKey, _ := ptr.RetrieveVaultMembership("deletedVaultHashed", ownerHashed); wrappedKey != "rotatedKey" {
+		t.Errorf("vault key of the owner isn't rotated")
+	}
+	if _, _, wrappedKey, _ := ptr.RetrieveEmergencyContact("deletedVaultHashed", "contact"); wrappedKey != "rotatedKey" {
+		t.Errorf("vault key of the emergency contact isn't rotated")
+	}
+
+}
+
+func TestStorage(t *testing.T) {
+
+	if _, err := OpenStorage("bogusdb", "pwkTestDb", "pwkTestDb"); err == nil {
+		t.Errorf("opened storage of an unknown backend")
+	}
+
+	storage, err := OpenStorage(DBBackendMemDB, "pwkTestDb", "pwkTestDb")
+	if err != nil {
+		t.Fatalf("opening memdb storage: %v", err)
+	}
+	defer storage.Close()
+
+	//an empty tree is saved to new storage, and the saved tree is loaded thereafter
+	tree, existing := storage.LoadTree(0)
+	if existing {
+		t.Errorf("new storage holds an existing tree")
+	}
+	tree.Set([]byte("key"), []byte("value"))
+	tree.SaveMommaTree()
+
+	tree, existing = storage.LoadTree(0)
+	if _, value, exists := tree.Get([]byte("key")); !existing || !exists || string(value) != "value" {
+		t.Errorf("bad loaded tree: %v %v %s", existing, exists, value)
+	}
+}
+
+//a db counting the writes made to it
+type writeCountingDB struct {
+	dbm.DB
+	writes int
+}
+
+func (db *writeCountingDB) Set(key, value []byte) {
+	db.writes++
+	db.DB.Set(key, value)
+}
+
+func (db *writeCountingDB) SetSync(key, value []byte) {
+	db.writes++
+	db.DB.SetSync(key, value)
+}
+
+func TestStaged(t *testing.T) {
+
+	storage, err := OpenStorage(DBBackendMemDB, "pwkTestDb", "pwkTestDb")
+	if err != nil {
+		t.Fatalf("opening memdb storage: %v", err)
+	}
+	defer storage.Close()
+	db := &writeCountingDB{DB: storage.DB}
+	storage.DB = db
+
+	tree, _ := storage.LoadTree(0)
+	ptw, _ := NewPwkTreePair(tree)
+
+	usernameHashed := cry.GetHashedHexString("stagedUsr")
+	cIdNameHashed := cry.GetHashedHexString("stagedCId")
+	ptw.SetVariables(usernameHashed, cIdNameHashed, "stagedCIdEncrypted")
+	if err := ptw.NewAccount("verifierEncrypted"); err != nil {
+		t.Fatalf("registering the account: %v", err)
+	}
+	hash, writes := ptw.Hash(), db.writes
+
+	//the staged writer reads through to the tree, yet writes nothing to the tree or the db
+	staged := ptw.Staged()
+	staged.SetVariables(usernameHashed, cIdNameHashed, "stagedCIdEncrypted")
+	if staged.NewAccount("verifierEncrypted") == nil {
+		t.Errorf("staged re-registering of an account does not produce an error")
+	}
+	if err := staged.NewRecord("stagedRecord"); err != nil {
+		t.Errorf("staged record: %v", err)
+	}
+	if exists, err := staged.VerifyRecordExists(); !exists || err != nil {
+		t.Errorf("staged record doesn't exist: %v", err)
+	}
+	if exists, _ := ptw.VerifyRecordExists(); exists {
+		t.Errorf("staged record exists in the tree before commit")
+	}
+	if !bytes.Equal(hash, ptw.Hash()) || db.writes != writes {
+		t.Errorf("staged writer modified the tree or wrote %d times to the db", db.writes-writes)
+	}
+
+	//a staged deletion leaves the tree unmodified
+	deleting := ptw.Staged()
+	deleting.SetVariables(usernameHashed, cIdNameHashed, "stagedCIdEncrypted")
+	if err := deleting.DeleteAccount(); err != nil {
+		t.Errorf("staged deletion: %v", err)
+	}
+	if _, err := deleting.LoadSubTree(); err == nil {
+		t.Errorf("staged deleted account exists")
+	}
+	if _, err := ptw.LoadSubTree(); err != nil {
+		t.Errorf("staged deletion deleted the account from the tree")
+	}
+
+	//committed modifications are written to the tree
+	if ptw.CommitStaged() == nil {
+		t.Errorf("committing a writer which isn't staged does not produce an error")
+	}
+	if err := staged.CommitStaged(); err != nil {
+		t.Fatalf("committing the staged writer: %v", err)
+	}
+	if exists, err := ptw.VerifyRecordExists(); !exists || err != nil {
+		t.Errorf("committed record doesn't exist: %v", err)
+	}
+	if bytes.Equal(hash, ptw.Hash()) {
+		t.Errorf("committing left the tree unmodified")
+	}
+}
+
+//the lists and values decoded from txs and the tree must never panic, and any input
+//  which decodes must encode to an equivalent input
+func FuzzDecode(f *testing.F) {
+
+	f.Add(emptyTxList)
+	f.Add("")
+	f.Add("codeHashed:recoveryKeyEncrypted;codeHashed2:recoveryKeyEncrypted2")
+	f.Add("cIdNameHashed.cIdNameEncrypted.cRecordEncrypted,a.b.c")
+	f.Add("cIdNameHashed.cIdNameEncrypted.cRecordEncrypted.history1+history2,a.b.c.d")
+	f.Add("testCode1;testCode2")
+	f.Add("member/wrappedKey")
+	f.Add("1/0/wrappedKey")
+	f.Add("requesterHashed/approved")
+	f.Add("vaultHashed.member.-.wrappedKey,vaultHashed.released.custodianHashed.wrappedKey")
+	f.Add("usernameHashed.member.wrappedKey,usernameHashed.share.wrappedShare")
+	f.Add(EndToEndState{Suite: "argon2id", VerifierEncrypted: "v", CIdList: "/a/b/", CRecordEncrypted: "r",
+		History: []string{"h1", "h2"}, Status: VaultMember, WrappedKey: "k"}.Encode())
+	f.Add(EncodeEndToEndError(errors.New("invalidCIdName")))
+	f.Add("suite: legacy\nhistory: ")
+
+	f.Fuzz(func(t *testing.T, encoded string) {
+
+		if codes, err := DecodeRecoveryCodes(encoded); err == nil {
+			if again, err := DecodeRecoveryCodes(EncodeRecoveryCodes(codes)); err != nil || !reflect.DeepEqual(codes, again) {
+				t.Errorf("recovery codes of %q re-decoded as %v: %v", encoded, again, err)
+			}
+		}
+		if records, err := DecodeRekeyedRecords(encoded); err == nil {
+			if again, err := DecodeRekeyedRecords(EncodeRekeyedRecords(records)); err != nil || !reflect.DeepEqual(records, again) {
+				t.Errorf("rekeyed records of %q re-decoded as %v: %v", encoded, again, err)
+			}
+		}
+		if shares, err := DecodeKeyShares(encoded); err == nil {
+			if again, err := DecodeKeyShares(EncodeKeyShares(shares)); err != nil || !reflect.DeepEqual(shares, again) {
+				t.Errorf("key shares of %q re-decoded as %v: %v", encoded, again, err)
+			}
+		}
+		if codes, err := DecodeTwoFactorBackupCodes(encoded); err == nil {
+			if again, err := DecodeTwoFactorBackupCodes(EncodeTwoFactorBackupCodes(codes)); err != nil || !reflect.DeepEqual(codes, again) {
+				t.Errorf("backup codes of %q re-decoded as %v: %v", encoded, again, err)
+			}
+		}
+		if keys, err := DecodeWrappedKeys(encoded); err == nil {
+			if again, err := DecodeWrappedKeys(EncodeWrappedKeys(keys)); err != nil || !reflect.DeepEqual(keys, again) {
+				t.Errorf("wrapped keys of %q re-decoded as %v: %v", encoded, again, err)
+			}
+		}
+		if keys, err := DecodeRotatedKeys(encoded); err == nil {
+			if again, err := DecodeRotatedKeys(EncodeRotatedKeys(keys)); err != nil || !reflect.DeepEqual(keys, again) {
+				t.Errorf("rotated keys of %q re-decoded as %v: %v", encoded, again, err)
+			}
+		}
+		if status, wrappedKey, err := readVaultMemberValue([]byte(encoded)); err == nil {
+			if again, againKey, err := readVaultMemberValue(getVaultMemberValue(status, wrappedKey)); err != nil ||
+				again != status || againKey != wrappedKey {
+				t.Errorf("vault member value %q re-read as %s %s: %v", encoded, again, againKey, err)
+			}
+		}
+		if requester, approved, err := readReconstructionValue([]byte(encoded)); err == nil {
+			if again, againApproved, err := readReconstructionValue(getReconstructionValue(requester, approved)); err != nil ||
+				again != requester || againApproved != approved {
+				t.Errorf("reconstruction value %q re-read as %s %v: %v", encoded, again, againApproved, err)
+			}
+		}
+		if waitBlocks, requestHeight, wrappedKey, err := readEmergencyContactValue([]byte(encoded)); err == nil {
+			if againWait, againHeight, againKey, err := readEmergencyContactValue(
+				getEmergencyContactValue(waitBlocks, requestHeight, wrappedKey)); err != nil ||
+				againWait != waitBlocks || againHeight != requestHeight || againKey != wrappedKey {
+				t.Errorf("emergency contact value %q re-read: %v", encoded, err)
+			}
+		}
+		if state, err := DecodeEndToEndState(encoded); err == nil {
+			if again, err := DecodeEndToEndState(state.Encode()); err != nil || !reflect.DeepEqual(state, again) {
+				t.Errorf("end-to-end state of %q re-decoded as %+v: %v", encoded, again, err)
+			}
+		}
+	})
 }
@@ -0,0 +1,280 @@
+//two-factor authentication of UI sessions, each enrolled account holds a totp secret
+//  encrypted under the master password along with hashed one-time backup codes. A backup
+//  code is proven within a tx by the hash of the code, which is hashed again to be stored.
+//  The time step of the last accepted totp code is stored so that codes may not be reused
+package tree
+
+import (
+	"errors"
+	"path"
+	"strconv"
+	"strings"
+
+	cry "github.com/rigelrozanski/passwerk/crypto"
+)
+
+//time steps either side of the current time step within which totp codes are accepted
+const TwoFactorSkew int64 = 1
+
+//the two-factor key of an account is derived one way from the master password as stretched
+//  by the cipher suite of the account, so that end-to-end clients may provide it to the server
+//  to verify their totp codes without providing the master password
+func TwoFactorKey(urlUsername, keyPassword string) string {
+	return cry.GetHashedHexString(path.Join(urlUsername, keyPassword, "twoFactorKey"))
+}
+
+//encode the hashed backup codes for a tx as codeHashed;codeHashed;...
+func EncodeTwoFactorBackupCodes(codesHashed []string) string {
+
+	if len(codesHashed) < 1 {
+		return emptyTxList
+	}
+	return strings.Join(codesHashed, ";")
+}
+
+func DecodeTwoFactorBackupCodes(encoded string) (codesHashed []string, err error) {
+
+	if encoded == emptyTxList {
+		return
+	}
+
+	for _, codeHashed := range strings.Split(encoded, ";") {
+		if len(codeHashed) < 1 {
+			err = errors.New("bad backup code list")
+			return
+		}
+		codesHashed = append(codesHashed, codeHashed)
+	}
+	return
+}
+
+//determine if a hashed backup code is within the stored list of unused codes
+func containsBackupCode(backupCodes []byte, codeHashed string) bool {
+
+	for _, storedCodeHashed := range strings.Split(string(backupCodes), ";") {
+		if storedCodeHashed == codeHashed {
+			return true
+		}
+	}
+	return false
+}
+
+//the time step of the last accepted totp code, -1 if no code has been accepted
+func getTwoFactorStep(subTree TreeReading, usernameHashed string) int64 {
+
+	_, value, exists := subTree.Get(getTwoFactorStepKey(usernameHashed))
+	if !exists {
+		return -1
+	}
+	step, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return step
+}
+
+/////////////////////////////////////////////
+//   WRITE Two-Factor Operations
+////////////////////////////////////////////
+
+//enroll an account in two-factor authentication
+func (ptw *PwkTreeWriter) EnableTwoFactor(secretEncrypted string, backupCodesHashed []string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	var subTree TreeWriting
+	subTree, err = ptw.LoadSubTree()
+	if err != nil {
+		err = errors.New("account doesn't exist")
+		return
+	}
+	if subTree.Has(getTwoFactorKey(ptw.wVar.usernameHashed)) {
+		err = errors.New("two-factor already enabled")
+		return
+	}
+
+	subTree.Set(getTwoFactorKey(ptw.wVar.usernameHashed), []byte(secretEncrypted))
+	subTree.Set(getTwoFactorBackupCodesKey(ptw.wVar.usernameHashed), []byte(strings.Join(backupCodesHashed, ";")))
+
+	ptw.saveSubTree(subTree)
+	return
+}
+
+//remove the two-factor secret and all backup codes of an account, an unused backup code
+//  must be provided as proof of the second factor
+func (ptw *PwkTreeWriter) DisableTwoFactor(codeHashed string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	var subTree TreeWriting
+	subTree, err = ptw.LoadSubTree()
+	if err != nil {
+		err = errors.New("account doesn't exist")
+		return
+	}
+	if !subTree.Has(getTwoFactorKey(ptw.wVar.usernameHashed)) {
+		err = errors.New("two-factor not enabled")
+		return
+	}
+	_, backupCodes, exists := subTree.Get(getTwoFactorBackupCodesKey(ptw.wVar.usernameHashed))
+	if !exists || !containsBackupCode(backupCodes, codeHashed) {
+		err = errors.New("backup code doesn't exist")
+		return
+	}
+	subTree.Remove(getTwoFactorKey(ptw.wVar.usernameHashed))
+	subTree.Remove(getTwoFactorBackupCodesKey(ptw.wVar.usernameHashed))
+
+	ptw.saveSubTree(subTree)
+	return
+}
+
+//remove a backup code from the list of unused codes once it has been used
+func (ptw *PwkTreeWriter) UseTwoFactorBackupCode(codeHashed string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	var subTree TreeWriting
+	subTree, err = ptw.LoadSubTree()
+	if err != nil {
+		err = errors.New("account doesn't exist")
+		return
+	}
+
+	backupCodesKey := getTwoFactorBackupCodesKey(ptw.wVar.usernameHashed)
+	_, backupCodes, exists := subTree.Get(backupCodesKey)
+	if !exists || !containsBackupCode(backupCodes, codeHashed) {
+		err = errors.New("backup code doesn't exist")
+		return
+	}
+
+	var remaining []string
+	for _, storedCodeHashed := range strings.Split(string(backupCodes), ";") {
+		if storedCodeHashed != codeHashed {
+			remaining = append(remaining, storedCodeHashed)
+		}
+	}
+	subTree.Set(backupCodesKey, []byte(strings.Join(remaining, ";")))
+
+	ptw.saveSubTree(subTree)
+	return
+}
+
+//record the time step of an accepted totp code, which must be later than the last accepted step
+func (ptw *PwkTreeWriter) UseTwoFactorStep(step int64) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	var subTree TreeWriting
+	subTree, err = ptw.LoadSubTree()
+	if err != nil {
+		err = errors.New("account doesn't exist")
+		return
+	}
+	if !subTree.Has(getTwoFactorKey(ptw.wVar.usernameHashed)) {
+		err = errors.New("two-factor not enabled")
+		return
+	}
+	if step <= getTwoFactorStep(subTree, ptw.wVar.usernameHashed) {
+		err = errors.New("totp code already used")
+		return
+	}
+	subTree.Set(getTwoFactorStepKey(ptw.wVar.usernameHashed), []byte(strconv.FormatInt(step, 10)))
+
+	ptw.saveSubTree(subTree)
+	return
+}
+
+func (ptw *PwkTreeWriter) VerifyTwoFactorEnabled() bool {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	subTree, err := ptw.LoadSubTree()
+	if err != nil {
+		return false
+	}
+	return subTree.Has(getTwoFactorKey(ptw.wVar.usernameHashed))
+}
+
+func (ptw *PwkTreeWriter) VerifyTwoFactorBackupCodeExists(codeHashed string) bool {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	subTree, err := ptw.LoadSubTree()
+	if err != nil {
+		return false
+	}
+	_, backupCodes, exists := subTree.Get(getTwoFactorBackupCodesKey(ptw.wVar.usernameHashed))
+	return exists && containsBackupCode(backupCodes, codeHashed)
+}
+
+//determine if a totp time step is later than the last accepted step
+func (ptw *PwkTreeWriter) VerifyTwoFactorStepUnused(step int64) bool {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	subTree, err := ptw.LoadSubTree()
+	if err != nil {
+		return false
+	}
+	return step > getTwoFactorStep(subTree, ptw.wVar.usernameHashed)
+}
+
+/////////////////////////////////////////////
+//   READ Two-Factor Operations
+////////////////////////////////////////////
+
+//retrieve the encrypted two-factor secret, an error is returned for accounts not enrolled
+func (ptr *PwkTreeReader) RetrieveTwoFactorSecret() (secretEncrypted string, err error) {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	subTree, err := ptr.loadSubTree()
+	if err != nil {
+		return
+	}
+
+	_, value, exists := subTree.Get(getTwoFactorKey(ptr.rVar.usernameHashed))
+	if !exists {
+		err = errors.New("two-factor not enabled")
+		return
+	}
+
+	secretEncrypted = string(value)
+	return
+}
+
+//determine if a hashed backup code is unused
+func (ptr *PwkTreeReader) VerifyTwoFactorBackupCode(codeHashed string) bool {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	subTree, err := ptr.loadSubTree()
+	if err != nil {
+		return false
+	}
+
+	_, backupCodes, exists := subTree.Get(getTwoFactorBackupCodesKey(ptr.rVar.usernameHashed))
+	return exists && containsBackupCode(backupCodes, codeHashed)
+}
+
+//retrieve the time step of the last accepted totp code, -1 if no code has been accepted
+func (ptr *PwkTreeReader) RetrieveTwoFactorStep() int64 {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	subTree, err := ptr.loadSubTree()
+	if err != nil {
+		return -1
+	}
+	return getTwoFactorStep(subTree, ptr.rVar.usernameHashed)
+}
@@ -34,7 +34,6 @@ type TreeWriting interface {
 	Remove(key []byte) (value []byte, removed bool)
 	Load(hash []byte)
 	Save() (hash []byte)
-	Copy() merkle.Tree
 
 	LoadSubTree(UsernameHashed string) (PwkMerkleTree, error)
 	NewSubTree(UsernameHashed string) PwkMerkleTree
@@ -0,0 +1,305 @@
+//shared vaults, subtrees whose records are encrypted with a per-vault key
+//  which is wrapped to the public key of each member
+package tree
+
+import (
+	"errors"
+	"strings"
+)
+
+//status of a user within a shared vault
+const (
+	VaultOwner   string = "owner"
+	VaultMember  string = "member"
+	VaultInvited string = "invited"
+)
+
+//value held for each member, status and wrapped key are seperated
+//  by a "/" which cannot appear within either
+func getVaultMemberValue(status, wrappedKey string) []byte {
+	return []byte(status + "/" + wrappedKey)
+}
+
+func readVaultMemberValue(value []byte) (status, wrappedKey string, err error) {
+	parts := strings.Split(string(value), "/")
+	if len(parts) != 2 {
+		err = errors.New("bad vault member record")
+		return
+	}
+	status, wrappedKey = parts[0], parts[1]
+	return
+}
+
+/////////////////////////////////////////////
+//   WRITE Vault Operations
+////////////////////////////////////////////
+
+//publish the public key of a user, a published key cannot be replaced
+func (ptw *PwkTreeWriter) PublishPublicKey(usernameHashed, publicKey string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	if ptw.tree.Has(getPublicKeyKey(usernameHashed)) {
+		err = errors.New("public key already published")
+		return
+	}
+
+	ptw.tree.Set(getPublicKeyKey(usernameHashed), []byte(publicKey))
+	return
+}
+
+func (ptw *PwkTreeWriter) VerifyPublicKeyExists(usernameHashed string) bool {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	return ptw.tree.Has(getPublicKeyKey(usernameHashed))
+}
+
+//create a shared vault with the owner holding the vault key wrapped to their public key
+func (ptw *PwkTreeWriter) NewVault(vaultHashed, ownerUsernameHashed, wrappedKey string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	if ptw.tree.Has(getMapKey(vaultHashed)) {
+		err = errors.New("vault already exists")
+		return
+	}
+
+	subTree := ptw.tree.NewSubTree(vaultHashed)
+	subTree.Set(GetCIdListKey(vaultHashed), []byte("/"))
+	subTree.Set(getVaultMemberKey(vaultHashed, ownerUsernameHashed), getVaultMemberValue(VaultOwner, wrappedKey))
+
+	ptw.tree.SaveSubTree(vaultHashed, subTree)
+	return
+}
+
+//retrieve the status of a user within a vault, an empty status is returned for non-members
+func (ptw *PwkTreeWriter) GetVaultMemberStatus(vaultHashed, usernameHashed string) (status string, err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	return ptw.getVaultMemberStatus(vaultHashed, usernameHashed)
+}
+
+func (ptw *PwkTreeWriter) getVaultMemberStatus(vaultHashed, usernameHashed string) (status string, err error) {
+
+	subTree, err := ptw.tree.LoadSubTree(vaultHashed)
+	if err != nil {
+		err = errors.New("vault doesn't exist")
+		return
+	}
+
+	_, value, exists := subTree.Get(getVaultMemberKey(vaultHashed, usernameHashed))
+	if !exists {
+		return
+	}
+
+	status, _, err = readVaultMemberValue(value)
+	return
+}
+
+//invite a user to a vault, only the owner (or organization admins for collections)
+//  may invite and the invitee must have a published key. The invitee of a collection
+//  must hold at least the role required to read the collection
+func (ptw *PwkTreeWriter) InviteVaultMember(vaultHashed, inviterUsernameHashed,
+	inviteeUsernameHashed, wrappedKey string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	err = ptw.verifyVaultAuthority(vaultHashed, inviterUsernameHashed)
+	if err != nil {
+		return
+	}
+
+	isCollection, inviteeRole, readRole, _, _ := getCollectionAccess(ptw.tree, vaultHashed, inviteeUsernameHashed)
+	if isCollection && OrgRoleRank(inviteeRole) < OrgRoleRank(readRole) {
+		err = errors.New("invitee's organization role may not read the collection")
+		return
+	}
+
+	var status string
+
+	if !ptw.tree.Has(getPublicKeyKey(inviteeUsernameHashed)) {
+		err = errors.New("invitee has no published public key")
+		return
+	}
+
+	status, err = ptw.getVaultMemberStatus(vaultHashed, inviteeUsernameHashed)
+	if err != nil {
+		return
+	}
+	if len(status) > 0 {
+		err = errors.New("invitee is already a member of the vault")
+		return
+	}
+
+	subTree, _ := ptw.tree.LoadSubTree(vaultHashed)
+	subTree.Set(getVaultMemberKey(vaultHashed, inviteeUsernameHashed), getVaultMemberValue(VaultInvited, wrappedKey))
+	ptw.tree.SaveSubTree(vaultHashed, subTree)
+
+	return
+}
+
+func (ptw *PwkTreeWriter) AcceptVaultInvite(vaultHashed, usernameHashed string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	subTree, err := ptw.tree.LoadSubTree(vaultHashed)
+	if err != nil {
+		err = errors.New("vault doesn't exist")
+		return
+	}
+
+	memberKey := getVaultMemberKey(vaultHashed, usernameHashed)
+	_, value, exists := subTree.Get(memberKey)
+	if !exists {
+		err = errors.New("no invite to accept")
+		return
+	}
+
+	var status, wrappedKey string
+	status, wrappedKey, err = readVaultMemberValue(value)
+	if err != nil {
+		return
+	}
+	if status != VaultInvited {
+		err = errors.New("no invite to accept")
+		return
+	}
+
+	subTree.Set(memberKey, getVaultMemberValue(VaultMember, wrappedKey))
+	ptw.tree.SaveSubTree(vaultHashed, subTree)
+
+	return
+}
+
+//revoke a member (or invite) from a vault, the owner (or organization admins for
+//  collections) may revoke any member and members may revoke themselves. The vault key
+//  is rotated so that any copy of the key retained by the revoked member is of no use,
+//  the records must be resealed under the new key and the new key must be wrapped to every
+//  remaining holder of the key. Emergency contacts and custodians are granted the key by
+//  the owner apart from membership, so a revoked member holding such a grant keeps it with
+//  the new key. Any reconstruction is cancelled as its released shares are of the previous key
+func (ptw *PwkTreeWriter) RevokeVaultMember(vaultHashed, revokerUsernameHashed,
+	memberUsernameHashed string, records []RekeyedRecord, keys []RotatedKey) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	var memberStatus string
+	memberStatus, err = ptw.getVaultMemberStatus(vaultHashed, memberUsernameHashed)
+	if err != nil {
+		return
+	}
+
+	switch {
+	case len(memberStatus) < 1:
+		err = errors.New("member to revoke doesn't exist")
+	case memberStatus == VaultOwner:
+		err = errors.New("the vault owner cannot be revoked")
+	case revokerUsernameHashed != memberUsernameHashed:
+		err = ptw.verifyVaultAuthority(vaultHashed, revokerUsernameHashed)
+	}
+	if err != nil {
+		return
+	}
+
+	subTree, _ := ptw.tree.LoadSubTree(vaultHashed)
+	err = rotationCovers(subTree, vaultHashed, memberUsernameHashed, records, keys)
+	if err != nil {
+		return
+	}
+
+	subTree.Remove(getVaultMemberKey(vaultHashed, memberUsernameHashed))
+	resealRecords(subTree, vaultHashed, records)
+	for _, key := range keys {
+		recordKey, _, rewrap, _ := getWrappedKeyRecord(subTree, key.UsernameHashed,
+			WrappedKey{VaultHashed: vaultHashed, Kind: key.Kind})
+		subTree.Set(recordKey, rewrap(key.WrappedKey))
+	}
+	if _, custodians, splitErr := getKeySplit(ptw.tree, vaultHashed); splitErr == nil {
+		clearReconstruction(subTree, vaultHashed, custodians)
+	}
+	ptw.tree.SaveSubTree(vaultHashed, subTree)
+
+	return
+}
+
+//verify that the rotation of the vault key on the revocation of a member covers every
+//  record of the vault and every remaining holder of the vault key
+func (ptw *PwkTreeWriter) VerifyRotationCovers(vaultHashed, memberUsernameHashed string,
+	records []RekeyedRecord, keys []RotatedKey) error {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	subTree, err := ptw.tree.LoadSubTree(vaultHashed)
+	if err != nil {
+		return errors.New("vault doesn't exist")
+	}
+	return rotationCovers(subTree, vaultHashed, memberUsernameHashed, records, keys)
+}
+
+func rotationCovers(subTree TreeReading, vaultHashed, memberUsernameHashed string,
+	records []RekeyedRecord, keys []RotatedKey) error {
+
+	if !coversRecords(subTree, vaultHashed, records) {
+		return errors.New("rotation must cover every record")
+	}
+	return rotationCoversHolders(subTree, vaultHashed, memberUsernameHashed, keys)
+}
+
+/////////////////////////////////////////////
+//   READ Vault Operations
+////////////////////////////////////////////
+
+func (ptr *PwkTreeReader) VaultExists(vaultHashed string) bool {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	return ptr.tree.Has(getMapKey(vaultHashed))
+}
+
+func (ptr *PwkTreeReader) RetrievePublicKey(usernameHashed string) (publicKey string, err error) {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	_, value, exists := ptr.tree.Get(getPublicKeyKey(usernameHashed))
+	if !exists {
+		err = errors.New("noPublicKey")
+		return
+	}
+
+	publicKey = string(value)
+	return
+}
+
+//retrieve the status and wrapped vault key of a user within a vault
+func (ptr *PwkTreeReader) RetrieveVaultMembership(vaultHashed, usernameHashed string) (
+	status, wrappedKey string, err error) {
+
+	ptr.mtx.Lock()
+	defer ptr.mtx.Unlock()
+
+	subTree, err := ptr.tree.LoadSubTree(vaultHashed)
+	if err != nil {
+		err = errors.New("notVaultMember")
+		return
+	}
+
+	_, value, exists := subTree.Get(getVaultMemberKey(vaultHashed, usernameHashed))
+	if !exists {
+		err = errors.New("notVaultMember")
+		return
+	}
+
+	return readVaultMemberValue(value)
+}
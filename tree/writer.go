@@ -2,7 +2,6 @@ package tree
 
 import (
 	"errors"
-	"fmt"
 	"strings"
 	"sync"
 )
@@ -55,6 +54,30 @@ func (ptw *PwkTreeWriter) SetVariables(
 //   Subtree Management
 ////////////////////////////////////////////
 
+//a writer staging its modifications in memory over the momma tree of this writer, which
+//  is left unmodified until the staged writer is committed. Nothing is written to the db
+//  through a staged writer, so a tx staged by CheckTx and never committed leaves the
+//  storage unchanged. The staged writer shares the lock of this writer
+func (ptw *PwkTreeWriter) Staged() PwkTreeWriter {
+	return NewPwkTreeWriter(ptw.mtx, newStagedTree(ptw.tree.(PwkMerkleTree)), "", "", "")
+}
+
+//write the modifications of a writer returned by Staged to the momma tree it was staged
+//  over, the writer may not be used once committed
+func (ptw *PwkTreeWriter) CommitStaged() error {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	staged, ok := ptw.tree.(*stagedTree)
+	if !ok {
+		return errors.New("writer is not staged")
+	}
+	staged.commit()
+
+	return nil
+}
+
 //exported because used by CheckTx
 func (ptw *PwkTreeWriter) LoadSubTree() (TreeWriting, error) {
 
@@ -117,6 +140,88 @@ func (ptw *PwkTreeWriter) VerifyRecordExists() (bool, error) {
 	return true, nil
 }
 
+func (ptw *PwkTreeWriter) VerifyAccountExists() bool {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	return ptw.tree.Has(getMapKey(ptw.wVar.usernameHashed))
+}
+
+//the usernames of deleted accounts are retired, see DeleteAccount
+func (ptw *PwkTreeWriter) VerifyAccountRetired() bool {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	return ptw.tree.Has(getRetiredAccountKey(ptw.wVar.usernameHashed))
+}
+
+//accounts are the subtrees holding a verifier, as opposed to vaults and organizations
+func (ptw *PwkTreeWriter) VerifyIsAccount(usernameHashed string) bool {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	subTree, err := ptw.tree.LoadSubTree(usernameHashed)
+	return err == nil && subTree.Has(GetVerifierKey(usernameHashed))
+}
+
+//create the subtree for a new account along with its verifier and an empty cIdList
+func (ptw *PwkTreeWriter) NewAccount(verifierEncrypted string) (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	if ptw.tree.Has(getMapKey(ptw.wVar.usernameHashed)) {
+		err = errors.New("account already exists")
+		return
+	}
+	if ptw.tree.Has(getRetiredAccountKey(ptw.wVar.usernameHashed)) {
+		err = errors.New("username of a deleted account may not be registered")
+		return
+	}
+
+	subTree := ptw.newSubTree()
+	subTree.Set(GetVerifierKey(ptw.wVar.usernameHashed), []byte(verifierEncrypted))
+	subTree.Set(GetCIdListKey(ptw.wVar.usernameHashed), []byte("/"))
+
+	ptw.saveSubTree(subTree)
+
+	return
+}
+
+//remove the account subtree along with all of the records it holds. Vaults and
+//  organizations share the namespace of accounts, only subtrees holding a verifier
+//  are accounts which may be deleted. The username of the account is retired so that
+//  the vault memberships, organization roles, emergency contacts and key shares held
+//  under its hash are never inherited by a new account of the same username
+func (ptw *PwkTreeWriter) DeleteAccount() (err error) {
+
+	ptw.mtx.Lock()
+	defer ptw.mtx.Unlock()
+
+	subTree, err := ptw.LoadSubTree()
+	if err != nil {
+		err = errors.New("account to delete doesn't exist")
+		return
+	}
+	if !subTree.Has(GetVerifierKey(ptw.wVar.usernameHashed)) {
+		err = errors.New("only accounts may be deleted")
+		return
+	}
+
+	ptw.tree.Remove(getMapKey(ptw.wVar.usernameHashed))
+
+	//the public keys are derived from the master password, so are removed with the account
+	ptw.tree.Remove(getPublicKeyKey(ptw.wVar.usernameHashed))
+	ptw.tree.Remove(getSigningKeyKey(ptw.wVar.usernameHashed))
+
+	ptw.tree.Set(getRetiredAccountKey(ptw.wVar.usernameHashed), []byte("retired"))
+
+	return
+}
+
 func (ptw *PwkTreeWriter) DeleteRecord() (err error) {
 
 	ptw.mtx.Lock()
@@ -140,61 +245,47 @@ func (ptw *PwkTreeWriter) DeleteRecord() (err error) {
 		return
 	}
 
-	//delete the main record from the merkle.Tree
-	_, successfulRemove := subTree.Remove(merkleRecordKey)
+	//delete the main record from the merkle.Tree, retaining it within the record history
+	cRecordEncrypted, successfulRemove := subTree.Remove(merkleRecordKey)
 	if !successfulRemove {
 		err = errors.New("error deleting the record from subTree")
 		return
 	}
+	archiveRecord(subTree, ptw.wVar.usernameHashed, ptw.wVar.cIdNameHashed, string(cRecordEncrypted))
 
 	//delete the index from the cIdName list
 	oldCIdListValues := string(cIdListValues)
 	newCIdListValues := strings.Replace(oldCIdListValues, "/"+ptw.wVar.cIdNameEncrypted+"/", "/", 1)
 	subTree.Set(cIdListKey, []byte(newCIdListValues))
 
-	//save the subTree
+	//save the subTree, note that the account remains
+	//  registered even if there are no more records held
 	ptw.saveSubTree(subTree)
 
-	//If there are no more values within the CIdList, then delete the CIdList
-	//   as well as the main username password sub tree
-	_, cIdListValues, _ = subTree.Get(cIdListKey)
-	if len(string(cIdListValues)) < 2 {
-		subTree.Remove(cIdListKey)
-		ptw.tree.Remove(getMapKey(ptw.wVar.usernameHashed))
-	}
-
 	return
 }
 
 //must delete any records with the same cIdName before adding a new record
-func (ptw *PwkTreeWriter) NewRecord(cpasswordEncrypted string) (err error) {
+func (ptw *PwkTreeWriter) NewRecord(cRecordEncrypted string) (err error) {
 
 	ptw.mtx.Lock()
 	defer ptw.mtx.Unlock()
 
 	var subTree TreeWriting
-	mapKey := getMapKey(ptw.wVar.usernameHashed)
 	cIdListKey := GetCIdListKey(ptw.wVar.usernameHashed)
 
-	//if the relavant subTree does not exist
-	//  create the subtree as well as the cIdList
-	if ptw.tree.Has(mapKey) {
-		subTree, err = ptw.LoadSubTree()
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		_, cIdListValues, _ := subTree.Get(cIdListKey)
-		subTree.Set(cIdListKey, []byte(string(cIdListValues)+ptw.wVar.cIdNameEncrypted+"/"))
-
-	} else {
-		subTree = ptw.newSubTree()
-		subTree.Set(cIdListKey, []byte("/"+ptw.wVar.cIdNameEncrypted+"/"))
+	//records may only be written to registered accounts
+	subTree, err = ptw.LoadSubTree()
+	if err != nil {
+		err = errors.New("account doesn't exist")
+		return
 	}
+	_, cIdListValues, _ := subTree.Get(cIdListKey)
+	subTree.Set(cIdListKey, []byte(string(cIdListValues)+ptw.wVar.cIdNameEncrypted+"/"))
 
 	//create the new record in the tree
 	insertKey := GetRecordKey(ptw.wVar.usernameHashed, ptw.wVar.cIdNameHashed)
-	insertValues := []byte(cpasswordEncrypted)
+	insertValues := []byte(cRecordEncrypted)
 	subTree.Set(insertKey, insertValues)
 
 	ptw.saveSubTree(subTree)
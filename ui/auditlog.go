@@ -0,0 +1,52 @@
+//server-side audit log of authentication failures
+package ui
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+//failures reported uniformly when uniform errors are enabled, as each reveals
+//  whether a username, password, second factor, session, or identifier is valid
+var authFailures = map[string]bool{
+	"badAuthentication": true,
+	"badTwoFactor":      true,
+	"twoFactorRequired": true,
+	"badSession":        true,
+	"tooManyAttempts":   true,
+	"invalidCIdName":    true,
+}
+
+//the audit log holds the detailed reason of each authentication failure, usernames
+//  are only ever logged hashed
+type AuditLog struct {
+	mtx sync.Mutex
+	w   io.Writer
+}
+
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{w: w}
+}
+
+//record an authentication failure, a nil audit log records nothing
+func (al *AuditLog) authFailure(clientIP, usernameHashed, operationalOption, reason string) {
+
+	if al == nil {
+		return
+	}
+
+	if len(usernameHashed) < 1 {
+		usernameHashed = "-"
+	}
+	if len(operationalOption) < 1 {
+		operationalOption = "-"
+	}
+
+	al.mtx.Lock()
+	defer al.mtx.Unlock()
+
+	fmt.Fprintf(al.w, "%s authFailure client=%s user=%s op=%s reason=%s\n",
+		time.Now().UTC().Format(time.RFC3339), clientIP, usernameHashed, operationalOption, reason)
+}
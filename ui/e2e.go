@@ -0,0 +1,201 @@
+//end-to-end access, the master username/password and record contents of end-to-end clients
+//  never reach the server. Clients derive keys and encrypt locally, the server relays the txs
+//  they build and outputs ciphertexts to requests signed by the account. Both are provided
+//  hex encoded as a URL section:
+//    /e2e/tx/<hex tx>              relay a tx, signed by the acting user except when registering
+//    /e2e/read/<hex read request>  output the ciphertexts of an account or shared vault
+//  where a read request is:  timestamp/reading/targetHashed/cIdNameHashed/twoFactor/signerUsernameHashed/signature
+//  with cIdNameHashed as "-" when no record is requested. Signers enrolled in two-factor
+//  authentication provide a totp code as twoFactor, which opens an end-to-end session whose
+//  token is then provided in its place. End-to-end requests are only served in end-to-end mode
+package ui
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	tre "github.com/rigelrozanski/passwerk/tree"
+)
+
+//URL path prefix of end-to-end requests
+const EndToEndPath string = "e2e"
+
+//prefix of the output of a relayed tx, followed by the response of tendermint
+const EndToEndResultPrefix string = "result: "
+
+//URL paths of end-to-end requests
+func EndToEndTxPath(tx string) string {
+	return "/" + path.Join(EndToEndPath, "tx", hex.EncodeToString([]byte(tx)))
+}
+
+func EndToEndReadPath(readRequest string) string {
+	return "/" + path.Join(EndToEndPath, "read", hex.EncodeToString([]byte(readRequest)))
+}
+
+//function handles the end-to-end requests of the passwerk local host, the output is
+//  plain text for use by clients rather than the ASCII assailant
+func (app *UIApp) EndToEndHandler(w http.ResponseWriter, r *http.Request) {
+
+	var dummyStringPtr [3]*string
+
+	output, err := app.newRequest().performEndToEnd(strings.TrimPrefix(r.URL.Path, "/"+EndToEndPath+"/"),
+		getClientIP(r), dummyStringPtr)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		output = tre.EncodeEndToEndError(err)
+	}
+	w.Write([]byte(output))
+}
+
+func (app *uiRequest) performEndToEnd(urlString, clientIP string, txBroadcastStr [3]*string) (
+	output string, err error) {
+
+	temp := strings.Split(urlString, "/")
+	if len(temp) != 2 {
+		err = errors.New("generalError")
+		return
+	}
+
+	decoded, decodeErr := hex.DecodeString(temp[1])
+	if decodeErr != nil {
+		err = errors.New("generalError")
+		return
+	}
+	request := string(decoded)
+	parts := strings.Split(request, "/")
+
+	//signatures and sessions which fail to verify are rate limited and audited as per failed
+	//  master password authentication, as are the totp codes of the verified signer
+	err = app.limiter.allow("", clientIP)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err == nil || !authFailures[err.Error()] {
+			return
+		}
+		var signerUsernameHashed string
+		if len(parts) > 1 {
+			signerUsernameHashed = parts[len(parts)-2]
+		}
+		app.auditLog.authFailure(clientIP, signerUsernameHashed, "endToEnd", err.Error())
+		switch err.Error() {
+		case "badAuthentication", "badSession":
+			app.limiter.fail("", clientIP)
+		case "badTwoFactor":
+			app.limiter.fail(signerUsernameHashed, clientIP)
+		}
+	}()
+
+	switch temp[0] {
+	case "tx":
+		err = app.verifyRelayedTx(request, parts)
+		if err != nil {
+			return
+		}
+
+		result := "relayed"
+		if app.testing {
+			*txBroadcastStr[0] = request
+		} else {
+			result = app.broadcastTxFromString(request)
+		}
+		output = EndToEndResultPrefix + result + "\n"
+
+	case "read":
+		var state tre.EndToEndState
+		state, err = app.ptr.ReadEndToEnd(request, time.Now(), app.suite)
+		if err != nil {
+			return
+		}
+
+		//the second factor is verified once the signature of the request has been verified
+		if len(parts) > 2 {
+			signerUsernameHashed := parts[len(parts)-2]
+			err = app.limiter.allow(signerUsernameHashed, clientIP)
+			if err != nil {
+				return
+			}
+			state.Session, err = app.authEndToEndTwoFactor(signerUsernameHashed, parts[len(parts)-3])
+			if err != nil {
+				return
+			}
+		}
+		output = state.Encode()
+
+	default:
+		err = errors.New("generalError")
+	}
+	return
+}
+
+//authenticate the second factor of a signer enrolled in two-factor authentication by the
+//  token of an end-to-end session, or by a totp code which opens a session. The token of
+//  an opened session is returned. Backup codes are not accepted as their use is recorded by
+//  a tx signed with keys only held by the client
+func (app *uiRequest) authEndToEndTwoFactor(signerUsernameHashed, twoFactor string) (token string, err error) {
+
+	twoFactorKey, totpCode, sessionToken := tre.DecodeEndToEndTwoFactor(twoFactor)
+	if len(sessionToken) > 0 {
+		sessionUsernameHashed, sessionErr := app.sessions.getEndToEnd(sessionToken)
+		if sessionErr != nil || sessionUsernameHashed != signerUsernameHashed {
+			err = errors.New("badSession")
+		}
+		return
+	}
+
+	step, err := app.ptr.VerifyEndToEndTwoFactor(signerUsernameHashed, twoFactorKey, totpCode, app.twoFactorTime())
+	if err != nil || step == 0 {
+		return
+	}
+	if !app.useTwoFactorStep(signerUsernameHashed, step) {
+		err = errors.New("badTwoFactor")
+		return
+	}
+	app.limiter.succeed(signerUsernameHashed)
+	return app.sessions.createEndToEnd(signerUsernameHashed)
+}
+
+//txs are only relayed when signed by the user they act on behalf of, txs of shared vaults and
+//  organizations are signed by the acting user and further verified within the tmsp application.
+//  New accounts are registered without a signature as their signing key is not yet published
+func (app *uiRequest) verifyRelayedTx(tx string, parts []string) error {
+
+	if len(parts) < 3 {
+		return errors.New("generalError")
+	}
+	if parts[1] == "registering" {
+		return nil
+	}
+
+	signerUsernameHashed, err := app.ptr.VerifyEndToEndSignature(tx)
+	if err != nil {
+		return err
+	}
+	if parts[2] == signerUsernameHashed {
+		return nil
+	}
+
+	//no user may act on behalf of another account
+	if _, err := app.ptr.RetrieveSigningKey(parts[2]); err == nil {
+		return errors.New("badAuthentication")
+	}
+
+	switch parts[1] {
+
+	//records of a shared vault may be written by its owner and members
+	case "writing", "deleting":
+		status, _, err := app.ptr.RetrieveVaultMembership(parts[2], signerUsernameHashed)
+		if err != nil || (status != tre.VaultOwner && status != tre.VaultMember) {
+			return errors.New("notVaultMember")
+		}
+
+	case "deletingAccount", "migratingSuite":
+		return errors.New("badAuthentication")
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+//brute-force protection of master password authentication
+package ui
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+//thresholds of authentication rate limiting
+type AuthLimits struct {
+	MaxFailures       int           //failures of an account before it is locked out
+	MaxClientFailures int           //failures of a client IP before it is locked out
+	Backoff           time.Duration //delay of an account after its first failure, doubled with each failure
+	Lockout           time.Duration //duration of lockouts, and the maximum backoff
+	FailureFloor      time.Duration //minimum duration of failed authentication responses
+}
+
+func DefaultAuthLimits() AuthLimits {
+	return AuthLimits{
+		MaxFailures:       5,
+		MaxClientFailures: 20,
+		Backoff:           time.Second,
+		Lockout:           15 * time.Minute,
+		FailureFloor:      250 * time.Millisecond,
+	}
+}
+
+//failed authentication attempts of an account or client
+type attemptRecord struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+//accounts are limited by their hashed username, and clients by their IP
+type authLimiter struct {
+	mtx      sync.Mutex
+	limits   AuthLimits
+	accounts map[string]*attemptRecord
+	clients  map[string]*attemptRecord
+	now      func() time.Time
+}
+
+func newAuthLimiter(limits AuthLimits) *authLimiter {
+	return &authLimiter{
+		limits:   limits,
+		accounts: make(map[string]*attemptRecord),
+		clients:  make(map[string]*attemptRecord),
+		now:      time.Now,
+	}
+}
+
+//determine if an account (if known) and client may attempt authentication,
+//  a nil limiter does not limit authentication
+func (al *authLimiter) allow(usernameHashed, clientIP string) error {
+
+	if al == nil {
+		return nil
+	}
+
+	al.mtx.Lock()
+	defer al.mtx.Unlock()
+
+	now := al.now()
+	if record, exists := al.accounts[usernameHashed]; exists && len(usernameHashed) > 0 && now.Before(record.blockedUntil) {
+		return errors.New("tooManyAttempts")
+	}
+	if record, exists := al.clients[clientIP]; exists && now.Before(record.blockedUntil) {
+		return errors.New("tooManyAttempts")
+	}
+	return nil
+}
+
+//record a failed authentication attempt, accounts back off exponentially with each
+//  failure until locked out, while clients are locked out once their failures accumulate
+func (al *authLimiter) fail(usernameHashed, clientIP string) {
+
+	if al == nil {
+		return
+	}
+
+	al.mtx.Lock()
+	defer al.mtx.Unlock()
+
+	now := al.now()
+
+	if len(usernameHashed) > 0 {
+		record := al.getRecord(al.accounts, usernameHashed, now)
+		record.failures++
+		record.lastFailure = now
+		if record.failures >= al.limits.MaxFailures {
+			record.blockedUntil = now.Add(al.limits.Lockout)
+		} else {
+			backoff := al.limits.Backoff << uint(record.failures-1)
+			if backoff > al.limits.Lockout || backoff < 0 {
+				backoff = al.limits.Lockout
+			}
+			record.blockedUntil = now.Add(backoff)
+		}
+	}
+
+	record := al.getRecord(al.clients, clientIP, now)
+	record.failures++
+	record.lastFailure = now
+	if record.failures >= al.limits.MaxClientFailures {
+		record.blockedUntil = now.Add(al.limits.Lockout)
+	}
+}
+
+//successful authentication resets the failures of the account, the failures of the
+//  client are not reset as a client may hold an account of its own
+func (al *authLimiter) succeed(usernameHashed string) {
+
+	if al == nil {
+		return
+	}
+
+	al.mtx.Lock()
+	defer al.mtx.Unlock()
+
+	delete(al.accounts, usernameHashed)
+}
+
+//pad failed authentication responses to the failure floor so that response times do
+//  not reveal whether an account exists
+func (al *authLimiter) padFailure(started time.Time) {
+
+	if al == nil {
+		return
+	}
+
+	if remaining := al.limits.FailureFloor - time.Since(started); remaining > 0 {
+		time.Sleep(remaining)
+	}
+}
+
+//retrieve the record of a key, records are forgotten once a lockout has passed since their last failure
+func (al *authLimiter) getRecord(records map[string]*attemptRecord, key string, now time.Time) *attemptRecord {
+
+	record, exists := records[key]
+	if !exists || now.Sub(record.lastFailure) > al.limits.Lockout {
+		record = &attemptRecord{}
+		records[key] = record
+	}
+	return record
+}
+
+//forget the records of accounts and clients which are no longer blocked nor recently failed
+func (al *authLimiter) expire() {
+
+	al.mtx.Lock()
+	defer al.mtx.Unlock()
+
+	now := al.now()
+	for _, records := range []map[string]*attemptRecord{al.accounts, al.clients} {
+		for key, record := range records {
+			if now.After(record.blockedUntil) && now.Sub(record.lastFailure) > al.limits.Lockout {
+				delete(records, key)
+			}
+		}
+	}
+}
+
+//periodically forget expired records until done is closed
+func (al *authLimiter) sweep(interval time.Duration, done <-chan struct{}) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			al.expire()
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,222 @@
+//in-memory session tokens so the master password isn't sent on every request nor held by the server
+package ui
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	cry "github.com/rigelrozanski/passwerk/crypto"
+	"golang.org/x/crypto/ed25519"
+)
+
+//default timeouts of sessions, a session expires once it has not been used for the
+//  idle timeout or once it has existed for the absolute timeout
+const DefaultSessionIdleTimeout time.Duration = 15 * time.Minute
+const DefaultSessionMaxLifetime time.Duration = 12 * time.Hour
+
+//a session never holds the master password, only the username and the keys derived
+//  from the stretched master password when logging in, which are wiped on logout or expiry.
+//  Sessions of end-to-end clients only hold the hashed username as the keys never reach the server
+type session struct {
+	username      *cry.Secret
+	keyPassword   *cry.Secret
+	boxPublicKey  [32]byte
+	boxPrivateKey *cry.Secret
+	signingKey    *cry.Secret
+	endToEnd      bool
+	created       time.Time
+	lastUsed      time.Time
+}
+
+//overwrite the credentials held by the session
+func (s *session) wipe() {
+	s.username.Wipe()
+	s.keyPassword.Wipe()
+	s.boxPrivateKey.Wipe()
+	s.signingKey.Wipe()
+}
+
+//a copy of the account keys held by the session, to be wiped by the caller once used
+func (s *session) keys() accountKeys {
+
+	boxPublicKey, boxPrivateKey := new([32]byte), new([32]byte)
+	*boxPublicKey = s.boxPublicKey
+	copy(boxPrivateKey[:], s.boxPrivateKey.Bytes())
+
+	return accountKeys{
+		keyPassword:   string(s.keyPassword.Bytes()),
+		boxPublicKey:  boxPublicKey,
+		boxPrivateKey: boxPrivateKey,
+		signingKey:    append(ed25519.PrivateKey{}, s.signingKey.Bytes()...),
+	}
+}
+
+//sessions are identified by the hash of their token, the token itself is never held
+type sessionStore struct {
+	mtx         sync.Mutex
+	sessions    map[string]*session
+	idleTimeout time.Duration
+	maxLifetime time.Duration
+}
+
+func newSessionStore(idleTimeout, maxLifetime time.Duration) *sessionStore {
+	return &sessionStore{
+		sessions:    make(map[string]*session),
+		idleTimeout: idleTimeout,
+		maxLifetime: maxLifetime,
+	}
+}
+
+func (s *session) expired(now time.Time, idleTimeout, maxLifetime time.Duration) bool {
+	return now.Sub(s.lastUsed) > idleTimeout || now.Sub(s.created) > maxLifetime
+}
+
+//create a session for the keys of an authenticated account, returning the session token
+func (ss *sessionStore) create(urlUsername string, keys accountKeys) (token string, err error) {
+	return ss.add(&session{
+		username:      cry.NewSecretFromBytes([]byte(urlUsername)),
+		keyPassword:   cry.NewSecretFromBytes([]byte(keys.keyPassword)),
+		boxPublicKey:  *keys.boxPublicKey,
+		boxPrivateKey: cry.NewSecretFromBytes(append([]byte{}, keys.boxPrivateKey[:]...)),
+		signingKey:    cry.NewSecretFromBytes(append([]byte{}, keys.signingKey...)),
+	})
+}
+
+//create a session for an end-to-end client once its second factor has been verified
+func (ss *sessionStore) createEndToEnd(usernameHashed string) (token string, err error) {
+	return ss.add(&session{
+		username: cry.NewSecretFromBytes([]byte(usernameHashed)),
+		endToEnd: true,
+	})
+}
+
+func (ss *sessionStore) add(s *session) (token string, err error) {
+
+	tokenBytes := make([]byte, 32)
+	_, err = rand.Read(tokenBytes)
+	if err != nil {
+		s.wipe()
+		return
+	}
+	token = hex.EncodeToString(tokenBytes)
+
+	ss.mtx.Lock()
+	defer ss.mtx.Unlock()
+
+	s.created = time.Now()
+	s.lastUsed = s.created
+	ss.sessions[cry.GetHashedHexString(token)] = s
+	return
+}
+
+//retrieve the username and account keys of a session, expired sessions are wiped
+func (ss *sessionStore) get(token string) (urlUsername string, keys accountKeys, err error) {
+
+	ss.mtx.Lock()
+	defer ss.mtx.Unlock()
+
+	s, err := ss.use(token, false)
+	if err != nil {
+		return
+	}
+	return string(s.username.Bytes()), s.keys(), nil
+}
+
+//retrieve the hashed username of an end-to-end session
+func (ss *sessionStore) getEndToEnd(token string) (usernameHashed string, err error) {
+
+	ss.mtx.Lock()
+	defer ss.mtx.Unlock()
+
+	s, err := ss.use(token, true)
+	if err != nil {
+		return
+	}
+	return string(s.username.Bytes()), nil
+}
+
+//the unexpired session of the token, sessions of end-to-end clients are only used by
+//  end-to-end requests. The mutex must be held
+func (ss *sessionStore) use(token string, endToEnd bool) (s *session, err error) {
+
+	tokenHashed := cry.GetHashedHexString(token)
+	s, exists := ss.sessions[tokenHashed]
+	if !exists || s.endToEnd != endToEnd {
+		err = errors.New("badSession")
+		return
+	}
+
+	now := time.Now()
+	if s.expired(now, ss.idleTimeout, ss.maxLifetime) {
+		s.wipe()
+		delete(ss.sessions, tokenHashed)
+		err = errors.New("badSession")
+		return
+	}
+	s.lastUsed = now
+	return
+}
+
+//revoke a single session
+func (ss *sessionStore) revoke(token string) bool {
+
+	ss.mtx.Lock()
+	defer ss.mtx.Unlock()
+
+	tokenHashed := cry.GetHashedHexString(token)
+	s, exists := ss.sessions[tokenHashed]
+	if !exists {
+		return false
+	}
+	s.wipe()
+	delete(ss.sessions, tokenHashed)
+	return true
+}
+
+//revoke all the sessions of an account
+func (ss *sessionStore) revokeAccount(urlUsername string) {
+
+	ss.mtx.Lock()
+	defer ss.mtx.Unlock()
+
+	for tokenHashed, s := range ss.sessions {
+		if !s.endToEnd && string(s.username.Bytes()) == urlUsername {
+			s.wipe()
+			delete(ss.sessions, tokenHashed)
+		}
+	}
+}
+
+//wipe all expired sessions
+func (ss *sessionStore) expire() {
+
+	ss.mtx.Lock()
+	defer ss.mtx.Unlock()
+
+	now := time.Now()
+	for tokenHashed, s := range ss.sessions {
+		if s.expired(now, ss.idleTimeout, ss.maxLifetime) {
+			s.wipe()
+			delete(ss.sessions, tokenHashed)
+		}
+	}
+}
+
+//periodically wipe expired sessions so that credentials are not held past expiry
+//  for sessions which are never used again, until done is closed
+func (ss *sessionStore) sweep(interval time.Duration, done <-chan struct{}) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ss.expire()
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,127 @@
+//TLS and mutual-TLS for the UI listener
+package ui
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"time"
+)
+
+//validity of generated self-signed certificates
+const selfSignedValidity time.Duration = 365 * 24 * time.Hour
+
+//generate a self-signed certificate and key for local use, the certificate is valid for
+//  each of the hosts (names or IP addresses) and may be trusted by clients as its own
+//  certificate authority
+func GenerateSelfSignedCert(certFile, keyFile string, hosts []string) error {
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{Organization: []string{"passwerk"}, CommonName: "passwerk"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if len(host) > 0 {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0644)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+}
+
+//load the TLS configuration of the UI listener, when a client certificate authority is
+//  provided clients must present a certificate signed by it (mutual-TLS)
+func LoadTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if len(clientCAFile) > 0 {
+		tlsConfig.ClientCAs, err = LoadCertPool(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
+
+//load a pool of PEM encoded certificates
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+
+	caPEM, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("no certificates found within " + caFile)
+	}
+	return pool, nil
+}
+
+//redirect plain HTTP requests to the HTTPS port of the UI listener. Note that the
+//  redirected request has already been sent in the clear
+func redirectHandler(portUI string) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+
+		target := "https://" + net.JoinHostPort(host, portUI) + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
@@ -2,44 +2,192 @@
 package ui
 
 import (
+	"crypto/tls"
 	"encoding/hex"
 	"errors"
-	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
 	"path"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/rigelrozanski/passwerk/audit"
 	cry "github.com/rigelrozanski/passwerk/crypto"
 	tre "github.com/rigelrozanski/passwerk/tree"
+	"golang.org/x/crypto/ed25519"
 )
 
 type UIApp struct {
-	ptr     tre.PwkTreeReader
-	portUI  string
-	testing bool // true during testing
+	ptr      tre.PwkTreeReader
+	portUI   string
+	breached audit.BreachedList // breached password hashes used for health reports
+	sessions *sessionStore      // sessions of logged in accounts
+	limiter  *authLimiter       // brute-force protection of authentication
+	auditLog *AuditLog          // server-side log of authentication failures
+	uniform  bool               // true if authentication failures are reported uniformly
+	suite    cry.CipherSuite    // cipher suite of new accounts and vaults
+	migrate  bool               // true if accounts are migrated to the cipher suite when logging in
+	e2e      bool               // true if only end-to-end requests are accepted
+	testing  bool               // true during testing
+	clock    func() time.Time   // time of totp verification, the current time if unset
+
+	stepsMtx  sync.Mutex
+	usedSteps map[string]int64 // last accepted totp time step of each account, ahead of the chain
+
+	broadcaster func(tx string) string // broadcasts txs in place of the local tendermint-core node if set
+
+	done      chan struct{} // closed when the app is closed, stopping its periodic sweeps
+	closeOnce sync.Once
+}
+
+//a request served by the app, each request holds its own copy of the tree reader as the
+//  reader variables are set by the request. Methods of the app reading the tree are served
+//  per request so that concurrent requests never share the variables of the reader
+type uiRequest struct {
+	*UIApp
+	ptr tre.PwkTreeReader
+}
+
+func (app *UIApp) newRequest() *uiRequest {
+	return &uiRequest{UIApp: app, ptr: app.ptr}
 }
 
+//listen on the bind address (all interfaces if empty), over HTTPS when a TLS configuration
+//  is provided. Plain HTTP requests on the redirect port are then redirected to HTTPS
 func HTTPListener(
 	ptr tre.PwkTreeReader,
+	bindAddr,
 	portUI string,
+	tlsConfig *tls.Config,
+	redirectPort string,
+	breached audit.BreachedList,
+	sessionIdleTimeout,
+	sessionMaxLifetime time.Duration,
+	authLimits AuthLimits,
+	auditLog *AuditLog,
+	uniformErrors bool,
+	cipherSuite cry.CipherSuite,
+	migrateSuites bool,
+	endToEnd bool,
 	testing bool) {
 
+	app := newUIApp(ptr, portUI, breached, sessionIdleTimeout, sessionMaxLifetime, authLimits, auditLog,
+		uniformErrors, cipherSuite, migrateSuites, endToEnd, testing, nil)
+	defer app.close()
+	mux := app.handler()
+
+	if tlsConfig == nil {
+		http.ListenAndServe(net.JoinHostPort(bindAddr, app.portUI), mux)
+		return
+	}
+
+	if len(redirectPort) > 0 {
+		go http.ListenAndServe(net.JoinHostPort(bindAddr, redirectPort), redirectHandler(app.portUI))
+	}
+
+	server := &http.Server{
+		Addr:      net.JoinHostPort(bindAddr, app.portUI),
+		Handler:   mux,
+		TLSConfig: tlsConfig,
+	}
+	server.ListenAndServeTLS("", "")
+}
+
+//the handler of the UI as served by HTTPListener, closing the handler stops the periodic
+//  sweeps of its sessions and rate limits
+type Handler struct {
+	http.Handler
+	app *UIApp
+}
+
+func (h *Handler) Close() {
+	h.app.close()
+}
+
+//the handler of the UI for serving passwerk in-process, which must be closed once no longer
+//  served. Txs are passed to the broadcaster, which returns the response of the broadcast
+func NewHandler(
+	ptr tre.PwkTreeReader,
+	breached audit.BreachedList,
+	sessionIdleTimeout,
+	sessionMaxLifetime time.Duration,
+	authLimits AuthLimits,
+	auditLog *AuditLog,
+	uniformErrors bool,
+	cipherSuite cry.CipherSuite,
+	migrateSuites bool,
+	endToEnd bool,
+	broadcaster func(tx string) string) *Handler {
+
+	app := newUIApp(ptr, "", breached, sessionIdleTimeout, sessionMaxLifetime, authLimits, auditLog,
+		uniformErrors, cipherSuite, migrateSuites, endToEnd, false, broadcaster)
+	return &Handler{app.handler(), app}
+}
+
+func newUIApp(
+	ptr tre.PwkTreeReader,
+	portUI string,
+	breached audit.BreachedList,
+	sessionIdleTimeout,
+	sessionMaxLifetime time.Duration,
+	authLimits AuthLimits,
+	auditLog *AuditLog,
+	uniformErrors bool,
+	cipherSuite cry.CipherSuite,
+	migrateSuites bool,
+	endToEnd bool,
+	testing bool,
+	broadcaster func(tx string) string) *UIApp {
+
 	app := &UIApp{
-		ptr:     ptr,
-		portUI:  portUI,
-		testing: testing,
+		ptr:         ptr,
+		portUI:      portUI,
+		breached:    breached,
+		sessions:    newSessionStore(sessionIdleTimeout, sessionMaxLifetime),
+		limiter:     newAuthLimiter(authLimits),
+		auditLog:    auditLog,
+		uniform:     uniformErrors,
+		suite:       cipherSuite,
+		migrate:     migrateSuites,
+		e2e:         endToEnd,
+		testing:     testing,
+		broadcaster: broadcaster,
+		done:        make(chan struct{}),
 	}
+	go app.sessions.sweep(time.Minute, app.done)
+	go app.limiter.sweep(time.Minute, app.done)
+	return app
+}
 
-	http.HandleFunc("/", app.UIInputHandler)
-	http.ListenAndServe(":"+app.portUI, nil)
+func (app *UIApp) close() {
+	app.closeOnce.Do(func() {
+		close(app.done)
+	})
+}
+
+//end-to-end requests are only served in end-to-end mode, as otherwise the account would
+//  be read without the second factor or session required by the UI
+func (app *UIApp) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", app.UIInputHandler)
+	if app.e2e {
+		mux.HandleFunc("/"+EndToEndPath+"/", app.EndToEndHandler)
+	}
+	return mux
 }
 
 //This method performs a broadcast_tx_commit call to tendermint
 //<incomplete code> rather than returning the raw html, data should be parsed and return the code, data, and log
 func (app *UIApp) broadcastTxFromString(tx string) (htmlString string) {
 
+	if app.broadcaster != nil {
+		return app.broadcaster(tx)
+	}
+
 	urlStringBytes := []byte(tx)
 	urlHexString := hex.EncodeToString(urlStringBytes[:])
 
@@ -57,36 +205,141 @@ func (app *UIApp) broadcastTxFromString(tx string) (htmlString string) {
 //function handles http requests from the passwerk local host (not tendermint local host)
 func (app *UIApp) UIInputHandler(w http.ResponseWriter, r *http.Request) {
 	urlString := r.URL.Path[1:]
+	if len(r.URL.RawQuery) > 0 {
+		urlString = urlString + "?" + r.URL.RawQuery
+	}
 
-	var dummyStringPtr [2]*string
+	var dummyStringPtr [3]*string
 
-	UIoutput := getUIoutput(app.performOperation(urlString, dummyStringPtr))
-	fmt.Fprintf(w, UIoutput)
+	page := getUIoutput(app.newRequest().performOperation(urlString, getClientIP(r), dummyStringPtr))
+	w.Write(page.Bytes())
+	page.Wipe()
 
 	return
 }
 
-func (app *UIApp) performOperation(urlString string, txBroadcastStr [2]*string) (
+//the client IP is taken from the connection, forwarding headers are not trusted
+func getClientIP(r *http.Request) string {
+	clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		clientIP = r.RemoteAddr
+	}
+	return clientIP
+}
+
+func (app *uiRequest) performOperation(urlString, clientIP string, txBroadcastStr [3]*string) (
 	urlUsername, //		2nd URL section - <manditory> master username to be read or written from
 	urlPassword, //		3rd URL section - <manditory> master password to be read or written with
 	urlCIdName, //		4th URL section - <optional> cipherable indicator name for the password
 	speachBubble, //	speach bubble text for the ASCII assailant
 	idNameList string, //	list of all the stored records which will be output if requested by the user (readingIdNames)
+	secrets secretOutput, //	decrypted record output, held as secrets until written
 	err error) {
 
 	//definitions
 	var urlOptionText string       //1st URL section - <manditory>  indicates the user write mode
-	var urlCPassword string        //5th URL section - <optional> cipherable password to be stored
+	var urlCPassword string        //5th URL section - <optional> cipherable password to be stored, record field to be read, or password policy
 	notSelected := "<notSelected>" //text indicating that a piece of URL input has not been submitted
+	started := time.Now()          //failed authentication responses are padded from the start of the operation
+
+	//authentication failures are recorded to the audit log with their detailed reason, and
+	//  may be reported uniformly so that neither the response nor its timing reveals
+	//  whether a username, password, second factor, or identifier is valid
+	var operationalOption, auditUsernameHashed string
+	defer func() {
+		if err == nil || !authFailures[err.Error()] {
+			return
+		}
+		app.auditLog.authFailure(clientIP, auditUsernameHashed, operationalOption, err.Error())
+		if app.uniform {
+			err = errors.New("badAuthentication")
+			speachBubble, idNameList = "", ""
+			secrets.wipe()
+			secrets = secretOutput{}
+			app.limiter.padFailure(started)
+		}
+	}()
+
+	//in end-to-end mode the master username/password may never be provided to the server
+	if app.e2e {
+		err = errors.New("endToEndOnly")
+		return
+	}
+
+	//seperate any additional record fields provided as URL query parameters
+	var urlFields url.Values
+	if i := strings.Index(urlString, "?"); i >= 0 {
+		urlFields, err = url.ParseQuery(urlString[i+1:])
+		if err != nil {
+			err = errors.New("generalError")
+			return
+		}
+		urlString = urlString[:i]
+	}
+
+	//the second factor of accounts enrolled in two-factor authentication is
+	//  provided as a URL query parameter rather than as a record field
+	twoFactorCode := urlFields.Get(twoFactorParam)
+	urlFields.Del(twoFactorParam)
+
+	temp := strings.Split(urlString, `/`)
+
+	//requests of a logged in session provide the session token as a URL query parameter
+	//  in place of the master username/password URL sections
+	sessionToken := urlFields.Get(sessionParam)
+	urlFields.Del(sessionParam)
+	viaSession := len(sessionToken) > 0
+	var keys accountKeys
+	defer func() {
+		keys.wipe()
+	}()
+	if viaSession {
+		err = app.limiter.allow("", clientIP)
+		if err != nil {
+			return
+		}
+
+		var sessionUsername string
+		sessionUsername, keys, err = app.sessions.get(sessionToken)
+		if err != nil {
+			app.limiter.fail("", clientIP)
+			app.limiter.padFailure(started)
+			return
+		}
+
+		//sessions hold the keys derived from the master password rather than the master
+		//  password itself, a placeholder takes the place of the master password URL section
+		temp = append([]string{temp[0], sessionUsername, sessionPassword}, temp[1:]...)
+	}
 
 	//if there are less than three variables provided make a fuss
-	if len(strings.Split(urlString, `/`)) < 3 {
+	if len(temp) < 3 {
 		err = errors.New("not enough URL arguments")
 		return
 	}
 
 	var urlStringSplit [5]string
-	temp := strings.Split(urlString, `/`)
+
+	//shared vault operations are prefixed with "v" and hold the vault name in the
+	//  4th URL section, the remaining sections are as per personal operations.
+	//  Organization operations are likewise prefixed with "o" and hold the organization name
+	var urlVaultName, urlOrgName string
+	inVault := len(temp[0]) > 1 && strings.HasPrefix(temp[0], "v")
+	inOrg := len(temp[0]) > 1 && strings.HasPrefix(temp[0], "o")
+	if inVault || inOrg {
+		if len(temp) < 4 || len(temp[3]) < 1 {
+			err = errors.New("generalError")
+			return
+		}
+		if inVault {
+			urlVaultName = temp[3]
+		} else {
+			urlOrgName = temp[3]
+		}
+		temp = append(temp[:3], temp[4:]...)
+		temp[0] = temp[0][1:]
+	}
+
 	copy(urlStringSplit[:], temp)
 
 	//initilize any elements that were not a part of the split
@@ -104,18 +357,73 @@ func (app *UIApp) performOperation(urlString string, txBroadcastStr [2]*string)
 
 	usernameHashed := cry.GetHashedHexString(urlUsername)
 	cIdNameHashed := cry.GetHashedHexString(urlCIdName)
+	auditUsernameHashed = usernameHashed
 
-	//These two strings generated the hashes which are used for encryption and decryption of passwords
-	//TODO create more secure shared key equivalent
-	hashInputCIdNameEncryption := tre.HashInputCIdNameEncryption(urlUsername, urlPassword)
-	hashInputCPasswordEncryption := tre.HashInputCPasswordEncryption(urlUsername, urlPassword, urlCIdName)
+	switch {
+	case inVault:
+		operationalOption, err = getVaultOperationalOption(notSelected, urlOptionText, urlUsername,
+			urlPassword, urlCIdName, urlCPassword)
+	case inOrg:
+		operationalOption, err = getOrgOperationalOption(notSelected, urlOptionText, urlUsername,
+			urlPassword, urlCIdName, urlCPassword)
+	default:
+		operationalOption, err = getOperationalOption(notSelected, urlOptionText, urlUsername,
+			urlPassword, urlCIdName, urlCPassword)
+	}
+	if err != nil {
+		return
+	}
+
+	//the master password is stretched anew when migrating the cipher suite of an account
+	//  and replaced when recovering, neither of which may be performed with a session
+	if viaSession && (operationalOption == "migratingSuite" || operationalOption == "recovering") {
+		err = errors.New("masterPasswordRequired")
+		return
+	}
+
+	//authentication is rate limited by account and client, failed attempts are
+	//  recorded once the operation has completed. Limits are checked before the master
+	//  password is stretched so that locked out accounts cost no key derivation
+	if operationalOption != "registering" {
+		err = app.limiter.allow(usernameHashed, clientIP)
+		if err != nil {
+			return
+		}
+
+		limitedUsernameHashed := usernameHashed
+		defer func() {
+			if err != nil && (err.Error() == "badAuthentication" || err.Error() == "badTwoFactor") {
+				app.limiter.fail(limitedUsernameHashed, clientIP)
+				app.limiter.padFailure(started)
+			}
+		}()
+	}
 
-	var operationalOption string
-	operationalOption, err = getOperationalOption(notSelected, urlOptionText, urlUsername,
-		urlPassword, urlCIdName, urlCPassword)
+	//the master password is stretched by the cipher suite of the account before generating
+	//  the hashes used for encryption and decryption of records, the 3rd URL section of
+	//  recovery holds a recovery code which is not stretched. Sessions hold the stretched
+	//  master password along with the account keys derived from it
+	var suite cry.CipherSuite
+	suite, err = app.cipherSuite(usernameHashed)
 	if err != nil {
 		return
 	}
+	keyPassword := urlPassword
+	switch {
+	case viaSession:
+		keyPassword = keys.keyPassword
+	case operationalOption != "recovering":
+		keyPassword = suite.KDF(urlPassword, usernameHashed)
+		keys = getAccountKeys(urlUsername, keyPassword)
+	}
+
+	//These two strings generated the hashes which are used for encryption and decryption of passwords
+	//TODO create more secure shared key equivalent
+	hashInputCIdNameEncryption := tre.HashInputCIdNameEncryption(urlUsername, keyPassword)
+	hashInputCPasswordEncryption := tre.HashInputCPasswordEncryption(urlUsername, keyPassword, urlCIdName)
+	hashInputCPasswordEncryptionOf := func(cIdName string) string {
+		return tre.HashInputCPasswordEncryption(urlUsername, keyPassword, cIdName)
+	}
 
 	app.ptr.SetVariables(
 		usernameHashed,
@@ -124,18 +432,277 @@ func (app *UIApp) performOperation(urlString string, txBroadcastStr [2]*string)
 		hashInputCPasswordEncryption,
 	)
 
-	//performing authentication (don't need to authenicate for writing passwords)
-	if operationalOption != "writing" &&
-		!app.ptr.AuthMasterPassword() {
+	//ciphertexts sealed before associated data was supported are only read to be
+	//  resealed by a migration, including the migration of an account as it logs in
+	readingLegacy := operationalOption == "migratingSuite" ||
+		operationalOption == "migratingVaultSuite" ||
+		(operationalOption == "loggingIn" && app.migrate && !viaSession && suite.ID() != app.suite.ID())
+
+	//performing authentication (only new accounts, and accounts being recovered
+	//  with a recovery code in place of the master password don't need to be authenticated)
+	if operationalOption != "registering" && operationalOption != "recovering" &&
+		!app.ptr.AuthMasterPassword(readingLegacy) {
 		err = errors.New("badAuthentication")
 		return
 	}
 
+	//accounts enrolled in two-factor authentication must also provide a current
+	//  totp code, or an unused backup code, before any record may be retrieved.
+	//  The second factor of sessions is authenticated when logging in. The backup code
+	//  consumed when disabling two-factor authentication may not also authenticate the request
+	if operationalOption != "registering" && operationalOption != "recovering" {
+		if operationalOption == "disablingTwoFactor" && twoFactorCode == urlCIdName {
+			err = errors.New("badTwoFactor")
+			return
+		}
+		if !viaSession {
+			err = app.authTwoFactor(usernameHashed, urlUsername, twoFactorCode, keys, txBroadcastStr)
+			if err != nil {
+				return
+			}
+		}
+		app.limiter.succeed(usernameHashed)
+	}
+
+	switch operationalOption {
+	case "generatingTwoFactor", "enablingTwoFactor", "disablingTwoFactor":
+		speachBubble, idNameList, err = app.performTwoFactorManagement(operationalOption, suite,
+			usernameHashed, urlUsername, urlCIdName, urlCPassword, keys, txBroadcastStr)
+		return
+
+	case "loggingIn":
+		//accounts are migrated to the configured cipher suite as they log in with the master
+		//  password, a failed migration is attempted again at the next log in
+		if app.migrate && !viaSession && suite.ID() != app.suite.ID() {
+			tx2broadcast, migrateErr := app.getAccountMigrationTx(suite, app.suite, usernameHashed,
+				urlUsername, urlPassword, keys)
+			if migrateErr == nil {
+				if app.testing {
+					*txBroadcastStr[0] = tx2broadcast
+				} else {
+					app.broadcastTxFromString(tx2broadcast)
+				}
+			}
+		}
+
+		var token string
+		token, err = app.sessions.create(urlUsername, keys)
+		if err != nil {
+			return
+		}
+		speachBubble = "ur logged in, use this session token in place of ur master username/password"
+		idNameList = "\nsession token: " + token
+		return
+
+	//logging out of a session revokes only that session, logging out with the
+	//  master username/password revokes all the sessions of the account
+	case "loggingOut":
+		if viaSession {
+			app.sessions.revoke(sessionToken)
+		} else {
+			app.sessions.revokeAccount(urlUsername)
+		}
+		speachBubble = "later"
+		return
+	}
+
+	//txs acting on the account, shared vaults, and organizations are signed by the acting user
+	signer := txSigner{
+		usernameHashed: usernameHashed,
+		signingKey:     keys.signingKey,
+	}
+
+	if inOrg {
+		orgHashed := cry.GetHashedHexString(tre.HashInputOrgName(urlOrgName))
+		speachBubble, err = app.performOrgManagement(operationalOption, orgHashed,
+			usernameHashed, urlCIdName, urlCPassword, signer, txBroadcastStr)
+		return
+	}
+
+	//vault management is performed seperately, while vault record operations are
+	//  performed as per personal records using the vault subtree and vault key
+	if inVault {
+		vaultHashed := cry.GetHashedHexString(tre.HashInputVaultName(urlVaultName))
+
+		switch operationalOption {
+		case "creatingVault", "invitingMember", "acceptingInvite", "revokingMember":
+			speachBubble, err = app.performVaultManagement(operationalOption, vaultHashed,
+				usernameHashed, urlCIdName, keys, signer, txBroadcastStr)
+			return
+		case "designatingContact", "requestingAccess", "cancelingAccess", "claimingAccess":
+			speachBubble, err = app.performEmergencyAccess(operationalOption, vaultHashed,
+				usernameHashed, urlCIdName, urlCPassword, keys, signer, txBroadcastStr)
+			return
+		case "splittingKey", "requestingReconstruction", "approvingReconstruction", "cancelingReconstruction",
+			"releasingShare", "reconstructing":
+			speachBubble, err = app.performKeySharing(operationalOption, vaultHashed,
+				usernameHashed, urlCIdName, urlCPassword, keys, signer, txBroadcastStr)
+			return
+		}
+
+		var vaultKey, status string
+		vaultKey, status, err = app.unwrapVaultKey(vaultHashed, usernameHashed, keys)
+		if err != nil {
+			return
+		}
+
+		//the records of organization collections are limited by the member's role
+		err = app.verifyCollectionRole(operationalOption, vaultHashed, usernameHashed, status)
+		if err != nil {
+			return
+		}
+
+		//only those who manage the vault may migrate its cipher suite
+		if operationalOption == "migratingVaultSuite" && !app.canManageVault(vaultHashed, usernameHashed, status) {
+			err = errors.New("notVaultOwner")
+			return
+		}
+
+		suite, err = app.cipherSuite(vaultHashed)
+		if err != nil {
+			return
+		}
+
+		usernameHashed = vaultHashed
+		hashInputCIdNameEncryption = tre.HashInputVaultCIdNameEncryption(vaultKey)
+		hashInputCPasswordEncryption = tre.HashInputVaultCPasswordEncryption(vaultKey, urlCIdName)
+		hashInputCPasswordEncryptionOf = func(cIdName string) string {
+			return tre.HashInputVaultCPasswordEncryption(vaultKey, cIdName)
+		}
+
+		app.ptr.SetVariables(
+			usernameHashed,
+			urlCIdName,
+			hashInputCIdNameEncryption,
+			hashInputCPasswordEncryption,
+		)
+	}
+
 	// performing operation
 	switch operationalOption {
+	case "registering":
+		if app.ptr.AccountExists() {
+			err = errors.New("accountExists")
+			return
+		}
+		if app.ptr.AccountRetired() {
+			err = errors.New("accountRetired")
+			return
+		}
+
+		//the 4th URL section optionally holds the number of recovery codes to generate
+		var recoveryCodes []string
+		var codes []tre.RecoveryCode
+		if urlCIdName != notSelected {
+			n, convErr := strconv.Atoi(urlCIdName)
+			if convErr != nil || n < 1 || n > maxRecoveryCodes {
+				err = errors.New("generalError")
+				return
+			}
+
+			recoveryCodes, codes, err = getRecoveryCodes(suite, usernameHashed, urlUsername, keyPassword, n)
+			if err != nil {
+				return
+			}
+		}
+
+		//publish the public key used to share vaults with the new account,
+		//  and the signing key used to authenticate vault and organization txs
+		var verifierEncrypted string
+		verifierEncrypted, err = cry.GetEncryptedBoundHexString(suite, hashInputCIdNameEncryption, tre.VerifierCanary,
+			tre.AssociatedDataVerifier(usernameHashed))
+		if err != nil {
+			return
+		}
+
+		//create the tx then broadcast
+		tx2broadcast := path.Join(
+			now(),
+			operationalOption,
+			usernameHashed,
+			verifierEncrypted,
+			cry.GetPublicKeyHexString(keys.boxPublicKey),
+			keys.signingPublicKeyHexString())
+		if len(codes) > 0 || suite.ID() != cry.LegacyCipherSuite.ID() {
+			tx2broadcast = path.Join(tx2broadcast, tre.EncodeRecoveryCodes(codes))
+		}
+
+		//accounts without a cipher suite use the legacy suite
+		if suite.ID() != cry.LegacyCipherSuite.ID() {
+			tx2broadcast = path.Join(tx2broadcast, suite.ID())
+		}
+
+		if app.testing {
+			*txBroadcastStr[0] = tx2broadcast
+		} else {
+			app.broadcastTxFromString(tx2broadcast)
+		}
+
+		speachBubble = "welcome to the club"
+
+		for _, recoveryCode := range recoveryCodes {
+			idNameList = idNameList + "\nrecovery code: " + recoveryCode
+		}
+
+	case "recovering":
+		//the 3rd URL section holds the recovery code, and the 4th the new master password
+		speachBubble, idNameList, err = app.performRecovery(suite, usernameHashed, urlUsername,
+			urlPassword, urlCIdName, twoFactorCode, txBroadcastStr)
+
+	case "migratingSuite", "migratingVaultSuite":
+		//the 4th URL section holds the identifier of the cipher suite to migrate to
+		var target cry.CipherSuite
+		target, err = cry.GetCipherSuite(urlCIdName)
+		if err != nil {
+			return
+		}
+		if target.ID() == suite.ID() {
+			speachBubble = "already using " + target.ID()
+			return
+		}
+
+		var tx2broadcast string
+		if operationalOption == "migratingSuite" {
+			tx2broadcast, err = app.getAccountMigrationTx(suite, target, usernameHashed,
+				urlUsername, urlPassword, keys)
+		} else {
+			tx2broadcast, err = app.getVaultMigrationTx(target, usernameHashed,
+				hashInputCIdNameEncryption, hashInputCPasswordEncryptionOf, signer)
+		}
+		if err != nil {
+			return
+		}
+
+		if app.testing {
+			*txBroadcastStr[0] = tx2broadcast
+		} else {
+			app.broadcastTxFromString(tx2broadcast)
+		}
+
+		speachBubble = "moved in to " + target.ID()
+
+	case "deletingAccount":
+		//create the tx, signed by the account holder, then broadcast
+		accountSigner := txSigner{
+			usernameHashed: usernameHashed,
+			signingKey:     keys.signingKey,
+		}
+		tx2broadcast := accountSigner.sign(path.Join(
+			now(),
+			operationalOption,
+			usernameHashed))
+
+		if app.testing {
+			*txBroadcastStr[0] = tx2broadcast
+		} else {
+			app.broadcastTxFromString(tx2broadcast)
+		}
+
+		speachBubble = "*sniff* - it was nice knowing u"
+
 	case "readingIdNames":
 		var idNameListArray []string
-		idNameListArray, err = app.ptr.RetrieveCIdNames()
+		idNameListArray, err = app.ptr.RetrieveCIdNames(false)
 		if err != nil {
 			return
 		}
@@ -147,13 +714,58 @@ func (app *UIApp) performOperation(urlString string, txBroadcastStr [2]*string)
 		}
 
 	case "readingPassword":
-		var cPasswordDecrypted string
-		cPasswordDecrypted, err = app.ptr.RetrieveCPassword()
+		var fields map[string]*cry.Secret
+		fields, err = app.retrieveRecordOutput(suite, usernameHashed, cIdNameHashed,
+			hashInputCPasswordEncryption, signer, txBroadcastStr)
 
 		if err != nil {
 			return
 		}
-		speachBubble = cPasswordDecrypted
+		defer tre.WipeRecord(fields)
+
+		secrets.bubble = cry.NewSecretFromBytes(fields[tre.FieldPassword].Bytes())
+
+		//list any additional fields held by the record
+		secrets.list = cry.NewSecret(0)
+		for _, name := range tre.SortedSecretFieldNames(fields) {
+			if name != tre.FieldPassword {
+				secrets.list.AppendString("\n" + name + ": ")
+				secrets.list.Append(fields[name].Bytes())
+			}
+		}
+
+	case "readingField":
+		var fields map[string]*cry.Secret
+
+		//the raw otp seed is only revealed when explicitly requested and not concealed
+		if urlCPassword == tre.FieldTOTPSeed {
+			fields, err = app.ptr.RetrieveCRecordSecrets(false)
+			if err != nil {
+				return
+			}
+			defer tre.WipeRecord(fields)
+
+			if string(fields[tre.FieldTOTPConcealed].Bytes()) == "true" {
+				err = errors.New("concealedField")
+				return
+			}
+			urlCPassword = tre.FieldTOTP
+		} else {
+			fields, err = app.retrieveRecordOutput(suite, usernameHashed, cIdNameHashed,
+				hashInputCPasswordEncryption, signer, txBroadcastStr)
+
+			if err != nil {
+				return
+			}
+			defer tre.WipeRecord(fields)
+		}
+
+		fieldValue, exists := fields[urlCPassword]
+		if !exists {
+			err = errors.New("invalidField")
+			return
+		}
+		secrets.bubble = cry.NewSecretFromBytes(fieldValue.Bytes())
 
 	case "deleting":
 		//determine encrypted text to delete
@@ -167,12 +779,12 @@ func (app *UIApp) performOperation(urlString string, txBroadcastStr [2]*string)
 		if len(mapCIdNameEncrypted2Delete) > 0 {
 
 			//create he tx then broadcast
-			tx2broadcast := path.Join(
+			tx2broadcast := signer.sign(path.Join(
 				now(),
 				operationalOption,
 				usernameHashed,
 				cIdNameHashed,
-				mapCIdNameEncrypted2Delete)
+				mapCIdNameEncrypted2Delete))
 
 			if app.testing {
 				*txBroadcastStr[0] = tx2broadcast
@@ -187,56 +799,1625 @@ func (app *UIApp) performOperation(urlString string, txBroadcastStr [2]*string)
 		}
 
 	case "writing":
-		//before writing, any duplicate records must first be deleted
-		//do not worry about error handling here for records that do not exist
-		//  it doesn't really matter if there is nothing to delete
-		var mapCIdNameEncrypted2Delete string
-		mapCIdNameEncrypted2Delete, err = app.ptr.GetCIdListEncryptedCIdName()
-		if len(mapCIdNameEncrypted2Delete) >= 0 && err == nil {
-
-			//create he tx then broadcast
-			tx2broadcast := path.Join(
-				now(),
-				"deleting",
-				usernameHashed,
-				cIdNameHashed,
-				mapCIdNameEncrypted2Delete)
-			if app.testing {
-				*txBroadcastStr[0] = tx2broadcast
-			} else {
-				app.broadcastTxFromString(tx2broadcast)
-			}
+		var cIdNameEncrypted, cRecordEncrypted string
+		cIdNameEncrypted, cRecordEncrypted, err = getEncryptedEntry(suite, hashInputCIdNameEncryption,
+			hashInputCPasswordEncryption, usernameHashed, cIdNameHashed, urlCIdName,
+			getRecordFields(urlCPassword, urlFields))
+		if err != nil {
+			return
 		}
 
-		//reset the error term because it doesn't matter if the record was non-existent
-		err = nil
-
-		//now write the records
-		//create he tx then broadcast
-		tx2broadcast := path.Join(
-			now(),
-			operationalOption,
+		app.broadcastRecord(
 			usernameHashed,
 			cIdNameHashed,
-			cry.GetEncryptedHexString(hashInputCIdNameEncryption, urlCIdName),
-			cry.GetEncryptedHexString(hashInputCPasswordEncryption, urlCPassword))
-		if app.testing {
-			*txBroadcastStr[1] = tx2broadcast
-		} else {
-			app.broadcastTxFromString(tx2broadcast)
-		}
+			cIdNameEncrypted,
+			cRecordEncrypted,
+			signer,
+			txBroadcastStr)
 
 		speachBubble = "Roger That"
-	}
 
-	//Writing output
-	return
-}
+	case "healthReport":
+		//the 4th URL section optionally holds the maximum record age in days
+		maxAgeDays := 0
+		if urlCIdName != notSelected {
+			maxAgeDays, err = strconv.Atoi(urlCIdName)
+			if err != nil {
+				err = errors.New("generalError")
+				return
+			}
+		}
 
-func getOperationalOption(notSelected,
-	urlOptionText,
-	urlUsername,
-	urlPassword,
+		var report audit.Report
+		report, err = audit.GenerateHealthReport(&app.ptr, urlUsername, keyPassword,
+			maxAgeDays, app.breached, time.Now())
+		if err != nil {
+			return
+		}
+
+		speachBubble = "lookin healthy"
+		if report.Issues > 0 {
+			speachBubble = strconv.Itoa(report.Issues) + " issues found"
+		}
+
+		for _, recordReport := range report.Records {
+			idNameList = idNameList + "\n" + getRecordReportOutput(recordReport)
+		}
+
+	case "writingGenerated":
+		//the 5th URL section optionally holds the password policy
+		policySpec := ""
+		if urlCPassword != notSelected {
+			policySpec = urlCPassword
+		}
+
+		var policy cry.PasswordPolicy
+		policy, err = cry.ParsePasswordPolicy(policySpec)
+		if err != nil {
+			err = errors.New("invalidPolicy")
+			return
+		}
+
+		var cPasswordGenerated string
+		cPasswordGenerated, err = cry.GeneratePassword(policy)
+		if err != nil {
+			err = errors.New("invalidPolicy")
+			return
+		}
+
+		var cIdNameEncrypted, cRecordEncrypted string
+		cIdNameEncrypted, cRecordEncrypted, err = getEncryptedEntry(suite, hashInputCIdNameEncryption,
+			hashInputCPasswordEncryption, usernameHashed, cIdNameHashed, urlCIdName,
+			getRecordFields(cPasswordGenerated, urlFields))
+		if err != nil {
+			return
+		}
+
+		app.broadcastRecord(
+			usernameHashed,
+			cIdNameHashed,
+			cIdNameEncrypted,
+			cRecordEncrypted,
+			signer,
+			txBroadcastStr)
+
+		speachBubble = cPasswordGenerated
+	}
+
+	//Writing output
+	return
+}
+
+//maximum number of recovery codes generated at registration, and the number generated
+//  to replace the codes of a recovered account
+const maxRecoveryCodes int = 16
+const recoveryCodeCount int = 8
+
+//generate recovery codes each wrapping the recovery key, the master password as stretched
+//  by the suite of the account, rather than the master password itself
+func getRecoveryCodes(suite cry.CipherSuite, usernameHashed, urlUsername, keyPassword string, n int) (
+	recoveryCodes []string, codes []tre.RecoveryCode, err error) {
+
+	recoveryCodes, err = cry.GenerateRecoveryCodes(n)
+	if err != nil {
+		return
+	}
+
+	for _, recoveryCode := range recoveryCodes {
+		codeHashed := cry.GetHashedHexString(tre.HashInputRecoveryCodeID(urlUsername, recoveryCode))
+
+		var recoveryKeyEncrypted string
+		recoveryKeyEncrypted, err = cry.GetEncryptedBoundHexString(suite, tre.HashInputRecoveryCode(urlUsername, recoveryCode),
+			keyPassword, tre.AssociatedDataRecoveryCode(usernameHashed, codeHashed))
+		if err != nil {
+			return
+		}
+
+		codes = append(codes, tre.RecoveryCode{
+			CodeHashed:           codeHashed,
+			RecoveryKeyEncrypted: recoveryKeyEncrypted,
+		})
+	}
+	return
+}
+
+//unwrap the recovery key of a recovery code, codes generated before recovery keys were
+//  wrapped hold the master password itself which is stretched by the suite of the account
+func readRecoveryKey(suite cry.CipherSuite, usernameHashed, urlUsername, recoveryCode, codeHashed,
+	recoveryKeyEncrypted string) (keyPassword string, err error) {
+
+	hashInputRecoveryCode := tre.HashInputRecoveryCode(urlUsername, recoveryCode)
+	keyPassword, err = cry.ReadDecryptedBound(hashInputRecoveryCode, recoveryKeyEncrypted,
+		tre.AssociatedDataRecoveryCode(usernameHashed, codeHashed))
+	if err == nil {
+		return
+	}
+
+	urlPassword, err := cry.ReadDecrypted(hashInputRecoveryCode, recoveryKeyEncrypted)
+	if err != nil {
+		return
+	}
+	return suite.KDF(urlPassword, usernameHashed), nil
+}
+
+//recover an account with a recovery code by unwrapping the recovery key, then re-encrypting
+//  all the account records and two-factor secret under the new master password and re-wrapping
+//  the keys wrapped to the account. Accounts enrolled in two-factor authentication must also
+//  provide a current totp code, or an unused backup code. All recovery codes are replaced, the
+//  new codes are output along with the speach bubble
+func (app *uiRequest) performRecovery(
+	suite cry.CipherSuite,
+	usernameHashed,
+	urlUsername,
+	urlRecoveryCode,
+	urlNewPassword,
+	twoFactorCode string,
+	txBroadcastStr [3]*string) (speachBubble, idNameList string, err error) {
+
+	badAuthErr := errors.New("badAuthentication")
+
+	codeHashed := cry.GetHashedHexString(tre.HashInputRecoveryCodeID(urlUsername, urlRecoveryCode))
+	app.ptr.SetVariables(usernameHashed, "", "", "")
+
+	recoveryKeyEncrypted, err := app.ptr.RetrieveRecoveryCode(codeHashed)
+	if err != nil {
+		err = badAuthErr
+		return
+	}
+	oldKeyPassword, err := readRecoveryKey(suite, usernameHashed, urlUsername, urlRecoveryCode,
+		codeHashed, recoveryKeyEncrypted)
+	if err != nil {
+		err = badAuthErr
+		return
+	}
+
+	//the records remain sealed with the cipher suite of the account
+	newKeyPassword := suite.KDF(urlNewPassword, usernameHashed)
+	oldKeys := getAccountKeys(urlUsername, oldKeyPassword)
+	defer oldKeys.wipe()
+	newKeys := getAccountKeys(urlUsername, newKeyPassword)
+	defer newKeys.wipe()
+
+	hashInputCIdNameEncryption := tre.HashInputCIdNameEncryption(urlUsername, oldKeyPassword)
+	app.ptr.SetVariables(usernameHashed, "", hashInputCIdNameEncryption, "")
+	if !app.ptr.AuthMasterPassword(false) {
+		err = badAuthErr
+		return
+	}
+	err = app.authTwoFactor(usernameHashed, urlUsername, twoFactorCode, oldKeys, txBroadcastStr)
+	if err != nil {
+		return
+	}
+
+	twoFactorSecretEncrypted := "-"
+	if secretEncrypted, retrieveErr := app.ptr.RetrieveTwoFactorSecret(); retrieveErr == nil {
+		var secret *cry.Secret
+		secret, err = cry.DecryptSecret(tre.HashInputTwoFactorEncryption(tre.TwoFactorKey(urlUsername, oldKeyPassword)),
+			secretEncrypted, "")
+		if err != nil {
+			return
+		}
+		twoFactorSecretEncrypted, err = cry.EncryptSecret(suite,
+			tre.HashInputTwoFactorEncryption(tre.TwoFactorKey(urlUsername, newKeyPassword)), secret.Bytes(), "")
+		secret.Wipe()
+		if err != nil {
+			return
+		}
+	}
+
+	//re-encrypt each record under the new master password
+	newHashInputCIdNameEncryption := tre.HashInputCIdNameEncryption(urlUsername, newKeyPassword)
+	records, err := app.rekeyRecords(usernameHashed,
+		hashInputCIdNameEncryption,
+		func(cIdName string) string {
+			return tre.HashInputCPasswordEncryption(urlUsername, oldKeyPassword, cIdName)
+		},
+		suite,
+		newHashInputCIdNameEncryption,
+		func(cIdName string) string {
+			return tre.HashInputCPasswordEncryption(urlUsername, newKeyPassword, cIdName)
+		},
+		false)
+	if err != nil {
+		return
+	}
+
+	recoveryCodes, codes, err := getRecoveryCodes(suite, usernameHashed, urlUsername, newKeyPassword, recoveryCodeCount)
+	if err != nil {
+		return
+	}
+
+	wrappedKeys, err := rewrapAccountKeys(app.ptr.RetrieveWrappedKeys(usernameHashed), oldKeys, newKeys)
+	if err != nil {
+		return
+	}
+
+	verifierEncrypted, err := cry.GetEncryptedBoundHexString(suite, newHashInputCIdNameEncryption,
+		tre.VerifierCanary, tre.AssociatedDataVerifier(usernameHashed))
+	if err != nil {
+		return
+	}
+
+	//the tx is signed with the signing key of the previous master password
+	signer := txSigner{
+		usernameHashed: usernameHashed,
+		signingKey:     oldKeys.signingKey,
+	}
+	tx2broadcast := signer.sign(path.Join(
+		now(),
+		"recovering",
+		usernameHashed,
+		codeHashed,
+		verifierEncrypted,
+		cry.GetPublicKeyHexString(newKeys.boxPublicKey),
+		newKeys.signingPublicKeyHexString(),
+		tre.EncodeRecoveryCodes(codes),
+		tre.EncodeRekeyedRecords(records),
+		tre.EncodeWrappedKeys(wrappedKeys),
+		twoFactorSecretEncrypted))
+
+	if app.testing {
+		*txBroadcastStr[0] = tx2broadcast
+	} else {
+		app.broadcastTxFromString(tx2broadcast)
+	}
+
+	speachBubble = "ur back in, keep these new codes safe"
+	for _, recoveryCode := range recoveryCodes {
+		idNameList = idNameList + "\nrecovery code: " + recoveryCode
+	}
+	return
+}
+
+//re-encrypt each record of an account or vault along with its history, the records are read
+//  with the current hash inputs and sealed with the suite under the new hash inputs. The record
+//  hash inputs are provided per identifier. Ciphertexts sealed before associated data was
+//  supported are only read (readingLegacy) to reseal them in a cipher suite migration
+func (app *uiRequest) rekeyRecords(
+	usernameHashed,
+	hashInputCIdNameEncryption string,
+	hashInputCPasswordEncryption func(cIdName string) string,
+	suite cry.CipherSuite,
+	newHashInputCIdNameEncryption string,
+	newHashInputCPasswordEncryption func(cIdName string) string,
+	readingLegacy bool) (records []tre.RekeyedRecord, err error) {
+
+	app.ptr.SetVariables(usernameHashed, "", hashInputCIdNameEncryption, "")
+	cIdNames, err := app.ptr.RetrieveCIdNames(readingLegacy)
+	if err != nil {
+		return
+	}
+
+	for _, cIdName := range cIdNames {
+		if len(cIdName) < 1 {
+			continue
+		}
+
+		app.ptr.SetVariables(usernameHashed, cIdName, hashInputCIdNameEncryption,
+			hashInputCPasswordEncryption(cIdName))
+
+		var fields map[string]*cry.Secret
+		fields, err = app.ptr.RetrieveCRecordSecrets(readingLegacy)
+		if err != nil {
+			return
+		}
+
+		record := tre.RekeyedRecord{CIdNameHashed: cry.GetHashedHexString(cIdName)}
+		record.CIdNameEncrypted, err = cry.GetEncryptedBoundHexString(suite, newHashInputCIdNameEncryption,
+			cIdName, tre.AssociatedDataCIdName(usernameHashed))
+		if err == nil {
+			record.CRecordEncrypted, err = tre.GetEncryptedRecordSecrets(suite, newHashInputCPasswordEncryption(cIdName),
+				usernameHashed, record.CIdNameHashed, fields)
+		}
+		tre.WipeRecord(fields)
+		if err != nil {
+			return
+		}
+
+		var history []map[string]*cry.Secret
+		history, err = app.ptr.RetrieveCRecordHistorySecrets(readingLegacy)
+		if err != nil {
+			return
+		}
+		for _, previous := range history {
+			var cRecordEncrypted string
+			if err == nil {
+				cRecordEncrypted, err = tre.GetEncryptedRecordSecrets(suite, newHashInputCPasswordEncryption(cIdName),
+					usernameHashed, record.CIdNameHashed, previous)
+				record.History = append(record.History, cRecordEncrypted)
+			}
+			tre.WipeRecord(previous)
+		}
+		if err != nil {
+			return
+		}
+		records = append(records, record)
+	}
+	return
+}
+
+//the cipher suite of an account or vault, the configured suite is used for usernames
+//  which don't exist so that their master passwords are stretched equivalently
+func (app *uiRequest) cipherSuite(usernameHashed string) (cry.CipherSuite, error) {
+
+	suite, err := app.ptr.RetrieveCipherSuite(usernameHashed)
+	if err == cry.ErrUnknownCipherSuite {
+		return nil, err
+	}
+	if err != nil {
+		return app.suite, nil
+	}
+	return suite, nil
+}
+
+//create the tx migrating an account to the target cipher suite, the records, verifier, and
+//  two-factor secret are re-encrypted under the master password as stretched by the target
+//  suite. The published keys are derived from the stretched master password, so new keys are
+//  published and every key wrapped to the account is re-wrapped to the new public key
+func (app *uiRequest) getAccountMigrationTx(
+	suite,
+	target cry.CipherSuite,
+	usernameHashed,
+	urlUsername,
+	urlPassword string,
+	keys accountKeys) (tx2broadcast string, err error) {
+
+	keyPassword := keys.keyPassword
+	targetKeyPassword := target.KDF(urlPassword, usernameHashed)
+	targetKeys := getAccountKeys(urlUsername, targetKeyPassword)
+	defer targetKeys.wipe()
+	targetHashInputCIdNameEncryption := tre.HashInputCIdNameEncryption(urlUsername, targetKeyPassword)
+
+	records, err := app.rekeyRecords(usernameHashed,
+		tre.HashInputCIdNameEncryption(urlUsername, keyPassword),
+		func(cIdName string) string {
+			return tre.HashInputCPasswordEncryption(urlUsername, keyPassword, cIdName)
+		},
+		target,
+		targetHashInputCIdNameEncryption,
+		func(cIdName string) string {
+			return tre.HashInputCPasswordEncryption(urlUsername, targetKeyPassword, cIdName)
+		},
+		true)
+	if err != nil {
+		return
+	}
+
+	verifierEncrypted, err := cry.GetEncryptedBoundHexString(target, targetHashInputCIdNameEncryption,
+		tre.VerifierCanary, tre.AssociatedDataVerifier(usernameHashed))
+	if err != nil {
+		return
+	}
+
+	twoFactorSecretEncrypted := "-"
+	if secretEncrypted, retrieveErr := app.ptr.RetrieveTwoFactorSecret(); retrieveErr == nil {
+		var secret *cry.Secret
+		secret, err = cry.DecryptSecret(tre.HashInputTwoFactorEncryption(tre.TwoFactorKey(urlUsername, keyPassword)),
+			secretEncrypted, "")
+		if err != nil {
+			return
+		}
+		twoFactorSecretEncrypted, err = cry.EncryptSecret(target,
+			tre.HashInputTwoFactorEncryption(tre.TwoFactorKey(urlUsername, targetKeyPassword)), secret.Bytes(), "")
+		secret.Wipe()
+		if err != nil {
+			return
+		}
+	}
+
+	wrappedKeys, err := rewrapAccountKeys(app.ptr.RetrieveWrappedKeys(usernameHashed), keys, targetKeys)
+	if err != nil {
+		return
+	}
+
+	//the tx is signed with the signing key being replaced
+	signer := txSigner{
+		usernameHashed: usernameHashed,
+		signingKey:     keys.signingKey,
+	}
+	tx2broadcast = signer.sign(path.Join(
+		now(),
+		"migratingSuite",
+		usernameHashed,
+		target.ID(),
+		verifierEncrypted,
+		twoFactorSecretEncrypted,
+		tre.EncodeRekeyedRecords(records),
+		cry.GetPublicKeyHexString(targetKeys.boxPublicKey),
+		targetKeys.signingPublicKeyHexString(),
+		tre.EncodeWrappedKeys(wrappedKeys)))
+	return
+}
+
+//re-wrap each key wrapped to the box keypair of the current account keys to the box keypair
+//  of the new account keys
+func rewrapAccountKeys(wrappedKeys []tre.WrappedKey, keys, newKeys accountKeys) (
+	[]tre.WrappedKey, error) {
+
+	for i := range wrappedKeys {
+		rewrappedKey, err := cry.RewrapKey(keys.boxPrivateKey, newKeys.boxPublicKey, wrappedKeys[i].WrappedKey)
+		if err != nil {
+			return nil, err
+		}
+		wrappedKeys[i].WrappedKey = rewrappedKey
+	}
+	return wrappedKeys, nil
+}
+
+//create the tx migrating a vault to the target cipher suite, the vault key is unchanged so
+//  the records are only resealed
+func (app *uiRequest) getVaultMigrationTx(
+	target cry.CipherSuite,
+	vaultHashed,
+	hashInputCIdNameEncryption string,
+	hashInputCPasswordEncryption func(cIdName string) string,
+	signer txSigner) (tx2broadcast string, err error) {
+
+	records, err := app.rekeyRecords(vaultHashed,
+		hashInputCIdNameEncryption, hashInputCPasswordEncryption,
+		target,
+		hashInputCIdNameEncryption, hashInputCPasswordEncryption,
+		true)
+	if err != nil {
+		return
+	}
+
+	tx2broadcast = signer.sign(path.Join(
+		now(),
+		"migratingVaultSuite",
+		vaultHashed,
+		signer.usernameHashed,
+		target.ID(),
+		tre.EncodeRekeyedRecords(records)))
+	return
+}
+
+//URL query parameters holding the second factor and session token, placeholder of the
+//  master password of sessions, and number of backup codes generated at enrollment
+const twoFactorParam string = "2fa"
+const sessionParam string = "session"
+const sessionPassword string = "<session>"
+const twoFactorBackupCodeCount int = 8
+
+//authenticate the second factor of accounts enrolled in two-factor authentication,
+//  a used backup code is removed by broadcasting a tx
+func (app *uiRequest) authTwoFactor(
+	usernameHashed,
+	urlUsername,
+	twoFactorCode string,
+	keys accountKeys,
+	txBroadcastStr [3]*string) error {
+
+	secretEncrypted, err := app.ptr.RetrieveTwoFactorSecret()
+	if err != nil {
+		return nil //not enrolled
+	}
+	if len(twoFactorCode) < 1 {
+		return errors.New("twoFactorRequired")
+	}
+
+	secret, err := cry.ReadDecrypted(tre.HashInputTwoFactorEncryption(tre.TwoFactorKey(urlUsername, keys.keyPassword)),
+		secretEncrypted)
+	if err != nil {
+		return errors.New("badTwoFactor")
+	}
+	signer := txSigner{
+		usernameHashed: usernameHashed,
+		signingKey:     keys.signingKey,
+	}
+
+	//totp codes are only accepted after the last accepted time step, the step is
+	//  recorded by the app immediately and on the chain once the tx is committed
+	var tx2broadcast string
+	key, err := cry.ParseOTPKey(secret)
+	step, valid := int64(0), false
+	if err == nil {
+		step, valid = cry.VerifyTOTPAfter(key, twoFactorCode, app.twoFactorTime(), tre.TwoFactorSkew,
+			app.lastTwoFactorStep(usernameHashed))
+	}
+	if valid && app.useTwoFactorStep(usernameHashed, step) {
+		tx2broadcast = signer.sign(path.Join(now(), "usingTwoFactorCode", usernameHashed, strconv.FormatInt(step, 10)))
+	} else {
+		codeProof := twoFactorBackupCodeProof(urlUsername, twoFactorCode)
+		if !app.ptr.VerifyTwoFactorBackupCode(cry.GetHashedHexString(codeProof)) {
+			return errors.New("badTwoFactor")
+		}
+		tx2broadcast = signer.sign(path.Join(now(), "usingBackupCode", usernameHashed, codeProof))
+	}
+
+	if app.testing {
+		*txBroadcastStr[2] = tx2broadcast
+	} else {
+		app.broadcastTxFromString(tx2broadcast)
+	}
+
+	return nil
+}
+
+func (app *UIApp) twoFactorTime() time.Time {
+	if app.clock != nil {
+		return app.clock()
+	}
+	return time.Now()
+}
+
+//the last accepted totp time step of an account, as recorded on the chain or by the app
+//  if the tx recording a later step has yet to be committed
+func (app *uiRequest) lastTwoFactorStep(usernameHashed string) int64 {
+
+	lastStep := app.ptr.RetrieveTwoFactorStep()
+
+	app.stepsMtx.Lock()
+	defer app.stepsMtx.Unlock()
+	if usedStep, exists := app.usedSteps[usernameHashed]; exists && usedStep > lastStep {
+		lastStep = usedStep
+	}
+	return lastStep
+}
+
+//record an accepted totp time step, false if a later step has been accepted concurrently
+func (app *UIApp) useTwoFactorStep(usernameHashed string, step int64) bool {
+
+	app.stepsMtx.Lock()
+	defer app.stepsMtx.Unlock()
+	if usedStep, exists := app.usedSteps[usernameHashed]; exists && usedStep >= step {
+		return false
+	}
+	if app.usedSteps == nil {
+		app.usedSteps = make(map[string]int64)
+	}
+	app.usedSteps[usernameHashed] = step
+	return true
+}
+
+//backup codes are proven within txs by the hash of the code, the stored backup codes are
+//  the hashes of their proofs so that the stored codes may not be used as proofs
+func twoFactorBackupCodeProof(urlUsername, backupCode string) string {
+	return cry.GetHashedHexString(tre.HashInputTwoFactorBackupCode(urlUsername, backupCode))
+}
+
+//generate a two-factor secret to be added to an authenticator app, enroll the account
+//  once a code of the secret has been confirmed, or disable two-factor authentication.
+//  When enrolling the 4th URL section holds the secret and the 5th URL section the code,
+//  when disabling the 4th URL section holds an unused backup code which is consumed
+func (app *uiRequest) performTwoFactorManagement(
+	operationalOption string,
+	suite cry.CipherSuite,
+	usernameHashed,
+	urlUsername,
+	urlSecret,
+	urlCode string,
+	keys accountKeys,
+	txBroadcastStr [3]*string) (speachBubble, idNameList string, err error) {
+
+	_, enrolledErr := app.ptr.RetrieveTwoFactorSecret()
+	enrolled := enrolledErr == nil
+
+	var tx2broadcast string
+
+	switch operationalOption {
+	case "generatingTwoFactor":
+		if enrolled {
+			err = errors.New("twoFactorEnabled")
+			return
+		}
+
+		var secret string
+		secret, err = cry.GenerateOTPSecret()
+		if err != nil {
+			return
+		}
+
+		speachBubble = "add this to ur authenticator then confirm with a code"
+		idNameList = "\ntwo-factor secret: " + secret + "\n" + cry.GetTOTPURI("passwerk", urlUsername, secret)
+		return
+
+	case "enablingTwoFactor":
+		if enrolled {
+			err = errors.New("twoFactorEnabled")
+			return
+		}
+
+		key, parseErr := cry.ParseOTPKey(urlSecret)
+		if parseErr != nil || !cry.VerifyTOTP(key, urlCode, app.twoFactorTime(), tre.TwoFactorSkew) {
+			err = errors.New("badTwoFactor")
+			return
+		}
+
+		var backupCodes []string
+		backupCodes, err = cry.GenerateRecoveryCodes(twoFactorBackupCodeCount)
+		if err != nil {
+			return
+		}
+
+		backupCodesHashed := make([]string, len(backupCodes))
+		for i, backupCode := range backupCodes {
+			backupCodesHashed[i] = cry.GetHashedHexString(twoFactorBackupCodeProof(urlUsername, backupCode))
+			idNameList = idNameList + "\nbackup code: " + backupCode
+		}
+
+		var secretEncrypted string
+		secretEncrypted, err = cry.GetEncryptedBoundHexString(suite,
+			tre.HashInputTwoFactorEncryption(tre.TwoFactorKey(urlUsername, keys.keyPassword)), urlSecret, "")
+		if err != nil {
+			return
+		}
+
+		tx2broadcast = path.Join(now(), operationalOption, usernameHashed,
+			secretEncrypted,
+			tre.EncodeTwoFactorBackupCodes(backupCodesHashed))
+		speachBubble = "two-factor enabled, keep these backup codes safe"
+
+	case "disablingTwoFactor":
+		if !enrolled {
+			err = errors.New("twoFactorNotEnabled")
+			return
+		}
+
+		codeProof := twoFactorBackupCodeProof(urlUsername, urlSecret)
+		if !app.ptr.VerifyTwoFactorBackupCode(cry.GetHashedHexString(codeProof)) {
+			err = errors.New("badTwoFactor")
+			return
+		}
+
+		tx2broadcast = path.Join(now(), operationalOption, usernameHashed, codeProof)
+		speachBubble = "two-factor disabled"
+	}
+
+	signer := txSigner{
+		usernameHashed: usernameHashed,
+		signingKey:     keys.signingKey,
+	}
+	tx2broadcast = signer.sign(tx2broadcast)
+	if app.testing {
+		*txBroadcastStr[0] = tx2broadcast
+	} else {
+		app.broadcastTxFromString(tx2broadcast)
+	}
+
+	return
+}
+
+//gather the saved password along with any additional record fields
+func getRecordFields(cPassword string, urlFields url.Values) map[string]string {
+
+	fields := map[string]string{tre.FieldPassword: cPassword}
+	for name, values := range urlFields {
+		if len(name) > 0 && name != tre.FieldPassword && len(values) > 0 {
+			fields[name] = values[0]
+		}
+	}
+	fields[tre.FieldModified] = time.Now().UTC().Format(time.RFC3339)
+	return fields
+}
+
+//broadcast the txs to write a record, before writing any duplicate records must first be deleted
+//encrypt the identifier to be added to the cIdList and the record fields to be written
+func getEncryptedEntry(
+	suite cry.CipherSuite,
+	hashInputCIdNameEncryption,
+	hashInputCPasswordEncryption,
+	usernameHashed,
+	cIdNameHashed,
+	urlCIdName string,
+	fields map[string]string) (cIdNameEncrypted, cRecordEncrypted string, err error) {
+
+	cIdNameEncrypted, err = cry.GetEncryptedBoundHexString(suite, hashInputCIdNameEncryption, urlCIdName,
+		tre.AssociatedDataCIdName(usernameHashed))
+	if err != nil {
+		return
+	}
+
+	cRecordEncrypted, err = tre.GetEncryptedRecord(suite, hashInputCPasswordEncryption, usernameHashed, cIdNameHashed, fields)
+	return
+}
+
+func (app *uiRequest) broadcastRecord(
+	usernameHashed,
+	cIdNameHashed,
+	cIdNameEncrypted,
+	cRecordEncrypted string,
+	signer txSigner,
+	txBroadcastStr [3]*string) {
+
+	//do not worry about error handling here for records that do not exist
+	//  it doesn't really matter if there is nothing to delete
+	mapCIdNameEncrypted2Delete, err := app.ptr.GetCIdListEncryptedCIdName()
+	if err == nil {
+
+		//create he tx then broadcast
+		tx2broadcast := signer.sign(path.Join(
+			now(),
+			"deleting",
+			usernameHashed,
+			cIdNameHashed,
+			mapCIdNameEncrypted2Delete))
+		if app.testing {
+			*txBroadcastStr[0] = tx2broadcast
+		} else {
+			app.broadcastTxFromString(tx2broadcast)
+		}
+	}
+
+	//now write the records
+	//create he tx then broadcast
+	tx2broadcast := signer.sign(path.Join(
+		now(),
+		"writing",
+		usernameHashed,
+		cIdNameHashed,
+		cIdNameEncrypted,
+		cRecordEncrypted))
+	if app.testing {
+		*txBroadcastStr[1] = tx2broadcast
+	} else {
+		app.broadcastTxFromString(tx2broadcast)
+	}
+}
+
+//retrieve the record fields for output, any otp seed is replaced by its current code.
+//  hotp records are re-written with an advanced counter so each code is only output once
+func (app *uiRequest) retrieveRecordOutput(
+	suite cry.CipherSuite,
+	usernameHashed,
+	cIdNameHashed,
+	hashInputCPasswordEncryption string,
+	signer txSigner,
+	txBroadcastStr [3]*string) (fields map[string]*cry.Secret, err error) {
+
+	fields, err = app.ptr.RetrieveCRecordSecrets(false)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tre.WipeRecord(fields)
+			fields = nil
+		}
+	}()
+
+	seed, exists := fields[tre.FieldTOTP]
+	if !exists {
+		return
+	}
+
+	var key cry.OTPKey
+	key, err = cry.ParseOTPKey(string(seed.Bytes()))
+	if err != nil {
+		err = errors.New("invalidOTPSeed")
+		return
+	}
+
+	var code string
+	switch key.Type {
+	case "hotp":
+		code, err = cry.GenerateHOTP(key, key.Counter)
+		if err != nil {
+			return
+		}
+
+		//advance the stored counter
+		var advancedSeed string
+		advancedSeed, err = cry.SetOTPCounter(string(seed.Bytes()), key.Counter+1)
+		if err != nil {
+			return
+		}
+		seed.Wipe()
+		fields[tre.FieldTOTP] = cry.NewSecretFromBytes([]byte(advancedSeed))
+
+		var cIdNameOrigEncrypted string
+		cIdNameOrigEncrypted, err = app.ptr.GetCIdListEncryptedCIdName()
+		if err != nil {
+			return
+		}
+
+		var cRecordEncrypted string
+		cRecordEncrypted, err = tre.GetEncryptedRecordSecrets(suite, hashInputCPasswordEncryption, usernameHashed, cIdNameHashed, fields)
+		if err != nil {
+			return
+		}
+
+		app.broadcastRecord(
+			usernameHashed,
+			cIdNameHashed,
+			cIdNameOrigEncrypted,
+			cRecordEncrypted,
+			signer,
+			txBroadcastStr)
+
+	default:
+		var remaining time.Duration
+		code, remaining, err = cry.GenerateTOTP(key, time.Now())
+		if err != nil {
+			return
+		}
+		code = code + " (valid " + strconv.Itoa(int(remaining.Seconds())) + "s)"
+	}
+
+	fields[tre.FieldTOTP].Wipe()
+	fields[tre.FieldTOTP] = cry.NewSecretFromBytes([]byte(code))
+	return
+}
+
+//retrieve the vault key of a member by unwrapping it with their private key
+func (app *uiRequest) unwrapVaultKey(
+	vaultHashed,
+	usernameHashed string,
+	keys accountKeys) (vaultKey, status string, err error) {
+
+	var wrappedKey string
+	status, wrappedKey, err = app.ptr.RetrieveVaultMembership(vaultHashed, usernameHashed)
+	if err != nil {
+		return
+	}
+
+	vaultKey, err = cry.UnwrapKey(keys.boxPrivateKey, wrappedKey)
+	if err != nil {
+		err = errors.New("notVaultMember")
+		return
+	}
+
+	//invites must be accepted before the vault may be used
+	if status == tre.VaultInvited {
+		err = errors.New("notVaultMember")
+	}
+	return
+}
+
+//wrap a secret to the published public key of a user
+func (app *uiRequest) wrapToUser(userHashed, secret string) (wrapped string, err error) {
+
+	publicKeyHex, err := app.ptr.RetrievePublicKey(userHashed)
+	if err != nil {
+		return
+	}
+	userPublicKey, err := cry.ReadPublicKeyHexString(publicKeyHex)
+	if err != nil {
+		err = errors.New("noPublicKey")
+		return
+	}
+	return cry.WrapKey(userPublicKey, secret)
+}
+
+//rotate the vault key on the revocation of a member, the records are resealed under a new
+//  vault key which is wrapped to every remaining holder of the key. Custodians are given
+//  shares of the new key split with the threshold of the previous split
+func (app *uiRequest) rotateVaultKey(
+	vaultHashed,
+	vaultKey,
+	memberUsernameHashed string) (records []tre.RekeyedRecord, keys []tre.RotatedKey, err error) {
+
+	newVaultKey, err := cry.GetRandomKeyHexString()
+	if err != nil {
+		return
+	}
+
+	suite, err := app.cipherSuite(vaultHashed)
+	if err != nil {
+		return
+	}
+	records, err = app.rekeyRecords(vaultHashed,
+		tre.HashInputVaultCIdNameEncryption(vaultKey),
+		func(cIdName string) string { return tre.HashInputVaultCPasswordEncryption(vaultKey, cIdName) },
+		suite,
+		tre.HashInputVaultCIdNameEncryption(newVaultKey),
+		func(cIdName string) string { return tre.HashInputVaultCPasswordEncryption(newVaultKey, cIdName) },
+		false)
+	if err != nil {
+		return
+	}
+
+	shares := make(map[string]string)
+	if threshold, custodians, splitErr := app.ptr.RetrieveKeySplit(vaultHashed); splitErr == nil {
+		var sharesHex []string
+		sharesHex, err = cry.GetSecretShareHexStrings(newVaultKey, threshold, len(custodians))
+		if err != nil {
+			return
+		}
+		for i, custodian := range custodians {
+			shares[custodian] = sharesHex[i]
+		}
+	}
+
+	keys, err = app.ptr.RetrieveVaultKeyHolders(vaultHashed, memberUsernameHashed)
+	if err != nil {
+		return
+	}
+	for i := range keys {
+		secret := newVaultKey
+		if keys[i].Kind == tre.WrappedKeyShare {
+			secret = shares[keys[i].UsernameHashed]
+		}
+		keys[i].WrappedKey, err = app.wrapToUser(keys[i].UsernameHashed, secret)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+//create a vault, or invite, accept, or revoke vault members
+//  the 5th URL section holds the username of the member being invited or revoked
+func (app *uiRequest) performVaultManagement(
+	operationalOption,
+	vaultHashed,
+	usernameHashed,
+	urlMemberName string,
+	keys accountKeys,
+	signer txSigner,
+	txBroadcastStr [3]*string) (speachBubble string, err error) {
+
+	var tx2broadcast string
+
+	switch operationalOption {
+	case "creatingVault":
+		if app.ptr.VaultExists(vaultHashed) {
+			err = errors.New("vaultExists")
+			return
+		}
+
+		//create a new vault key and wrap it to the owner
+		var vaultKey, wrappedKey string
+		vaultKey, err = cry.GetRandomKeyHexString()
+		if err != nil {
+			return
+		}
+		wrappedKey, err = cry.WrapKey(keys.boxPublicKey, vaultKey)
+		if err != nil {
+			return
+		}
+
+		tx2broadcast = path.Join(now(), operationalOption, vaultHashed, usernameHashed, wrappedKey)
+		speachBubble = "vault is open for business"
+
+	case "invitingMember":
+		var vaultKey, status string
+		vaultKey, status, err = app.unwrapVaultKey(vaultHashed, usernameHashed, keys)
+		if err != nil {
+			return
+		}
+		if !app.canManageVault(vaultHashed, usernameHashed, status) {
+			err = errors.New("notVaultOwner")
+			return
+		}
+
+		//wrap the vault key to the invitee's published public key
+		inviteeUsernameHashed := cry.GetHashedHexString(urlMemberName)
+
+		//the invitee to a collection must hold a role which may read the collection
+		isCollection, inviteeRole, readRole, _, _ := app.ptr.RetrieveCollectionAccess(vaultHashed, inviteeUsernameHashed)
+		if isCollection && tre.OrgRoleRank(inviteeRole) < tre.OrgRoleRank(readRole) {
+			err = errors.New("insufficientRole")
+			return
+		}
+
+		var publicKeyHex, wrappedKey string
+		publicKeyHex, err = app.ptr.RetrievePublicKey(inviteeUsernameHashed)
+		if err != nil {
+			return
+		}
+		var publicKey *[32]byte
+		publicKey, err = cry.ReadPublicKeyHexString(publicKeyHex)
+		if err != nil {
+			err = errors.New("noPublicKey")
+			return
+		}
+		wrappedKey, err = cry.WrapKey(publicKey, vaultKey)
+		if err != nil {
+			return
+		}
+
+		tx2broadcast = path.Join(now(), operationalOption, vaultHashed, usernameHashed,
+			inviteeUsernameHashed, wrappedKey)
+		speachBubble = "invite sent"
+
+	case "acceptingInvite":
+		//verify the invite is for this member before accepting
+		var status, wrappedKey string
+		status, wrappedKey, err = app.ptr.RetrieveVaultMembership(vaultHashed, usernameHashed)
+		if err != nil {
+			return
+		}
+
+		unwrapped, unwrapErr := cry.UnwrapSecret(keys.boxPrivateKey, wrappedKey)
+		unwrapped.Wipe()
+		if unwrapErr != nil || status != tre.VaultInvited {
+			err = errors.New("notVaultMember")
+			return
+		}
+
+		tx2broadcast = path.Join(now(), operationalOption, vaultHashed, usernameHashed)
+		speachBubble = "welcome to the vault"
+
+	case "revokingMember":
+		var status, wrappedKey string
+		status, wrappedKey, err = app.ptr.RetrieveVaultMembership(vaultHashed, usernameHashed)
+		if err != nil {
+			return
+		}
+
+		memberUsernameHashed := cry.GetHashedHexString(urlMemberName)
+		if !app.canManageVault(vaultHashed, usernameHashed, status) && memberUsernameHashed != usernameHashed {
+			err = errors.New("notVaultOwner")
+			return
+		}
+
+		//invitees hold the vault key and may revoke their own invite
+		var vaultKey string
+		vaultKey, err = cry.UnwrapKey(keys.boxPrivateKey, wrappedKey)
+		if err != nil {
+			err = errors.New("notVaultMember")
+			return
+		}
+
+		var records []tre.RekeyedRecord
+		var rotatedKeys []tre.RotatedKey
+		records, rotatedKeys, err = app.rotateVaultKey(vaultHashed, vaultKey, memberUsernameHashed)
+		if err != nil {
+			return
+		}
+
+		tx2broadcast = path.Join(now(), operationalOption, vaultHashed, usernameHashed, memberUsernameHashed,
+			tre.EncodeRekeyedRecords(records), tre.EncodeRotatedKeys(rotatedKeys))
+		speachBubble = "they're outta here"
+	}
+
+	tx2broadcast = signer.sign(tx2broadcast)
+	if app.testing {
+		*txBroadcastStr[0] = tx2broadcast
+	} else {
+		app.broadcastTxFromString(tx2broadcast)
+	}
+
+	//vaults without a cipher suite use the legacy suite, new vaults are
+	//  migrated to the configured suite while they're empty
+	if operationalOption == "creatingVault" && app.suite.ID() != cry.LegacyCipherSuite.ID() {
+		tx2broadcast = signer.sign(path.Join(now(), "migratingVaultSuite", vaultHashed,
+			usernameHashed, app.suite.ID(), tre.EncodeRekeyedRecords(nil)))
+		if app.testing {
+			*txBroadcastStr[1] = tx2broadcast
+		} else {
+			app.broadcastTxFromString(tx2broadcast)
+		}
+	}
+
+	return
+}
+
+//designate an emergency contact of a vault, request or claim emergency access as the contact,
+//  or cancel emergency access as the vault owner. The 4th URL section holds the username
+//  of the contact and the 5th URL section holds the waiting period in blocks
+func (app *uiRequest) performEmergencyAccess(
+	operationalOption,
+	vaultHashed,
+	usernameHashed,
+	urlContactName,
+	urlWaitBlocks string,
+	keys accountKeys,
+	signer txSigner,
+	txBroadcastStr [3]*string) (speachBubble string, err error) {
+
+	var tx2broadcast string
+
+	switch operationalOption {
+	case "designatingContact":
+		var waitBlocks uint64
+		waitBlocks, err = strconv.ParseUint(urlWaitBlocks, 10, 64)
+		if err != nil || waitBlocks > tre.MaxEmergencyWaitBlocks {
+			err = errors.New("invalidWait")
+			return
+		}
+
+		var vaultKey, status string
+		vaultKey, status, err = app.unwrapVaultKey(vaultHashed, usernameHashed, keys)
+		if err != nil {
+			return
+		}
+		if status != tre.VaultOwner {
+			err = errors.New("notVaultOwner")
+			return
+		}
+
+		//wrap the vault key to the contact's published public key
+		contactUsernameHashed := cry.GetHashedHexString(urlContactName)
+
+		var publicKeyHex, wrappedKey string
+		publicKeyHex, err = app.ptr.RetrievePublicKey(contactUsernameHashed)
+		if err != nil {
+			return
+		}
+		var publicKey *[32]byte
+		publicKey, err = cry.ReadPublicKeyHexString(publicKeyHex)
+		if err != nil {
+			err = errors.New("noPublicKey")
+			return
+		}
+		wrappedKey, err = cry.WrapKey(publicKey, vaultKey)
+		if err != nil {
+			return
+		}
+
+		tx2broadcast = path.Join(now(), operationalOption, vaultHashed, usernameHashed,
+			contactUsernameHashed, strconv.FormatUint(waitBlocks, 10), wrappedKey)
+		speachBubble = "emergency contact set"
+
+	case "requestingAccess":
+		var waitBlocks, requestHeight uint64
+		waitBlocks, requestHeight, _, err = app.ptr.RetrieveEmergencyContact(vaultHashed, usernameHashed)
+		if err != nil {
+			err = errors.New("notEmergencyContact")
+			return
+		}
+		if requestHeight > 0 {
+			err = errors.New("accessPending")
+			return
+		}
+
+		tx2broadcast = path.Join(now(), operationalOption, vaultHashed, usernameHashed)
+		speachBubble = "access requested, the owner has " + strconv.FormatUint(waitBlocks, 10) + " blocks to cancel"
+
+	case "cancelingAccess":
+		var status string
+		status, _, err = app.ptr.RetrieveVaultMembership(vaultHashed, usernameHashed)
+		if err != nil {
+			return
+		}
+		if status != tre.VaultOwner {
+			err = errors.New("notVaultOwner")
+			return
+		}
+
+		contactUsernameHashed := cry.GetHashedHexString(urlContactName)
+		_, _, _, err = app.ptr.RetrieveEmergencyContact(vaultHashed, contactUsernameHashed)
+		if err != nil {
+			err = errors.New("notEmergencyContact")
+			return
+		}
+
+		tx2broadcast = path.Join(now(), operationalOption, vaultHashed, usernameHashed, contactUsernameHashed)
+		speachBubble = "emergency access cancelled"
+
+	case "claimingAccess":
+		var waitBlocks, requestHeight uint64
+		var wrappedKey string
+		waitBlocks, requestHeight, wrappedKey, err = app.ptr.RetrieveEmergencyContact(vaultHashed, usernameHashed)
+		if err != nil {
+			err = errors.New("notEmergencyContact")
+			return
+		}
+
+		//verify the wrapped key is for this contact before claiming
+		unwrapped, unwrapErr := cry.UnwrapSecret(keys.boxPrivateKey, wrappedKey)
+		unwrapped.Wipe()
+		if unwrapErr != nil {
+			err = errors.New("notEmergencyContact")
+			return
+		}
+
+		switch {
+		case requestHeight < 1:
+			err = errors.New("accessNotRequested")
+			return
+		case !tre.EmergencyWaitPassed(app.ptr.RetrieveBlockHeight(), requestHeight, waitBlocks):
+			err = errors.New("accessPending")
+			return
+		}
+
+		tx2broadcast = path.Join(now(), operationalOption, vaultHashed, usernameHashed)
+		speachBubble = "emergency access granted"
+	}
+
+	tx2broadcast = signer.sign(tx2broadcast)
+	if app.testing {
+		*txBroadcastStr[0] = tx2broadcast
+	} else {
+		app.broadcastTxFromString(tx2broadcast)
+	}
+
+	return
+}
+
+//split the vault key across custodians as the vault owner, request reconstruction of the vault
+//  key, approve or cancel the request, release a share to the requester as a custodian, or
+//  reconstruct the vault key as the requester. When splitting, the 4th URL section holds the
+//  threshold of shares required for reconstruction and the 5th URL section holds the comma
+//  seperated usernames of the custodians. When approving or releasing, the 4th URL section
+//  holds the username of the requester, which must be that of the pending request
+func (app *uiRequest) performKeySharing(
+	operationalOption,
+	vaultHashed,
+	usernameHashed,
+	urlMemberName,
+	urlCustodianNames string,
+	keys accountKeys,
+	signer txSigner,
+	txBroadcastStr [3]*string) (speachBubble string, err error) {
+
+	var tx2broadcast string
+
+	switch operationalOption {
+	case "splittingKey":
+		var threshold int
+		threshold, err = strconv.Atoi(urlMemberName)
+		if err != nil {
+			err = errors.New("invalidThreshold")
+			return
+		}
+		custodianNames := strings.Split(urlCustodianNames, ",")
+
+		var vaultKey, status string
+		vaultKey, status, err = app.unwrapVaultKey(vaultHashed, usernameHashed, keys)
+		if err != nil {
+			return
+		}
+		if status != tre.VaultOwner {
+			err = errors.New("notVaultOwner")
+			return
+		}
+
+		var sharesHex []string
+		sharesHex, err = cry.GetSecretShareHexStrings(vaultKey, threshold, len(custodianNames))
+		if err != nil {
+			err = errors.New("invalidThreshold")
+			return
+		}
+
+		//wrap each share to the published public key of its custodian
+		shares := make([]tre.KeyShare, len(custodianNames))
+		for i, custodianName := range custodianNames {
+			shares[i].CustodianHashed = cry.GetHashedHexString(custodianName)
+			shares[i].WrappedShare, err = app.wrapToUser(shares[i].CustodianHashed, sharesHex[i])
+			if err != nil {
+				return
+			}
+		}
+
+		tx2broadcast = path.Join(now(), operationalOption, vaultHashed, usernameHashed,
+			strconv.Itoa(threshold), tre.EncodeKeyShares(shares))
+		speachBubble = "key split among custodians"
+
+	case "requestingReconstruction":
+		var threshold int
+		threshold, _, err = app.ptr.RetrieveKeySplit(vaultHashed)
+		if err != nil {
+			err = errors.New("notSplit")
+			return
+		}
+
+		status, _, membershipErr := app.ptr.RetrieveVaultMembership(vaultHashed, usernameHashed)
+		if membershipErr == nil && status != tre.VaultInvited {
+			err = errors.New("alreadyVaultMember")
+			return
+		}
+		if _, _, _, requestErr := app.ptr.RetrieveReconstruction(vaultHashed); requestErr == nil {
+			err = errors.New("reconstructionPending")
+			return
+		}
+
+		tx2broadcast = path.Join(now(), operationalOption, vaultHashed, usernameHashed)
+		speachBubble = "reconstruction requested, once approved " + strconv.Itoa(threshold) +
+			" custodians must release their shares"
+
+	case "approvingReconstruction":
+		var status string
+		status, _, err = app.ptr.RetrieveVaultMembership(vaultHashed, usernameHashed)
+		if err != nil {
+			return
+		}
+		if !app.canManageVault(vaultHashed, usernameHashed, status) {
+			err = errors.New("notVaultOwner")
+			return
+		}
+
+		requesterHashed, approved, _, requestErr := app.ptr.RetrieveReconstruction(vaultHashed)
+		if requestErr != nil || requesterHashed != cry.GetHashedHexString(urlMemberName) {
+			err = errors.New("notRequester")
+			return
+		}
+		if approved {
+			err = errors.New("reconstructionApproved")
+			return
+		}
+
+		tx2broadcast = path.Join(now(), operationalOption, vaultHashed, usernameHashed, requesterHashed)
+		speachBubble = "reconstruction approved for " + urlMemberName
+
+	case "cancelingReconstruction":
+		requesterHashed, _, _, requestErr := app.ptr.RetrieveReconstruction(vaultHashed)
+		if requestErr != nil {
+			err = errors.New("notRequested")
+			return
+		}
+		if requesterHashed != usernameHashed {
+			status, _, _ := app.ptr.RetrieveVaultMembership(vaultHashed, usernameHashed)
+			if !app.canManageVault(vaultHashed, usernameHashed, status) {
+				err = errors.New("notVaultOwner")
+				return
+			}
+		}
+
+		tx2broadcast = path.Join(now(), operationalOption, vaultHashed, usernameHashed)
+		speachBubble = "reconstruction cancelled"
+
+	//the custodian names the requester they are releasing their share to, the share is only
+	//  released when the named requester is that of the pending and approved request
+	case "releasingShare":
+		requesterHashed, approved, _, requestErr := app.ptr.RetrieveReconstruction(vaultHashed)
+		if requestErr != nil {
+			err = errors.New("notRequested")
+			return
+		}
+		if !approved {
+			err = errors.New("reconstructionUnapproved")
+			return
+		}
+		if requesterHashed != cry.GetHashedHexString(urlMemberName) {
+			err = errors.New("notRequester")
+			return
+		}
+
+		//unwrap the custodian's share and re-wrap it to the requester
+		var wrappedShare, share string
+		wrappedShare, err = app.ptr.RetrieveKeyShare(vaultHashed, usernameHashed)
+		if err != nil {
+			err = errors.New("notCustodian")
+			return
+		}
+		share, err = cry.UnwrapKey(keys.boxPrivateKey, wrappedShare)
+		if err != nil {
+			err = errors.New("notCustodian")
+			return
+		}
+		wrappedShare, err = app.wrapToUser(requesterHashed, share)
+		if err != nil {
+			return
+		}
+
+		tx2broadcast = path.Join(now(), operationalOption, vaultHashed, usernameHashed, requesterHashed, wrappedShare)
+		speachBubble = "share released to " + urlMemberName
+
+	case "reconstructing":
+		var threshold int
+		threshold, _, err = app.ptr.RetrieveKeySplit(vaultHashed)
+		if err != nil {
+			err = errors.New("notSplit")
+			return
+		}
+
+		var requesterHashed string
+		var releasedShares []string
+		requesterHashed, _, releasedShares, err = app.ptr.RetrieveReconstruction(vaultHashed)
+		if err != nil || requesterHashed != usernameHashed {
+			err = errors.New("notRequested")
+			return
+		}
+
+		var sharesHex []string
+		for _, releasedShare := range releasedShares {
+			share, unwrapErr := cry.UnwrapKey(keys.boxPrivateKey, releasedShare)
+			if unwrapErr == nil {
+				sharesHex = append(sharesHex, share)
+			}
+		}
+		if len(sharesHex) < threshold {
+			err = errors.New("sharesPending")
+			return
+		}
+
+		var vaultKey, wrappedKey string
+		vaultKey, err = cry.CombineSecretShareHexStrings(sharesHex)
+		if err != nil {
+			return
+		}
+		wrappedKey, err = cry.WrapKey(keys.boxPublicKey, vaultKey)
+		if err != nil {
+			return
+		}
+
+		tx2broadcast = path.Join(now(), operationalOption, vaultHashed, usernameHashed, wrappedKey)
+		speachBubble = "vault key reconstructed"
+	}
+
+	tx2broadcast = signer.sign(tx2broadcast)
+	if app.testing {
+		*txBroadcastStr[0] = tx2broadcast
+	} else {
+		app.broadcastTxFromString(tx2broadcast)
+	}
+
+	return
+}
+
+//vault owners may manage members, as may organization admins holding the key of a collection
+func (app *uiRequest) canManageVault(vaultHashed, usernameHashed, status string) bool {
+
+	if status == tre.VaultOwner {
+		return true
+	}
+
+	isCollection, role, _, _, _ := app.ptr.RetrieveCollectionAccess(vaultHashed, usernameHashed)
+	return isCollection && status == tre.VaultMember && tre.OrgRoleRank(role) >= tre.OrgRoleRank(tre.OrgAdmin)
+}
+
+//verify the organization role of a collection member permits the record operation
+func (app *uiRequest) verifyCollectionRole(operationalOption, vaultHashed, usernameHashed, status string) error {
+
+	isCollection, role, readRole, writeRole, err := app.ptr.RetrieveCollectionAccess(vaultHashed, usernameHashed)
+	if err != nil {
+		return err
+	}
+	if !isCollection || status == tre.VaultOwner {
+		return nil
+	}
+
+	minimumRole := readRole
+	switch operationalOption {
+	case "writing", "deleting", "writingGenerated":
+		minimumRole = writeRole
+	}
+
+	if tre.OrgRoleRank(role) < tre.OrgRoleRank(minimumRole) {
+		return errors.New("insufficientRole")
+	}
+	return nil
+}
+
+//create an organization, set or remove the roles of members, or add collections
+//  the 4th URL section holds the username of the member or the name of the collection vault,
+//  the 5th URL section holds the role to set, or the read and write roles of the collection
+func (app *uiRequest) performOrgManagement(
+	operationalOption,
+	orgHashed,
+	usernameHashed,
+	urlMemberName,
+	urlRoles string,
+	signer txSigner,
+	txBroadcastStr [3]*string) (speachBubble string, err error) {
+
+	var tx2broadcast string
+
+	//all operations other than creating an organization require an owner or admin
+	if operationalOption != "creatingOrg" {
+		var role string
+		role, err = app.ptr.RetrieveOrgRole(orgHashed, usernameHashed)
+		if err != nil || tre.OrgRoleRank(role) < tre.OrgRoleRank(tre.OrgAdmin) {
+			err = errors.New("notOrgAdmin")
+			return
+		}
+	}
+
+	switch operationalOption {
+	case "creatingOrg":
+		if app.ptr.OrgExists(orgHashed) {
+			err = errors.New("orgExists")
+			return
+		}
+
+		tx2broadcast = path.Join(now(), operationalOption, orgHashed, usernameHashed)
+		speachBubble = "the org is in session"
+
+	case "settingRole":
+		if tre.OrgRoleRank(urlRoles) < 1 {
+			err = errors.New("invalidRole")
+			return
+		}
+
+		tx2broadcast = path.Join(now(), operationalOption, orgHashed, usernameHashed,
+			cry.GetHashedHexString(urlMemberName), urlRoles)
+		speachBubble = "role granted"
+
+	case "removingMember":
+		tx2broadcast = path.Join(now(), operationalOption, orgHashed, usernameHashed,
+			cry.GetHashedHexString(urlMemberName))
+		speachBubble = "they're outta the org"
+
+	case "addingCollection":
+		roles := strings.Split(urlRoles, ",")
+		if len(roles) != 2 || tre.OrgRoleRank(roles[0]) < 1 || tre.OrgRoleRank(roles[1]) < 1 {
+			err = errors.New("invalidRole")
+			return
+		}
+
+		vaultHashed := cry.GetHashedHexString(tre.HashInputVaultName(urlMemberName))
+
+		var status string
+		status, _, err = app.ptr.RetrieveVaultMembership(vaultHashed, usernameHashed)
+		if err != nil || status != tre.VaultOwner {
+			err = errors.New("notVaultOwner")
+			return
+		}
+
+		tx2broadcast = path.Join(now(), operationalOption, orgHashed, usernameHashed,
+			vaultHashed, roles[0], roles[1])
+		speachBubble = "the vault is now a collection"
+	}
+
+	tx2broadcast = signer.sign(tx2broadcast)
+	if app.testing {
+		*txBroadcastStr[0] = tx2broadcast
+	} else {
+		app.broadcastTxFromString(tx2broadcast)
+	}
+
+	return
+}
+
+//the keys of an account derived from the stretched master password, the private keys
+//  are to be wiped once used
+type accountKeys struct {
+	keyPassword   string
+	boxPublicKey  *[32]byte
+	boxPrivateKey *[32]byte
+	signingKey    ed25519.PrivateKey
+}
+
+func getAccountKeys(urlUsername, keyPassword string) accountKeys {
+
+	boxPublicKey, boxPrivateKey := cry.GetBoxKeyPair(tre.HashInputBoxKey(urlUsername, keyPassword))
+	_, signingKey := cry.GetSigningKeyPair(tre.HashInputSigningKey(urlUsername, keyPassword))
+	return accountKeys{
+		keyPassword:   keyPassword,
+		boxPublicKey:  boxPublicKey,
+		boxPrivateKey: boxPrivateKey,
+		signingKey:    signingKey,
+	}
+}
+
+func (keys accountKeys) signingPublicKeyHexString() string {
+	return hex.EncodeToString(keys.signingKey.Public().(ed25519.PublicKey))
+}
+
+//overwrite the private keys, the zero value holds no keys
+func (keys accountKeys) wipe() {
+	if keys.boxPrivateKey != nil {
+		cry.Wipe(keys.boxPrivateKey[:])
+	}
+	cry.Wipe(keys.signingKey)
+}
+
+//the signer of txs, the zero value leaves txs unsigned
+type txSigner struct {
+	usernameHashed string
+	signingKey     ed25519.PrivateKey
+}
+
+//append the hashed username of the signer to the tx, followed by the signature of both
+func (signer txSigner) sign(tx string) string {
+
+	if len(signer.usernameHashed) < 1 {
+		return tx
+	}
+
+	message := path.Join(tx, signer.usernameHashed)
+	return path.Join(message, cry.SignHexString(signer.signingKey, message))
+}
+
+//output the health of a record, the record's password is never included
+func getRecordReportOutput(recordReport audit.RecordReport) string {
+
+	output := recordReport.CIdName + ": " + strconv.Itoa(int(recordReport.EntropyBits)) + " bits"
+	if recordReport.Weak {
+		output += " weak"
+	}
+	if recordReport.AgeDays >= 0 {
+		output += ", " + strconv.Itoa(recordReport.AgeDays) + " days old"
+		if recordReport.Stale {
+			output += " stale"
+		}
+	}
+	if len(recordReport.DuplicateOf) > 0 {
+		output += ", duplicate of " + strings.Join(recordReport.DuplicateOf, " ")
+	}
+	if recordReport.Breached {
+		output += ", breached"
+	}
+	return output
+}
+
+func getOperationalOption(notSelected,
+	urlOptionText,
+	urlUsername,
+	urlPassword,
 	urlCIdName,
 	urlCPassword string) (string, error) {
 
@@ -256,6 +2437,8 @@ func getOperationalOption(notSelected,
 	case "r":
 		if anyAreNotSelected([]string{urlUsername, urlPassword}) {
 			return "", genErr
+		} else if urlCIdName != notSelected && urlCPassword != notSelected {
+			return "readingField", nil
 		} else if urlCIdName != notSelected {
 			return "readingPassword", nil
 		} else {
@@ -274,6 +2457,181 @@ func getOperationalOption(notSelected,
 		} else {
 			return "deleting", nil
 		}
+	case "g":
+		if anyAreNotSelected([]string{urlCIdName, urlUsername, urlPassword}) {
+			return "", genErr
+		} else {
+			return "writingGenerated", nil
+		}
+	case "h":
+		if anyAreNotSelected([]string{urlUsername, urlPassword}) {
+			return "", genErr
+		} else {
+			return "healthReport", nil
+		}
+	case "n":
+		if anyAreNotSelected([]string{urlUsername, urlPassword}) {
+			return "", genErr
+		} else {
+			return "registering", nil
+		}
+	case "x":
+		if anyAreNotSelected([]string{urlUsername, urlPassword}) {
+			return "", genErr
+		} else {
+			return "deletingAccount", nil
+		}
+	case "k":
+		if anyAreNotSelected([]string{urlUsername, urlPassword, urlCIdName}) {
+			return "", genErr
+		} else {
+			return "recovering", nil
+		}
+	case "t":
+		if anyAreNotSelected([]string{urlUsername, urlPassword}) {
+			return "", genErr
+		} else if urlCIdName != notSelected && urlCPassword != notSelected {
+			return "enablingTwoFactor", nil
+		} else if urlCIdName == notSelected {
+			return "generatingTwoFactor", nil
+		} else {
+			return "", genErr
+		}
+	case "f":
+		if anyAreNotSelected([]string{urlUsername, urlPassword}) {
+			return "", genErr
+		} else {
+			return "disablingTwoFactor", nil
+		}
+	case "i":
+		if anyAreNotSelected([]string{urlUsername, urlPassword}) {
+			return "", genErr
+		} else {
+			return "loggingIn", nil
+		}
+	case "q":
+		if anyAreNotSelected([]string{urlUsername, urlPassword}) {
+			return "", genErr
+		} else {
+			return "loggingOut", nil
+		}
+	case "m":
+		if anyAreNotSelected([]string{urlUsername, urlPassword, urlCIdName}) {
+			return "", genErr
+		} else {
+			return "migratingSuite", nil
+		}
+	default:
+		return "", genErr
+	}
+}
+
+//shared vault operations, record operations are as per personal records
+func getVaultOperationalOption(notSelected,
+	urlOptionText,
+	urlUsername,
+	urlPassword,
+	urlMemberName,
+	urlCPassword string) (string, error) {
+
+	genErr := errors.New("generalError")
+
+	if urlUsername == notSelected || urlPassword == notSelected {
+		return "", genErr
+	}
+
+	switch urlOptionText {
+	case "r", "w", "d", "g":
+		return getOperationalOption(notSelected, urlOptionText, urlUsername,
+			urlPassword, urlMemberName, urlCPassword)
+	case "c":
+		return "creatingVault", nil
+	case "a":
+		return "acceptingInvite", nil
+	case "i":
+		if urlMemberName == notSelected {
+			return "", genErr
+		}
+		return "invitingMember", nil
+	case "x":
+		if urlMemberName == notSelected {
+			return "", genErr
+		}
+		return "revokingMember", nil
+	case "e":
+		if urlMemberName == notSelected || urlCPassword == notSelected {
+			return "", genErr
+		}
+		return "designatingContact", nil
+	case "q":
+		return "requestingAccess", nil
+	case "n":
+		if urlMemberName == notSelected {
+			return "", genErr
+		}
+		return "cancelingAccess", nil
+	case "k":
+		return "claimingAccess", nil
+	case "s":
+		if urlMemberName == notSelected || urlCPassword == notSelected {
+			return "", genErr
+		}
+		return "splittingKey", nil
+	case "o":
+		return "requestingReconstruction", nil
+	case "p":
+		if urlMemberName == notSelected {
+			return "", genErr
+		}
+		return "approvingReconstruction", nil
+	case "u":
+		return "cancelingReconstruction", nil
+	case "l":
+		return "releasingShare", nil
+	case "b":
+		return "reconstructing", nil
+	case "m":
+		if urlMemberName == notSelected {
+			return "", genErr
+		}
+		return "migratingVaultSuite", nil
+	default:
+		return "", genErr
+	}
+}
+
+//organization operations
+func getOrgOperationalOption(notSelected,
+	urlOptionText,
+	urlUsername,
+	urlPassword,
+	urlMemberName,
+	urlRoles string) (string, error) {
+
+	genErr := errors.New("generalError")
+
+	if urlUsername == notSelected || urlPassword == notSelected {
+		return "", genErr
+	}
+
+	switch urlOptionText {
+	case "c":
+		return "creatingOrg", nil
+	case "s":
+		if urlMemberName == notSelected || urlRoles == notSelected {
+			return "", genErr
+		}
+		return "settingRole", nil
+	case "x":
+		if urlMemberName == notSelected {
+			return "", genErr
+		}
+		return "removingMember", nil
+	case "a":
+		if urlMemberName == notSelected || urlRoles == notSelected {
+			return "", genErr
+		}
+		return "addingCollection", nil
 	default:
 		return "", genErr
 	}
@@ -285,9 +2643,17 @@ func getUIoutput(
 	urlCIdName,
 	speachBubble,
 	idNameList string,
-	err error) string {
+	secrets secretOutput,
+	err error) (page *cry.Secret) {
+
+	//the decrypted record output is copied into the page and then wiped, the page
+	//  itself is to be wiped once written
+	defer secrets.wipe()
+	if err != nil {
+		secrets = secretOutput{}
+	}
 
-	if len(speachBubble) < 1 {
+	if len(speachBubble) < 1 && secrets.bubble.Len() < 1 {
 		speachBubble = "i h8 myslf"
 	}
 
@@ -302,12 +2668,135 @@ func getUIoutput(
 
 		case "invalidCIdName":
 			speachBubble = "sry nvr heard of it </3"
+
+		case "invalidPolicy":
+			speachBubble = "i cant make a password like that"
+
+		case "concealedField":
+			speachBubble = "my lips are sealed"
+
+		case "invalidOTPSeed":
+			speachBubble = "that otp seed is gibberish"
+
+		case "invalidField":
+			speachBubble = "that field is a mystery to me"
+
+		case "notVaultMember":
+			speachBubble = "u aint in that vault"
+
+		case "notVaultOwner":
+			speachBubble = "only the vault owner can do that"
+
+		case "vaultExists":
+			speachBubble = "that vault already exists"
+
+		case "noPublicKey":
+			speachBubble = "they dont have a key for me to share with"
+
+		case "accountExists":
+			speachBubble = "someone already goes by that name"
+
+		case "accountRetired":
+			speachBubble = "that name retired with a deleted account"
+
+		case "endToEndOnly":
+			speachBubble = "keep ur secrets, i only speak end-to-end"
+
+		case "orgExists":
+			speachBubble = "that org already exists"
+
+		case "notOrgAdmin":
+			speachBubble = "only org admins can do that"
+
+		case "invalidRole":
+			speachBubble = "never heard of that role"
+
+		case "insufficientRole":
+			speachBubble = "ur role doesnt allow that"
+
+		case "invalidWait":
+			speachBubble = "thats no waiting period"
+
+		case "notEmergencyContact":
+			speachBubble = "u aint their emergency contact"
+
+		case "accessNotRequested":
+			speachBubble = "u gotta request access first"
+
+		case "accessPending":
+			speachBubble = "hang tight, the wait aint over"
+
+		case "invalidThreshold":
+			speachBubble = "thats no threshold"
+
+		case "notSplit":
+			speachBubble = "that vault key aint split"
+
+		case "alreadyVaultMember":
+			speachBubble = "u already in that vault"
+
+		case "notRequested":
+			speachBubble = "no reconstruction was requested"
+
+		case "reconstructionPending":
+			speachBubble = "a reconstruction is already pending"
+
+		case "reconstructionApproved":
+			speachBubble = "that reconstruction is already approved"
+
+		case "reconstructionUnapproved":
+			speachBubble = "the owner aint approved that reconstruction"
+
+		case "notRequester":
+			speachBubble = "they didnt request that reconstruction"
+
+		case "notCustodian":
+			speachBubble = "u aint a custodian"
+
+		case "sharesPending":
+			speachBubble = "waiting on more custodians"
+
+		case "twoFactorRequired":
+			speachBubble = "u need ur second factor"
+
+		case "badTwoFactor":
+			speachBubble = "that code dont check out"
+
+		case "twoFactorEnabled":
+			speachBubble = "two-factor is already on"
+
+		case "twoFactorNotEnabled":
+			speachBubble = "two-factor aint on"
+
+		case "badSession":
+			speachBubble = "ur session is over, log in again"
+
+		case "masterPasswordRequired":
+			speachBubble = "u need ur master password for that"
+
+		case "tooManyAttempts":
+			speachBubble = "whoa slow down, try again later"
+
+		case "malformedCiphertext":
+			speachBubble = "that record is garbled"
+
+		case "shortCiphertext":
+			speachBubble = "that record got cut short"
+
+		case "tamperedCiphertext":
+			speachBubble = "somebody messed with that record"
+
+		case "legacyCiphertext":
+			speachBubble = "that record is from the olden days, move in to another cipher suite first"
+
+		case "unknownCipherSuite":
+			speachBubble = "never heard of that cipher suite"
 		default:
 			speachBubble = err.Error()
 		}
 	}
 
-	return "passwerk" + `
+	header := "passwerk" + `
  __________________________________________
 |                                          |
 |  u: ` + urlUsername + `
@@ -318,7 +2807,8 @@ func getUIoutput(
 	
 *coughs*
 
-      /||||\    {` + speachBubble + `}
+      /||||\    {`
+	footer := `}
      |-o-o-~|  / 
     _   ~       
    /        '\
@@ -333,8 +2823,30 @@ func getUIoutput(
 
 ` + idNameList
 
+	page = cry.NewSecret(len(header) + len(speachBubble) + secrets.bubble.Len() + len(footer) + secrets.list.Len())
+	page.AppendString(header)
+	if secrets.bubble.Len() > 0 {
+		page.Append(secrets.bubble.Bytes())
+	} else {
+		page.AppendString(speachBubble)
+	}
+	page.AppendString(footer)
+	page.Append(secrets.list.Bytes())
+
+	return
+}
+
+//decrypted record output, held as secrets until it's copied into the output page
+type secretOutput struct {
+	bubble *cry.Secret //speach bubble text, replaces the plain speach bubble when set
+	list   *cry.Secret //listed record fields, appended to the plain list
+}
+
+func (so secretOutput) wipe() {
+	so.bubble.Wipe()
+	so.list.Wipe()
 }
 
 func now() string {
-	return time.Now().Format(time.StampMicro)
+	return tre.NewTxTimestamp()
 }
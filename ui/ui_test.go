@@ -2,67 +2,81 @@
 package ui
 
 import (
+	"bytes"
+	"crypto/tls"
+	"encoding/hex"
 	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/rigelrozanski/passwerk/audit"
+	cry "github.com/rigelrozanski/passwerk/crypto"
 	"github.com/rigelrozanski/passwerk/tmsp"
 	tre "github.com/rigelrozanski/passwerk/tree"
 )
 
 func TestUi(t *testing.T) {
 
-	//inititilize DB for testing
-	pwkDb, ptw, ptr, err := tre.InitTestingDB()
+	//inititilize the in-memory trees for testing
+	ptw, ptr := tre.NewMemTreePair()
 
+	//breached password list holding the SHA-1 hash of savedPass1
+	breached, err := audit.ReadBreachedList(strings.NewReader("19F25FE085AAC1EDC6DBAF5D844B833790B7A03C"))
 	if err != nil {
 		t.Errorf(err.Error())
 	}
 
-	//remove the testing db before exit
-	defer func() {
-		err = tre.DeleteTestingDB(pwkDb)
-
-		if err != nil {
-			t.Errorf("err deleting testing DB: ", err.Error())
-		}
-	}()
-
-	//init a testing app stuct for the UI
+	//init a testing app stuct for the UI, totp codes are verified at a testing time
+	//  which is advanced a time step for each generated code
+	totpTime := time.Now()
 	app := &UIApp{
-		ptr:     ptr,
-		portUI:  "8080",
-		testing: true,
+		ptr:      ptr,
+		portUI:   "8080",
+		breached: breached,
+		sessions: newSessionStore(DefaultSessionIdleTimeout, DefaultSessionMaxLifetime),
+		suite:    cry.LegacyCipherSuite,
+		testing:  true,
+		clock:    func() time.Time { return totpTime },
 	}
 
 	testNo := 0
 
-	testStandard := func(url, expectedContains string) {
+	testStandard := func(url, expectedContains string) string {
 
 		testNo += 1 //used to identify which test is being run for failed tests
 
-		pntHolder := [2]string{"", ""}
+		pntHolder := [3]string{"", "", ""}
 
-		var tx2SpoofBroadcast [2]*string
+		var tx2SpoofBroadcast [3]*string
 
 		tx2SpoofBroadcast[0] = &pntHolder[0]
 		tx2SpoofBroadcast[1] = &pntHolder[1]
+		tx2SpoofBroadcast[2] = &pntHolder[2]
 
-		testOutput := getUIoutput(app.performOperation(url, tx2SpoofBroadcast))
+		page := getUIoutput(app.newRequest().performOperation(url, "127.0.0.1", tx2SpoofBroadcast))
+		testOutput := string(page.Bytes())
+		page.Wipe()
 
 		//split the testOuptut to remove the header above the ascii charater which contains the raw url
 		splitOutput := strings.Split(testOutput, `/||||\`) //parse by the ascii character's hair (which contains the url charcter / aka users can't enter it)
 		if len(splitOutput) < 2 {
 			err = errors.New("improper http output parse")
-			return
+			return ""
 		} else {
 			testOutput = splitOutput[1]
 		}
 
-		//perform the spoof broadcasts
-		for i := 0; i < 2; i++ {
+		//perform the spoof broadcasts, the backup code tx of the second factor is
+		//  broadcast ahead of the operation as it is outside of testing
+		for _, i := range []int{2, 0, 1} {
 			if len(*tx2SpoofBroadcast[i]) > 0 {
 
 				urlStringBytes := []byte(*tx2SpoofBroadcast[i])
@@ -76,6 +90,33 @@ func TestUi(t *testing.T) {
 			t.Errorf("test number: " + strconv.Itoa(testNo))
 			t.Errorf("error expected: " + expectedContains + " recieved: " + testOutput)
 		}
+
+		return testOutput
+	}
+
+	//retrieve the values listed within an output following a prefix such as "recovery code: "
+	getListed := func(testOutput, prefix string) (values []string) {
+		for _, line := range strings.Split(testOutput, "\n") {
+			if strings.HasPrefix(line, prefix) {
+				values = append(values, strings.TrimPrefix(line, prefix))
+			}
+		}
+		return
+	}
+
+	//generate the code of a totp secret for the next time step
+	getTOTPCode := func(secret string) string {
+		key, err := cry.ParseOTPKey(secret)
+		if err != nil {
+			t.Errorf(err.Error())
+			return ""
+		}
+		totpTime = totpTime.Add(time.Duration(key.Period) * time.Second)
+		code, _, err := cry.GenerateTOTP(key, totpTime)
+		if err != nil {
+			t.Errorf(err.Error())
+		}
+		return code
 	}
 
 	//Speach Bubbles responses
@@ -87,11 +128,77 @@ func TestUi(t *testing.T) {
 		"...psst down at my toes",      //4
 		"*Chuckles* - nvr heard of no", //5
 		"Roger That",                   //6
+		"welcome to the club",          //7
+		"someone already goes by that", //8
+		"it was nice knowing u",        //9
+		"that field is a mystery",      //10
+		"my lips are sealed",           //11
+		"i cant make a password like",  //12
+		"vault is open for business",   //13
+		"that vault already exists",    //14
+		"u aint in that vault",         //15
+		"they dont have a key",         //16
+		"invite sent",                  //17
+		"welcome to the vault",         //18
+		"only the vault owner",         //19
+		"they're outta here",           //20
+		"the org is in session",        //21
+		"that org already exists",      //22
+		"only org admins can do that",  //23
+		"never heard of that role",     //24
+		"role granted",                 //25
+		"the vault is now a collect",   //26
+		"ur role doesnt allow that",    //27
+		"they're outta the org",        //28
+		"emergency contact set",        //29
+		"u aint their emergency",       //30
+		"u gotta request access first", //31
+		"access requested, the owner",  //32
+		"hang tight, the wait aint",    //33
+		"emergency access granted",     //34
+		"emergency access cancelled",   //35
+		"ur back in, keep these new",   //36
+		"key split among custodians",   //37
+		"thats no threshold",           //38
+		"reconstruction requested, on", //39
+		"no reconstruction was",        //40
+		"u aint a custodian",           //41
+		"waiting on more custodians",   //42
+		"share released",               //43
+		"vault key reconstructed",      //44
+		"u already in that vault",      //45
+		"add this to ur authenticator", //46
+		"that code dont check out",     //47
+		"two-factor enabled, keep",     //48
+		"u need ur second factor",      //49
+		"two-factor is already on",     //50
+		"two-factor disabled",          //51
+		"two-factor aint on",           //52
+		"ur logged in, use this",       //53
+		"later",                        //54
+		"ur session is over, log in",   //55
+		"whoa slow down, try again",    //56
+		"already using",                //57
+		"never heard of that cipher",   //58
+		"moved in to",                  //59
+		"i only speak end-to-end",      //60
+		"u need ur master password",    //61
+		"that name retired with a",     //62
+		"a reconstruction is already",  //63
+		"that reconstruction is",       //64
+		"the owner aint approved",      //65
+		"they didnt request that",      //66
+		"reconstruction approved for",  //67
+		"reconstruction cancelled",     //68
 	}
 
 	read := "r"
 	write := "w"
 	delete := "d"
+	generate := "g"
+	health := "h"
+	register := "n"
+	deleteAccount := "x"
 
 	mUsr := "masterUsr"
 	mPwd := "masterPwd"
@@ -101,8 +208,18 @@ func TestUi(t *testing.T) {
 	//test for keyboard vomit
 	testStandard("asd:SDF%$%^fgsadf", sbRes[0])
 
-	//test for submitting a new password for a new user
-	testStandard(path.Join(write, mUsr, mPwd, cId[0], cPwd[0]), sbRes[6])
+	//test for writing to an account which hasn't been registered
+	testStandard(path.Join(write, mUsr, mPwd, cId[0], cPwd[0]), sbRes[2])
+
+	//test for registering a new account, and re-registering the same account
+	testStandard(path.Join(register, mUsr, mPwd), sbRes[7])
+	testStandard(path.Join(register, mUsr, "masterzzzzPi"), sbRes[8])
+
+	//test for writing with a mistyped master password
+	testStandard(path.Join(write, mUsr, "masterzzzzPi", cId[0], cPwd[0]), sbRes[2])
+
+	//test for submitting a new password with additional fields for a new user
+	testStandard(path.Join(write, mUsr, mPwd, cId[0], cPwd[0])+"?username=bob&url=example.com", sbRes[6])
 	testStandard(path.Join(write, mUsr, mPwd, cId[1], cPwd[1]), sbRes[6])
 
 	//test for invalid bad authentication
@@ -117,6 +234,500 @@ func TestUi(t *testing.T) {
 	testStandard(path.Join(read, mUsr, mPwd, cId[0]), cPwd[0])
 	testStandard(path.Join(read, mUsr, mPwd, cId[1]), cPwd[1])
 
+	//test for retrieval of additional record fields
+	testStandard(path.Join(read, mUsr, mPwd, cId[0]), "username: bob")
+	testStandard(path.Join(read, mUsr, mPwd, cId[0], "url"), "example.com")
+	testStandard(path.Join(read, mUsr, mPwd, cId[0], "notes"), sbRes[10])
+	testStandard(path.Join(read, mUsr, mPwd, cId[1], "password"), cPwd[1])
+
+	//test for one-time password codes in place of the stored seeds
+	totpSeed := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	hotpSeed := "otpauth%3A%2F%2Fhotp%2Fpasswerk%3Fsecret%3D" + totpSeed + "%26counter%3D0"
+	testStandard(path.Join(write, mUsr, mPwd, "totpID", "totpPass")+"?totp="+totpSeed, sbRes[6])
+	testStandard(path.Join(read, mUsr, mPwd, "totpID", "totp"), "s)}")
+	testStandard(path.Join(read, mUsr, mPwd, "totpID", "totpseed"), totpSeed)
+	testStandard(path.Join(write, mUsr, mPwd, "totpID", "totpPass")+"?totpconcealed=true&totp="+totpSeed, sbRes[6])
+	testStandard(path.Join(read, mUsr, mPwd, "totpID", "totpseed"), sbRes[11])
+	testStandard(path.Join(write, mUsr, mPwd, "hotpID", "hotpPass")+"?totp="+hotpSeed, sbRes[6])
+	testStandard(path.Join(read, mUsr, mPwd, "hotpID", "totp"), "755224")
+	testStandard(path.Join(read, mUsr, mPwd, "hotpID"), "totp: 287082")
+	testStandard(path.Join(read, mUsr, mPwd, "hotpID", "totp"), "359152")
+
+	//test for writing generated passwords
+	testStandard(path.Join(generate, mUsr, mPwd, "genID", "diceware,4,noupper,nodigits")+"?url=example.com", ".")
+	testStandard(path.Join(read, mUsr, mPwd, "genID", "url"), "example.com")
+	testStandard(path.Join(read, mUsr, mPwd, "genID", "password"), ".")
+	testStandard(path.Join(generate, mUsr, mPwd, "genID", "random,2"), sbRes[12])
+
+	//test for the health report
+	testStandard(path.Join(health, mUsr, mPwd), "issues found")
+	testStandard(path.Join(health, mUsr, mPwd, "30"), cId[0]+": ")
+	testStandard(path.Join(health, mUsr, mPwd), "breached")
+	testStandard(path.Join(health, mUsr, "masterzzzzPi"), sbRes[2])
+
+	//test for shared vaults between two users
+	mUsr2 := "masterUsr2"
+	mPwd2 := "masterPwd2"
+	vault := "team"
+	testStandard(path.Join(register, mUsr2, mPwd2), sbRes[7])
+	testStandard(path.Join("vc", mUsr, mPwd, vault), sbRes[13])
+	testStandard(path.Join("vc", mUsr2, mPwd2, vault), sbRes[14])
+	testStandard(path.Join("vw", mUsr, mPwd, vault, "sharedID", "sharedPass"), sbRes[6])
+	testStandard(path.Join("vr", mUsr, mPwd, vault, "sharedID"), "sharedPass")
+	testStandard(path.Join("vr", mUsr2, mPwd2, vault, "sharedID"), sbRes[15])
+	testStandard(path.Join("vi", mUsr2, mPwd2, vault, mUsr), sbRes[15])
+	testStandard(path.Join("vi", mUsr, mPwd, vault, "nobody"), sbRes[16])
+	testStandard(path.Join("vi", mUsr, mPwd, vault, mUsr2), sbRes[17])
+	testStandard(path.Join("vr", mUsr2, mPwd2, vault, "sharedID"), sbRes[15])
+	testStandard(path.Join("va", mUsr2, "masterzzzzPi", vault), sbRes[2])
+	testStandard(path.Join("va", mUsr2, mPwd2, vault), sbRes[18])
+	testStandard(path.Join("vr", mUsr2, mPwd2, vault, "sharedID"), "sharedPass")
+	testStandard(path.Join("vr", mUsr2, mPwd2, vault), "sharedID")
+	testStandard(path.Join("vw", mUsr2, mPwd2, vault, "sharedID2", "sharedPass2"), sbRes[6])
+	testStandard(path.Join("vr", mUsr, mPwd, vault, "sharedID2"), "sharedPass2")
+	testStandard(path.Join("vx", mUsr2, mPwd2, vault, mUsr), sbRes[19])
+	testStandard(path.Join("vx", mUsr, mPwd, vault, mUsr2), sbRes[20])
+	testStandard(path.Join("vr", mUsr2, mPwd2, vault, "sharedID"), sbRes[15])
+
+	//the records are resealed under the rotated vault key
+	testStandard(path.Join("vr", mUsr, mPwd, vault, "sharedID"), "sharedPass")
+	testStandard(path.Join("vr", mUsr, mPwd, vault, "sharedID2"), "sharedPass2")
+
+	//concurrent requests each read with their own tree reader
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		for cIdName, cPassword := range map[string]string{"sharedID": "sharedPass", "sharedID2": "sharedPass2"} {
+			wg.Add(1)
+			go func(cIdName, cPassword string) {
+				defer wg.Done()
+
+				var slots [3]string
+				var tx2SpoofBroadcast [3]*string
+				for i := range slots {
+					tx2SpoofBroadcast[i] = &slots[i]
+				}
+				page := getUIoutput(app.newRequest().performOperation(path.Join("vr", mUsr, mPwd, vault, cIdName),
+					"127.0.0.1", tx2SpoofBroadcast))
+				output := string(page.Bytes())
+				page.Wipe()
+				if !strings.Contains(output, cPassword) || (cIdName == "sharedID" && strings.Contains(output, "sharedPass2")) {
+					t.Errorf("concurrent read of %s recieved: %s", cIdName, output)
+				}
+			}(cIdName, cPassword)
+		}
+	}
+	wg.Wait()
+
+	//test for onboarding and offboarding members of an organization collection
+	mUsr3 := "masterUsr3"
+	mPwd3 := "masterPwd3"
+	org := "acme"
+	collection := "infra"
+	testStandard(path.Join(register, mUsr3, mPwd3), sbRes[7])
+	testStandard(path.Join("oc", mUsr, mPwd, org), sbRes[21])
+	testStandard(path.Join("oc", mUsr2, mPwd2, org), sbRes[22])
+	testStandard(path.Join("vc", mUsr, mPwd, collection), sbRes[13])
+	testStandard(path.Join("oa", mUsr2, mPwd2, org, collection, "member,admin"), sbRes[23])
+	testStandard(path.Join("oa", mUsr, mPwd, org, collection, "member"), sbRes[24])
+	testStandard(path.Join("oa", mUsr, mPwd, org, collection, "member,admin"), sbRes[26])
+	testStandard(path.Join("vw", mUsr, mPwd, collection, "dbID", "dbPass"), sbRes[6])
+	testStandard(path.Join("vi", mUsr, mPwd, collection, mUsr2), sbRes[27])
+	testStandard(path.Join("os", mUsr, mPwd, org, mUsr2, "boss"), sbRes[24])
+	testStandard(path.Join("os", mUsr, mPwd, org, mUsr2, "member"), sbRes[25])
+	testStandard(path.Join("os", mUsr, mPwd, org, mUsr3, "admin"), sbRes[25])
+	testStandard(path.Join("vi", mUsr, mPwd, collection, mUsr3), sbRes[17])
+	testStandard(path.Join("va", mUsr3, mPwd3, collection), sbRes[18])
+	testStandard(path.Join("vi", mUsr3, mPwd3, collection, mUsr2), sbRes[17])
+	testStandard(path.Join("va", mUsr2, mPwd2, collection), sbRes[18])
+	testStandard(path.Join("vr", mUsr2, mPwd2, collection, "dbID"), "dbPass")
+	testStandard(path.Join("vw", mUsr2, mPwd2, collection, "dbID2", "dbPass2"), sbRes[27])
+	testStandard(path.Join("vw", mUsr3, mPwd3, collection, "dbID2", "dbPass2"), sbRes[6])
+	testStandard(path.Join("vr", mUsr, mPwd, collection, "dbID2"), "dbPass2")
+	testStandard(path.Join("os", mUsr2, mPwd2, org, mUsr3, "readonly"), sbRes[23])
+	testStandard(path.Join("ox", mUsr3, mPwd3, org, mUsr2), sbRes[28])
+	testStandard(path.Join("vr", mUsr2, mPwd2, collection, "dbID"), sbRes[15])
+	testStandard(path.Join("os", mUsr2, mPwd2, org, mUsr3, "readonly"), sbRes[23])
+
+	//lowering a role below the read role of a collection revokes the collection membership
+	testStandard(path.Join("os", mUsr, mPwd, org, mUsr3, "readonly"), sbRes[25])
+	testStandard(path.Join("vr", mUsr3, mPwd3, collection, "dbID"), sbRes[15])
+
+	//test for emergency access to a vault after a waiting period of two blocks
+	testStandard(path.Join("ve", mUsr2, mPwd2, vault, mUsr3, "2"), sbRes[15])
+	testStandard(path.Join("ve", mUsr, mPwd, vault, mUsr2, "2"), sbRes[29])
+	testStandard(path.Join("vq", mUsr3, mPwd3, vault), sbRes[30])
+	testStandard(path.Join("vk", mUsr2, mPwd2, vault), sbRes[31])
+	testStandard(path.Join("vq", mUsr2, mPwd2, vault), sbRes[32])
+	testStandard(path.Join("vk", mUsr2, mPwd2, vault), sbRes[33])
+	testStandard(path.Join("vw", mUsr, mPwd, vault, "sharedID3", "sharedPass3"), sbRes[6])
+	testStandard(path.Join("vk", mUsr2, mPwd2, vault), sbRes[33])
+	testStandard(path.Join("vw", mUsr, mPwd, vault, "sharedID4", "sharedPass4"), sbRes[6])
+	testStandard(path.Join("vk", mUsr2, mPwd2, vault), sbRes[34])
+	testStandard(path.Join("vr", mUsr2, mPwd2, vault, "sharedID3"), "sharedPass3")
+
+	//test for the owner cancelling emergency access during the waiting period
+	testStandard(path.Join("ve", mUsr, mPwd, vault, mUsr3, "2"), sbRes[29])
+	testStandard(path.Join("vq", mUsr3, mPwd3, vault), sbRes[32])
+	testStandard(path.Join("vn", mUsr3, mPwd3, vault, mUsr3), sbRes[15])
+	testStandard(path.Join("vn", mUsr, mPwd, vault, mUsr3), sbRes[35])
+	testStandard(path.Join("vw", mUsr, mPwd, vault, "sharedID5", "sharedPass5"), sbRes[6])
+	testStandard(path.Join("vw", mUsr, mPwd, vault, "sharedID6", "sharedPass6"), sbRes[6])
+	testStandard(path.Join("vk", mUsr3, mPwd3, vault), sbRes[30])
+	testStandard(path.Join("vr", mUsr3, mPwd3, vault, "sharedID3"), sbRes[15])
+
+	//test for recovering an account with a recovery code, after which all previous codes are invalid.
+	//  The vault keys wrapped to the account are re-wrapped to its new public key
+	mUsr4 := "masterUsr4"
+	mPwd4 := "masterPwd4"
+	mPwd4New := "masterPwd4New"
+	testStandard(path.Join(register, mUsr4, mPwd4, "many"), sbRes[1])
+	codes := getListed(testStandard(path.Join(register, mUsr4, mPwd4, "2"), sbRes[7]), "recovery code: ")
+	if len(codes) != 2 {
+		t.Errorf("expected 2 recovery codes at registration, recieved: " + strconv.Itoa(len(codes)))
+		return
+	}
+	testStandard(path.Join(write, mUsr4, mPwd4, "recID", "recPass")+"?username=carol", sbRes[6])
+	testStandard(path.Join("vc", mUsr4, mPwd4, "recVault"), sbRes[13])
+	testStandard(path.Join("vw", mUsr4, mPwd4, "recVault", "recVaultID", "recVaultPass"), sbRes[6])
+	testStandard(path.Join("k", mUsr4, "aaaaa-aaaaa-aaaaa-aaaaa", mPwd4New), sbRes[2])
+	newCodes := getListed(testStandard(path.Join("k", mUsr4, codes[0], mPwd4New), sbRes[36]), "recovery code: ")
+	if len(newCodes) < 1 {
+		t.Errorf("expected new recovery codes after recovery")
+		return
+	}
+	testStandard(path.Join(read, mUsr4, mPwd4, "recID"), sbRes[2])
+	testStandard(path.Join(read, mUsr4, mPwd4New, "recID"), "recPass")
+	testStandard(path.Join(read, mUsr4, mPwd4New, "recID"), "username: carol")
+	testStandard(path.Join("vr", mUsr4, mPwd4New, "recVault", "recVaultID"), "recVaultPass")
+	testStandard(path.Join("k", mUsr4, codes[0], mPwd4), sbRes[2])
+	testStandard(path.Join("k", mUsr4, codes[1], mPwd4), sbRes[2])
+	testStandard(path.Join("k", mUsr4, newCodes[0], mPwd4), sbRes[36])
+	testStandard(path.Join(read, mUsr4, mPwd4, "recID"), "recPass")
+	testStandard(path.Join("vr", mUsr4, mPwd4, "recVault", "recVaultID"), "recVaultPass")
+
+	//codes generated before recovery keys, which wrap the master password, still recover the account
+	oldCode := "bbbbb-bbbbb-bbbbb-bbbbb"
+	oldCodeEncrypted, _ := cry.GetEncryptedHexString(tre.HashInputRecoveryCode(mUsr4, oldCode), mPwd4)
+	ptw.SetVariables(cry.GetHashedHexString(mUsr4), "", "")
+	ptw.SetRecoveryCodes([]tre.RecoveryCode{{
+		CodeHashed:           cry.GetHashedHexString(tre.HashInputRecoveryCodeID(mUsr4, oldCode)),
+		RecoveryKeyEncrypted: oldCodeEncrypted,
+	}})
+	newCodes = getListed(testStandard(path.Join("k", mUsr4, oldCode, mPwd4New), sbRes[36]), "recovery code: ")
+	testStandard(path.Join("vr", mUsr4, mPwd4New, "recVault", "recVaultID"), "recVaultPass")
+	testStandard(path.Join("k", mUsr4, newCodes[0], mPwd4), sbRes[36])
+
+	//test for reconstructing a vault key split 2-of-3 across custodians
+	mUsr5 := "masterUsr5"
+	mPwd5 := "masterPwd5"
+	testStandard(path.Join(register, mUsr5, mPwd5), sbRes[7])
+	testStandard(path.Join("vs", mUsr2, mPwd2, vault, "2", mUsr3+","+mUsr4), sbRes[19])
+	testStandard(path.Join("vs", mUsr, mPwd, vault, "3", mUsr2+","+mUsr3), sbRes[38])
+	testStandard(path.Join("vs", mUsr, mPwd, vault, "2", mUsr2+","+mUsr3+","+mUsr4), sbRes[37])
+	testStandard(path.Join("vl", mUsr2, mPwd2, vault), sbRes[40])
+	testStandard(path.Join("vo", mUsr2, mPwd2, vault), sbRes[45])
+	testStandard(path.Join("vo", mUsr5, mPwd5, vault), sbRes[39])
+
+	//a pending request may not be replaced, only cancelled by the requester or the vault owner
+	testStandard(path.Join("vo", mUsr5, mPwd5, vault), sbRes[63])
+	testStandard(path.Join("vu", mUsr3, mPwd3, vault), sbRes[19])
+	testStandard(path.Join("vu", mUsr5, mPwd5, vault), sbRes[68])
+	testStandard(path.Join("vo", mUsr5, mPwd5, vault), sbRes[39])
+
+	//shares are only released once the vault owner approves the request, and only to the
+	//  requester named by the custodian
+	testStandard(path.Join("vl", mUsr3, mPwd3, vault, mUsr5), sbRes[65])
+	testStandard(path.Join("vp", mUsr2, mPwd2, vault, mUsr5), sbRes[19])
+	testStandard(path.Join("vp", mUsr, mPwd, vault, mUsr2), sbRes[66])
+	testStandard(path.Join("vp", mUsr, mPwd, vault, mUsr5), sbRes[67])
+	testStandard(path.Join("vp", mUsr, mPwd, vault, mUsr5), sbRes[64])
+	testStandard(path.Join("vb", mUsr5, mPwd5, vault), sbRes[42])
+	testStandard(path.Join("vl", mUsr5, mPwd5, vault, mUsr5), sbRes[41])
+	testStandard(path.Join("vl", mUsr3, mPwd3, vault), sbRes[66])
+	testStandard(path.Join("vl", mUsr3, mPwd3, vault, mUsr2), sbRes[66])
+	testStandard(path.Join("vl", mUsr3, mPwd3, vault, mUsr5), sbRes[43]+" to "+mUsr5)
+	testStandard(path.Join("vb", mUsr5, mPwd5, vault), sbRes[42])
+	testStandard(path.Join("vl", mUsr4, mPwd4, vault, mUsr5), sbRes[43])
+	testStandard(path.Join("vb", mUsr5, mPwd5, vault), sbRes[44])
+	testStandard(path.Join("vr", mUsr5, mPwd5, vault, "sharedID"), "sharedPass")
+
+	//test for enrolling in two-factor authentication, reading with a totp code and
+	//  single-use backup codes, then disabling two-factor authentication
+	mUsr6 := "masterUsr6"
+	mPwd6 := "masterPwd6"
+	testStandard(path.Join(register, mUsr6, mPwd6), sbRes[7])
+	testStandard(path.Join(write, mUsr6, mPwd6, "tfaID", "tfaPass"), sbRes[6])
+	secret := getListed(testStandard(path.Join("t", mUsr6, mPwd6), sbRes[46]), "two-factor secret: ")[0]
+	testStandard(path.Join("t", mUsr6, mPwd6, secret, "notACode"), sbRes[47])
+	backupCodes := getListed(testStandard(path.Join("t", mUsr6, mPwd6, secret, getTOTPCode(secret)), sbRes[48]), "backup code: ")
+	testStandard(path.Join("t", mUsr6, mPwd6)+"?2fa="+getTOTPCode(secret), sbRes[50])
+	testStandard(path.Join(read, mUsr6, mPwd6, "tfaID"), sbRes[49])
+	testStandard(path.Join(read, mUsr6, mPwd6, "tfaID")+"?2fa=notACode", sbRes[47])
+	testStandard(path.Join(read, mUsr6, "wrongPwd", "tfaID")+"?2fa="+getTOTPCode(secret), sbRes[2])
+	totpCode := getTOTPCode(secret)
+	testStandard(path.Join(read, mUsr6, mPwd6, "tfaID")+"?2fa="+totpCode, "tfaPass")
+	testStandard(path.Join(read, mUsr6, mPwd6, "tfaID")+"?2fa="+totpCode, sbRes[47])
+	testStandard(path.Join(read, mUsr6, mPwd6, "tfaID")+"?2fa="+backupCodes[0], "tfaPass")
+	testStandard(path.Join(read, mUsr6, mPwd6, "tfaID")+"?2fa="+backupCodes[0], sbRes[47])
+	testStandard(path.Join(write, mUsr6, mPwd6, "tfaID2", "tfaPass2")+"?2fa="+backupCodes[1], sbRes[6])
+	testStandard(path.Join(read, mUsr6, mPwd6, "tfaID2", "2fa")+"?2fa="+getTOTPCode(secret), sbRes[10])
+
+	//test for logging in, the second factor is only required when logging in
+	testStandard(path.Join("i", mUsr6, mPwd6), sbRes[49])
+	token := getListed(testStandard(path.Join("i", mUsr6, mPwd6)+"?2fa="+getTOTPCode(secret), sbRes[53]), "session token: ")[0]
+	if output := testStandard(path.Join(read, "tfaID")+"?session="+token, "tfaPass"); strings.Contains(output, mPwd6) {
+		t.Errorf("session output contains the master password")
+	}
+	testStandard(path.Join(write, "sessionID", "sessionPass")+"?session="+token, sbRes[6])
+	testStandard(path.Join(read, "sessionID")+"?session="+token, "sessionPass")
+	testStandard(path.Join("vc", "sessionVault")+"?session="+token, sbRes[13])
+	testStandard(path.Join("vw", "sessionVault", "vaultID", "vaultPass")+"?session="+token, sbRes[6])
+	testStandard(path.Join("vr", "sessionVault", "vaultID")+"?session="+token, "vaultPass")
+	testStandard(path.Join("m", "argon2id")+"?session="+token, sbRes[61])
+	testStandard(path.Join(read, "tfaID")+"?session=notAToken", sbRes[55])
+	testStandard("q?session="+token, sbRes[54])
+	testStandard(path.Join(read, "tfaID")+"?session="+token, sbRes[55])
+	testStandard(path.Join("f", mUsr6, mPwd6), sbRes[49])
+	testStandard(path.Join("f", mUsr6, mPwd6)+"?2fa="+getTOTPCode(secret), sbRes[47])
+	testStandard(path.Join("f", mUsr6, mPwd6, backupCodes[0])+"?2fa="+getTOTPCode(secret), sbRes[47])
+	testStandard(path.Join("f", mUsr6, mPwd6, backupCodes[2])+"?2fa="+backupCodes[2], sbRes[47])
+	testStandard(path.Join("f", mUsr6, mPwd6, backupCodes[2])+"?2fa="+getTOTPCode(secret), sbRes[51])
+	testStandard(path.Join(read, mUsr6, mPwd6, "tfaID2"), "tfaPass2")
+	testStandard(path.Join("f", mUsr6, mPwd6), sbRes[52])
+
+	//test for logging out all the sessions of an account with the master password
+	tokens := []string{
+		getListed(testStandard(path.Join("i", mUsr6, mPwd6), sbRes[53]), "session token: ")[0],
+		getListed(testStandard(path.Join("i", mUsr6, mPwd6), sbRes[53]), "session token: ")[0],
+	}
+	testStandard(path.Join("i", mUsr6, "wrongPwd"), sbRes[2])
+	testStandard(path.Join(read, "tfaID")+"?session="+tokens[1], "tfaPass")
+	testStandard(path.Join("q", mUsr6, mPwd6), sbRes[54])
+	for _, token := range tokens {
+		testStandard(path.Join(read, "tfaID")+"?session="+token, sbRes[55])
+	}
+
+	//test for migrating an account, along with its two-factor secret, and a vault between
+	//  cipher suites. Only those who manage a vault may migrate its suite. The published keys
+	//  of the account are replaced, and the vault keys wrapped to it are re-wrapped
+	mUsr7 := "masterUsr7"
+	mPwd7 := "masterPwd7"
+	testStandard(path.Join(register, mUsr7, mPwd7), sbRes[7])
+	testStandard(path.Join(write, mUsr7, mPwd7, "suiteID", "suitePass")+"?username=dave", sbRes[6])
+	secret7 := getListed(testStandard(path.Join("t", mUsr7, mPwd7), sbRes[46]), "two-factor secret: ")[0]
+	testStandard(path.Join("t", mUsr7, mPwd7, secret7, getTOTPCode(secret7)), sbRes[48])
+	tfa7 := func() string { return "?2fa=" + getTOTPCode(secret7) }
+	testStandard(path.Join("vc", mUsr7, mPwd7, "keptVault")+tfa7(), sbRes[13])
+	testStandard(path.Join("vw", mUsr7, mPwd7, "keptVault", "keptID", "keptPass")+tfa7(), sbRes[6])
+	publicKey7, _ := app.ptr.RetrievePublicKey(cry.GetHashedHexString(mUsr7))
+	testStandard(path.Join("m", mUsr7, mPwd7, "rot13")+tfa7(), sbRes[58])
+	testStandard(path.Join("m", mUsr7, mPwd7, "legacy")+tfa7(), sbRes[57])
+	testStandard(path.Join("m", mUsr7, mPwd7, "argon2id")+tfa7(), sbRes[59])
+	testStandard(path.Join("m", mUsr7, mPwd7, "argon2id")+tfa7(), sbRes[57])
+	if publicKey, _ := app.ptr.RetrievePublicKey(cry.GetHashedHexString(mUsr7)); publicKey == publicKey7 {
+		t.Errorf("the public key was not replaced by the migration")
+	}
+	testStandard(path.Join("vr", mUsr7, mPwd7, "keptVault", "keptID")+tfa7(), "keptPass")
+	testStandard(path.Join(read, mUsr7, "wrongPwd", "suiteID")+tfa7(), sbRes[2])
+	testStandard(path.Join(read, mUsr7, mPwd7, "suiteID")+tfa7(), "suitePass")
+	testStandard(path.Join(read, mUsr7, mPwd7, "suiteID")+tfa7(), "username: dave")
+	testStandard(path.Join(write, mUsr7, mPwd7, "suiteID2", "suitePass2")+tfa7(), sbRes[6])
+	testStandard(path.Join(read, mUsr7, mPwd7, "suiteID2")+tfa7(), "suitePass2")
+	testStandard(path.Join("vc", mUsr7, mPwd7, "suiteVault")+tfa7(), sbRes[13])
+	testStandard(path.Join("vw", mUsr7, mPwd7, "suiteVault", "vaultID", "vaultPass")+tfa7(), sbRes[6])
+	testStandard(path.Join("vm", mUsr2, mPwd2, "suiteVault", "argon2id"), sbRes[15])
+	testStandard(path.Join("vm", mUsr7, mPwd7, "suiteVault", "argon2id")+tfa7(), sbRes[59])
+	testStandard(path.Join("vr", mUsr7, mPwd7, "suiteVault", "vaultID")+tfa7(), "vaultPass")
+
+	//test for migrating accounts to the configured cipher suite as they log in
+	app.migrate = true
+	testStandard(path.Join("i", mUsr7, mPwd7)+tfa7(), sbRes[53])
+	testStandard(path.Join("m", mUsr7, mPwd7, "legacy")+tfa7(), sbRes[57])
+	testStandard(path.Join(read, mUsr7, mPwd7, "suiteID2")+tfa7(), "suitePass2")
+	testStandard(path.Join("vr", mUsr7, mPwd7, "keptVault", "keptID")+tfa7(), "keptPass")
+	app.migrate = false
+
+	//test for recovering an account enrolled in two-factor authentication, the second factor is
+	//  required and the enrollment is kept along with the history of each record
+	mUsr10 := "masterUsr10"
+	mPwd10 := "masterPwd10"
+	mPwd10New := "masterPwd10New"
+	codes10 := getListed(testStandard(path.Join(register, mUsr10, mPwd10, "1"), sbRes[7]), "recovery code: ")
+	secret10 := getListed(testStandard(path.Join("t", mUsr10, mPwd10), sbRes[46]), "two-factor secret: ")[0]
+	backupCodes10 := getListed(testStandard(path.Join("t", mUsr10, mPwd10, secret10, getTOTPCode(secret10)), sbRes[48]),
+		"backup code: ")
+	tfa10 := func() string { return "?2fa=" + getTOTPCode(secret10) }
+	testStandard(path.Join(write, mUsr10, mPwd10, "histID", "histPass1")+tfa10(), sbRes[6])
+	testStandard(path.Join(write, mUsr10, mPwd10, "histID", "histPass2")+tfa10(), sbRes[6])
+	testStandard(path.Join("k", mUsr10, codes10[0], mPwd10New), sbRes[49])
+	testStandard(path.Join("k", mUsr10, codes10[0], mPwd10New)+"?2fa=notACode", sbRes[47])
+	testStandard(path.Join("k", mUsr10, codes10[0], mPwd10New)+"?2fa="+backupCodes10[0], sbRes[36])
+	testStandard(path.Join(read, mUsr10, mPwd10New, "histID"), sbRes[49])
+	testStandard(path.Join(read, mUsr10, mPwd10New, "histID")+tfa10(), "histPass2")
+	testStandard(path.Join(read, mUsr10, mPwd10New, "histID")+"?2fa="+backupCodes10[0], sbRes[47])
+	testStandard(path.Join(read, mUsr10, mPwd10New, "histID")+"?2fa="+backupCodes10[1], "histPass2")
+	app.ptr.SetVariables(cry.GetHashedHexString(mUsr10), "histID", tre.HashInputCIdNameEncryption(mUsr10, mPwd10New),
+		tre.HashInputCPasswordEncryption(mUsr10, mPwd10New, "histID"))
+	history10, err := app.ptr.RetrieveCRecordHistorySecrets(false)
+	if err != nil || len(history10) != 1 || string(history10[0][tre.FieldPassword].Bytes()) != "histPass1" {
+		t.Errorf("the record history was not kept by the recovery: %v", err)
+	}
+	for _, previous := range history10 {
+		tre.WipeRecord(previous)
+	}
+
+	//test for creating accounts and vaults with the configured cipher suite
+	mUsr8 := "masterUsr8"
+	mPwd8 := "masterPwd8"
+	app.suite = cry.Argon2idCipherSuite
+	testStandard(path.Join(register, mUsr8, mPwd8, "1"), sbRes[7])
+	testStandard(path.Join(write, mUsr8, mPwd8, "suiteID", "suitePass"), sbRes[6])
+	testStandard(path.Join(read, mUsr8, mPwd8, "suiteID"), "suitePass")
+	testStandard(path.Join("m", mUsr8, mPwd8, "argon2id"), sbRes[57])
+	testStandard(path.Join("vc", mUsr8, mPwd8, "suiteVault2"), sbRes[13])
+	testStandard(path.Join("vm", mUsr8, mPwd8, "suiteVault2", "argon2id"), sbRes[57])
+	app.suite = cry.LegacyCipherSuite
+	testStandard(path.Join(read, mUsr8, mPwd8, "suiteID"), "suitePass")
+
+	//perform an end-to-end request of the hex encoded request along with any relayed tx
+	testEndToEnd := func(endpoint, request, expectedContains string) string {
+
+		testNo += 1
+
+		var relayed string
+		var tx2SpoofBroadcast [3]*string
+		tx2SpoofBroadcast[0] = &relayed
+
+		output, err := app.newRequest().performEndToEnd(path.Join(endpoint, hex.EncodeToString([]byte(request))),
+			"127.0.0.1", tx2SpoofBroadcast)
+		if err != nil {
+			output = "error: " + err.Error()
+		}
+		if len(relayed) > 0 {
+			tmsp.TestspoofBroadcast([]byte(relayed), ptw)
+		}
+
+		if !strings.Contains(output, expectedContains) {
+			t.Errorf("test number: " + strconv.Itoa(testNo))
+			t.Errorf("error expected: " + expectedContains + " recieved: " + output)
+		}
+		return output
+	}
+
+	//test for end-to-end mode, where the master username/password are never provided
+	mUsr9 := "masterUsr9"
+	mPwd9 := "masterPwd9"
+	mUsr9Hashed := cry.GetHashedHexString(mUsr9)
+	signer9 := txSigner{mUsr9Hashed, getAccountKeys(mUsr9, mPwd9).signingKey}
+	signer2 := txSigner{cry.GetHashedHexString(mUsr2), getAccountKeys(mUsr2, mPwd2).signingKey}
+	testStandard(path.Join(register, mUsr9, mPwd9), sbRes[7])
+	app.e2e = true
+	testStandard(path.Join(read, mUsr9, mPwd9), sbRes[60])
+	app.e2e = false
+
+	twoFactorReadRequest := func(signer txSigner, targetHashed, cIdNameHashed, twoFactor string, timestamp time.Time) string {
+		return signer.sign(path.Join(timestamp.UTC().Format(tre.EndToEndTimeFormat), "reading", targetHashed, cIdNameHashed,
+			twoFactor))
+	}
+	readRequest := func(signer txSigner, targetHashed, cIdNameHashed string, timestamp time.Time) string {
+		return twoFactorReadRequest(signer, targetHashed, cIdNameHashed, "-", timestamp)
+	}
+	output := testEndToEnd("read", readRequest(signer9, mUsr9Hashed, "-", time.Now()), "suite: legacy")
+	state, err := tre.DecodeEndToEndState(output)
+	if err != nil || len(state.VerifierEncrypted) < 1 || len(state.CIdList) > 1 {
+		t.Errorf("bad end-to-end state: %v", err)
+	}
+	testEndToEnd("read", readRequest(signer9, mUsr9Hashed, "-", time.Now().Add(-time.Hour)), "staleRequest")
+	testEndToEnd("read", readRequest(signer2, mUsr9Hashed, "-", time.Now()), "notVaultMember")
+	forged := readRequest(signer2, mUsr9Hashed, "-", time.Now())
+	forged = path.Join(path.Dir(path.Dir(forged)), mUsr9Hashed, path.Base(forged))
+	testEndToEnd("read", forged, "badAuthentication")
+	testEndToEnd("read", "notHex", "generalError")
+
+	//test for relaying txs encrypted by the client, which must be signed by the account
+	e2eCIdHashed := cry.GetHashedHexString("e2eID")
+	e2eCIdEncrypted, e2eRecordEncrypted, err := getEncryptedEntry(cry.LegacyCipherSuite,
+		tre.HashInputCIdNameEncryption(mUsr9, mPwd9), tre.HashInputCPasswordEncryption(mUsr9, mPwd9, "e2eID"),
+		mUsr9Hashed, e2eCIdHashed, "e2eID", map[string]string{tre.FieldPassword: "e2ePass"})
+	if err != nil {
+		t.Errorf("encrypting the end-to-end record: %v", err)
+	}
+	writeTx := path.Join(now(), "writing", mUsr9Hashed, e2eCIdHashed, e2eCIdEncrypted, e2eRecordEncrypted)
+	testEndToEnd("tx", writeTx, "badAuthentication")
+	testEndToEnd("tx", signer2.sign(writeTx), "badAuthentication")
+	testEndToEnd("tx", signer9.sign(writeTx), "result: relayed")
+	testStandard(path.Join(read, mUsr9, mPwd9, "e2eID"), "e2ePass")
+	output = testEndToEnd("read", readRequest(signer9, mUsr9Hashed, e2eCIdHashed, time.Now()), "record: ")
+	state, _ = tre.DecodeEndToEndState(output)
+	fields, err := tre.ReadDecryptedRecord(tre.HashInputCPasswordEncryption(mUsr9, mPwd9, "e2eID"),
+		mUsr9Hashed, e2eCIdHashed, state.CRecordEncrypted)
+	if err != nil || fields[tre.FieldPassword] != "e2ePass" {
+		t.Errorf("bad end-to-end record: %v", err)
+	}
+	testEndToEnd("tx", signer2.sign(path.Join(now(), "deletingAccount", mUsr9Hashed)), "badAuthentication")
+	testStandard(path.Join(read, mUsr9, mPwd9, "e2eID"), "e2ePass")
+	testEndToEnd("tx", signer9.sign(path.Join(now(), "deletingAccount", mUsr9Hashed)), "result: relayed")
+	testStandard(path.Join(read, mUsr9, mPwd9), sbRes[2])
+
+	//test for the second factor of end-to-end reads, a totp code opens a session which
+	//  is provided in place of further codes
+	mUsr11 := "masterUsr11"
+	mPwd11 := "masterPwd11"
+	mUsr11Hashed := cry.GetHashedHexString(mUsr11)
+	signer11 := txSigner{mUsr11Hashed, getAccountKeys(mUsr11, mPwd11).signingKey}
+	testStandard(path.Join(register, mUsr11, mPwd11), sbRes[7])
+	secret11 := getListed(testStandard(path.Join("t", mUsr11, mPwd11), sbRes[46]), "two-factor secret: ")[0]
+	testStandard(path.Join("t", mUsr11, mPwd11, secret11, getTOTPCode(secret11)), sbRes[48])
+	twoFactor11 := func(totpCode string) string {
+		return tre.EncodeEndToEndTwoFactor(tre.TwoFactorKey(mUsr11, mPwd11), totpCode)
+	}
+	testEndToEnd("read", readRequest(signer11, mUsr11Hashed, "-", time.Now()), "twoFactorRequired")
+	testEndToEnd("read", twoFactorReadRequest(signer11, mUsr11Hashed, "-", twoFactor11("000000"), time.Now()), "badTwoFactor")
+	testEndToEnd("read", twoFactorReadRequest(signer11, mUsr11Hashed, "-",
+		tre.EncodeEndToEndTwoFactor(tre.TwoFactorKey(mUsr11, "wrongPwd"), getTOTPCode(secret11)), time.Now()), "badTwoFactor")
+	totpCode11 := getTOTPCode(secret11)
+	output = testEndToEnd("read", twoFactorReadRequest(signer11, mUsr11Hashed, "-", twoFactor11(totpCode11), time.Now()),
+		"session: ")
+	state, _ = tre.DecodeEndToEndState(output)
+	if len(state.VerifierEncrypted) < 1 || len(state.Session) < 1 {
+		t.Errorf("bad end-to-end state opening a session: %v", state)
+	}
+	testEndToEnd("read", twoFactorReadRequest(signer11, mUsr11Hashed, "-", twoFactor11(totpCode11), time.Now()), "badTwoFactor")
+	testEndToEnd("read", twoFactorReadRequest(signer11, mUsr11Hashed, "-", state.Session, time.Now()), "verifier: ")
+	testEndToEnd("read", twoFactorReadRequest(signer11, mUsr11Hashed, "-", "notAToken", time.Now()), "badSession")
+	testEndToEnd("read", twoFactorReadRequest(signer2, signer2.usernameHashed, "-", state.Session, time.Now()), "badSession")
+	testStandard(path.Join(read, mUsr11, mPwd11)+"?session="+state.Session, sbRes[55])
+
+	//test for locking out an account, or an unknown username, after repeated failures
+	app.limiter = newAuthLimiter(AuthLimits{MaxFailures: 2, MaxClientFailures: 100, Lockout: time.Hour})
+	for _, username := range []string{mUsr, "unknownUsr"} {
+		testStandard(path.Join(read, username, "wrongPwd"), sbRes[2])
+		testStandard(path.Join(read, username, "wrongPwd"), sbRes[2])
+		testStandard(path.Join(read, username, mPwd), sbRes[56])
+	}
+	testStandard(path.Join(read, mUsr2, mPwd2), sbRes[4])
+	app.limiter = nil
+
+	//test for reporting authentication failures uniformly, with the detailed
+	//  reasons only held within the audit log
+	var auditLog bytes.Buffer
+	app.auditLog = NewAuditLog(&auditLog)
+	app.uniform = true
+	testStandard(path.Join(read, "unknownUsr", mPwd), sbRes[2])
+	testStandard(path.Join(read, mUsr, mPwd, "unknownID"), sbRes[2])
+	testStandard(path.Join(read, mUsr6, mPwd6, "tfaID")+"?session=notAToken", sbRes[2])
+	testStandard(path.Join(read, mUsr, mPwd, cId[1]), cPwd[1])
+	for _, reason := range []string{"badAuthentication", "invalidCIdName", "badSession"} {
+		if !strings.Contains(auditLog.String(), "reason="+reason) {
+			t.Errorf("audit log is missing the reason " + reason)
+		}
+	}
+	if strings.Contains(auditLog.String(), "unknownUsr") {
+		t.Errorf("audit log contains an unhashed username")
+	}
+	app.auditLog = nil
+	app.uniform = false
+
 	//test for invalid retrieval
 	testStandard(path.Join(read, mUsr, mPwd, "sdfaasdf"), sbRes[3])
 
@@ -129,9 +740,286 @@ func TestUi(t *testing.T) {
 	//test to make sure deletion actually deleted
 	testStandard(path.Join(read, mUsr, mPwd, cId[0]), sbRes[3])
 
-	//test deletion of all passwords which should also delete the user account
+	//test deletion of all passwords which should not delete the user account
 	testStandard(path.Join(delete, mUsr, mPwd, cId[1]), sbRes[5])
+	testStandard(path.Join(read, mUsr, mPwd), sbRes[4])
+
+	//test for deleting the account with a bad password, then a good password
+	testStandard(path.Join(deleteAccount, mUsr, "masterzzzzPi"), sbRes[2])
+	testStandard(path.Join(deleteAccount, mUsr, mPwd), sbRes[9])
 
-	//test that the user account has been deleted
+	//test that the user account has been deleted, and its username retired
 	testStandard(path.Join(read, mUsr, mPwd), sbRes[2])
+	testStandard(path.Join(register, mUsr, mPwd), sbRes[62])
+}
+
+func TestSessionStore(t *testing.T) {
+
+	sessions := newSessionStore(time.Hour, time.Hour)
+	token, err := sessions.create("username", getAccountKeys("username", "keyPassword"))
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	//sessions hold the derived keys rather than the master password
+	urlUsername, keys, err := sessions.get(token)
+	if err != nil || urlUsername != "username" || keys.keyPassword != "keyPassword" ||
+		!bytes.Equal(keys.signingKey, getAccountKeys("username", "keyPassword").signingKey) ||
+		*keys.boxPrivateKey != *getAccountKeys("username", "keyPassword").boxPrivateKey {
+		t.Errorf("session does not hold the account keys")
+	}
+	keys.wipe()
+	if _, keys, _ = sessions.get(token); !bytes.Equal(keys.signingKey, getAccountKeys("username", "keyPassword").signingKey) {
+		t.Errorf("wiping the retrieved keys wiped the keys held by the session")
+	}
+
+	//expired sessions should be wiped
+	heldKeyPassword := sessions.sessions[cry.GetHashedHexString(token)].keyPassword.Bytes()
+	heldSigningKey := sessions.sessions[cry.GetHashedHexString(token)].signingKey.Bytes()
+	sessions.maxLifetime = 0
+	time.Sleep(time.Millisecond)
+	sessions.expire()
+	if _, _, err := sessions.get(token); err == nil {
+		t.Errorf("expired session does not produce an error")
+	}
+	if string(heldKeyPassword) != strings.Repeat("\x00", len("keyPassword")) ||
+		string(heldSigningKey) != strings.Repeat("\x00", len(heldSigningKey)) {
+		t.Errorf("expired session was not wiped")
+	}
+
+	//idle sessions should expire upon use
+	sessions = newSessionStore(0, time.Hour)
+	token, err = sessions.create("username", getAccountKeys("username", "keyPassword"))
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	time.Sleep(time.Millisecond)
+	if _, _, err := sessions.get(token); err == nil {
+		t.Errorf("idle session does not produce an error")
+	}
+
+	//the sweeps of a closed app stop
+	app := newUIApp(tre.PwkTreeReader{}, "", audit.BreachedList{}, time.Hour, time.Hour, AuthLimits{}, nil, false,
+		cry.LegacyCipherSuite, false, false, true, nil)
+	stopped := make(chan struct{})
+	go func() {
+		app.sessions.sweep(time.Millisecond, app.done)
+		app.limiter.sweep(time.Millisecond, app.done)
+		close(stopped)
+	}()
+	app.close()
+	app.close()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Errorf("sweeps of a closed app did not stop")
+	}
+}
+
+func TestTLS(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "passwerkTLS")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	//generate self-signed certificates for the server, and for a client which also acts
+	//  as the certificate authority of client certificates
+	serverCert, serverKey := path.Join(dir, "server.pem"), path.Join(dir, "serverKey.pem")
+	clientCert, clientKey := path.Join(dir, "client.pem"), path.Join(dir, "clientKey.pem")
+	for _, files := range [][2]string{{serverCert, serverKey}, {clientCert, clientKey}} {
+		err = GenerateSelfSignedCert(files[0], files[1], []string{"localhost", "127.0.0.1"})
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+
+	tlsConfig, err := LoadTLSConfig(serverCert, serverKey, clientCert)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("passwerk"))
+	}))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	rootCAs, err := LoadCertPool(serverCert)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	getWithCerts := func(certs []tls.Certificate) error {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      rootCAs,
+			Certificates: certs,
+		}}}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	//clients must present a certificate signed by the client certificate authority
+	if getWithCerts(nil) == nil {
+		t.Errorf("client without a certificate does not produce an error")
+	}
+	cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if err = getWithCerts([]tls.Certificate{cert}); err != nil {
+		t.Errorf(err.Error())
+	}
+
+	//plain HTTP requests should be redirected to the HTTPS port
+	recorder := httptest.NewRecorder()
+	redirectHandler("8443").ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8080/r/usr/pwd", nil))
+	if location := recorder.Header().Get("Location"); location != "https://localhost:8443/r/usr/pwd" {
+		t.Errorf("bad redirect location: " + location)
+	}
+}
+
+func TestAuthLimiter(t *testing.T) {
+
+	limiter := newAuthLimiter(AuthLimits{
+		MaxFailures:       3,
+		MaxClientFailures: 5,
+		Backoff:           time.Second,
+		Lockout:           time.Minute,
+	})
+	now := time.Unix(0, 0)
+	limiter.now = func() time.Time { return now }
+
+	//accounts back off exponentially until locked out
+	for i, backoff := range []time.Duration{time.Second, 2 * time.Second, time.Minute} {
+		limiter.fail("account", "client")
+		if limiter.allow("account", "otherClient") == nil {
+			t.Errorf("account not blocked after failure " + strconv.Itoa(i+1))
+		}
+		now = now.Add(backoff)
+		if limiter.allow("account", "otherClient") != nil {
+			t.Errorf("account still blocked after backoff " + strconv.Itoa(i+1))
+		}
+	}
+
+	//successful authentication resets the failures of an account
+	limiter.fail("account", "client")
+	limiter.succeed("account")
+	if limiter.allow("account", "otherClient") != nil {
+		t.Errorf("account blocked after success")
+	}
+
+	//clients are locked out once their failures accumulate across accounts
+	limiter.fail("account2", "client")
+	if limiter.allow("account3", "client") == nil {
+		t.Errorf("client not blocked after accumulated failures")
+	}
+	now = now.Add(time.Minute + time.Second)
+	if limiter.allow("account3", "client") != nil {
+		t.Errorf("client still blocked after lockout")
+	}
+
+	//records are forgotten once a lockout has passed since their last failure
+	now = now.Add(time.Minute)
+	limiter.expire()
+	if len(limiter.accounts) > 0 || len(limiter.clients) > 0 {
+		t.Errorf("records were not forgotten")
+	}
+}
+
+//the UI paths of the tests above, acting on the account of the fuzz state
+var fuzzSeedURLs = []string{
+	"asd:SDF%$%^fgsadf",
+	"",
+	"/",
+	"n/fuzzUsr2/fuzzPwd2",
+	"r/fuzzUsr/fuzzPwd",
+	"r/fuzzUsr/fuzzPwd/fuzzID",
+	"r/fuzzUsr/fuzzPwd/fuzzID/password",
+	"w/fuzzUsr/fuzzPwd/fuzzID2/fuzzPass2",
+	"d/fuzzUsr/fuzzPwd/fuzzID",
+	"g/fuzzUsr/fuzzPwd/fuzzID3/16",
+	"h/fuzzUsr/fuzzPwd",
+	"x/fuzzUsr/fuzzPwd",
+	"t/fuzzUsr/fuzzPwd",
+	"k/fuzzUsr/fuzzPwd",
+	"i/fuzzUsr/fuzzPwd",
+	"f/fuzzUsr/fuzzPwd/fuzzID/fuzzField",
+	"m/fuzzUsr/fuzzPwd/argon2id",
+	"q?session=token",
+	"vc/fuzzUsr/fuzzPwd/fuzzVault2",
+	"vr/fuzzUsr/fuzzPwd/fuzzVault",
+	"vw/fuzzUsr/fuzzPwd/fuzzVault/fuzzID/fuzzPass",
+	"vi/fuzzUsr/fuzzPwd/fuzzVault/fuzzUsr2",
+	"ve/fuzzUsr/fuzzPwd/fuzzVault/fuzzUsr2/1",
+	"vs/fuzzUsr/fuzzPwd/fuzzVault/fuzzUsr2/1",
+	"vm/fuzzUsr/fuzzPwd/fuzzVault/argon2id",
+	"oc/fuzzUsr/fuzzPwd/fuzzOrg",
+	"os/fuzzUsr/fuzzPwd/fuzzOrg/fuzzUsr2/admin",
+	"tx/" + hex.EncodeToString([]byte("timeStamp/writing/fuzzUsr/fuzzID/fuzzID/fuzzPass")),
+	"read/" + hex.EncodeToString([]byte("timeStamp/reading/fuzzUsr")),
+}
+
+//perform the operation of the url upon the tree, broadcasting the txs it produces
+func fuzzPerformOperation(app *UIApp, ptw tre.PwkTreeWriter, url string) string {
+
+	var slots [3]string
+	var tx2SpoofBroadcast [3]*string
+	for i := range slots {
+		tx2SpoofBroadcast[i] = &slots[i]
+	}
+
+	page := getUIoutput(app.newRequest().performOperation(url, "127.0.0.1", tx2SpoofBroadcast))
+	output := string(page.Bytes())
+	page.Wipe()
+
+	for _, tx := range slots {
+		if len(tx) > 0 {
+			tmsp.TestspoofBroadcast([]byte(tx), ptw)
+		}
+	}
+	return output
+}
+
+//no url must panic the UI, whether parsed as a standard or end-to-end request, nor
+//  the tmsp application broadcast the txs it produces
+func FuzzPerformOperation(f *testing.F) {
+
+	for _, url := range fuzzSeedURLs {
+		f.Add(url)
+	}
+
+	f.Fuzz(func(t *testing.T, url string) {
+
+		ptw, ptr := tre.NewMemTreePair()
+		app := &UIApp{
+			ptr:      ptr,
+			portUI:   "8080",
+			breached: audit.BreachedList{},
+			sessions: newSessionStore(DefaultSessionIdleTimeout, DefaultSessionMaxLifetime),
+			suite:    cry.LegacyCipherSuite,
+			testing:  true,
+		}
+		for _, setup := range []string{
+			"n/fuzzUsr/fuzzPwd",
+			"w/fuzzUsr/fuzzPwd/fuzzID/fuzzPass",
+			"vc/fuzzUsr/fuzzPwd/fuzzVault",
+		} {
+			fuzzPerformOperation(app, ptw, setup)
+		}
+
+		fuzzPerformOperation(app, ptw, url)
+
+		var relayed string
+		var tx2SpoofBroadcast [3]*string
+		tx2SpoofBroadcast[0] = &relayed
+		app.newRequest().performEndToEnd(url, "127.0.0.1", tx2SpoofBroadcast)
+		if len(relayed) > 0 {
+			tmsp.TestspoofBroadcast([]byte(relayed), ptw)
+		}
+	})
 }